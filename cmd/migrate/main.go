@@ -13,6 +13,7 @@ import (
 	_ "github.com/go-sql-driver/mysql" // MySQL driver
 	"github.com/sirupsen/logrus"
 	"gitlab.smartbet.am/golang/notification/ent"
+	notificationdb "gitlab.smartbet.am/golang/notification/internal/db"
 )
 
 func main() {
@@ -64,10 +65,16 @@ func main() {
 	client = client.Debug()
 
 	// Run migrations
-	if err := client.Schema.Create(context.Background()); err != nil {
-		log.Fatalf("Failed to create schema: %v", err)
+	if err := notificationdb.Migrate(context.Background(), db, client, logger); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	backfilled, err := notificationdb.BackfillStatusTimestamps(context.Background(), db)
+	if err != nil {
+		log.Fatalf("Failed to backfill notification status timestamps: %v", err)
+	}
+	logger.WithField("count", backfilled).Info("Backfilled notification status timestamps")
+
 	logger.Info("Migration completed successfully!")
 }
 
@@ -316,8 +316,8 @@ func createNotificationsForTenant(ctx context.Context, client *ent.Client, tenan
 		scheduled  bool
 		addresses  []string // Different addresses for email vs SMS
 	}{
-		{10, notification.TypeEMAIL, notification.StatusCOMPLETED, "Welcome Bonus", "Welcome to our platform! Notification", "bonus@goodwin.am", false, []string{"user%d@example.com"}},
-		{5, notification.TypeSMS, notification.StatusCOMPLETED, "", "Your verification code:", "", false, []string{"+37499%07d"}},
+		{10, notification.TypeEMAIL, notification.StatusSENT, "Welcome Bonus", "Welcome to our platform! Notification", "bonus@goodwin.am", false, []string{"user%d@example.com"}},
+		{5, notification.TypeSMS, notification.StatusSENT, "", "Your verification code:", "", false, []string{"+37499%07d"}},
 		{3, notification.TypeEMAIL, notification.StatusFAILED, "Account Update", "Important account information", "system@goodwin.am", false, []string{"user%d@example.com"}},
 		{2, notification.TypeSMS, notification.StatusPENDING, "", "Special offer! Don't miss out!", "", false, []string{"+37499%07d"}},
 		{2, notification.TypeEMAIL, notification.StatusPENDING, "Weekend Promotion", "Don't miss our weekend special!", "promo@goodwin.am", true, []string{"user%d@example.com"}},
@@ -354,9 +354,13 @@ func createNotificationsForTenant(ctx context.Context, client *ent.Client, tenan
 				futureTime := now.Add(24 * time.Hour).Unix()
 				create.SetScheduleTs(futureTime)
 			}
+			if notif.status == notification.StatusSENT {
+				create.SetSentAt(now)
+			}
 			if notif.status == notification.StatusFAILED {
 				create.SetErrorMessage("Delivery failed - test error message")
 				create.SetRetryCount(2)
+				create.SetFailedAt(now)
 			}
 
 			// Add metadata with message type
@@ -13,9 +13,11 @@ import (
 	"gitlab.smartbet.am/golang/notification/internal/kafka"
 	"gitlab.smartbet.am/golang/notification/internal/logger"
 	"gitlab.smartbet.am/golang/notification/internal/providers"
+	"gitlab.smartbet.am/golang/notification/internal/realtime"
 	"gitlab.smartbet.am/golang/notification/internal/repository"
 	"gitlab.smartbet.am/golang/notification/internal/server"
 	"gitlab.smartbet.am/golang/notification/internal/services"
+	"gitlab.smartbet.am/golang/notification/internal/unsubscribe"
 	"gitlab.smartbet.am/golang/notification/internal/workers"
 
 	// Import generated docs for Swagger (generated during build)
@@ -26,11 +28,11 @@ import (
 
 // @title Notification Engine API
 // @version 1.0
-// @description A high-performance, multi-tenant notification engine supporting Email, SMS, and Push notifications with per-partner configurations and batch processing capabilities.
+// @description A high-performance, multi-tenant notification engine supporting Email, SMS, Push, Telegram, Viber, WhatsApp and in-app notifications with per-partner configurations and batch processing capabilities.
 // @description
 // @description ## Features
 // @description - **Multi-tenant Architecture**: Per-partner configurations with isolated data
-// @description - **Multiple Notification Types**: Email, SMS, and Push notifications
+// @description - **Multiple Notification Types**: Email, SMS, Push, Telegram, Viber, WhatsApp and in-app inbox notifications
 // @description - **Provider Flexibility**: Support for multiple providers per channel
 // @description - **Dual API Support**: HTTP REST API and Kafka messaging
 // @description - **Batch Processing**: Efficient batch sending with configurable thresholds
@@ -113,6 +115,30 @@ func main() {
 		fx.Provide(func(client *ent.Client, logger *logrus.Logger) *repository.NotificationRepository {
 			return repository.NewNotificationRepository(client, logger)
 		}),
+		fx.Provide(func(client *ent.Client, logger *logrus.Logger) *repository.DeliveryAttemptRepository {
+			return repository.NewDeliveryAttemptRepository(client, logger)
+		}),
+		fx.Provide(func(client *ent.Client, logger *logrus.Logger) *repository.SuppressionRepository {
+			return repository.NewSuppressionRepository(client, logger)
+		}),
+		fx.Provide(func(client *ent.Client, logger *logrus.Logger) *repository.PreferenceRepository {
+			return repository.NewPreferenceRepository(client, logger)
+		}),
+		fx.Provide(func(client *ent.Client, logger *logrus.Logger) *repository.ContactRepository {
+			return repository.NewContactRepository(client, logger)
+		}),
+		fx.Provide(func(client *ent.Client, logger *logrus.Logger) *repository.DeviceRepository {
+			return repository.NewDeviceRepository(client, logger)
+		}),
+		fx.Provide(func(client *ent.Client, logger *logrus.Logger) *repository.InboxRepository {
+			return repository.NewInboxRepository(client, logger)
+		}),
+		fx.Provide(func(client *ent.Client, logger *logrus.Logger) *repository.WhatsAppSessionRepository {
+			return repository.NewWhatsAppSessionRepository(client, logger)
+		}),
+		fx.Provide(func(client *ent.Client, logger *logrus.Logger) *repository.SelfExclusionRepository {
+			return repository.NewSelfExclusionRepository(client, logger)
+		}),
 		fx.Provide(func(client *ent.Client, logger *logrus.Logger) *repository.PartnerConfigRepository {
 			return repository.NewPartnerConfigRepository(client, logger)
 		}),
@@ -121,22 +147,78 @@ func main() {
 		fx.Provide(func() *providers.ProviderRegistry {
 			return providers.NewProviderRegistry()
 		}),
-		fx.Provide(func(registry *providers.ProviderRegistry, configRepo *repository.PartnerConfigRepository, logger *logrus.Logger) *providers.EmailProviderManager {
-			return providers.NewEmailProviderManager(registry, configRepo, logger)
+		fx.Provide(func(registry *providers.ProviderRegistry, configRepo *repository.PartnerConfigRepository, unsubscribeLinks *unsubscribe.Links, logger *logrus.Logger) *providers.EmailProviderManager {
+			return providers.NewEmailProviderManager(registry, configRepo, unsubscribeLinks, logger)
+		}),
+		fx.Provide(func(registry *providers.ProviderRegistry, configRepo *repository.PartnerConfigRepository, cfg *config.Config, logger *logrus.Logger) *providers.SMSProviderManager {
+			return providers.NewSMSProviderManager(registry, configRepo, cfg, logger)
+		}),
+		fx.Provide(func(registry *providers.ProviderRegistry, configRepo *repository.PartnerConfigRepository, logger *logrus.Logger) *providers.PushProviderManager {
+			return providers.NewPushProviderManager(registry, configRepo, logger)
 		}),
-		fx.Provide(func(registry *providers.ProviderRegistry, configRepo *repository.PartnerConfigRepository, logger *logrus.Logger) *providers.SMSProviderManager {
-			return providers.NewSMSProviderManager(registry, configRepo, logger)
+		fx.Provide(func(registry *providers.ProviderRegistry, configRepo *repository.PartnerConfigRepository, logger *logrus.Logger) *providers.TelegramProviderManager {
+			return providers.NewTelegramProviderManager(registry, configRepo, logger)
+		}),
+		fx.Provide(func(registry *providers.ProviderRegistry, configRepo *repository.PartnerConfigRepository, logger *logrus.Logger) *providers.ViberProviderManager {
+			return providers.NewViberProviderManager(registry, configRepo, logger)
+		}),
+		fx.Provide(func(registry *providers.ProviderRegistry, configRepo *repository.PartnerConfigRepository, sessions *repository.WhatsAppSessionRepository, logger *logrus.Logger) *providers.WhatsAppProviderManager {
+			return providers.NewWhatsAppProviderManager(registry, configRepo, sessions, logger)
 		}),
 
 		// Services
+		fx.Provide(func(cfg *config.Config) *unsubscribe.Links {
+			return unsubscribe.NewLinks(cfg)
+		}),
+		fx.Provide(func(logger *logrus.Logger) *realtime.Hub {
+			return realtime.NewHub(logger)
+		}),
+		fx.Provide(func(cfg *config.Config) *realtime.Tokens {
+			return realtime.NewTokens(cfg)
+		}),
+		fx.Provide(func(cfg *config.Config, publisher *kafka.Publisher, hub *realtime.Hub, logger *logrus.Logger) *realtime.Broadcaster {
+			return realtime.NewBroadcaster(cfg, publisher, hub, logger)
+		}),
+		fx.Provide(func(
+			notifRepo *repository.NotificationRepository,
+			contactRepo *repository.ContactRepository,
+			logger *logrus.Logger,
+		) *services.CascadeService {
+			return services.NewCascadeService(notifRepo, contactRepo, logger)
+		}),
 		fx.Provide(func(
+			inboxRepo *repository.InboxRepository,
 			notifRepo *repository.NotificationRepository,
+			cascades *services.CascadeService,
+			logger *logrus.Logger,
+		) *services.InboxService {
+			return services.NewInboxService(inboxRepo, notifRepo, cascades, logger)
+		}),
+		fx.Provide(func(notifRepo *repository.NotificationRepository, logger *logrus.Logger) *services.DigestService {
+			return services.NewDigestService(notifRepo, logger)
+		}),
+		fx.Provide(func(
+			notifRepo *repository.NotificationRepository,
+			attemptRepo *repository.DeliveryAttemptRepository,
 			configRepo *repository.PartnerConfigRepository,
+			suppressionRepo *repository.SuppressionRepository,
+			preferenceRepo *repository.PreferenceRepository,
+			selfExclusionRepo *repository.SelfExclusionRepository,
+			contactRepo *repository.ContactRepository,
+			deviceRepo *repository.DeviceRepository,
+			cascades *services.CascadeService,
+			digests *services.DigestService,
+			broadcaster *realtime.Broadcaster,
+			unsubscribeLinks *unsubscribe.Links,
 			emailManager *providers.EmailProviderManager,
 			smsManager *providers.SMSProviderManager,
+			pushManager *providers.PushProviderManager,
+			telegramManager *providers.TelegramProviderManager,
+			viberManager *providers.ViberProviderManager,
+			whatsappManager *providers.WhatsAppProviderManager,
 			logger *logrus.Logger,
 		) *services.NotificationService {
-			return services.NewNotificationService(notifRepo, configRepo, emailManager, smsManager, logger)
+			return services.NewNotificationService(notifRepo, attemptRepo, configRepo, suppressionRepo, preferenceRepo, selfExclusionRepo, contactRepo, deviceRepo, cascades, digests, broadcaster, unsubscribeLinks, emailManager, smsManager, pushManager, telegramManager, viberManager, whatsappManager, logger)
 		}),
 		fx.Provide(func(
 			notificationSvc *services.NotificationService,
@@ -153,20 +235,66 @@ func main() {
 			return services.NewBufferedNotificationService(notificationSvc, configRepo, logger)
 		}),
 
+		fx.Provide(func(
+			notifRepo *repository.NotificationRepository,
+			attemptRepo *repository.DeliveryAttemptRepository,
+			suppressionRepo *repository.SuppressionRepository,
+			cascades *services.CascadeService,
+			logger *logrus.Logger,
+		) *services.DeliveryReceiptService {
+			return services.NewDeliveryReceiptService(notifRepo, attemptRepo, suppressionRepo, cascades, logger)
+		}),
+
 		// Handlers
 		fx.Provide(func(
 			publisher *kafka.Publisher,
 			notifRepo *repository.NotificationRepository,
+			attemptRepo *repository.DeliveryAttemptRepository,
+			contactRepo *repository.ContactRepository,
+			configRepo *repository.PartnerConfigRepository,
 			logger *logrus.Logger,
 		) *handlers.NotificationHandler {
-			return handlers.NewNotificationHandler(publisher, notifRepo, logger)
+			return handlers.NewNotificationHandler(publisher, notifRepo, attemptRepo, contactRepo, configRepo, logger)
+		}),
+		fx.Provide(func(configRepo *repository.PartnerConfigRepository, emailManager *providers.EmailProviderManager, logger *logrus.Logger) *handlers.ConfigHandler {
+			return handlers.NewConfigHandler(configRepo, emailManager, logger)
+		}),
+		fx.Provide(func(suppressionRepo *repository.SuppressionRepository, logger *logrus.Logger) *handlers.SuppressionHandler {
+			return handlers.NewSuppressionHandler(suppressionRepo, logger)
+		}),
+		fx.Provide(func(preferenceRepo *repository.PreferenceRepository, unsubscribeLinks *unsubscribe.Links, logger *logrus.Logger) *handlers.PreferenceHandler {
+			return handlers.NewPreferenceHandler(preferenceRepo, unsubscribeLinks, logger)
+		}),
+		fx.Provide(func(contactRepo *repository.ContactRepository, logger *logrus.Logger) *handlers.ContactHandler {
+			return handlers.NewContactHandler(contactRepo, logger)
 		}),
-		fx.Provide(func(configRepo *repository.PartnerConfigRepository, logger *logrus.Logger) *handlers.ConfigHandler {
-			return handlers.NewConfigHandler(configRepo, logger)
+		fx.Provide(func(deviceRepo *repository.DeviceRepository, logger *logrus.Logger) *handlers.DeviceHandler {
+			return handlers.NewDeviceHandler(deviceRepo, logger)
+		}),
+		fx.Provide(func(inbox *services.InboxService, logger *logrus.Logger) *handlers.InboxHandler {
+			return handlers.NewInboxHandler(inbox, logger)
+		}),
+		fx.Provide(func(hub *realtime.Hub, tokens *realtime.Tokens, logger *logrus.Logger) *handlers.StreamHandler {
+			return handlers.NewStreamHandler(hub, tokens, logger)
+		}),
+		fx.Provide(func(selfExclusionRepo *repository.SelfExclusionRepository, logger *logrus.Logger) *handlers.SelfExclusionHandler {
+			return handlers.NewSelfExclusionHandler(selfExclusionRepo, logger)
+		}),
+		fx.Provide(func(preferenceRepo *repository.PreferenceRepository, unsubscribeLinks *unsubscribe.Links, logger *logrus.Logger) *handlers.UnsubscribeHandler {
+			return handlers.NewUnsubscribeHandler(preferenceRepo, unsubscribeLinks, logger)
 		}),
 		fx.Provide(func(logger *logrus.Logger) *handlers.HealthHandler {
 			return handlers.NewHealthHandler(logger)
 		}),
+		fx.Provide(func(
+			cfg *config.Config,
+			configRepo *repository.PartnerConfigRepository,
+			receiptSvc *services.DeliveryReceiptService,
+			sessions *repository.WhatsAppSessionRepository,
+			logger *logrus.Logger,
+		) *handlers.WebhookHandler {
+			return handlers.NewWebhookHandler(cfg, configRepo, receiptSvc, sessions, logger)
+		}),
 
 		// Workers
 		fx.Provide(func(
@@ -184,6 +312,43 @@ func main() {
 		) *workers.SchedulerWorker {
 			return workers.NewSchedulerWorker(notifRepo, notificationSvc, logger)
 		}),
+		fx.Provide(func(
+			notifRepo *repository.NotificationRepository,
+			notificationSvc *services.NotificationService,
+			logger *logrus.Logger,
+		) *workers.DigestWorker {
+			return workers.NewDigestWorker(notifRepo, notificationSvc, logger)
+		}),
+
+		fx.Provide(func(
+			attemptRepo *repository.DeliveryAttemptRepository,
+			configRepo *repository.PartnerConfigRepository,
+			receiptSvc *services.DeliveryReceiptService,
+			logger *logrus.Logger,
+		) *workers.DLRPollWorker {
+			return workers.NewDLRPollWorker(attemptRepo, configRepo, receiptSvc, logger)
+		}),
+
+		fx.Provide(func(
+			configRepo *repository.PartnerConfigRepository,
+			receiptSvc *services.DeliveryReceiptService,
+			logger *logrus.Logger,
+		) *workers.BouncePollWorker {
+			return workers.NewBouncePollWorker(configRepo, receiptSvc, logger)
+		}),
+
+		fx.Provide(func(
+			cfg *config.Config,
+			subscriber *kafka.Subscriber,
+			selfExclusionRepo *repository.SelfExclusionRepository,
+			logger *logrus.Logger,
+		) *workers.SelfExclusionWorker {
+			return workers.NewSelfExclusionWorker(cfg, subscriber, selfExclusionRepo, logger)
+		}),
+
+		fx.Provide(func(cfg *config.Config, hub *realtime.Hub, logger *logrus.Logger) *workers.RealtimeWorker {
+			return workers.NewRealtimeWorker(cfg, hub, logger)
+		}),
 
 		// Server
 		fx.Provide(func(
@@ -191,9 +356,18 @@ func main() {
 			notifHandler *handlers.NotificationHandler,
 			configHandler *handlers.ConfigHandler,
 			healthHandler *handlers.HealthHandler,
+			webhookHandler *handlers.WebhookHandler,
+			suppressionHandler *handlers.SuppressionHandler,
+			preferenceHandler *handlers.PreferenceHandler,
+			selfExclusionHandler *handlers.SelfExclusionHandler,
+			contactHandler *handlers.ContactHandler,
+			deviceHandler *handlers.DeviceHandler,
+			inboxHandler *handlers.InboxHandler,
+			streamHandler *handlers.StreamHandler,
+			unsubscribeHandler *handlers.UnsubscribeHandler,
 			logger *logrus.Logger,
 		) *server.FiberServer {
-			return server.NewFiberServer(cfg, notifHandler, configHandler, healthHandler, logger)
+			return server.NewFiberServer(cfg, notifHandler, configHandler, healthHandler, webhookHandler, suppressionHandler, preferenceHandler, selfExclusionHandler, contactHandler, deviceHandler, inboxHandler, streamHandler, unsubscribeHandler, logger)
 		}),
 
 		// Lifecycle
@@ -202,6 +376,13 @@ func main() {
 			fiberServer *server.FiberServer,
 			notificationWorker *workers.NotificationWorker,
 			schedulerWorker *workers.SchedulerWorker,
+			digestWorker *workers.DigestWorker,
+			dlrPollWorker *workers.DLRPollWorker,
+			bouncePollWorker *workers.BouncePollWorker,
+			selfExclusionWorker *workers.SelfExclusionWorker,
+			realtimeWorker *workers.RealtimeWorker,
+			hub *realtime.Hub,
+			emailManager *providers.EmailProviderManager,
 			logger *logrus.Logger,
 		) {
 			lifecycle.Append(fx.Hook{
@@ -216,6 +397,21 @@ func main() {
 					if err := schedulerWorker.Start(workerCtx); err != nil {
 						return err
 					}
+					if err := digestWorker.Start(workerCtx); err != nil {
+						return err
+					}
+					if err := dlrPollWorker.Start(workerCtx); err != nil {
+						return err
+					}
+					if err := bouncePollWorker.Start(workerCtx); err != nil {
+						return err
+					}
+					if err := selfExclusionWorker.Start(workerCtx); err != nil {
+						return err
+					}
+					if err := realtimeWorker.Start(workerCtx); err != nil {
+						return err
+					}
 
 					// Start HTTP server in goroutine
 					go func() {
@@ -230,6 +426,9 @@ func main() {
 				OnStop: func(ctx context.Context) error {
 					logger.Info("Stopping notification engine application")
 
+					// End open streams so the server doesn't wait on them
+					hub.Shutdown()
+
 					// Shutdown server
 					if err := fiberServer.Shutdown(ctx); err != nil {
 						logger.WithError(err).Error("Error shutting down server")
@@ -238,6 +437,14 @@ func main() {
 					// Stop workers
 					notificationWorker.Stop()
 					schedulerWorker.Stop()
+					digestWorker.Stop()
+					dlrPollWorker.Stop()
+					bouncePollWorker.Stop()
+					selfExclusionWorker.Stop()
+					realtimeWorker.Stop()
+
+					// Close pooled SMTP connections
+					emailManager.Close()
 
 					logger.Info("Notification engine stopped")
 					return nil
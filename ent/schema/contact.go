@@ -0,0 +1,51 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+// Contact holds the schema definition for the Contact entity.
+// A contact maps a tenant's user ID to the addresses notifications can target it with,
+// push tokens are kept in the device registry.
+type Contact struct {
+	ent.Schema
+}
+
+// Fields of the Contact.
+func (Contact) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("tenant_id"),
+		field.String("user_id").MaxLen(128).NotEmpty(),
+		field.String("email").Optional(),
+		field.String("phone").MaxLen(32).Optional(),
+		// Chat of the user with the tenant's Telegram bot, known once the user started the bot
+		field.String("telegram_chat_id").MaxLen(64).Optional(),
+		field.String("locale").MaxLen(16).Optional(),
+		// IANA time zone name, e.g. Asia/Yerevan
+		field.String("time_zone").MaxLen(64).Optional(),
+	}
+}
+
+func (Contact) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		mixin.Time{},
+	}
+}
+
+// Edges of the Contact.
+func (Contact) Edges() []ent.Edge {
+	return nil
+}
+
+func (Contact) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("tenant_id", "user_id").Unique(),
+		// Self-exclusions of a user cover all of its addresses, looked up by address when sending
+		index.Fields("tenant_id", "email"),
+		index.Fields("tenant_id", "phone"),
+		index.Fields("tenant_id", "telegram_chat_id"),
+	}
+}
@@ -0,0 +1,66 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+// DeliveryAttempt holds the schema definition for the DeliveryAttempt entity.
+// Every hand-over of a notification to a provider is recorded as one attempt.
+type DeliveryAttempt struct {
+	ent.Schema
+}
+
+// Fields of the DeliveryAttempt.
+func (DeliveryAttempt) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("notification_id"),
+		field.Int("attempt").Default(1),
+		field.String("provider_name").Optional(),
+		field.String("provider_type"),
+		field.String("provider_message_id").Optional(),
+		field.Text("request_summary").Optional(),
+		field.Text("response_summary").Optional(),
+		field.Int64("latency_ms").Default(0),
+		field.Enum("outcome").Values("SENT", "FAILED"),
+		// Last status reported by the provider after the hand-over, e.g. a delivery receipt
+		field.String("provider_status").Optional(),
+		field.Time("provider_status_time").Optional().Nillable(),
+		// Error code reported by the provider or mobile operator with a failed delivery
+		field.String("operator_error_code").Optional(),
+		field.String("error_class").Optional(),
+		field.Text("error_message").Optional(),
+		// How often and when the provider was last asked for a delivery report of the attempt
+		field.Int("receipt_polls").Default(0),
+		field.Time("receipt_polled_at").Optional().Nillable(),
+	}
+}
+
+func (DeliveryAttempt) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		mixin.Time{},
+	}
+}
+
+// Edges of the DeliveryAttempt.
+func (DeliveryAttempt) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("notification", Notification.Type).
+			Ref("delivery_attempts").
+			Field("notification_id").
+			Unique().
+			Required(),
+	}
+}
+
+func (DeliveryAttempt) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("notification_id", "attempt").Unique(),
+		index.Fields("provider_type", "provider_message_id"),
+		index.Fields("provider_type", "outcome", "provider_status"),
+		index.Fields("provider_type", "outcome", "create_time"),
+	}
+}
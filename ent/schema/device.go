@@ -0,0 +1,50 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+// Device holds the schema definition for the Device entity.
+// A device is a push token of a tenant's user; push notifications targeting the user by ID
+// go to all of its active devices.
+type Device struct {
+	ent.Schema
+}
+
+// Fields of the Device.
+func (Device) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("tenant_id"),
+		field.String("user_id").MaxLen(128).NotEmpty(),
+		// Tokens migrated from the push_tokens of contacts have an unknown platform
+		field.Enum("platform").Values("ios", "android", "web", "unknown"),
+		field.String("token").MaxLen(512).NotEmpty(),
+		field.String("app_version").MaxLen(32).Optional(),
+		field.Time("last_seen_at"),
+		// Devices are deactivated when unregistered or when a provider reports the token invalid
+		field.Bool("active").Default(true),
+		field.Time("deactivated_at").Optional().Nillable(),
+		field.String("deactivation_reason").Optional(),
+	}
+}
+
+func (Device) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		mixin.Time{},
+	}
+}
+
+// Edges of the Device.
+func (Device) Edges() []ent.Edge {
+	return nil
+}
+
+func (Device) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("tenant_id", "token").Unique(),
+		index.Fields("tenant_id", "user_id", "active"),
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"entgo.io/ent"
 	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
 	"entgo.io/ent/schema/index"
 	"entgo.io/ent/schema/mixin"
@@ -17,19 +18,39 @@ type Notification struct {
 	ent.Schema
 }
 
+// Attachment is a file sent with an email. The file is provided either inline as base64
+// content or referenced by URL or object key and fetched when the email is sent.
 type Attachment struct {
 	Filename    string `json:"filename"`
-	Content     string `json:"content"`
+	Content     string `json:"content,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Bucket      string `json:"bucket,omitempty"`
+	ObjectKey   string `json:"object_key,omitempty"`
 	Disposition string `json:"disposition"`
 	Type        string `json:"type"`
+	ContentID   string `json:"content_id,omitempty"`
 }
 
 type NotificationMeta struct {
-	Service    string                 `json:"service,omitempty"`
-	TemplateID string                 `json:"template_id,omitempty"`
-	Params     map[string]interface{} `json:"params,omitempty"`
-	Attachment *Attachment            `json:"attachment,omitempty"`
-	Data       json.RawMessage        `json:"data,omitempty"`
+	Service     string                 `json:"service,omitempty"`
+	TemplateID  string                 `json:"template_id,omitempty"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+	Attachment  *Attachment            `json:"attachment,omitempty"`
+	Attachments []*Attachment          `json:"attachments,omitempty"`
+	Data        json.RawMessage        `json:"data,omitempty"`
+	TextBody    string                 `json:"text_body,omitempty"`
+	Headers     map[string]string      `json:"headers,omitempty"`
+}
+
+// GetAttachments returns all attachments, including the legacy single attachment
+func (m *NotificationMeta) GetAttachments() []*Attachment {
+	if m == nil {
+		return nil
+	}
+	if m.Attachment == nil {
+		return m.Attachments
+	}
+	return append([]*Attachment{m.Attachment}, m.Attachments...)
 }
 
 // Fields of the Notification.
@@ -45,12 +66,45 @@ func (Notification) Fields() []ent.Field {
 		field.Text("address").GoType(types.Address("")),
 		field.String("request_id").Unique(),
 		field.Int64("schedule_ts").Optional().Nillable(),
-		field.Enum("type").Values("SMS", "EMAIL", "PUSH"),
-		field.Enum("status").Values("ACTIVE", "COMPLETED", "CANCEL", "PENDING", "FAILED").Default("PENDING"),
+		// INAPP notifications are addressed to a user ID and kept in the user's inbox,
+		// TELEGRAM ones to the chat ID of the user with the tenant's bot, VIBER and WHATSAPP ones to a phone number
+		field.Enum("type").Values("SMS", "EMAIL", "PUSH", "INAPP", "TELEGRAM", "VIBER", "WHATSAPP"),
+		// Allowed transitions are defined by models.NotificationStatus and enforced by the repository
+		field.Enum("status").Values("PENDING", "ACTIVE", "SENT", "DELIVERED", "BOUNCED", "UNDELIVERED", "OPENED", "CLICKED", "FAILED", "CANCEL", "SUPPRESSED", "CAPPED", "DUPLICATE", "HELD", "DIGESTED").Default("PENDING"),
 		field.JSON("meta", &NotificationMeta{}).Optional(),
 		field.String("error_message").Nillable().Optional(),
 		field.String("batch_id").Optional(),
 		field.Int("retry_count").Default(0),
+		field.String("message_type").Optional(),
+		field.JSON("data", map[string]interface{}{}).Optional(),
+		// Notifications of a channel cascade share the cascade ID and are sent one step after the other
+		field.String("cascade_id").Optional(),
+		field.Int("cascade_step").Optional(),
+		// Hash of the recipient, channel and content, used to drop duplicates within the tenant's dedup window
+		field.String("content_hash").MaxLen(64).Optional(),
+		// Notifications of a digest policy are held under a key per recipient and channel until
+		// the digest is due, the digest notification gets the same key
+		field.String("digest_key").MaxLen(64).Optional(),
+		field.Time("digest_due_at").Optional().Nillable(),
+		// Request ID of the digest a held notification was sent in
+		field.String("digest_request_id").Optional(),
+		// Time the notification reached each status after being queued
+		field.Time("sent_at").Optional().Nillable(),
+		field.Time("delivered_at").Optional().Nillable(),
+		field.Time("bounced_at").Optional().Nillable(),
+		field.Time("undelivered_at").Optional().Nillable(),
+		field.Time("opened_at").Optional().Nillable(),
+		field.Time("clicked_at").Optional().Nillable(),
+		field.Time("failed_at").Optional().Nillable(),
+		field.Time("cancelled_at").Optional().Nillable(),
+		field.Time("suppressed_at").Optional().Nillable(),
+		field.Time("capped_at").Optional().Nillable(),
+		field.Time("duplicated_at").Optional().Nillable(),
+		field.Time("held_at").Optional().Nillable(),
+		field.Time("digested_at").Optional().Nillable(),
+		// Inbox state of INAPP notifications, independent of the delivery status
+		field.Time("read_at").Optional().Nillable(),
+		field.Time("archived_at").Optional().Nillable(),
 	}
 }
 
@@ -62,7 +116,9 @@ func (Notification) Mixin() []ent.Mixin {
 
 // Edges of the Notification.
 func (Notification) Edges() []ent.Edge {
-	return nil
+	return []ent.Edge{
+		edge.To("delivery_attempts", DeliveryAttempt.Type),
+	}
 }
 
 func (Notification) Indexes() []ent.Index {
@@ -72,5 +128,13 @@ func (Notification) Indexes() []ent.Index {
 		index.Fields("tenant_id", "status"),
 		index.Fields("batch_id"),
 		index.Fields("type", "status"),
+		index.Fields("tenant_id", "message_type", "create_time"),
+		index.Fields("cascade_id", "cascade_step"),
+		index.Fields("tenant_id", "type", "message_type", "sent_at"),
+		index.Fields("tenant_id", "content_hash", "create_time"),
+		index.Fields("tenant_id", "digest_key", "status"),
+		index.Fields("status", "digest_due_at"),
+		index.Fields("digest_request_id"),
+		index.Fields("tenant_id", "type", "address").Annotations(entsql.PrefixColumn("address", 128)),
 	}
 }
@@ -30,6 +30,28 @@ type RateLimit struct {
 	Strategy string `json:"strategy"`
 }
 
+// FrequencyCap limits how many notifications of a message type a recipient gets on a channel
+// within a window, e.g. 2 promo SMS per 24h
+type FrequencyCap struct {
+	Channel     string `json:"channel"`
+	MessageType string `json:"message_type"`
+	Limit       int    `json:"limit"`
+	Window      string `json:"window"`
+}
+
+// DigestPolicy holds the notifications of a message type, optionally only those of a channel
+// or with a tag, and sends them to each recipient as one digest per channel once the window
+// passed or MaxItems were collected. Headline and Body are Go templates of the digest.
+type DigestPolicy struct {
+	MessageType string `json:"message_type"`
+	Channel     string `json:"channel,omitempty"`
+	Tag         string `json:"tag,omitempty"`
+	Window      string `json:"window"`
+	MaxItems    int    `json:"max_items"`
+	Headline    string `json:"headline,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
+
 // SMTPConfig represents SMTP configuration with multiple from addresses
 type SMTPConfig struct {
 	Host       string `json:"Host"`
@@ -55,6 +77,43 @@ type SMTPConfig struct {
 	MSGSystemFromName  string `json:"MSGSystemFromName"`
 	MSGPaymentFromName string `json:"MSGPaymentFromName"`
 	MSGSupportFromName string `json:"MSGSupportFromName"`
+
+	// Attachment size limits in bytes, defaults apply when not set
+	MaxAttachmentSize      int64 `json:"MaxAttachmentSize,omitempty"`
+	MaxTotalAttachmentSize int64 `json:"MaxTotalAttachmentSize,omitempty"`
+
+	// Storage for attachments referenced by object key, keys must start with AttachmentKeyPrefix
+	AttachmentBucket    string `json:"AttachmentBucket,omitempty"`
+	AttachmentRegion    string `json:"AttachmentRegion,omitempty"`
+	AttachmentKeyPrefix string `json:"AttachmentKeyPrefix,omitempty"`
+
+	// Hosts attachments can be downloaded from by URL, a leading "*." allows all subdomains.
+	// URL attachments are refused when empty.
+	AttachmentURLHosts []string `json:"AttachmentURLHosts,omitempty"`
+
+	// Connection reuse, defaults apply when not set
+	MaxConnections           int `json:"MaxConnections,omitempty"`
+	MaxMessagesPerConnection int `json:"MaxMessagesPerConnection,omitempty"`
+	ConnectionIdleTimeout    int `json:"ConnectionIdleTimeout,omitempty"` // seconds
+
+	// DKIM signing, messages are signed when domain, selector and key are set
+	DKIMDomain        string   `json:"DKIMDomain,omitempty"`
+	DKIMSelector      string   `json:"DKIMSelector,omitempty"`
+	DKIMPrivateKey    string   `json:"DKIMPrivateKey,omitempty"`
+	DKIMSignedHeaders []string `json:"DKIMSignedHeaders,omitempty"`
+
+	// Bounce handling: BounceAddress is used as envelope sender so DSNs reach the bounce
+	// mailbox, which is polled over POP3 when its host is set
+	BounceAddress         string `json:"BounceAddress,omitempty"`
+	BounceMailboxHost     string `json:"BounceMailboxHost,omitempty"`
+	BounceMailboxPort     string `json:"BounceMailboxPort,omitempty"`
+	BounceMailboxUsername string `json:"BounceMailboxUsername,omitempty"`
+	BounceMailboxPassword string `json:"BounceMailboxPassword,omitempty"`
+	BounceMailboxSecure   string `json:"BounceMailboxSecure,omitempty"`
+
+	// Event webhooks of the relay the SMTP server belongs to
+	SESTopicARN              string `json:"SESTopicARN,omitempty"`
+	SendGridWebhookPublicKey string `json:"SendGridWebhookPublicKey,omitempty"`
 }
 
 // GetFromAddress returns the appropriate from address based on message type
@@ -112,8 +171,15 @@ func (PartnerConfig) Fields() []ent.Field {
 		field.JSON("email_providers", []ProviderConfig{}).Optional(),
 		field.JSON("sms_providers", []ProviderConfig{}).Optional(),
 		field.JSON("push_providers", []ProviderConfig{}).Optional(),
+		field.JSON("telegram_providers", []ProviderConfig{}).Optional(),
+		field.JSON("viber_providers", []ProviderConfig{}).Optional(),
+		field.JSON("whatsapp_providers", []ProviderConfig{}).Optional(),
 		field.JSON("batch_config", &BatchConfig{}).Optional(),
 		field.JSON("rate_limits", map[string]RateLimit{}).Optional(),
+		field.JSON("frequency_caps", []FrequencyCap{}).Optional(),
+		// Windows per message type in which notifications with the same content to the same recipient are dropped
+		field.JSON("dedup_windows", map[string]string{}).Optional(),
+		field.JSON("digest_policies", []DigestPolicy{}).Optional(),
 
 		field.Bool("enabled").Default(true),
 	}
@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+// Preference holds the schema definition for the Preference entity.
+// A recipient, known by user ID, address or both, opts in or out of a message type on a channel.
+type Preference struct {
+	ent.Schema
+}
+
+// Fields of the Preference.
+func (Preference) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("tenant_id"),
+		field.String("user_id").MaxLen(128).Default(""),
+		// Addresses are stored lower-cased like suppressions
+		field.String("address").Default(""),
+		field.Enum("channel").Values("EMAIL", "SMS", "PUSH", "INAPP", "TELEGRAM", "VIBER", "WHATSAPP"),
+		field.String("message_type").MaxLen(32),
+		field.Bool("opted_in"),
+		// Where the last change came from, e.g. api or unsubscribe_link
+		field.String("source").Optional(),
+	}
+}
+
+func (Preference) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		mixin.Time{},
+	}
+}
+
+// Edges of the Preference.
+func (Preference) Edges() []ent.Edge {
+	return nil
+}
+
+func (Preference) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("tenant_id", "user_id", "address", "channel", "message_type").Unique(),
+		index.Fields("tenant_id", "address", "channel", "message_type"),
+	}
+}
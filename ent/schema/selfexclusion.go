@@ -0,0 +1,49 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+// SelfExclusion holds the schema definition for the SelfExclusion entity.
+// Promotional notifications aren't sent to the addresses of self-excluded players.
+type SelfExclusion struct {
+	ent.Schema
+}
+
+// Fields of the SelfExclusion.
+func (SelfExclusion) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("tenant_id"),
+		// The player ID at the operator, the same player may be excluded under several addresses
+		field.String("user_id").MaxLen(128).Default(""),
+		// Addresses are stored lower-cased like suppressions, an exclusion covers every channel
+		field.String("address"),
+		field.Time("starts_at"),
+		// Exclusions without an end are indefinite
+		field.Time("ends_at").Optional().Nillable(),
+		// Where the last change came from, e.g. api or kafka
+		field.String("source").Optional(),
+		field.Text("detail").Optional(),
+	}
+}
+
+func (SelfExclusion) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		mixin.Time{},
+	}
+}
+
+// Edges of the SelfExclusion.
+func (SelfExclusion) Edges() []ent.Edge {
+	return nil
+}
+
+func (SelfExclusion) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("tenant_id", "address").Unique(),
+		index.Fields("tenant_id", "user_id"),
+	}
+}
@@ -0,0 +1,43 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// SelfExclusionBlock holds the schema definition for the SelfExclusionBlock entity.
+// Every promotional notification withheld from a self-excluded player is recorded for
+// compliance audits. Entries are never updated.
+type SelfExclusionBlock struct {
+	ent.Schema
+}
+
+// Fields of the SelfExclusionBlock.
+func (SelfExclusionBlock) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("tenant_id").Immutable(),
+		field.Int("notification_id").Immutable(),
+		field.String("request_id").Immutable(),
+		field.Enum("channel").Values("EMAIL", "SMS", "PUSH", "INAPP", "TELEGRAM", "VIBER", "WHATSAPP").Immutable(),
+		field.String("address").Immutable(),
+		field.String("message_type").MaxLen(32).Immutable(),
+		field.Int("self_exclusion_id").Immutable(),
+		field.String("user_id").MaxLen(128).Default("").Immutable(),
+		field.Time("blocked_at").Default(time.Now).Immutable(),
+	}
+}
+
+// Edges of the SelfExclusionBlock.
+func (SelfExclusionBlock) Edges() []ent.Edge {
+	return nil
+}
+
+func (SelfExclusionBlock) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("tenant_id", "blocked_at"),
+		index.Fields("tenant_id", "address"),
+	}
+}
@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+// Suppression holds the schema definition for the Suppression entity.
+// Notifications aren't sent to suppressed addresses of a tenant.
+type Suppression struct {
+	ent.Schema
+}
+
+// Fields of the Suppression.
+func (Suppression) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("tenant_id"),
+		field.Enum("channel").Values("EMAIL", "SMS", "PUSH", "INAPP", "TELEGRAM", "VIBER", "WHATSAPP"),
+		// Addresses are stored lower-cased so lookups don't depend on the spelling of the request
+		field.String("address"),
+		field.Enum("reason").Values("hard_bounce", "complaint", "unsubscribe", "manual"),
+		// Where the suppression came from, e.g. dsn, ses, sendgrid or api
+		field.String("source").Optional(),
+		field.Text("detail").Optional(),
+		field.Int("notification_id").Optional().Nillable(),
+		field.Time("expires_at").Optional().Nillable(),
+	}
+}
+
+func (Suppression) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		mixin.Time{},
+	}
+}
+
+// Edges of the Suppression.
+func (Suppression) Edges() []ent.Edge {
+	return nil
+}
+
+func (Suppression) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("tenant_id", "channel", "address").Unique(),
+	}
+}
@@ -0,0 +1,42 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+// WhatsAppSession holds the schema definition for the WhatsAppSession entity.
+// A session records the last message a phone number sent to the tenant's WhatsApp number;
+// free-form messages can only be sent within 24 hours of it.
+type WhatsAppSession struct {
+	ent.Schema
+}
+
+// Fields of the WhatsAppSession.
+func (WhatsAppSession) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("tenant_id"),
+		// Phone number in the form WhatsApp reports it, digits without the leading +
+		field.String("phone").MaxLen(32).NotEmpty(),
+		field.Time("last_inbound_at"),
+	}
+}
+
+func (WhatsAppSession) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		mixin.Time{},
+	}
+}
+
+// Edges of the WhatsAppSession.
+func (WhatsAppSession) Edges() []ent.Edge {
+	return nil
+}
+
+func (WhatsAppSession) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("tenant_id", "phone").Unique(),
+	}
+}
@@ -35,7 +35,9 @@ const mockConfig = `{
 		"topics": {
 			"notifications": "notifications",
 			"events": "notification-events",
-			"dead_letter": "notifications-dlq"
+			"dead_letter": "notifications-dlq",
+			"self_exclusions": "self-exclusions",
+			"realtime": "notification-realtime"
 		}
 	},
 	"providers": {
@@ -68,6 +70,16 @@ const mockConfig = `{
 	"auth": {
 		"jwt_secret": "your-secret-key",
 		"kafka_api_key": "your-kafka-api-key"
+	},
+	"webhooks": {
+		"public_base_url": "http://localhost:8080"
+	},
+	"unsubscribe": {
+		"secret": "your-unsubscribe-secret"
+	},
+	"realtime": {
+		"secret": "your-realtime-secret",
+		"token_ttl": "1h"
 	}
 }`
 
@@ -80,6 +92,9 @@ type Config struct {
 	Swagger       SwaggerConfig       `json:"swagger"`
 	Logging       LoggingConfig       `json:"logging"`
 	Auth          AuthConfig          `json:"auth"`
+	Webhooks      WebhooksConfig      `json:"webhooks"`
+	Unsubscribe   UnsubscribeConfig   `json:"unsubscribe"`
+	Realtime      RealtimeConfig      `json:"realtime"`
 }
 
 type ServerConfig struct {
@@ -110,6 +125,10 @@ type TopicsConfig struct {
 	Notifications string `json:"notifications"`
 	Events        string `json:"events"`
 	DeadLetter    string `json:"dead_letter"`
+	// SelfExclusions carries player self-exclusions from the operator, empty disables the sync
+	SelfExclusions string `json:"self_exclusions"`
+	// Realtime fans in-app notifications out to every instance holding user streams, empty keeps them local
+	Realtime string `json:"realtime"`
 }
 
 type ProvidersConfig struct {
@@ -154,6 +173,28 @@ type AuthConfig struct {
 	KafkaAPIKey string `json:"kafka_api_key"`
 }
 
+// WebhooksConfig holds the settings for provider callbacks
+type WebhooksConfig struct {
+	// PublicBaseURL is the externally reachable address providers post callbacks to
+	PublicBaseURL string `json:"public_base_url"`
+}
+
+// UnsubscribeConfig holds the settings for signed unsubscribe links
+type UnsubscribeConfig struct {
+	// Secret signs unsubscribe tokens, links sent before it changes stop working
+	Secret string `json:"secret"`
+	// BaseURL is where the unsubscribe endpoint is reachable, defaults to the webhooks public base URL
+	BaseURL string `json:"base_url"`
+}
+
+// RealtimeConfig holds the settings for in-app notification streams
+type RealtimeConfig struct {
+	// Secret signs stream tokens, kept apart from the JWT secret so a stream token never grants API access
+	Secret string `json:"secret"`
+	// TokenTTL is how long a stream token can be used to open a stream
+	TokenTTL string `json:"token_ttl"`
+}
+
 // Helper methods to parse duration strings
 func (c *Config) GetServerReadTimeout() time.Duration {
 	if d, err := time.ParseDuration(c.Server.ReadTimeout); err == nil {
@@ -190,6 +231,33 @@ func (c *Config) GetBatchRetryBackoff() time.Duration {
 	return 5 * time.Second
 }
 
+func (c *Config) GetRealtimeTokenTTL() time.Duration {
+	if d, err := time.ParseDuration(c.Realtime.TokenTTL); err == nil && d > 0 {
+		return d
+	}
+	return time.Hour
+}
+
+// GetWebhookURL returns the public URL of a webhook path, empty when no public base URL is configured
+func (c *Config) GetWebhookURL(path string) string {
+	if c.Webhooks.PublicBaseURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(c.Webhooks.PublicBaseURL, "/") + path
+}
+
+// GetUnsubscribeURL returns the public URL of an unsubscribe token, empty when no base URL is configured
+func (c *Config) GetUnsubscribeURL(token string) string {
+	baseURL := c.Unsubscribe.BaseURL
+	if baseURL == "" {
+		baseURL = c.Webhooks.PublicBaseURL
+	}
+	if baseURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/u/" + token
+}
+
 func (c *Config) GetDSN() string {
 	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
 		c.Database.User,
@@ -247,6 +315,15 @@ func (c *Config) overrideWithEnv() {
 	if kafkaKey := os.Getenv("KAFKA_API_KEY"); kafkaKey != "" {
 		c.Auth.KafkaAPIKey = kafkaKey
 	}
+	if webhookURL := os.Getenv("WEBHOOK_PUBLIC_BASE_URL"); webhookURL != "" {
+		c.Webhooks.PublicBaseURL = webhookURL
+	}
+	if unsubscribeSecret := os.Getenv("UNSUBSCRIBE_SECRET"); unsubscribeSecret != "" {
+		c.Unsubscribe.Secret = unsubscribeSecret
+	}
+	if realtimeSecret := os.Getenv("REALTIME_SECRET"); realtimeSecret != "" {
+		c.Realtime.Secret = realtimeSecret
+	}
 }
 
 // Provider creates a new config instance using Uber FX lifecycle
@@ -47,9 +47,8 @@ func NewEntClient(db *sql.DB, logger *logrus.Logger) (*ent.Client, error) {
 	drv := entsql.OpenDB(dialect.MySQL, db)
 	client := ent.NewClient(ent.Driver(drv))
 
-	// Run migrations
-	if err := client.Schema.Create(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed to create schema: %w", err)
+	if err := Migrate(context.Background(), db, client, logger); err != nil {
+		return nil, err
 	}
 
 	Database = client
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+)
+
+// Migrate brings the database up to date: data the new schema can't hold as is is rewritten
+// first, the schema is created next and data moved into new tables last. The server and the
+// migrate command both run it, so they always migrate in the same order.
+func Migrate(ctx context.Context, db *sql.DB, client *ent.Client, logger *logrus.Logger) error {
+	// COMPLETED was replaced by SENT, rewrite it before the schema drops the value
+	migrated, err := MigrateLegacyStatuses(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to migrate notification statuses: %w", err)
+	}
+
+	// Attempt numbers became unique per notification, renumber before the index is created
+	renumbered, err := RenumberDeliveryAttempts(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to renumber delivery attempts: %w", err)
+	}
+
+	if err := client.Schema.Create(ctx); err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	// Push tokens of contacts moved to the device registry, which has to exist first
+	devices, err := MigrateContactPushTokens(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to migrate contact push tokens: %w", err)
+	}
+
+	if logger != nil {
+		logger.WithFields(logrus.Fields{
+			"statuses":    migrated,
+			"attempts":    renumbered,
+			"push_tokens": devices,
+		}).Info("Database migrated")
+	}
+	return nil
+}
+
+// MigrateLegacyStatuses moves notifications in the legacy COMPLETED status to SENT. The status
+// column is widened to hold both values first, the schema migration narrows it afterwards.
+// It returns the number of migrated notifications and does nothing once COMPLETED is gone.
+func MigrateLegacyStatuses(ctx context.Context, db *sql.DB) (int64, error) {
+	var columnType string
+	err := db.QueryRowContext(ctx, `SELECT COLUMN_TYPE FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND table_name = 'notifications' AND column_name = 'status'`).Scan(&columnType)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !strings.Contains(columnType, "'COMPLETED'") {
+		return 0, nil
+	}
+
+	if _, err := db.ExecContext(ctx, `ALTER TABLE notifications MODIFY COLUMN status
+		ENUM('ACTIVE','COMPLETED','CANCEL','PENDING','FAILED','SENT','DELIVERED','BOUNCED','UNDELIVERED','OPENED','CLICKED')
+		NOT NULL DEFAULT 'PENDING'`); err != nil {
+		return 0, fmt.Errorf("failed to widen status column: %w", err)
+	}
+
+	result, err := db.ExecContext(ctx, "UPDATE notifications SET status = 'SENT' WHERE status = 'COMPLETED'")
+	if err != nil {
+		return 0, fmt.Errorf("failed to rewrite COMPLETED notifications: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// RenumberDeliveryAttempts gives the attempts of every notification distinct numbers in the
+// order they were recorded, so the unique index on notification and attempt number can be
+// created. Attempts recorded concurrently could share a number before. It returns the number
+// of renumbered attempts and does nothing once the index exists.
+func RenumberDeliveryAttempts(ctx context.Context, db *sql.DB) (int64, error) {
+	var tables, indexes int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name = 'delivery_attempts'`).Scan(&tables); err != nil {
+		return 0, err
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name = 'delivery_attempts'
+		AND index_name = 'deliveryattempt_notification_id_attempt'`).Scan(&indexes); err != nil {
+		return 0, err
+	}
+	if indexes > 0 {
+		return 0, nil
+	}
+
+	result, err := db.ExecContext(ctx, `UPDATE delivery_attempts AS attempts
+		JOIN (SELECT id, ROW_NUMBER() OVER (PARTITION BY notification_id ORDER BY id) AS number
+			FROM delivery_attempts) AS numbered ON numbered.id = attempts.id
+		SET attempts.attempt = numbered.number
+		WHERE attempts.attempt <> numbered.number`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to renumber delivery attempts: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// BackfillStatusTimestamps sets the timestamp of the status notifications are in when it's
+// missing, using their last update as the best known time. It returns the number of updated rows.
+// Status changes set the timestamps since they were added, so this is a one-off run by the
+// migrate command; each statement scans the whole table.
+func BackfillStatusTimestamps(ctx context.Context, db *sql.DB) (int64, error) {
+	backfills := []struct {
+		column   string
+		statuses string
+	}{
+		{"sent_at", "'SENT','DELIVERED','BOUNCED','UNDELIVERED','OPENED','CLICKED'"},
+		{"delivered_at", "'DELIVERED'"},
+		{"undelivered_at", "'UNDELIVERED'"},
+		{"failed_at", "'FAILED'"},
+		{"cancelled_at", "'CANCEL'"},
+	}
+
+	var total int64
+	for _, backfill := range backfills {
+		result, err := db.ExecContext(ctx, fmt.Sprintf(
+			"UPDATE notifications SET %[1]s = update_time WHERE %[1]s IS NULL AND status IN (%[2]s)",
+			backfill.column, backfill.statuses))
+		if err != nil {
+			return total, fmt.Errorf("failed to backfill %s: %w", backfill.column, err)
+		}
+
+		updated, _ := result.RowsAffected()
+		total += updated
+	}
+
+	return total, nil
+}
+
+// MigrateContactPushTokens moves the push tokens contacts had before the device registry into
+// it as active devices of an unknown platform, then drops the column. Tokens already in the
+// registry keep their device. It returns the number of migrated tokens and does nothing once
+// the column is gone.
+func MigrateContactPushTokens(ctx context.Context, db *sql.DB) (int64, error) {
+	var columns int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND table_name = 'contacts' AND column_name = 'push_tokens'`).Scan(&columns); err != nil {
+		return 0, err
+	}
+	if columns == 0 {
+		return 0, nil
+	}
+
+	type contactTokens struct {
+		tenantID int64
+		userID   string
+		tokens   []string
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT tenant_id, user_id, push_tokens FROM contacts WHERE push_tokens IS NOT NULL")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read push tokens: %w", err)
+	}
+	var contacts []contactTokens
+	for rows.Next() {
+		var entry contactTokens
+		var raw []byte
+		if err := rows.Scan(&entry.tenantID, &entry.userID, &raw); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read push tokens: %w", err)
+		}
+		if err := json.Unmarshal(raw, &entry.tokens); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("invalid push tokens of user %s: %w", entry.userID, err)
+		}
+		contacts = append(contacts, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read push tokens: %w", err)
+	}
+
+	var migrated int64
+	now := time.Now()
+	for _, entry := range contacts {
+		for _, token := range entry.tokens {
+			token = strings.TrimSpace(token)
+			if token == "" || len(token) > 512 {
+				continue
+			}
+
+			result, err := db.ExecContext(ctx, `INSERT IGNORE INTO devices
+				(create_time, update_time, tenant_id, user_id, platform, token, last_seen_at, active)
+				VALUES (?, ?, ?, ?, 'unknown', ?, ?, TRUE)`,
+				now, now, entry.tenantID, entry.userID, token, now)
+			if err != nil {
+				return migrated, fmt.Errorf("failed to migrate push token of user %s: %w", entry.userID, err)
+			}
+
+			inserted, _ := result.RowsAffected()
+			migrated += inserted
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, "ALTER TABLE contacts DROP COLUMN push_tokens"); err != nil {
+		return migrated, fmt.Errorf("failed to drop push_tokens column: %w", err)
+	}
+
+	return migrated, nil
+}
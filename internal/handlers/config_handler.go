@@ -10,18 +10,21 @@ import (
 	"gitlab.smartbet.am/golang/notification/ent/schema"
 	"gitlab.smartbet.am/golang/notification/internal/logger"
 	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/providers"
 	"gitlab.smartbet.am/golang/notification/internal/repository"
 )
 
 type ConfigHandler struct {
-	configRepo *repository.PartnerConfigRepository
-	logger     *logrus.Logger
+	configRepo   *repository.PartnerConfigRepository
+	emailManager *providers.EmailProviderManager
+	logger       *logrus.Logger
 }
 
-func NewConfigHandler(configRepo *repository.PartnerConfigRepository, logger *logrus.Logger) *ConfigHandler {
+func NewConfigHandler(configRepo *repository.PartnerConfigRepository, emailManager *providers.EmailProviderManager, logger *logrus.Logger) *ConfigHandler {
 	return &ConfigHandler{
-		configRepo: configRepo,
-		logger:     logger,
+		configRepo:   configRepo,
+		emailManager: emailManager,
+		logger:       logger,
 	}
 }
 
@@ -112,6 +115,28 @@ func (h *ConfigHandler) UpdateConfig(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := models.ValidateFrequencyCaps(req.FrequencyCaps); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:     err.Error(),
+			Code:      "INVALID_REQUEST",
+			Timestamp: time.Now(),
+		})
+	}
+	if err := models.ValidateDedupWindows(req.DedupWindows); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:     err.Error(),
+			Code:      "INVALID_REQUEST",
+			Timestamp: time.Now(),
+		})
+	}
+	if err := models.ValidateDigestPolicies(req.DigestPolicies); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:     err.Error(),
+			Code:      "INVALID_REQUEST",
+			Timestamp: time.Now(),
+		})
+	}
+
 	// Get existing config or create new one
 	config, err := h.configRepo.GetByTenantID(context.Background(), tenantID)
 	if err != nil {
@@ -125,8 +150,14 @@ func (h *ConfigHandler) UpdateConfig(c *fiber.Ctx) error {
 	config.EmailProviders = req.EmailProviders
 	config.SMSProviders = req.SMSProviders
 	config.PushProviders = req.PushProviders
+	config.TelegramProviders = req.TelegramProviders
+	config.ViberProviders = req.ViberProviders
+	config.WhatsAppProviders = req.WhatsAppProviders
 	config.BatchConfig = req.BatchConfig
 	config.RateLimits = req.RateLimits
+	config.FrequencyCaps = req.FrequencyCaps
+	config.DedupWindows = req.DedupWindows
+	config.DigestPolicies = req.DigestPolicies
 	config.Enabled = req.Enabled
 
 	if err := h.configRepo.Save(context.Background(), config); err != nil {
@@ -137,6 +168,7 @@ func (h *ConfigHandler) UpdateConfig(c *fiber.Ctx) error {
 			Timestamp: time.Now(),
 		})
 	}
+	h.emailManager.Invalidate(tenantID)
 
 	return c.JSON(models.ConfigSuccessResponse{
 		Message:   "Configuration updated successfully",
@@ -208,6 +240,7 @@ func (h *ConfigHandler) AddEmailProvider(c *fiber.Ctx) error {
 			Timestamp: time.Now(),
 		})
 	}
+	h.emailManager.Invalidate(tenantID)
 
 	return c.Status(fiber.StatusCreated).JSON(models.ConfigSuccessResponse{
 		Message:   "Email provider added successfully",
@@ -359,12 +392,225 @@ func (h *ConfigHandler) AddPushProvider(c *fiber.Ctx) error {
 	})
 }
 
+// AddTelegramProvider adds a new Telegram bot provider to a tenant configuration
+// @Summary Add Telegram provider
+// @Description Add a new Telegram bot provider to a specific tenant configuration
+// @Tags configuration
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param provider body models.AddProviderRequest true "Telegram provider request"
+// @Success 201 {object} models.ConfigSuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /config/{tenant_id}/providers/telegram [post]
+func (h *ConfigHandler) AddTelegramProvider(c *fiber.Ctx) error {
+	tenantIDStr := c.Params("tenant_id")
+	tenantID, err := strconv.ParseInt(tenantIDStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:     "Invalid tenant ID",
+			Code:      "INVALID_TENANT_ID",
+			Timestamp: time.Now(),
+		})
+	}
+
+	var req models.AddProviderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:     "Invalid request body",
+			Code:      "INVALID_REQUEST",
+			Timestamp: time.Now(),
+		})
+	}
+
+	// Get existing config
+	config, err := h.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to get configuration",
+			Code:      "CONFIG_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	// Add new provider using the correct schema type
+	newProvider := schema.ProviderConfig{
+		Name:     req.Name,
+		Type:     req.Type,
+		Priority: req.Priority,
+		Enabled:  req.Enabled,
+		Config:   req.Config,
+	}
+
+	config.TelegramProviders = append(config.TelegramProviders, newProvider)
+
+	if err := h.configRepo.Save(context.Background(), config); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to save configuration",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.ConfigSuccessResponse{
+		Message:   "Telegram provider added successfully",
+		Status:    "success",
+		TenantID:  tenantID,
+		Timestamp: time.Now(),
+	})
+}
+
+// AddViberProvider adds a new Viber provider to a tenant configuration
+// @Summary Add Viber provider
+// @Description Add a new Viber provider to a specific tenant configuration
+// @Tags configuration
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param provider body models.AddProviderRequest true "Viber provider request"
+// @Success 201 {object} models.ConfigSuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /config/{tenant_id}/providers/viber [post]
+func (h *ConfigHandler) AddViberProvider(c *fiber.Ctx) error {
+	tenantIDStr := c.Params("tenant_id")
+	tenantID, err := strconv.ParseInt(tenantIDStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:     "Invalid tenant ID",
+			Code:      "INVALID_TENANT_ID",
+			Timestamp: time.Now(),
+		})
+	}
+
+	var req models.AddProviderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:     "Invalid request body",
+			Code:      "INVALID_REQUEST",
+			Timestamp: time.Now(),
+		})
+	}
+
+	// Get existing config
+	config, err := h.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to get configuration",
+			Code:      "CONFIG_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	// Add new provider using the correct schema type
+	newProvider := schema.ProviderConfig{
+		Name:     req.Name,
+		Type:     req.Type,
+		Priority: req.Priority,
+		Enabled:  req.Enabled,
+		Config:   req.Config,
+	}
+
+	config.ViberProviders = append(config.ViberProviders, newProvider)
+
+	if err := h.configRepo.Save(context.Background(), config); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to save configuration",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.ConfigSuccessResponse{
+		Message:   "Viber provider added successfully",
+		Status:    "success",
+		TenantID:  tenantID,
+		Timestamp: time.Now(),
+	})
+}
+
+// AddWhatsAppProvider adds a new WhatsApp provider to a tenant configuration
+// @Summary Add WhatsApp provider
+// @Description Add a new WhatsApp provider to a specific tenant configuration
+// @Tags configuration
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param provider body models.AddProviderRequest true "WhatsApp provider request"
+// @Success 201 {object} models.ConfigSuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /config/{tenant_id}/providers/whatsapp [post]
+func (h *ConfigHandler) AddWhatsAppProvider(c *fiber.Ctx) error {
+	tenantIDStr := c.Params("tenant_id")
+	tenantID, err := strconv.ParseInt(tenantIDStr, 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:     "Invalid tenant ID",
+			Code:      "INVALID_TENANT_ID",
+			Timestamp: time.Now(),
+		})
+	}
+
+	var req models.AddProviderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:     "Invalid request body",
+			Code:      "INVALID_REQUEST",
+			Timestamp: time.Now(),
+		})
+	}
+
+	// Get existing config
+	config, err := h.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to get configuration",
+			Code:      "CONFIG_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	// Add new provider using the correct schema type
+	newProvider := schema.ProviderConfig{
+		Name:     req.Name,
+		Type:     req.Type,
+		Priority: req.Priority,
+		Enabled:  req.Enabled,
+		Config:   req.Config,
+	}
+
+	config.WhatsAppProviders = append(config.WhatsAppProviders, newProvider)
+
+	if err := h.configRepo.Save(context.Background(), config); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to save configuration",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.ConfigSuccessResponse{
+		Message:   "WhatsApp provider added successfully",
+		Status:    "success",
+		TenantID:  tenantID,
+		Timestamp: time.Now(),
+	})
+}
+
 // RemoveProvider removes a provider from a tenant configuration
 // @Summary Remove provider
 // @Description Remove a specific provider from a tenant configuration by provider type and name
 // @Tags configuration
 // @Param tenant_id path int true "Tenant ID" minimum(1)
-// @Param type path string true "Provider type" Enums(email,sms,push)
+// @Param type path string true "Provider type" Enums(email,sms,push,telegram,viber,whatsapp)
 // @Param name path string true "Provider name"
 // @Success 200 {object} models.ConfigSuccessResponse
 // @Failure 400 {object} models.ErrorResponse
@@ -432,6 +678,30 @@ func (h *ConfigHandler) RemoveProvider(c *fiber.Ctx) error {
 				break
 			}
 		}
+	case "telegram":
+		for i, provider := range config.TelegramProviders {
+			if provider.Name == providerName {
+				config.TelegramProviders = append(config.TelegramProviders[:i], config.TelegramProviders[i+1:]...)
+				found = true
+				break
+			}
+		}
+	case "viber":
+		for i, provider := range config.ViberProviders {
+			if provider.Name == providerName {
+				config.ViberProviders = append(config.ViberProviders[:i], config.ViberProviders[i+1:]...)
+				found = true
+				break
+			}
+		}
+	case "whatsapp":
+		for i, provider := range config.WhatsAppProviders {
+			if provider.Name == providerName {
+				config.WhatsAppProviders = append(config.WhatsAppProviders[:i], config.WhatsAppProviders[i+1:]...)
+				found = true
+				break
+			}
+		}
 	default:
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error:     "Invalid provider type",
@@ -455,6 +725,9 @@ func (h *ConfigHandler) RemoveProvider(c *fiber.Ctx) error {
 			Timestamp: time.Now(),
 		})
 	}
+	if providerType == "email" {
+		h.emailManager.Invalidate(tenantID)
+	}
 
 	return c.JSON(models.ConfigSuccessResponse{
 		Message:   "Provider removed successfully",
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+)
+
+const maxContactBulkSize = 1000
+
+// phoneNumberPattern accepts E.164 numbers, with or without the leading plus
+var phoneNumberPattern = regexp.MustCompile(`^\+?[1-9][0-9]{6,14}$`)
+
+// telegramChatIDPattern accepts numeric chat IDs, negative for groups and channels
+var telegramChatIDPattern = regexp.MustCompile(`^-?[1-9][0-9]{0,19}$`)
+
+type ContactHandler struct {
+	contactRepo *repository.ContactRepository
+	logger      *logrus.Logger
+}
+
+func NewContactHandler(contactRepo *repository.ContactRepository, logger *logrus.Logger) *ContactHandler {
+	return &ContactHandler{
+		contactRepo: contactRepo,
+		logger:      logger,
+	}
+}
+
+// GetContact returns a contact of a tenant
+// @Summary Get a contact
+// @Description Get the addresses and settings stored for a user of a tenant
+// @Tags contacts
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.ContactResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /contacts/{tenant_id}/{user_id} [get]
+func (h *ContactHandler) GetContact(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	userID := c.Params("user_id")
+	entry, err := h.contactRepo.Get(context.Background(), tenantID, userID)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return contactNotFound(c)
+		}
+		logger.WithTenant(tenantID).Error("Failed to get contact", err, map[string]interface{}{
+			"user_id": userID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to get contact",
+			Code:      "CONTACT_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	return c.JSON(contactToResponse(entry))
+}
+
+// UpsertContact stores a contact of a tenant
+// @Summary Create or replace a contact
+// @Description Store the email, phone number, Telegram chat ID, locale and time zone of a user of a tenant, replacing all fields of an existing contact. Notification requests can then target the user by user ID; push tokens are registered as devices.
+// @Tags contacts
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id path string true "User ID"
+// @Param contact body models.ContactRequest true "Contact (user_id is taken from the path)"
+// @Success 200 {object} models.ContactResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /contacts/{tenant_id}/{user_id} [put]
+func (h *ContactHandler) UpsertContact(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	var req models.ContactRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	req.UserID = c.Params("user_id")
+	if message := normalizeContact(&req); message != "" {
+		return badRequest(c, message)
+	}
+
+	entry, err := h.contactRepo.Upsert(context.Background(), tenantID, &req)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to save contact", err, map[string]interface{}{
+			"user_id": req.UserID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to save contact",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	return c.JSON(contactToResponse(entry))
+}
+
+// UpsertContacts stores many contacts of a tenant
+// @Summary Create or replace contacts in bulk
+// @Description Store up to 1000 contacts of a tenant at once, replacing existing contacts with the same user ID. Invalid contacts are skipped and returned.
+// @Tags contacts
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param contacts body models.ContactBulkRequest true "Contacts"
+// @Success 200 {object} models.ContactBulkResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /contacts/{tenant_id}/bulk [post]
+func (h *ContactHandler) UpsertContacts(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	var req models.ContactBulkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	if len(req.Contacts) == 0 {
+		return badRequest(c, "Contacts list cannot be empty")
+	}
+	if len(req.Contacts) > maxContactBulkSize {
+		return badRequest(c, "Too many contacts, at most 1000 can be upserted at once")
+	}
+
+	response := models.ContactBulkResponse{TenantID: tenantID}
+
+	// A user listed more than once keeps its last entry
+	position := make(map[string]int, len(req.Contacts))
+	valid := make([]*models.ContactRequest, 0, len(req.Contacts))
+	for i, contactReq := range req.Contacts {
+		if contactReq == nil {
+			response.Invalid = append(response.Invalid, &models.InvalidContactInfo{Index: i, Error: "Contact is empty"})
+			continue
+		}
+		if message := normalizeContact(contactReq); message != "" {
+			response.Invalid = append(response.Invalid, &models.InvalidContactInfo{Index: i, UserID: contactReq.UserID, Error: message})
+			continue
+		}
+		if at, ok := position[contactReq.UserID]; ok {
+			valid[at] = contactReq
+			continue
+		}
+		position[contactReq.UserID] = len(valid)
+		valid = append(valid, contactReq)
+	}
+
+	response.Upserted, err = h.contactRepo.UpsertBulk(context.Background(), tenantID, valid)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to upsert contacts", err, map[string]interface{}{
+			"upserted": response.Upserted,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to save contacts",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+			Details:   response,
+		})
+	}
+
+	logger.WithTenant(tenantID).Info("Contacts upserted", map[string]interface{}{
+		"upserted": response.Upserted,
+		"invalid":  len(response.Invalid),
+	})
+
+	return c.JSON(response)
+}
+
+// DeleteContact removes a contact of a tenant
+// @Summary Delete a contact
+// @Description Remove the contact of a user so notification requests can no longer target it by user ID
+// @Tags contacts
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.ConfigSuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /contacts/{tenant_id}/{user_id} [delete]
+func (h *ContactHandler) DeleteContact(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	userID := c.Params("user_id")
+	deleted, err := h.contactRepo.Delete(context.Background(), tenantID, userID)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to delete contact", err, map[string]interface{}{
+			"user_id": userID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to delete contact",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+	if !deleted {
+		return contactNotFound(c)
+	}
+
+	return c.JSON(models.ConfigSuccessResponse{
+		Message:   "Contact deleted successfully",
+		Status:    "success",
+		TenantID:  tenantID,
+		Timestamp: time.Now(),
+	})
+}
+
+// normalizeContact trims the fields of a contact and checks them, returning what's wrong
+// with it or an empty string
+func normalizeContact(req *models.ContactRequest) string {
+	req.UserID = strings.TrimSpace(req.UserID)
+	req.Email = strings.TrimSpace(req.Email)
+	req.Phone = strings.TrimSpace(req.Phone)
+	req.TelegramChatID = strings.TrimSpace(req.TelegramChatID)
+	req.Locale = strings.TrimSpace(req.Locale)
+	req.TimeZone = strings.TrimSpace(req.TimeZone)
+
+	if req.UserID == "" {
+		return "User ID is required"
+	}
+	if len(req.UserID) > 128 {
+		return "User ID is too long"
+	}
+	if len(req.PushTokens) > 0 {
+		return "push_tokens is no longer supported, register push tokens with POST /devices/{tenant_id}"
+	}
+	if req.Email != "" && !isSuppressionAddress(models.TypeEmail, req.Email) {
+		return "Invalid email"
+	}
+	if req.Phone != "" && !phoneNumberPattern.MatchString(req.Phone) {
+		return "Invalid phone number, expected E.164 format"
+	}
+	if req.TelegramChatID != "" && !telegramChatIDPattern.MatchString(req.TelegramChatID) {
+		return "Invalid Telegram chat ID"
+	}
+	if len(req.Locale) > 16 {
+		return "Invalid locale"
+	}
+	if req.TimeZone != "" {
+		if _, err := time.LoadLocation(req.TimeZone); err != nil || len(req.TimeZone) > 64 {
+			return "Invalid time zone"
+		}
+	}
+	return ""
+}
+
+func contactToResponse(entry *ent.Contact) *models.ContactResponse {
+	return &models.ContactResponse{
+		ID:             entry.ID,
+		TenantID:       entry.TenantID,
+		UserID:         entry.UserID,
+		Email:          entry.Email,
+		Phone:          entry.Phone,
+		TelegramChatID: entry.TelegramChatID,
+		Locale:         entry.Locale,
+		TimeZone:       entry.TimeZone,
+		CreatedAt:      entry.CreateTime,
+		UpdatedAt:      entry.UpdateTime,
+	}
+}
+
+func contactNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:     "Contact not found",
+		Code:      "NOT_FOUND",
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+)
+
+type DeviceHandler struct {
+	deviceRepo *repository.DeviceRepository
+	logger     *logrus.Logger
+}
+
+func NewDeviceHandler(deviceRepo *repository.DeviceRepository, logger *logrus.Logger) *DeviceHandler {
+	return &DeviceHandler{
+		deviceRepo: deviceRepo,
+		logger:     logger,
+	}
+}
+
+// RegisterDevice stores a push token of a tenant's user
+// @Summary Register a device
+// @Description Register the push token of a user's device, or refresh its last seen time. A token that is already registered is moved to the user and reactivated. Push notifications targeting the user by user ID go to all of its active devices.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param device body models.DeviceRegisterRequest true "Device"
+// @Success 200 {object} models.DeviceResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /devices/{tenant_id} [post]
+func (h *DeviceHandler) RegisterDevice(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	var req models.DeviceRegisterRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	req.UserID = strings.TrimSpace(req.UserID)
+	req.Token = strings.TrimSpace(req.Token)
+	req.AppVersion = strings.TrimSpace(req.AppVersion)
+	if req.UserID == "" {
+		return badRequest(c, "User ID is required")
+	}
+	if len(req.UserID) > 128 {
+		return badRequest(c, "User ID is too long")
+	}
+	if !req.Platform.IsValid() {
+		return badRequest(c, "Invalid platform, expected ios, android or web")
+	}
+	if req.Token == "" {
+		return badRequest(c, "Token is required")
+	}
+	if len(req.Token) > 512 {
+		return badRequest(c, "Token is too long")
+	}
+	if len(req.AppVersion) > 32 {
+		return badRequest(c, "App version is too long")
+	}
+
+	entry, err := h.deviceRepo.Register(context.Background(), tenantID, &req)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to register device", err, map[string]interface{}{
+			"user_id":  req.UserID,
+			"platform": req.Platform,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to register device",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	return c.JSON(deviceToResponse(entry))
+}
+
+// UnregisterDevice deactivates a push token of a tenant
+// @Summary Unregister a device
+// @Description Stop sending push notifications to a token, for example when the user logs out of the app. The device is kept, inactive, until the token is registered again.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param device body models.DeviceUnregisterRequest true "Token to unregister"
+// @Success 200 {object} models.ConfigSuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /devices/{tenant_id}/unregister [post]
+func (h *DeviceHandler) UnregisterDevice(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	var req models.DeviceUnregisterRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		return badRequest(c, "Token is required")
+	}
+
+	deactivated, err := h.deviceRepo.Deactivate(context.Background(), tenantID, req.Token, repository.DeactivationUnregistered)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to unregister device", err, map[string]interface{}{})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to unregister device",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+	if !deactivated {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:     "Active device not found",
+			Code:      "NOT_FOUND",
+			Timestamp: time.Now(),
+		})
+	}
+
+	return c.JSON(models.ConfigSuccessResponse{
+		Message:   "Device unregistered successfully",
+		Status:    "success",
+		TenantID:  tenantID,
+		Timestamp: time.Now(),
+	})
+}
+
+// ListDevices returns the devices of a tenant's user
+// @Summary List the devices of a user
+// @Description List the push tokens registered for a user, most recently seen first. Inactive devices, unregistered or pruned after a provider reported the token invalid, are included unless active=true.
+// @Tags devices
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id path string true "User ID"
+// @Param active query bool false "Only list active devices"
+// @Success 200 {object} models.DeviceListResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /devices/{tenant_id}/users/{user_id} [get]
+func (h *DeviceHandler) ListDevices(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	userID := c.Params("user_id")
+	entries, err := h.deviceRepo.ListByUser(context.Background(), tenantID, userID, c.QueryBool("active", false))
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to list devices", err, map[string]interface{}{
+			"user_id": userID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to list devices",
+			Code:      "DEVICE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	response := models.DeviceListResponse{
+		TenantID: tenantID,
+		UserID:   userID,
+		Devices:  make([]*models.DeviceResponse, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		response.Devices = append(response.Devices, deviceToResponse(entry))
+	}
+
+	return c.JSON(response)
+}
+
+func deviceToResponse(entry *ent.Device) *models.DeviceResponse {
+	return &models.DeviceResponse{
+		ID:                 entry.ID,
+		TenantID:           entry.TenantID,
+		UserID:             entry.UserID,
+		Platform:           models.DevicePlatform(entry.Platform),
+		Token:              entry.Token,
+		AppVersion:         entry.AppVersion,
+		LastSeenAt:         entry.LastSeenAt,
+		Active:             entry.Active,
+		DeactivatedAt:      entry.DeactivatedAt,
+		DeactivationReason: entry.DeactivationReason,
+		CreatedAt:          entry.CreateTime,
+		UpdatedAt:          entry.UpdateTime,
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+	"gitlab.smartbet.am/golang/notification/internal/services"
+)
+
+const (
+	defaultInboxPageSize = 20
+	maxInboxPageSize     = 100
+	maxInboxUpdateSize   = 500
+)
+
+type InboxHandler struct {
+	inbox  *services.InboxService
+	logger *logrus.Logger
+}
+
+func NewInboxHandler(inbox *services.InboxService, logger *logrus.Logger) *InboxHandler {
+	return &InboxHandler{
+		inbox:  inbox,
+		logger: logger,
+	}
+}
+
+// ListInbox returns a page of a user's inbox
+// @Summary List the inbox of a user
+// @Description List the in-app notifications sent to a user, newest first. Archived messages are only listed with archived=true. Scheduled, held and withheld notifications show up once they are sent.
+// @Tags inbox
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id path string true "User ID"
+// @Param unread query bool false "Only list unread messages"
+// @Param archived query bool false "List archived messages instead"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} models.InboxListResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /inbox/{tenant_id}/users/{user_id} [get]
+func (h *InboxHandler) ListInbox(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+	userID := c.Params("user_id")
+
+	filter := repository.InboxFilter{
+		UnreadOnly: c.QueryBool("unread", false),
+		Archived:   c.QueryBool("archived", false),
+		Limit:      c.QueryInt("limit", defaultInboxPageSize),
+		Offset:     c.QueryInt("offset", 0),
+	}
+	if filter.Limit <= 0 || filter.Limit > maxInboxPageSize {
+		filter.Limit = defaultInboxPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	messages, total, err := h.inbox.List(context.Background(), tenantID, userID, filter)
+	if err != nil {
+		return h.inboxError(c, tenantID, userID, "Failed to list inbox", err)
+	}
+	unread, err := h.inbox.CountUnread(context.Background(), tenantID, userID)
+	if err != nil {
+		return h.inboxError(c, tenantID, userID, "Failed to list inbox", err)
+	}
+
+	response := models.InboxListResponse{
+		TenantID: tenantID,
+		UserID:   userID,
+		Total:    total,
+		Unread:   unread,
+		Limit:    filter.Limit,
+		Offset:   filter.Offset,
+		Messages: make([]*models.InboxMessage, 0, len(messages)),
+	}
+	for _, message := range messages {
+		response.Messages = append(response.Messages, services.NewInboxMessage(message))
+	}
+
+	return c.JSON(response)
+}
+
+// GetUnreadCount returns the number of unread messages of a user's inbox
+// @Summary Count unread inbox messages
+// @Description Count the messages of a user's inbox that are neither read nor archived, e.g. for a badge
+// @Tags inbox
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.InboxUnreadCountResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /inbox/{tenant_id}/users/{user_id}/unread-count [get]
+func (h *InboxHandler) GetUnreadCount(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+	userID := c.Params("user_id")
+
+	unread, err := h.inbox.CountUnread(context.Background(), tenantID, userID)
+	if err != nil {
+		return h.inboxError(c, tenantID, userID, "Failed to count unread messages", err)
+	}
+
+	return c.JSON(models.InboxUnreadCountResponse{
+		TenantID: tenantID,
+		UserID:   userID,
+		Unread:   unread,
+	})
+}
+
+// MarkRead marks messages of a user's inbox read
+// @Summary Mark inbox messages read
+// @Description Mark messages of a user's inbox read. Reading a message moves its notification to OPENED, which stops a channel cascade it is a step of.
+// @Tags inbox
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id path string true "User ID"
+// @Param messages body models.InboxUpdateRequest true "Messages to mark read"
+// @Success 200 {object} models.InboxUpdateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /inbox/{tenant_id}/users/{user_id}/read [post]
+func (h *InboxHandler) MarkRead(c *fiber.Ctx) error {
+	return h.update(c, "Failed to mark messages read", h.inbox.MarkRead)
+}
+
+// MarkUnread marks messages of a user's inbox unread
+// @Summary Mark inbox messages unread
+// @Description Mark read messages of a user's inbox unread again. The status of their notifications stays OPENED.
+// @Tags inbox
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id path string true "User ID"
+// @Param messages body models.InboxUpdateRequest true "Messages to mark unread"
+// @Success 200 {object} models.InboxUpdateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /inbox/{tenant_id}/users/{user_id}/unread [post]
+func (h *InboxHandler) MarkUnread(c *fiber.Ctx) error {
+	return h.update(c, "Failed to mark messages unread", h.inbox.MarkUnread)
+}
+
+// ArchiveMessages archives messages of a user's inbox
+// @Summary Archive inbox messages
+// @Description Move messages of a user's inbox to the archive. Archived messages don't count as unread and are only listed with archived=true.
+// @Tags inbox
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id path string true "User ID"
+// @Param messages body models.InboxUpdateRequest true "Messages to archive"
+// @Success 200 {object} models.InboxUpdateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /inbox/{tenant_id}/users/{user_id}/archive [post]
+func (h *InboxHandler) ArchiveMessages(c *fiber.Ctx) error {
+	return h.update(c, "Failed to archive messages", h.inbox.Archive)
+}
+
+// update applies an update to the messages of a user's inbox named in the request body
+func (h *InboxHandler) update(c *fiber.Ctx, failure string, apply func(context.Context, int64, string, []string) (int, error)) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+	userID := c.Params("user_id")
+
+	var req models.InboxUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	requestIDs := make([]string, 0, len(req.RequestIDs))
+	for _, requestID := range req.RequestIDs {
+		if requestID = strings.TrimSpace(requestID); requestID != "" {
+			requestIDs = append(requestIDs, requestID)
+		}
+	}
+	if len(requestIDs) == 0 {
+		return badRequest(c, "Request IDs are required")
+	}
+	if len(requestIDs) > maxInboxUpdateSize {
+		return badRequest(c, "Too many request IDs, at most 500 can be updated at once")
+	}
+
+	updated, err := apply(context.Background(), tenantID, userID, requestIDs)
+	if err != nil {
+		return h.inboxError(c, tenantID, userID, failure, err)
+	}
+	unread, err := h.inbox.CountUnread(context.Background(), tenantID, userID)
+	if err != nil {
+		return h.inboxError(c, tenantID, userID, failure, err)
+	}
+
+	return c.JSON(models.InboxUpdateResponse{
+		TenantID: tenantID,
+		UserID:   userID,
+		Updated:  updated,
+		Unread:   unread,
+	})
+}
+
+func (h *InboxHandler) inboxError(c *fiber.Ctx, tenantID int64, userID, message string, err error) error {
+	logger.WithTenant(tenantID).Error(message, err, map[string]interface{}{
+		"user_id": userID,
+	})
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:     message,
+		Code:      "INBOX_ERROR",
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/enttest"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+	"gitlab.smartbet.am/golang/notification/internal/services"
+	"gitlab.smartbet.am/golang/notification/types"
+)
+
+// newInboxApp serves the inbox routes of a handler backed by an in-memory SQLite database
+func newInboxApp(t *testing.T) (*fiber.App, *ent.Client) {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	client := enttest.Open(t, "sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared&_fk=1", name))
+	t.Cleanup(func() { client.Close() })
+
+	log := logrus.New()
+	notifRepo := repository.NewNotificationRepository(client, log)
+	cascades := services.NewCascadeService(notifRepo, repository.NewContactRepository(client, log), log)
+	handler := NewInboxHandler(services.NewInboxService(repository.NewInboxRepository(client, log), notifRepo, cascades, log), log)
+
+	app := fiber.New()
+	inbox := app.Group("/inbox")
+	inbox.Get("/:tenant_id/users/:user_id", handler.ListInbox)
+	inbox.Get("/:tenant_id/users/:user_id/unread-count", handler.GetUnreadCount)
+	inbox.Post("/:tenant_id/users/:user_id/read", handler.MarkRead)
+	inbox.Post("/:tenant_id/users/:user_id/unread", handler.MarkUnread)
+	inbox.Post("/:tenant_id/users/:user_id/archive", handler.ArchiveMessages)
+	return app, client
+}
+
+// createInAppNotification stores an in-app notification to a user in the given status
+func createInAppNotification(t *testing.T, client *ent.Client, tenantID int64, userID, requestID string, status notification.Status, sentAt time.Time) {
+	t.Helper()
+	create := client.Notification.Create().
+		SetRequestID(requestID).
+		SetTenantID(tenantID).
+		SetType(notification.TypeINAPP).
+		SetBody("Message " + requestID).
+		SetAddress(types.Address(userID)).
+		SetStatus(status)
+	if status != notification.StatusPENDING {
+		create.SetSentAt(sentAt)
+	}
+	if _, err := create.Save(context.Background()); err != nil {
+		t.Fatalf("failed to create notification: %v", err)
+	}
+}
+
+// doJSON sends a request to the app and decodes the JSON response into out
+func doJSON(t *testing.T, app *fiber.App, method, path, body string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if out != nil && resp.StatusCode == fiber.StatusOK {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("invalid response %s: %v", data, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestInboxHandler(t *testing.T) {
+	app, client := newInboxApp(t)
+
+	now := time.Now()
+	createInAppNotification(t, client, 1001, "player-1", "req-1", notification.StatusSENT, now.Add(-2*time.Minute))
+	createInAppNotification(t, client, 1001, "player-1", "req-2", notification.StatusSENT, now.Add(-time.Minute))
+	// Not in the inbox: not sent yet, to another user or of another tenant
+	createInAppNotification(t, client, 1001, "player-1", "req-pending", notification.StatusPENDING, now)
+	createInAppNotification(t, client, 1001, "player-2", "req-other-user", notification.StatusSENT, now)
+	createInAppNotification(t, client, 1002, "player-1", "req-other-tenant", notification.StatusSENT, now)
+
+	var list models.InboxListResponse
+	if status := doJSON(t, app, "GET", "/inbox/1001/users/player-1", "", &list); status != fiber.StatusOK {
+		t.Fatalf("list status = %d", status)
+	}
+	if list.Total != 2 || list.Unread != 2 || len(list.Messages) != 2 || list.Messages[0].RequestID != "req-2" {
+		t.Fatalf("list = %+v, want req-2 and req-1 unread", list)
+	}
+
+	var update models.InboxUpdateResponse
+	if status := doJSON(t, app, "POST", "/inbox/1001/users/player-1/read", `{"request_ids":["req-1","req-other-user"]}`, &update); status != fiber.StatusOK {
+		t.Fatalf("read status = %d", status)
+	}
+	if update.Updated != 1 || update.Unread != 1 {
+		t.Errorf("read = %+v, want 1 updated and 1 unread", update)
+	}
+
+	// Reading a message opens its notification
+	read, err := client.Notification.Query().Where(notification.RequestID("req-1")).Only(context.Background())
+	if err != nil {
+		t.Fatalf("failed to load notification: %v", err)
+	}
+	if read.Status != notification.StatusOPENED || read.ReadAt == nil {
+		t.Errorf("read notification status = %s, read at %v, want OPENED", read.Status, read.ReadAt)
+	}
+
+	var count models.InboxUnreadCountResponse
+	if status := doJSON(t, app, "GET", "/inbox/1001/users/player-1/unread-count", "", &count); status != fiber.StatusOK || count.Unread != 1 {
+		t.Errorf("unread count = %d (status %d), want 1", count.Unread, status)
+	}
+
+	if status := doJSON(t, app, "GET", "/inbox/1001/users/player-1?unread=true", "", &list); status != fiber.StatusOK || list.Total != 1 || list.Messages[0].RequestID != "req-2" {
+		t.Errorf("unread list = %+v, want req-2", list)
+	}
+
+	if status := doJSON(t, app, "POST", "/inbox/1001/users/player-1/unread", `{"request_ids":["req-1","req-2"]}`, &update); status != fiber.StatusOK || update.Updated != 1 || update.Unread != 2 {
+		t.Errorf("unread = %+v (status %d), want 1 updated and 2 unread", update, status)
+	}
+
+	if status := doJSON(t, app, "POST", "/inbox/1001/users/player-1/archive", `{"request_ids":["req-2"]}`, &update); status != fiber.StatusOK || update.Updated != 1 || update.Unread != 1 {
+		t.Errorf("archive = %+v (status %d), want 1 updated and 1 unread", update, status)
+	}
+	if status := doJSON(t, app, "GET", "/inbox/1001/users/player-1", "", &list); status != fiber.StatusOK || list.Total != 1 || list.Messages[0].RequestID != "req-1" {
+		t.Errorf("list after archiving = %+v, want req-1", list)
+	}
+	if status := doJSON(t, app, "GET", "/inbox/1001/users/player-1?archived=true", "", &list); status != fiber.StatusOK || list.Total != 1 || !list.Messages[0].Archived {
+		t.Errorf("archived list = %+v, want req-2", list)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{name: "invalid tenant", method: "GET", path: "/inbox/abc/users/player-1"},
+		{name: "no request IDs", method: "POST", path: "/inbox/1001/users/player-1/read", body: `{"request_ids":[" "]}`},
+		{name: "invalid body", method: "POST", path: "/inbox/1001/users/player-1/archive", body: `{`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := doJSON(t, app, tt.method, tt.path, tt.body, nil); status != fiber.StatusBadRequest {
+				t.Errorf("status = %d, want 400", status)
+			}
+		})
+	}
+}
@@ -4,38 +4,56 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/ent/schema"
 	"gitlab.smartbet.am/golang/notification/internal/kafka"
 	"gitlab.smartbet.am/golang/notification/internal/logger"
 	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/providers/email"
 	"gitlab.smartbet.am/golang/notification/internal/repository"
 )
 
 type NotificationHandler struct {
-	publisher *kafka.Publisher
-	notifRepo *repository.NotificationRepository
-	logger    *logrus.Logger
+	publisher   *kafka.Publisher
+	notifRepo   *repository.NotificationRepository
+	attemptRepo *repository.DeliveryAttemptRepository
+	contactRepo *repository.ContactRepository
+	configRepo  *repository.PartnerConfigRepository
+	logger      *logrus.Logger
 }
 
 func NewNotificationHandler(
 	publisher *kafka.Publisher,
 	notifRepo *repository.NotificationRepository,
+	attemptRepo *repository.DeliveryAttemptRepository,
+	contactRepo *repository.ContactRepository,
+	configRepo *repository.PartnerConfigRepository,
 	logger *logrus.Logger,
 ) *NotificationHandler {
 	return &NotificationHandler{
-		publisher: publisher,
-		notifRepo: notifRepo,
-		logger:    logger,
+		publisher:   publisher,
+		notifRepo:   notifRepo,
+		attemptRepo: attemptRepo,
+		contactRepo: contactRepo,
+		configRepo:  configRepo,
+		logger:      logger,
 	}
 }
 
 // SendNotification handles HTTP requests and publishes to Kafka
 // @Summary Send a notification
-// @Description Send a single notification via HTTP API. The notification can be sent immediately or scheduled for future delivery.
+// @Description Send a single notification via HTTP API. The notification can be sent immediately or scheduled for future delivery. Recipients can be given as addresses, as user_ids resolved to the addresses of their contacts on the channel, or both; user IDs without an address are skipped and reported. Instead of a type, a cascade of channels with per-step timeouts can be given for user_ids: each user's channels are tried in order until one delivers.
 // @Tags notifications
 // @Accept json
 // @Produce json
@@ -76,6 +94,51 @@ func (h *NotificationHandler) SendNotification(c *fiber.Ctx) error {
 		})
 	}
 
+	// Users are resolved before queuing so the ones without an address can be reported
+	var unresolved []string
+	if len(req.Cascade) > 0 {
+		var err error
+		req.UserIDs, unresolved, err = h.reachableUsers(context.Background(), &req)
+		if err != nil {
+			logger.WithRequest(req.RequestID).Error("Failed to resolve user IDs", err, map[string]interface{}{
+				"tenant_id": req.TenantID,
+				"user_ids":  len(req.UserIDs),
+			})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to resolve user IDs",
+				"code":  "RESOLVE_ERROR",
+			})
+		}
+		if len(req.UserIDs) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":               "None of the user IDs has an address on any cascade channel",
+				"code":                "NO_RECIPIENTS",
+				"unresolved_user_ids": unresolved,
+			})
+		}
+	} else if len(req.UserIDs) > 0 {
+		var err error
+		req.Recipients, unresolved, err = h.contactRepo.ResolveRecipients(context.Background(), req.TenantID, req.Type, req.Recipients, req.UserIDs)
+		if err != nil {
+			logger.WithRequest(req.RequestID).Error("Failed to resolve user IDs", err, map[string]interface{}{
+				"tenant_id": req.TenantID,
+				"user_ids":  len(req.UserIDs),
+			})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to resolve user IDs",
+				"code":  "RESOLVE_ERROR",
+			})
+		}
+		if len(req.Recipients) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":               "None of the user IDs has an address for the channel",
+				"code":                "NO_RECIPIENTS",
+				"unresolved_user_ids": unresolved,
+			})
+		}
+		req.UserIDs = nil
+	}
+
 	// Publish to Kafka for async processing
 	data, err := json.Marshal(req)
 	if err != nil {
@@ -99,9 +162,10 @@ func (h *NotificationHandler) SendNotification(c *fiber.Ctx) error {
 	}
 
 	response := models.NotificationResponse{
-		RequestID: req.RequestID,
-		Status:    "queued",
-		Message:   "Notification queued for processing",
+		RequestID:         req.RequestID,
+		Status:            "queued",
+		Message:           "Notification queued for processing",
+		UnresolvedUserIDs: unresolved,
 	}
 
 	return c.Status(fiber.StatusAccepted).JSON(response)
@@ -109,7 +173,7 @@ func (h *NotificationHandler) SendNotification(c *fiber.Ctx) error {
 
 // SendBatchNotification handles batch notification requests
 // @Summary Send batch notifications
-// @Description Send multiple notifications in a batch to multiple recipients
+// @Description Send multiple notifications in a batch to multiple recipients, given as addresses, as user_ids resolved to the addresses of their contacts on the channel, or both. User IDs without an address are skipped and reported.
 // @Tags notifications
 // @Accept json
 // @Produce json
@@ -137,6 +201,51 @@ func (h *NotificationHandler) SendBatchNotification(c *fiber.Ctx) error {
 		})
 	}
 
+	for _, attachment := range req.Attachments {
+		if err := validateAttachment(attachment); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+				"code":  "VALIDATION_ERROR",
+			})
+		}
+	}
+	if err := h.validateAttachmentLimits(req.TenantID, req.Attachments); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "VALIDATION_ERROR",
+		})
+	}
+
+	// Users are resolved before splitting the batch so the ones without an address can be reported
+	var unresolved []string
+	if len(req.UserIDs) > 0 {
+		if !isChannel(req.Type) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid notification type",
+				"code":  "VALIDATION_ERROR",
+			})
+		}
+
+		var err error
+		req.Recipients, unresolved, err = h.contactRepo.ResolveRecipients(context.Background(), req.TenantID, req.Type, req.Recipients, req.UserIDs)
+		if err != nil {
+			logger.WithTenant(req.TenantID).Error("Failed to resolve user IDs", err, map[string]interface{}{
+				"user_ids": len(req.UserIDs),
+			})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to resolve user IDs",
+				"code":  "RESOLVE_ERROR",
+			})
+		}
+		if len(req.Recipients) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":               "None of the user IDs has an address for the channel",
+				"code":                "NO_RECIPIENTS",
+				"unresolved_user_ids": unresolved,
+			})
+		}
+	}
+
 	batchID := uuid.New().String()
 
 	// Split recipients into chunks and publish to Kafka
@@ -163,6 +272,7 @@ func (h *NotificationHandler) SendBatchNotification(c *fiber.Ctx) error {
 			Data:        req.Data,
 			BatchID:     batchID,
 			MessageType: req.MessageType,
+			Attachments: req.Attachments,
 		}
 
 		data, _ := json.Marshal(notifReq)
@@ -177,10 +287,11 @@ func (h *NotificationHandler) SendBatchNotification(c *fiber.Ctx) error {
 	}
 
 	response := models.BatchNotificationResponse{
-		BatchID:          batchID,
-		TotalRecipients:  len(req.Recipients),
-		QueuedRecipients: publishedCount,
-		Status:           "processing",
+		BatchID:           batchID,
+		TotalRecipients:   len(req.Recipients),
+		QueuedRecipients:  publishedCount,
+		Status:            "processing",
+		UnresolvedUserIDs: unresolved,
 	}
 
 	return c.Status(fiber.StatusAccepted).JSON(response)
@@ -188,7 +299,7 @@ func (h *NotificationHandler) SendBatchNotification(c *fiber.Ctx) error {
 
 // GetNotificationStatus retrieves notification status by request ID
 // @Summary Get notification status
-// @Description Get the current status and details of a notification by its request ID
+// @Description Get the current status and details of a notification by its request ID, including the timeline of delivery attempts
 // @Tags notifications
 // @Produce json
 // @Param request_id path string true "Request ID"
@@ -232,9 +343,150 @@ func (h *NotificationHandler) GetNotificationStatus(c *fiber.Ctx) error {
 		response.ScheduleTS = notification.ScheduleTs
 	}
 
+	if notification.CascadeID != "" {
+		response.CascadeID = notification.CascadeID
+		response.CascadeStep = &notification.CascadeStep
+	}
+
+	response.SentAt = notification.SentAt
+	response.DeliveredAt = notification.DeliveredAt
+	response.BouncedAt = notification.BouncedAt
+	response.UndeliveredAt = notification.UndeliveredAt
+	response.OpenedAt = notification.OpenedAt
+	response.ClickedAt = notification.ClickedAt
+	response.FailedAt = notification.FailedAt
+	response.CancelledAt = notification.CancelledAt
+	response.SuppressedAt = notification.SuppressedAt
+	response.CappedAt = notification.CappedAt
+	response.DuplicatedAt = notification.DuplicatedAt
+	response.HeldAt = notification.HeldAt
+	response.DigestedAt = notification.DigestedAt
+	response.DigestRequestID = notification.DigestRequestID
+
+	attempts, err := h.attemptRepo.GetByNotificationID(context.Background(), notification.ID)
+	if err != nil {
+		logger.WithRequest(requestID).Error("Failed to load delivery attempts", err, map[string]interface{}{
+			"notification_id": notification.ID,
+		})
+	}
+
+	for _, attempt := range attempts {
+		response.Attempts = append(response.Attempts, &models.DeliveryAttemptResponse{
+			Attempt:           attempt.Attempt,
+			ProviderName:      attempt.ProviderName,
+			ProviderType:      attempt.ProviderType,
+			ProviderMessageID: attempt.ProviderMessageID,
+			Outcome:           string(attempt.Outcome),
+			ProviderStatus:    attempt.ProviderStatus,
+			OperatorErrorCode: attempt.OperatorErrorCode,
+			ErrorClass:        attempt.ErrorClass,
+			ErrorMessage:      attempt.ErrorMessage,
+			RequestSummary:    attempt.RequestSummary,
+			ResponseSummary:   attempt.ResponseSummary,
+			LatencyMS:         attempt.LatencyMs,
+			CreatedAt:         attempt.CreateTime,
+		})
+
+		// The latest accepted attempt carries the message ID the provider knows it by
+		if attempt.ProviderMessageID != "" {
+			response.ProviderMessageID = attempt.ProviderMessageID
+		}
+	}
+
 	return c.JSON(response)
 }
 
+// GetCascadeStatus retrieves the progress of the cascades of a request
+// @Summary Get cascade status
+// @Description Get the steps of every user's channel cascade of a notification request, with the status of each step and of the cascade as a whole
+// @Tags notifications
+// @Produce json
+// @Param request_id path string true "Request ID returned when the cascade was sent"
+// @Success 200 {object} models.CascadeStatusResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /notifications/cascade/{request_id}/status [get]
+func (h *NotificationHandler) GetCascadeStatus(c *fiber.Ctx) error {
+	requestID := c.Params("request_id")
+	if requestID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Request ID is required",
+			"code":  "MISSING_REQUEST_ID",
+		})
+	}
+
+	notifications, err := h.notifRepo.GetCascadesByRequestID(context.Background(), requestID)
+	if err != nil {
+		logger.WithRequest(requestID).Error("Failed to get cascade notifications", err, map[string]interface{}{})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get cascade status",
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+	if len(notifications) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Cascade not found",
+			"code":  "NOT_FOUND",
+		})
+	}
+
+	response := models.CascadeStatusResponse{RequestID: requestID}
+
+	// Notifications are ordered by cascade, so each cascade is a run of them
+	var chain *models.CascadeChainStatus
+	for _, notif := range notifications {
+		if chain == nil || chain.CascadeID != notif.CascadeID {
+			chain = &models.CascadeChainStatus{
+				CascadeID: notif.CascadeID,
+				UserID:    strings.TrimPrefix(notif.CascadeID, requestID+":"),
+			}
+			response.Cascades = append(response.Cascades, chain)
+		}
+
+		chain.Steps = append(chain.Steps, &models.CascadeStepStatus{
+			Step:         notif.CascadeStep,
+			Type:         string(notif.Type),
+			RequestID:    notif.RequestID,
+			Status:       string(notif.Status),
+			ScheduleTS:   notif.ScheduleTs,
+			ErrorMessage: notif.ErrorMessage,
+			UpdatedAt:    notif.UpdateTime,
+		})
+	}
+
+	for _, chain := range response.Cascades {
+		chain.Status = cascadeStatus(chain.Steps)
+	}
+
+	return c.JSON(response)
+}
+
+// cascadeStatus sums up the steps of a cascade
+func cascadeStatus(steps []*models.CascadeStepStatus) string {
+	var pending, sent bool
+	for _, step := range steps {
+		switch step.Status {
+		case "DELIVERED", "OPENED", "CLICKED":
+			return "DELIVERED"
+		case "PENDING", "ACTIVE":
+			pending = true
+		case "SENT":
+			sent = true
+		}
+	}
+
+	switch {
+	case pending:
+		return "PENDING"
+	case sent:
+		return "SENT"
+	default:
+		return "FAILED"
+	}
+}
+
 // GetBatchStatus retrieves batch status by batch ID
 // @Summary Get batch status
 // @Description Get the status and statistics of a batch notification by its batch ID
@@ -264,15 +516,17 @@ func (h *NotificationHandler) GetBatchStatus(c *fiber.Ctx) error {
 	}
 
 	// Calculate batch status
-	var completed, failed, pending int
+	var completed, failed, pending, skipped int
 	firstNotification := notifications[0]
 
 	for _, notif := range notifications {
 		switch notif.Status {
-		case "COMPLETED":
+		case "SENT", "DELIVERED", "OPENED", "CLICKED", "DIGESTED":
 			completed++
-		case "FAILED":
+		case "FAILED", "UNDELIVERED", "BOUNCED":
 			failed++
+		case "SUPPRESSED", "CAPPED", "DUPLICATE":
+			skipped++
 		default:
 			pending++
 		}
@@ -286,7 +540,7 @@ func (h *NotificationHandler) GetBatchStatus(c *fiber.Ctx) error {
 				status = "FAILED"
 			}
 		} else {
-			status = "COMPLETED"
+			status = "SENT"
 		}
 	}
 
@@ -301,11 +555,115 @@ func (h *NotificationHandler) GetBatchStatus(c *fiber.Ctx) error {
 		CompletedCount: completed,
 		FailedCount:    failed,
 		PendingCount:   pending,
+		SkippedCount:   skipped,
 	}
 
 	return c.JSON(response)
 }
 
+// GetDeliveryStats returns delivery rates per message type
+// @Summary Get delivery statistics
+// @Description Count a tenant's notifications by message type and outcome, with delivery rates for promotional (promo, bonus) and transactional traffic. Delivery rates are based on provider delivery reports.
+// @Tags notifications
+// @Produce json
+// @Param tenant_id query int true "Tenant ID" minimum(1)
+// @Param type query string false "Notification type" Enums(EMAIL, SMS, PUSH, INAPP, TELEGRAM, VIBER, WHATSAPP)
+// @Param from query string false "Start of the window (RFC 3339), defaults to 7 days ago"
+// @Param to query string false "End of the window (RFC 3339), defaults to now"
+// @Success 200 {object} models.DeliveryStatsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /notifications/stats/delivery [get]
+func (h *NotificationHandler) GetDeliveryStats(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Query("tenant_id"), 10, 64)
+	if err != nil || tenantID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID",
+			"code":  "INVALID_TENANT_ID",
+		})
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -7)
+	for param, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid " + param + " time, expected RFC 3339",
+					"code":  "INVALID_REQUEST",
+				})
+			}
+			*target = parsed
+		}
+	}
+
+	var notifType *notification.Type
+	if value := c.Query("type"); value != "" {
+		t := notification.Type(value)
+		if err := notification.TypeValidator(t); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid notification type",
+				"code":  "INVALID_REQUEST",
+			})
+		}
+		notifType = &t
+	}
+
+	counts, err := h.notifRepo.CountByMessageTypeAndStatus(context.Background(), tenantID, notifType, from, to)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to count notifications", err, map[string]interface{}{})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get delivery statistics",
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+
+	response := models.DeliveryStatsResponse{
+		TenantID:      tenantID,
+		Type:          c.Query("type"),
+		From:          from,
+		To:            to,
+		MessageTypes:  []*models.MessageTypeDeliveryStats{},
+		Promotional:   &models.DeliveryStats{},
+		Transactional: &models.DeliveryStats{},
+	}
+
+	byMessageType := make(map[models.MessageType]*models.MessageTypeDeliveryStats)
+	for _, count := range counts {
+		// Notifications without a message type are sent as system messages
+		messageType := models.MessageType(count.MessageType)
+		if messageType == "" {
+			messageType = models.MessageTypeSystem
+		}
+
+		stats, ok := byMessageType[messageType]
+		if !ok {
+			stats = &models.MessageTypeDeliveryStats{
+				MessageType: string(messageType),
+				Promotional: messageType.IsPromotional(),
+			}
+			byMessageType[messageType] = stats
+			response.MessageTypes = append(response.MessageTypes, stats)
+		}
+
+		status := models.NotificationStatus(count.Status)
+		stats.Add(status, count.Count)
+		if stats.Promotional {
+			response.Promotional.Add(status, count.Count)
+		} else {
+			response.Transactional.Add(status, count.Count)
+		}
+	}
+
+	sort.Slice(response.MessageTypes, func(i, j int) bool {
+		return response.MessageTypes[i].MessageType < response.MessageTypes[j].MessageType
+	})
+
+	return c.JSON(response)
+}
+
 // PublishToKafka handles direct Kafka publishing
 // @Summary Publish to Kafka
 // @Description Directly publish a notification to Kafka bypassing the HTTP API queue
@@ -342,6 +700,7 @@ func (h *NotificationHandler) PublishToKafka(c *fiber.Ctx) error {
 		TenantID:    req.TenantID,
 		Type:        req.Type,
 		Recipients:  req.Recipients,
+		UserIDs:     req.UserIDs,
 		Body:        req.Body,
 		Headline:    req.Headline,
 		MessageType: req.MessageType,
@@ -377,8 +736,15 @@ func (h *NotificationHandler) validateRequest(req *models.NotificationRequest) e
 		return fiber.NewError(fiber.StatusBadRequest, "Tenant ID is required")
 	}
 
-	if len(req.Recipients) == 0 {
-		return fiber.NewError(fiber.StatusBadRequest, "Recipients list cannot be empty")
+	if len(req.Recipients) == 0 && len(req.UserIDs) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Recipients or user IDs are required")
+	}
+
+	if err := req.ValidateCascade(); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if len(req.Cascade) > 0 {
+		req.Type = req.Cascade[0].Type
 	}
 
 	if req.Type == "" {
@@ -391,11 +757,117 @@ func (h *NotificationHandler) validateRequest(req *models.NotificationRequest) e
 
 	// Validate notification type
 	switch req.Type {
-	case models.TypeEmail, models.TypeSMS, models.TypePush:
+	case models.TypeEmail, models.TypeSMS, models.TypePush, models.TypeInApp, models.TypeTelegram, models.TypeViber, models.TypeWhatsApp:
 		// Valid types
 	default:
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid notification type")
 	}
 
+	if len(req.AllAttachments()) > 0 && req.Type != models.TypeEmail {
+		return fiber.NewError(fiber.StatusBadRequest, "Attachments are only supported for email notifications")
+	}
+
+	for _, attachment := range req.AllAttachments() {
+		if err := validateAttachment(attachment); err != nil {
+			return err
+		}
+	}
+
+	return h.validateAttachmentLimits(req.TenantID, req.AllAttachments())
+}
+
+// validateAttachmentLimits checks attachments against the limits, types and sources the tenant's
+// SMTP config allows, so attachments that can't be sent are refused before they're queued.
+// Tenants without an SMTP provider are checked against the default limits.
+func (h *NotificationHandler) validateAttachmentLimits(tenantID int64, attachments []*models.Attachment) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	var smtpConfig schema.SMTPConfig
+	if partnerConfig, err := h.configRepo.GetByTenantID(context.Background(), tenantID); err == nil {
+		for _, provider := range partnerConfig.EmailProviders {
+			if provider.Enabled && provider.Type == "smtp" {
+				if smtpConfig, err = email.ParseSMTPConfig(provider.Config); err != nil {
+					return fiber.NewError(fiber.StatusBadRequest, "Invalid SMTP config: "+err.Error())
+				}
+				break
+			}
+		}
+	}
+
+	converted := make([]*schema.Attachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		if attachment != nil {
+			converted = append(converted, repository.ToSchemaAttachment(attachment))
+		}
+	}
+	if err := email.ValidateAttachments(&smtpConfig, converted); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid attachment: "+err.Error())
+	}
+	return nil
+}
+
+// reachableUsers splits the user IDs of a cascade request into the ones with an address on at
+// least one of its channels and the ones without
+func (h *NotificationHandler) reachableUsers(ctx context.Context, req *models.NotificationRequest) ([]string, []string, error) {
+	userIDs := repository.UniqueUserIDs(req.UserIDs)
+
+	reachable := make(map[string]bool, len(userIDs))
+	for _, step := range req.Cascade {
+		addresses, err := h.contactRepo.AddressesByUser(ctx, req.TenantID, step.Type, userIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		for userID := range addresses {
+			reachable[userID] = true
+		}
+	}
+
+	var resolved, unresolved []string
+	for _, userID := range userIDs {
+		if reachable[userID] {
+			resolved = append(resolved, userID)
+		} else {
+			unresolved = append(unresolved, userID)
+		}
+	}
+	return resolved, unresolved, nil
+}
+
+func validateAttachment(attachment *models.Attachment) error {
+	if attachment == nil || attachment.Filename == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Attachment filename is required")
+	}
+
+	sources := 0
+	for _, source := range []string{attachment.Content, attachment.URL, attachment.ObjectKey} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fiber.NewError(fiber.StatusBadRequest, "Attachment "+attachment.Filename+" must have exactly one of content, url or object_key")
+	}
+
+	if attachment.Content != "" {
+		if _, err := base64.StdEncoding.DecodeString(attachment.Content); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Attachment "+attachment.Filename+" content must be base64 encoded")
+		}
+	}
+
+	if attachment.URL != "" {
+		parsed, err := url.Parse(attachment.URL)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Attachment "+attachment.Filename+" url must be an https URL")
+		}
+	}
+
+	switch attachment.Disposition {
+	case "", "attachment", "inline":
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "Attachment disposition must be attachment or inline")
+	}
+
 	return nil
 }
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+	"gitlab.smartbet.am/golang/notification/internal/unsubscribe"
+)
+
+type PreferenceHandler struct {
+	preferenceRepo *repository.PreferenceRepository
+	unsubscribe    *unsubscribe.Links
+	logger         *logrus.Logger
+}
+
+func NewPreferenceHandler(preferenceRepo *repository.PreferenceRepository, unsubscribeLinks *unsubscribe.Links, logger *logrus.Logger) *PreferenceHandler {
+	return &PreferenceHandler{
+		preferenceRepo: preferenceRepo,
+		unsubscribe:    unsubscribeLinks,
+		logger:         logger,
+	}
+}
+
+// GetPreferences returns the preferences of a recipient
+// @Summary Get recipient preferences
+// @Description Get whether a recipient, known by user ID, address or both, receives each optional message type (bonus, promo, report) on each channel. Message types without a stored preference are opted in; payment, system and support messages are always sent.
+// @Tags preferences
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id query string false "User ID"
+// @Param address query string false "Email address, phone number or push token"
+// @Success 200 {object} models.PreferencesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /preferences/{tenant_id} [get]
+func (h *PreferenceHandler) GetPreferences(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	userID := c.Query("user_id")
+	address := c.Query("address")
+	if userID == "" && address == "" {
+		return badRequest(c, "Either user_id or address is required")
+	}
+
+	return h.respond(c, tenantID, userID, address)
+}
+
+// UpdatePreferences stores the preferences of a recipient
+// @Summary Update recipient preferences
+// @Description Opt a recipient, known by user ID, address or both, in or out of optional message types (bonus, promo, report) per channel. Preferences not listed are left unchanged. Opt-outs of a user ID without address cover all addresses of the user's contact.
+// @Tags preferences
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param preferences body models.PreferencesRequest true "Preferences to change"
+// @Success 200 {object} models.PreferencesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /preferences/{tenant_id} [put]
+func (h *PreferenceHandler) UpdatePreferences(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	var req models.PreferencesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	if req.UserID == "" && req.Address == "" {
+		return badRequest(c, "Either user_id or address is required")
+	}
+	if len(req.Preferences) == 0 {
+		return badRequest(c, "Preferences list cannot be empty")
+	}
+	for _, pref := range req.Preferences {
+		if pref == nil || !isChannel(pref.Channel) {
+			return badRequest(c, "Invalid channel")
+		}
+		if !pref.MessageType.AllowsOptOut() {
+			return badRequest(c, "Message type "+string(pref.MessageType)+" can't be opted out of")
+		}
+	}
+
+	for _, pref := range req.Preferences {
+		if err := h.preferenceRepo.Set(context.Background(), tenantID, req.UserID, req.Address, pref, "api"); err != nil {
+			logger.WithTenant(tenantID).Error("Failed to save preference", err, map[string]interface{}{
+				"channel":      pref.Channel,
+				"message_type": pref.MessageType,
+			})
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:     "Failed to save preferences",
+				Code:      "SAVE_ERROR",
+				Timestamp: time.Now(),
+			})
+		}
+	}
+
+	return h.respond(c, tenantID, req.UserID, req.Address)
+}
+
+// CreateUnsubscribeLink returns a signed unsubscribe link of a recipient
+// @Summary Create an unsubscribe link
+// @Description Create a signed link that opts the recipient out of a message type on a channel. Notification bodies and headlines can also contain {{unsubscribe_url}}, which is replaced with the link of each recipient when sending.
+// @Tags preferences
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param link body models.UnsubscribeLinkRequest true "Recipient and message type"
+// @Success 200 {object} models.UnsubscribeLinkResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /preferences/{tenant_id}/unsubscribe-link [post]
+func (h *PreferenceHandler) CreateUnsubscribeLink(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	var req models.UnsubscribeLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	if !isChannel(req.Channel) {
+		return badRequest(c, "Invalid channel")
+	}
+	if req.Address == "" {
+		return badRequest(c, "Address is required")
+	}
+	if !req.MessageType.AllowsOptOut() {
+		return badRequest(c, "Message type "+string(req.MessageType)+" can't be opted out of")
+	}
+
+	link, err := h.unsubscribe.URL(&unsubscribe.Token{
+		TenantID:    tenantID,
+		Channel:     req.Channel,
+		Address:     req.Address,
+		MessageType: req.MessageType,
+	})
+	if err != nil {
+		if errors.Is(err, unsubscribe.ErrNotConfigured) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+				Error:     "Unsubscribe links are not configured",
+				Code:      "NOT_CONFIGURED",
+				Timestamp: time.Now(),
+			})
+		}
+		return err
+	}
+
+	return c.JSON(models.UnsubscribeLinkResponse{
+		URL: link,
+	})
+}
+
+// respond returns the full preference matrix of a recipient
+func (h *PreferenceHandler) respond(c *fiber.Ctx, tenantID int64, userID, address string) error {
+	stored, err := h.preferenceRepo.GetByRecipient(context.Background(), tenantID, userID, address)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to get preferences", err, map[string]interface{}{})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to get preferences",
+			Code:      "PREFERENCE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	return c.JSON(models.PreferencesResponse{
+		TenantID:    tenantID,
+		UserID:      userID,
+		Address:     address,
+		Preferences: preferenceMatrix(stored),
+	})
+}
+
+// preferenceMatrix lists a preference for every channel and optional message type, opted in
+// unless a stored preference says otherwise
+func preferenceMatrix(stored []*ent.Preference) []*models.ChannelPreference {
+	byKey := make(map[string]*ent.Preference, len(stored))
+	for _, entry := range stored {
+		byKey[string(entry.Channel)+"/"+entry.MessageType] = entry
+	}
+
+	var matrix []*models.ChannelPreference
+	for _, channel := range []models.NotificationType{models.TypeEmail, models.TypeSMS, models.TypePush, models.TypeInApp, models.TypeTelegram, models.TypeViber, models.TypeWhatsApp} {
+		for _, messageType := range models.OptionalMessageTypes {
+			pref := &models.ChannelPreference{
+				Channel:     channel,
+				MessageType: messageType,
+				OptedIn:     true,
+			}
+			if entry, ok := byKey[string(channel)+"/"+string(messageType)]; ok {
+				pref.OptedIn = entry.OptedIn
+				pref.Source = entry.Source
+				pref.UpdatedAt = &entry.UpdateTime
+			}
+			matrix = append(matrix, pref)
+		}
+	}
+	return matrix
+}
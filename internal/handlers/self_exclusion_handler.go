@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+)
+
+type SelfExclusionHandler struct {
+	selfExclusionRepo *repository.SelfExclusionRepository
+	logger            *logrus.Logger
+}
+
+func NewSelfExclusionHandler(selfExclusionRepo *repository.SelfExclusionRepository, logger *logrus.Logger) *SelfExclusionHandler {
+	return &SelfExclusionHandler{
+		selfExclusionRepo: selfExclusionRepo,
+		logger:            logger,
+	}
+}
+
+// ListSelfExclusions returns a page of a tenant's self-exclusion registry
+// @Summary List self-excluded addresses
+// @Description List the addresses of self-excluded players of a tenant, newest first. Ended exclusions are included unless active=true.
+// @Tags self-exclusions
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id query string false "Player ID"
+// @Param address query string false "Part of the address"
+// @Param active query bool false "Only exclusions in effect now"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} models.SelfExclusionListResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /self-exclusions/{tenant_id} [get]
+func (h *SelfExclusionHandler) ListSelfExclusions(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	filter := repository.SelfExclusionFilter{
+		UserID:     c.Query("user_id"),
+		Address:    c.Query("address"),
+		ActiveOnly: c.QueryBool("active", false),
+		Limit:      c.QueryInt("limit", defaultSuppressionPageSize),
+		Offset:     c.QueryInt("offset", 0),
+	}
+	if filter.Limit <= 0 || filter.Limit > maxSuppressionPageSize {
+		filter.Limit = defaultSuppressionPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	entries, total, err := h.selfExclusionRepo.List(context.Background(), tenantID, filter)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to list self-exclusions", err, map[string]interface{}{})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to list self-exclusions",
+			Code:      "SELF_EXCLUSION_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	response := models.SelfExclusionListResponse{
+		TenantID:       tenantID,
+		Total:          total,
+		Limit:          filter.Limit,
+		Offset:         filter.Offset,
+		SelfExclusions: make([]*models.SelfExclusionResponse, 0, len(entries)),
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		response.SelfExclusions = append(response.SelfExclusions, selfExclusionToResponse(entry, now))
+	}
+
+	return c.JSON(response)
+}
+
+// SyncSelfExclusion stores the self-exclusion of a player
+// @Summary Sync a self-exclusion
+// @Description Exclude the addresses of a self-excluded player from promo and bonus notifications of a tenant, on every channel, from starts_at (default now) until ends_at (indefinite when omitted). Syncing an address again replaces its period, so an exclusion is lifted by sending an ends_at. Notifications to excluded addresses are marked SUPPRESSED and recorded in the audit log.
+// @Tags self-exclusions
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param self_exclusion body models.SelfExclusionRequest true "Self-exclusion"
+// @Success 200 {object} models.SelfExclusionSyncResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /self-exclusions/{tenant_id} [put]
+func (h *SelfExclusionHandler) SyncSelfExclusion(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	var req models.SelfExclusionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+	if err := req.Validate(); err != nil {
+		return badRequest(c, err.Error())
+	}
+
+	entries, err := h.selfExclusionRepo.Sync(context.Background(), tenantID, &req, "api")
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to save self-exclusion", err, map[string]interface{}{
+			"user_id": req.UserID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to save self-exclusion",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	logger.WithTenant(tenantID).Info("Self-exclusion synced", map[string]interface{}{
+		"user_id":   req.UserID,
+		"addresses": len(entries),
+		"source":    "api",
+	})
+
+	response := models.SelfExclusionSyncResponse{
+		TenantID:       tenantID,
+		SelfExclusions: make([]*models.SelfExclusionResponse, 0, len(entries)),
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		response.SelfExclusions = append(response.SelfExclusions, selfExclusionToResponse(entry, now))
+	}
+
+	return c.JSON(response)
+}
+
+// ListSelfExclusionBlocks returns a page of a tenant's self-exclusion audit log
+// @Summary List blocked notifications
+// @Description List the promo and bonus notifications of a tenant that were withheld from self-excluded players, newest first, for compliance audits
+// @Tags self-exclusions
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param address query string false "Address"
+// @Param from query string false "Start of the window (RFC 3339)"
+// @Param to query string false "End of the window (RFC 3339)"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} models.SelfExclusionBlockListResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /self-exclusions/{tenant_id}/blocks [get]
+func (h *SelfExclusionHandler) ListSelfExclusionBlocks(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	filter := repository.SelfExclusionBlockFilter{
+		Address: c.Query("address"),
+		Limit:   c.QueryInt("limit", defaultSuppressionPageSize),
+		Offset:  c.QueryInt("offset", 0),
+	}
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return badRequest(c, "Invalid "+param+" time, expected RFC 3339")
+			}
+			*target = &parsed
+		}
+	}
+	if filter.Limit <= 0 || filter.Limit > maxSuppressionPageSize {
+		filter.Limit = defaultSuppressionPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	entries, total, err := h.selfExclusionRepo.ListBlocks(context.Background(), tenantID, filter)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to list self-exclusion blocks", err, map[string]interface{}{})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to list blocked notifications",
+			Code:      "SELF_EXCLUSION_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	response := models.SelfExclusionBlockListResponse{
+		TenantID: tenantID,
+		Total:    total,
+		Limit:    filter.Limit,
+		Offset:   filter.Offset,
+		Blocks:   make([]*models.SelfExclusionBlockResponse, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		response.Blocks = append(response.Blocks, &models.SelfExclusionBlockResponse{
+			ID:              entry.ID,
+			NotificationID:  entry.NotificationID,
+			RequestID:       entry.RequestID,
+			Channel:         models.NotificationType(entry.Channel),
+			Address:         entry.Address,
+			MessageType:     models.MessageType(entry.MessageType),
+			SelfExclusionID: entry.SelfExclusionID,
+			UserID:          entry.UserID,
+			BlockedAt:       entry.BlockedAt,
+		})
+	}
+
+	return c.JSON(response)
+}
+
+func selfExclusionToResponse(entry *ent.SelfExclusion, now time.Time) *models.SelfExclusionResponse {
+	return &models.SelfExclusionResponse{
+		ID:        entry.ID,
+		TenantID:  entry.TenantID,
+		UserID:    entry.UserID,
+		Address:   entry.Address,
+		StartsAt:  entry.StartsAt,
+		EndsAt:    entry.EndsAt,
+		Active:    !entry.StartsAt.After(now) && (entry.EndsAt == nil || entry.EndsAt.After(now)),
+		Source:    entry.Source,
+		Detail:    entry.Detail,
+		CreatedAt: entry.CreateTime,
+		UpdatedAt: entry.UpdateTime,
+	}
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/realtime"
+)
+
+const (
+	// streamTokenLocal carries the verified token from the upgrade request to the WebSocket
+	streamTokenLocal = "stream_token"
+	// streamWriteWait bounds how long writing one event to a client may take
+	streamWriteWait = 10 * time.Second
+	// streamPongWait is how long a WebSocket may stay silent before it is considered gone
+	streamPongWait = 60 * time.Second
+	// streamPingPeriod keeps idle connections alive through proxies, below streamPongWait
+	streamPingPeriod = 25 * time.Second
+	// maxStreamMessageSize limits what clients may send, streams are one-way
+	maxStreamMessageSize = 512
+)
+
+type StreamHandler struct {
+	hub       *realtime.Hub
+	tokens    *realtime.Tokens
+	websocket fiber.Handler
+	logger    *logrus.Logger
+}
+
+func NewStreamHandler(hub *realtime.Hub, tokens *realtime.Tokens, logger *logrus.Logger) *StreamHandler {
+	h := &StreamHandler{
+		hub:    hub,
+		tokens: tokens,
+		logger: logger,
+	}
+	h.websocket = websocket.New(h.serveWebSocket)
+	return h
+}
+
+// IssueStreamToken issues a token opening the real-time stream of a user's inbox
+// @Summary Issue a stream token
+// @Description Issues a short-lived token scoped to one user that opens the real-time stream of their inbox at /stream/ws or /stream/sse. Browsers can't send the bearer token on WebSocket and EventSource requests, so the partner's backend requests a stream token on behalf of the logged-in user and hands it to the frontend.
+// @Tags inbox
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.StreamTokenResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /inbox/{tenant_id}/users/{user_id}/stream-token [post]
+func (h *StreamHandler) IssueStreamToken(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+	userID := c.Params("user_id")
+
+	token, expiresAt, err := h.tokens.Issue(tenantID, userID)
+	if err != nil {
+		if errors.Is(err, realtime.ErrNotConfigured) {
+			return streamNotConfigured(c)
+		}
+		logger.WithTenant(tenantID).Error("Failed to issue stream token", err, map[string]interface{}{
+			"user_id": userID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to issue stream token",
+			Code:      "STREAM_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	return c.JSON(models.StreamTokenResponse{
+		TenantID:  tenantID,
+		UserID:    userID,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// StreamWebSocket streams new in-app notifications of a user over a WebSocket
+// @Summary Inbox stream over WebSocket
+// @Description Upgrades to a WebSocket pushing every new in-app notification of the user the stream token was issued for as a JSON text message {"type":"notification","message":{...}}. The token is only checked when the stream opens. Messages sent while the user is offline stay in the inbox, clients reload it after reconnecting. Served outside the /api/v1 base path without bearer authentication.
+// @Tags inbox
+// @Param token query string true "Stream token"
+// @Success 101 {object} models.StreamEvent "Switching protocols, then one message per notification"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 426 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /stream/ws [get]
+func (h *StreamHandler) StreamWebSocket(c *fiber.Ctx) error {
+	token, err := h.tokens.Verify(c.Query("token"))
+	if err != nil {
+		return h.streamTokenError(c, err)
+	}
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(models.ErrorResponse{
+			Error:     "WebSocket upgrade required",
+			Code:      "UPGRADE_REQUIRED",
+			Timestamp: time.Now(),
+		})
+	}
+
+	c.Locals(streamTokenLocal, token)
+	return h.websocket(c)
+}
+
+// StreamSSE streams new in-app notifications of a user as server-sent events
+// @Summary Inbox stream over server-sent events
+// @Description Fallback for clients that can't use WebSockets. Streams every new in-app notification of the user the stream token was issued for as a "notification" event whose data is {"type":"notification","message":{...}}, with comment lines keeping idle connections open. Served outside the /api/v1 base path without bearer authentication.
+// @Tags inbox
+// @Produce text/event-stream
+// @Param token query string true "Stream token"
+// @Success 200 {object} models.StreamEvent "One event per notification"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /stream/sse [get]
+func (h *StreamHandler) StreamSSE(c *fiber.Ctx) error {
+	token, err := h.tokens.Verify(c.Query("token"))
+	if err != nil {
+		return h.streamTokenError(c, err)
+	}
+
+	stream := h.hub.Open(token.TenantID, token.UserID)
+	if stream == nil {
+		return streamUnavailable(c)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// The server's write timeout covers whole responses, each write gets its own deadline instead
+	conn := c.Context().Conn()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.hub.Close(stream)
+
+		ticker := time.NewTicker(streamPingPeriod)
+		defer ticker.Stop()
+
+		write := func(chunk string) bool {
+			if err := conn.SetWriteDeadline(time.Now().Add(streamWriteWait)); err != nil {
+				return false
+			}
+			if _, err := w.WriteString(chunk); err != nil {
+				return false
+			}
+			return w.Flush() == nil
+		}
+
+		if !write(": connected\n\n") {
+			return
+		}
+		for {
+			select {
+			case payload, ok := <-stream.Events:
+				if !ok {
+					return
+				}
+				if !write("event: " + models.StreamEventNotification + "\ndata: " + string(payload) + "\n\n") {
+					return
+				}
+			case <-ticker.C:
+				if !write(": ping\n\n") {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// serveWebSocket pushes the events of the user's stream until either side closes it
+func (h *StreamHandler) serveWebSocket(conn *websocket.Conn) {
+	token, ok := conn.Locals(streamTokenLocal).(*realtime.StreamToken)
+	if !ok {
+		return
+	}
+
+	stream := h.hub.Open(token.TenantID, token.UserID)
+	if stream == nil {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "shutting down"),
+			time.Now().Add(streamWriteWait))
+		return
+	}
+	defer h.hub.Close(stream)
+
+	// Clients don't send anything, reading only processes pongs and notices the close
+	conn.SetReadLimit(maxStreamMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	})
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(streamPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case payload, ok := <-stream.Events:
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down"),
+					time.Now().Add(streamWriteWait))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func (h *StreamHandler) streamTokenError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, realtime.ErrNotConfigured) {
+		return streamNotConfigured(c)
+	}
+
+	message := "Invalid stream token"
+	if errors.Is(err, realtime.ErrExpiredToken) {
+		message = "Stream token expired"
+	}
+	return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+		Error:     message,
+		Code:      "INVALID_STREAM_TOKEN",
+		Timestamp: time.Now(),
+	})
+}
+
+func streamNotConfigured(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+		Error:     "Real-time streams are not configured",
+		Code:      "STREAM_NOT_CONFIGURED",
+		Timestamp: time.Now(),
+	})
+}
+
+func streamUnavailable(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+		Error:     "Server is shutting down",
+		Code:      "STREAM_UNAVAILABLE",
+		Timestamp: time.Now(),
+	})
+}
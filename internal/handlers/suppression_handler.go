@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"context"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+)
+
+const (
+	defaultSuppressionPageSize = 50
+	maxSuppressionPageSize     = 500
+	maxSuppressionImportSize   = 10000
+)
+
+type SuppressionHandler struct {
+	suppressionRepo *repository.SuppressionRepository
+	logger          *logrus.Logger
+}
+
+func NewSuppressionHandler(suppressionRepo *repository.SuppressionRepository, logger *logrus.Logger) *SuppressionHandler {
+	return &SuppressionHandler{
+		suppressionRepo: suppressionRepo,
+		logger:          logger,
+	}
+}
+
+// ListSuppressions returns a page of a tenant's suppression list
+// @Summary List suppressed addresses
+// @Description List the addresses notifications of a tenant are not sent to, newest first. Expired entries are included until they are removed.
+// @Tags suppressions
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param channel query string false "Channel" Enums(EMAIL, SMS, PUSH, INAPP, TELEGRAM, VIBER, WHATSAPP)
+// @Param reason query string false "Reason" Enums(hard_bounce, complaint, unsubscribe, manual)
+// @Param address query string false "Part of the address"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} models.SuppressionListResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /suppressions/{tenant_id} [get]
+func (h *SuppressionHandler) ListSuppressions(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	filter := repository.SuppressionFilter{
+		Channel: models.NotificationType(c.Query("channel")),
+		Reason:  models.SuppressionReason(c.Query("reason")),
+		Address: c.Query("address"),
+		Limit:   c.QueryInt("limit", defaultSuppressionPageSize),
+		Offset:  c.QueryInt("offset", 0),
+	}
+
+	if filter.Channel != "" && !isChannel(filter.Channel) {
+		return badRequest(c, "Invalid channel")
+	}
+	if filter.Reason != "" && !filter.Reason.IsValid() {
+		return badRequest(c, "Invalid reason")
+	}
+	if filter.Limit <= 0 || filter.Limit > maxSuppressionPageSize {
+		filter.Limit = defaultSuppressionPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	entries, total, err := h.suppressionRepo.List(context.Background(), tenantID, filter)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to list suppressions", err, map[string]interface{}{})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to list suppressions",
+			Code:      "SUPPRESSION_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	response := models.SuppressionListResponse{
+		TenantID:     tenantID,
+		Total:        total,
+		Limit:        filter.Limit,
+		Offset:       filter.Offset,
+		Suppressions: make([]*models.SuppressionResponse, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		response.Suppressions = append(response.Suppressions, suppressionToResponse(entry))
+	}
+
+	return c.JSON(response)
+}
+
+// GetSuppression returns a single suppression of a tenant
+// @Summary Get a suppressed address
+// @Description Get a single entry of a tenant's suppression list
+// @Tags suppressions
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param id path int true "Suppression ID"
+// @Success 200 {object} models.SuppressionResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /suppressions/{tenant_id}/{id} [get]
+func (h *SuppressionHandler) GetSuppression(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return badRequest(c, "Invalid suppression ID")
+	}
+
+	entry, err := h.suppressionRepo.Get(context.Background(), tenantID, id)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return suppressionNotFound(c)
+		}
+		logger.WithTenant(tenantID).Error("Failed to get suppression", err, map[string]interface{}{
+			"suppression_id": id,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to get suppression",
+			Code:      "SUPPRESSION_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	return c.JSON(suppressionToResponse(entry))
+}
+
+// CreateSuppression adds an address to a tenant's suppression list
+// @Summary Suppress an address
+// @Description Add an address to a tenant's suppression list. Notifications to it are marked SUPPRESSED instead of being sent until the entry expires or is deleted. Suppressing an address again replaces its reason and expiry.
+// @Tags suppressions
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param suppression body models.SuppressionRequest true "Suppression (tenant_id is taken from the path)"
+// @Success 201 {object} models.SuppressionResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /suppressions/{tenant_id} [post]
+func (h *SuppressionHandler) CreateSuppression(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	var req models.SuppressionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	if req.Reason == "" {
+		req.Reason = models.SuppressionReasonManual
+	}
+	if !isChannel(req.Channel) {
+		return badRequest(c, "Invalid channel")
+	}
+	if !req.Reason.IsValid() {
+		return badRequest(c, "Invalid reason")
+	}
+	if !isSuppressionAddress(req.Channel, req.Address) {
+		return badRequest(c, "Invalid address")
+	}
+
+	req.TenantID = tenantID
+	req.Source = "api"
+
+	entry, err := h.suppressionRepo.Suppress(context.Background(), &req)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to save suppression", err, map[string]interface{}{
+			"channel": req.Channel,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to save suppression",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(suppressionToResponse(entry))
+}
+
+// ImportSuppressions adds many addresses to a tenant's suppression list
+// @Summary Import suppressed addresses
+// @Description Add up to 10000 addresses of one channel to a tenant's suppression list with the same reason and expiry. Existing entries are updated; invalid addresses are skipped and returned.
+// @Tags suppressions
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param import body models.SuppressionImportRequest true "Addresses to suppress"
+// @Success 200 {object} models.SuppressionImportResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /suppressions/{tenant_id}/import [post]
+func (h *SuppressionHandler) ImportSuppressions(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	var req models.SuppressionImportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest(c, "Invalid request body")
+	}
+
+	if req.Reason == "" {
+		req.Reason = models.SuppressionReasonManual
+	}
+	if !isChannel(req.Channel) {
+		return badRequest(c, "Invalid channel")
+	}
+	if !req.Reason.IsValid() {
+		return badRequest(c, "Invalid reason")
+	}
+	if len(req.Addresses) == 0 {
+		return badRequest(c, "Addresses list cannot be empty")
+	}
+	if len(req.Addresses) > maxSuppressionImportSize {
+		return badRequest(c, "Too many addresses, at most 10000 can be imported at once")
+	}
+
+	response := models.SuppressionImportResponse{TenantID: tenantID}
+
+	valid := make([]string, 0, len(req.Addresses))
+	for _, address := range req.Addresses {
+		if isSuppressionAddress(req.Channel, address) {
+			valid = append(valid, address)
+		} else {
+			response.Invalid = append(response.Invalid, address)
+		}
+	}
+	req.Addresses = valid
+
+	response.Imported, err = h.suppressionRepo.Import(context.Background(), tenantID, &req)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to import suppressions", err, map[string]interface{}{
+			"channel":  req.Channel,
+			"imported": response.Imported,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to import suppressions",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+			Details:   response,
+		})
+	}
+
+	logger.WithTenant(tenantID).Info("Suppressions imported", map[string]interface{}{
+		"channel":  req.Channel,
+		"reason":   req.Reason,
+		"imported": response.Imported,
+		"invalid":  len(response.Invalid),
+	})
+
+	return c.JSON(response)
+}
+
+// DeleteSuppression removes an address from a tenant's suppression list
+// @Summary Remove a suppressed address
+// @Description Remove an entry from a tenant's suppression list so notifications are sent to the address again
+// @Tags suppressions
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param id path int true "Suppression ID"
+// @Success 200 {object} models.ConfigSuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /suppressions/{tenant_id}/{id} [delete]
+func (h *SuppressionHandler) DeleteSuppression(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return invalidTenantID(c)
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return badRequest(c, "Invalid suppression ID")
+	}
+
+	deleted, err := h.suppressionRepo.Delete(context.Background(), tenantID, id)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to delete suppression", err, map[string]interface{}{
+			"suppression_id": id,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:     "Failed to delete suppression",
+			Code:      "SAVE_ERROR",
+			Timestamp: time.Now(),
+		})
+	}
+	if !deleted {
+		return suppressionNotFound(c)
+	}
+
+	return c.JSON(models.ConfigSuccessResponse{
+		Message:   "Suppression deleted successfully",
+		Status:    "success",
+		TenantID:  tenantID,
+		Timestamp: time.Now(),
+	})
+}
+
+func suppressionToResponse(entry *ent.Suppression) *models.SuppressionResponse {
+	return &models.SuppressionResponse{
+		ID:             entry.ID,
+		TenantID:       entry.TenantID,
+		Channel:        models.NotificationType(entry.Channel),
+		Address:        entry.Address,
+		Reason:         models.SuppressionReason(entry.Reason),
+		Source:         entry.Source,
+		Detail:         entry.Detail,
+		NotificationID: entry.NotificationID,
+		ExpiresAt:      entry.ExpiresAt,
+		CreatedAt:      entry.CreateTime,
+		UpdatedAt:      entry.UpdateTime,
+	}
+}
+
+// isChannel reports whether channel is one of the notification types
+func isChannel(channel models.NotificationType) bool {
+	switch channel {
+	case models.TypeEmail, models.TypeSMS, models.TypePush, models.TypeInApp, models.TypeTelegram, models.TypeViber, models.TypeWhatsApp:
+		return true
+	}
+	return false
+}
+
+// isSuppressionAddress checks that an address can receive notifications of the channel
+func isSuppressionAddress(channel models.NotificationType, address string) bool {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return false
+	}
+	if channel == models.TypeEmail {
+		parsed, err := mail.ParseAddress(address)
+		return err == nil && parsed.Address == address
+	}
+	if channel == models.TypeTelegram {
+		return telegramChatIDPattern.MatchString(address)
+	}
+	return true
+}
+
+func invalidTenantID(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:     "Invalid tenant ID",
+		Code:      "INVALID_TENANT_ID",
+		Timestamp: time.Now(),
+	})
+}
+
+func badRequest(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:     message,
+		Code:      "INVALID_REQUEST",
+		Timestamp: time.Now(),
+	})
+}
+
+func suppressionNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:     "Suppression not found",
+		Code:      "NOT_FOUND",
+		Timestamp: time.Now(),
+	})
+}
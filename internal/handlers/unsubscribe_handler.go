@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+	"gitlab.smartbet.am/golang/notification/internal/unsubscribe"
+)
+
+// unsubscribePage renders every page of the hosted unsubscribe flow. The confirmation form
+// posts back to the link itself, so no token is ever placed in the page.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Unsubscribe</title>
+</head>
+<body style="font-family: sans-serif; max-width: 32em; margin: 4em auto; padding: 0 1em;">
+{{if .Confirm}}<h1>Unsubscribe</h1>
+<p>Stop sending {{.MessageType}} messages to <strong>{{.Address}}</strong> by {{.Channel}}?</p>
+<form method="post">
+<input type="hidden" name="List-Unsubscribe" value="One-Click">
+<button type="submit">Unsubscribe</button>
+</form>
+{{else}}<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{end}}</body>
+</html>
+`))
+
+type unsubscribePageData struct {
+	Confirm     bool
+	Title       string
+	Message     string
+	Address     string
+	Channel     string
+	MessageType string
+}
+
+// UnsubscribeHandler serves the public pages behind the signed unsubscribe links
+type UnsubscribeHandler struct {
+	preferenceRepo *repository.PreferenceRepository
+	unsubscribe    *unsubscribe.Links
+	logger         *logrus.Logger
+}
+
+func NewUnsubscribeHandler(preferenceRepo *repository.PreferenceRepository, unsubscribeLinks *unsubscribe.Links, logger *logrus.Logger) *UnsubscribeHandler {
+	return &UnsubscribeHandler{
+		preferenceRepo: preferenceRepo,
+		unsubscribe:    unsubscribeLinks,
+		logger:         logger,
+	}
+}
+
+// ShowUnsubscribe asks the recipient to confirm unsubscribing
+// @Summary Unsubscribe confirmation page
+// @Description Shows an HTML page asking the recipient to confirm the opt-out carried by a signed unsubscribe link. Opening the link doesn't unsubscribe by itself, as mail scanners prefetch links. Served outside the /api/v1 base path without bearer authentication.
+// @Tags unsubscribe
+// @Produce html
+// @Param token path string true "Signed unsubscribe token"
+// @Success 200 {string} string "Confirmation page"
+// @Failure 400 {string} string "Invalid link page"
+// @Failure 503 {string} string "Unavailable page"
+// @Router /u/{token} [get]
+func (h *UnsubscribeHandler) ShowUnsubscribe(c *fiber.Ctx) error {
+	token, status, page := h.verify(c.Params("token"))
+	if token == nil {
+		return renderUnsubscribePage(c, status, page)
+	}
+
+	return renderUnsubscribePage(c, fiber.StatusOK, &unsubscribePageData{
+		Confirm:     true,
+		Address:     token.Address,
+		Channel:     string(token.Channel),
+		MessageType: string(token.MessageType),
+	})
+}
+
+// Unsubscribe opts the recipient out
+// @Summary Unsubscribe
+// @Description Opts the recipient of a signed unsubscribe link out of the message type on the channel. Accepts RFC 8058 one-click posts (List-Unsubscribe=One-Click) sent by mail clients as well as the confirmation form, and is safe to repeat. Served outside the /api/v1 base path without bearer authentication.
+// @Tags unsubscribe
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param token path string true "Signed unsubscribe token"
+// @Success 200 {string} string "Unsubscribed page"
+// @Failure 400 {string} string "Invalid link page"
+// @Failure 500 {string} string "Error page"
+// @Failure 503 {string} string "Unavailable page"
+// @Router /u/{token} [post]
+func (h *UnsubscribeHandler) Unsubscribe(c *fiber.Ctx) error {
+	token, status, page := h.verify(c.Params("token"))
+	if token == nil {
+		return renderUnsubscribePage(c, status, page)
+	}
+
+	pref := &models.ChannelPreference{
+		Channel:     token.Channel,
+		MessageType: token.MessageType,
+		OptedIn:     false,
+	}
+	if err := h.preferenceRepo.Set(context.Background(), token.TenantID, "", token.Address, pref, "unsubscribe_link"); err != nil {
+		logger.WithTenant(token.TenantID).Error("Failed to save unsubscribe", err, map[string]interface{}{
+			"channel":      token.Channel,
+			"message_type": token.MessageType,
+		})
+		return renderUnsubscribePage(c, fiber.StatusInternalServerError, &unsubscribePageData{
+			Title:   "Something went wrong",
+			Message: "We couldn't process your request. Please try again later.",
+		})
+	}
+
+	logger.WithTenant(token.TenantID).Info("Recipient unsubscribed", map[string]interface{}{
+		"channel":      token.Channel,
+		"message_type": token.MessageType,
+		"one_click":    c.FormValue("List-Unsubscribe") == "One-Click",
+	})
+
+	return renderUnsubscribePage(c, fiber.StatusOK, &unsubscribePageData{
+		Title:   "You have been unsubscribed",
+		Message: "You will no longer receive " + string(token.MessageType) + " messages at " + token.Address + " by " + string(token.Channel) + ".",
+	})
+}
+
+// verify decodes a signed token, returning the status and page to respond with when it can't be used
+func (h *UnsubscribeHandler) verify(value string) (*unsubscribe.Token, int, *unsubscribePageData) {
+	token, err := h.unsubscribe.Verify(value)
+	if errors.Is(err, unsubscribe.ErrNotConfigured) {
+		return nil, fiber.StatusServiceUnavailable, &unsubscribePageData{
+			Title:   "Unsubscribing is unavailable",
+			Message: "Please try again later.",
+		}
+	}
+	if err != nil || !isChannel(token.Channel) || !token.MessageType.AllowsOptOut() {
+		return nil, fiber.StatusBadRequest, &unsubscribePageData{
+			Title:   "Invalid unsubscribe link",
+			Message: "This link is not valid. Please use the link from a recent message.",
+		}
+	}
+	return token, 0, nil
+}
+
+func renderUnsubscribePage(c *fiber.Ctx, status int, page *unsubscribePageData) error {
+	var buf bytes.Buffer
+	if err := unsubscribePage.Execute(&buf, page); err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Type("html", "utf-8")
+	return c.Status(status).Send(buf.Bytes())
+}
@@ -0,0 +1,645 @@
+// File: internal/handlers/webhook_handler.go
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+	"gitlab.smartbet.am/golang/notification/internal/config"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/providers/email"
+	"gitlab.smartbet.am/golang/notification/internal/providers/sms"
+	"gitlab.smartbet.am/golang/notification/internal/providers/viber"
+	"gitlab.smartbet.am/golang/notification/internal/providers/whatsapp"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+	"gitlab.smartbet.am/golang/notification/internal/services"
+)
+
+// WebhookHandler receives provider callbacks. The routes are public, every request is
+// authenticated with the provider's own signature scheme instead.
+type WebhookHandler struct {
+	config      *config.Config
+	configRepo  *repository.PartnerConfigRepository
+	receipts    *services.DeliveryReceiptService
+	sessions    *repository.WhatsAppSessionRepository
+	snsVerifier *email.SNSVerifier
+	logger      *logrus.Logger
+}
+
+func NewWebhookHandler(
+	config *config.Config,
+	configRepo *repository.PartnerConfigRepository,
+	receipts *services.DeliveryReceiptService,
+	sessions *repository.WhatsAppSessionRepository,
+	logger *logrus.Logger,
+) *WebhookHandler {
+	return &WebhookHandler{
+		config:      config,
+		configRepo:  configRepo,
+		receipts:    receipts,
+		sessions:    sessions,
+		snsVerifier: email.NewSNSVerifier(),
+		logger:      logger,
+	}
+}
+
+// TwilioStatusCallback handles Twilio message status callbacks
+// @Summary Twilio status callback
+// @Description Receives Twilio StatusCallback posts, validates the X-Twilio-Signature with the tenant's auth token and advances the notification to DELIVERED or UNDELIVERED. Served outside the /api/v1 base path without bearer authentication.
+// @Tags webhooks
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param X-Twilio-Signature header string true "Twilio request signature"
+// @Param MessageSid formData string true "Twilio message SID"
+// @Param MessageStatus formData string true "Twilio message status"
+// @Param ErrorCode formData string false "Twilio error code"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/twilio/{tenant_id}/status [post]
+func (h *WebhookHandler) TwilioStatusCallback(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID",
+			"code":  "INVALID_TENANT_ID",
+		})
+	}
+
+	params, err := url.ParseQuery(string(c.Body()))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid form body",
+			"code":  "INVALID_REQUEST",
+		})
+	}
+
+	authToken, err := h.twilioAuthToken(tenantID)
+	if err != nil {
+		logger.WithTenant(tenantID).Error("Failed to get Twilio auth token for status callback", err, map[string]interface{}{})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid signature",
+			"code":  "INVALID_SIGNATURE",
+		})
+	}
+
+	if !sms.ValidateTwilioSignature(authToken, h.callbackURL(c), params, c.Get("X-Twilio-Signature")) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid signature",
+			"code":  "INVALID_SIGNATURE",
+		})
+	}
+
+	messageSID := params.Get("MessageSid")
+	messageStatus := params.Get("MessageStatus")
+	if messageSID == "" || messageStatus == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "MessageSid and MessageStatus are required",
+			"code":  "INVALID_REQUEST",
+		})
+	}
+
+	receipt := &services.DeliveryReceipt{
+		TenantID:          tenantID,
+		ProviderType:      "twilio",
+		ProviderMessageID: messageSID,
+		ProviderStatus:    messageStatus,
+	}
+	if status, final := sms.TwilioDeliveryStatus(messageStatus); final {
+		receipt.Status = status
+	}
+	if errorCode := params.Get("ErrorCode"); errorCode != "" {
+		receipt.ErrorCode = errorCode
+		receipt.ErrorMessage = fmt.Sprintf("Twilio delivery failed: %s (error code: %s)", messageStatus, errorCode)
+	}
+
+	if err := h.receipts.Apply(context.Background(), receipt); err != nil {
+		// Unknown messages are acknowledged so Twilio doesn't keep retrying them
+		if errors.Is(err, services.ErrUnknownProviderMessage) {
+			logger.WithTenant(tenantID).Info("Status callback for unknown Twilio message", map[string]interface{}{
+				"message_sid": messageSID,
+				"status":      messageStatus,
+			})
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+
+		logger.WithTenant(tenantID).Error("Failed to apply Twilio status callback", err, map[string]interface{}{
+			"message_sid": messageSID,
+			"status":      messageStatus,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to process status callback",
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// NikitaDeliveryReport handles delivery reports pushed by the Nikita SMS API
+// @Summary Nikita delivery report
+// @Description Receives Nikita delivery reports (DLR) as JSON, form or query parameters, authenticated with the dlr_token of the tenant's custom SMS provider. The message-id is matched against the ID the notification was sent with and the notification advances to DELIVERED or UNDELIVERED, recording the operator error code. Served outside the /api/v1 base path without bearer authentication.
+// @Tags webhooks
+// @Accept json,x-www-form-urlencoded
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param token query string true "DLR token from the custom provider config"
+// @Param report body sms.NikitaDeliveryReport false "Delivery report, alternatively sent as form or query parameters"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/nikita/{tenant_id}/dlr [post]
+func (h *WebhookHandler) NikitaDeliveryReport(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID",
+			"code":  "INVALID_TENANT_ID",
+		})
+	}
+
+	provider, err := h.nikitaProvider(tenantID)
+	if err != nil || !provider.VerifyDLRToken(c.Query("token")) {
+		if err != nil {
+			logger.WithTenant(tenantID).Error("Failed to get Nikita provider for delivery report", err, map[string]interface{}{})
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid token",
+			"code":  "INVALID_TOKEN",
+		})
+	}
+
+	report := &sms.NikitaDeliveryReport{}
+	if len(c.Body()) > 0 {
+		err = c.BodyParser(report)
+	} else {
+		err = c.QueryParser(report)
+	}
+	if err != nil || report.MessageID == "" || report.Status == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "message-id and status are required",
+			"code":  "INVALID_REQUEST",
+		})
+	}
+
+	if err := h.receipts.Apply(context.Background(), services.NikitaReceipt(tenantID, report)); err != nil {
+		// Unknown messages are acknowledged so Nikita doesn't keep retrying them
+		if errors.Is(err, services.ErrUnknownProviderMessage) {
+			logger.WithTenant(tenantID).Info("Delivery report for unknown Nikita message", map[string]interface{}{
+				"message_id": report.MessageID,
+				"status":     report.Status,
+			})
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+
+		logger.WithTenant(tenantID).Error("Failed to apply Nikita delivery report", err, map[string]interface{}{
+			"message_id": report.MessageID,
+			"status":     report.Status,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to process delivery report",
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ViberDeliveryReport handles Viber delivery and seen reports pushed by the Nikita broker API
+// @Summary Viber delivery report
+// @Description Receives Nikita Viber delivery reports as JSON, form or query parameters, authenticated with the dlr_token of the tenant's Viber provider. The message-id is matched against the ID the notification was sent with and the notification advances to DELIVERED, OPENED once the message was seen, or UNDELIVERED. With the SMS fallback an undelivered Viber message waits for the report of the SMS sent in its place (channel=sms). Served outside the /api/v1 base path without bearer authentication.
+// @Tags webhooks
+// @Accept json,x-www-form-urlencoded
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param token query string true "DLR token from the Viber provider config"
+// @Param report body viber.DeliveryReport false "Delivery report, alternatively sent as form or query parameters"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/viber/{tenant_id}/dlr [post]
+func (h *WebhookHandler) ViberDeliveryReport(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID",
+			"code":  "INVALID_TENANT_ID",
+		})
+	}
+
+	provider, err := h.viberProvider(tenantID)
+	if err != nil || !provider.VerifyDLRToken(c.Query("token")) {
+		if err != nil {
+			logger.WithTenant(tenantID).Error("Failed to get Viber provider for delivery report", err, map[string]interface{}{})
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid token",
+			"code":  "INVALID_TOKEN",
+		})
+	}
+
+	report := &viber.DeliveryReport{}
+	if len(c.Body()) > 0 {
+		err = c.BodyParser(report)
+	} else {
+		err = c.QueryParser(report)
+	}
+	if err != nil || report.MessageID == "" || report.Status == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "message-id and status are required",
+			"code":  "INVALID_REQUEST",
+		})
+	}
+
+	if err := h.receipts.Apply(context.Background(), services.ViberReceipt(tenantID, report)); err != nil {
+		// Unknown messages are acknowledged so Nikita doesn't keep retrying them
+		if errors.Is(err, services.ErrUnknownProviderMessage) {
+			logger.WithTenant(tenantID).Info("Delivery report for unknown Viber message", map[string]interface{}{
+				"message_id": report.MessageID,
+				"status":     report.Status,
+				"channel":    report.Channel,
+			})
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+
+		logger.WithTenant(tenantID).Error("Failed to apply Viber delivery report", err, map[string]interface{}{
+			"message_id": report.MessageID,
+			"status":     report.Status,
+			"channel":    report.Channel,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to process delivery report",
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// WhatsAppVerify answers the verification request Meta sends when the webhook is subscribed
+// @Summary WhatsApp webhook verification
+// @Description Echoes hub.challenge when hub.verify_token matches the verify_token of the tenant's WhatsApp provider. Served outside the /api/v1 base path without bearer authentication.
+// @Tags webhooks
+// @Produce plain
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param hub.mode query string true "Always subscribe"
+// @Param hub.verify_token query string true "Verify token from the WhatsApp provider config"
+// @Param hub.challenge query string true "Challenge to echo"
+// @Success 200 {string} string "The challenge"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /webhooks/whatsapp/{tenant_id} [get]
+func (h *WebhookHandler) WhatsAppVerify(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID",
+			"code":  "INVALID_TENANT_ID",
+		})
+	}
+
+	provider, err := h.whatsappProvider(tenantID)
+	if err != nil || !provider.VerifySubscription(c.Query("hub.mode"), c.Query("hub.verify_token")) {
+		if err != nil {
+			logger.WithTenant(tenantID).Error("Failed to get WhatsApp provider for webhook verification", err, map[string]interface{}{})
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid verify token",
+			"code":  "INVALID_TOKEN",
+		})
+	}
+
+	return c.SendString(c.Query("hub.challenge"))
+}
+
+// WhatsAppEvents handles the messages webhook of the WhatsApp Cloud API
+// @Summary WhatsApp webhook
+// @Description Receives WhatsApp Cloud API webhook posts, verified with the X-Hub-Signature-256 header and the app_secret of the tenant's WhatsApp provider. Statuses are matched by message ID; delivered advances the notification to DELIVERED, read to OPENED and failed to UNDELIVERED with the error code. Messages users send open the 24-hour customer service window for free-form messages. Changes of other phone numbers are ignored. Served outside the /api/v1 base path without bearer authentication.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param X-Hub-Signature-256 header string true "sha256= followed by the hex HMAC-SHA256 of the body"
+// @Param payload body whatsapp.WebhookPayload true "Webhook payload"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/whatsapp/{tenant_id} [post]
+func (h *WebhookHandler) WhatsAppEvents(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID",
+			"code":  "INVALID_TENANT_ID",
+		})
+	}
+
+	provider, err := h.whatsappProvider(tenantID)
+	if err != nil || !provider.VerifySignature(c.Get("X-Hub-Signature-256"), c.Body()) {
+		if err != nil {
+			logger.WithTenant(tenantID).Error("Failed to get WhatsApp provider for webhook", err, map[string]interface{}{})
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid signature",
+			"code":  "INVALID_SIGNATURE",
+		})
+	}
+
+	statuses, messages, err := whatsapp.ParseWebhook(c.Body(), provider.PhoneNumberID())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid WhatsApp webhook",
+			"code":  "INVALID_REQUEST",
+		})
+	}
+
+	for _, message := range messages {
+		if err := h.sessions.Touch(context.Background(), tenantID, message.From, message.SentAt()); err != nil {
+			logger.WithTenant(tenantID).Error("Failed to record WhatsApp session", err, map[string]interface{}{
+				"from":       message.From,
+				"message_id": message.ID,
+			})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to process WhatsApp webhook",
+				"code":  "INTERNAL_ERROR",
+			})
+		}
+	}
+
+	for i := range statuses {
+		status := &statuses[i]
+		if err := h.receipts.Apply(context.Background(), services.WhatsAppReceipt(tenantID, status)); err != nil {
+			// Statuses of messages sent outside the engine are acknowledged and skipped
+			if errors.Is(err, services.ErrUnknownProviderMessage) {
+				continue
+			}
+
+			logger.WithTenant(tenantID).Error("Failed to apply WhatsApp status", err, map[string]interface{}{
+				"message_id": status.ID,
+				"status":     status.Status,
+			})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to process WhatsApp webhook",
+				"code":  "INTERNAL_ERROR",
+			})
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SESNotification handles SES bounce, complaint and delivery notifications delivered by SNS
+// @Summary SES event notification
+// @Description Receives SNS HTTP notifications for the SES identity used by the tenant's SMTP provider. The SNS signature is verified and the topic must match SESTopicARN of the SMTP config; subscription confirmations are confirmed automatically. Hard bounces move the notification to BOUNCED and, like complaints, suppress the address. Served outside the /api/v1 base path without bearer authentication.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param message body email.SNSMessage true "SNS message"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/ses/{tenant_id} [post]
+func (h *WebhookHandler) SESNotification(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID",
+			"code":  "INVALID_TENANT_ID",
+		})
+	}
+
+	// SNS posts JSON with a text/plain content type
+	var msg email.SNSMessage
+	if err := json.Unmarshal(c.Body(), &msg); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid SNS message",
+			"code":  "INVALID_REQUEST",
+		})
+	}
+
+	smtpConfig, err := h.smtpConfig(tenantID)
+	if err != nil || smtpConfig.SESTopicARN == "" || smtpConfig.SESTopicARN != msg.TopicArn {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Unknown topic",
+			"code":  "INVALID_SIGNATURE",
+		})
+	}
+
+	if err := h.snsVerifier.Verify(c.Context(), &msg); err != nil {
+		logger.WithTenant(tenantID).Error("Invalid SNS message signature", err, map[string]interface{}{
+			"topic_arn": msg.TopicArn,
+		})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid signature",
+			"code":  "INVALID_SIGNATURE",
+		})
+	}
+
+	switch msg.Type {
+	case "SubscriptionConfirmation":
+		if err := h.snsVerifier.ConfirmSubscription(c.Context(), &msg); err != nil {
+			logger.WithTenant(tenantID).Error("Failed to confirm SNS subscription", err, map[string]interface{}{
+				"topic_arn": msg.TopicArn,
+			})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to confirm subscription",
+				"code":  "INTERNAL_ERROR",
+			})
+		}
+		logger.WithTenant(tenantID).Info("SNS subscription confirmed", map[string]interface{}{
+			"topic_arn": msg.TopicArn,
+		})
+		return c.SendStatus(fiber.StatusNoContent)
+
+	case "Notification":
+		events, err := email.ParseSESNotification(tenantID, msg.Message)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid SES notification",
+				"code":  "INVALID_REQUEST",
+			})
+		}
+		return h.applyEmailEvents(c, tenantID, events)
+
+	default:
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// SendGridEvents handles the SendGrid Event Webhook
+// @Summary SendGrid event webhook
+// @Description Receives signed SendGrid Event Webhook posts, verified with SendGridWebhookPublicKey of the tenant's SMTP config. Events are matched by smtp-id; bounces, spam reports, deliveries, opens and clicks advance the notification, hard bounces and spam reports suppress the address. Served outside the /api/v1 base path without bearer authentication.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param tenant_id path int true "Tenant ID" minimum(1)
+// @Param X-Twilio-Email-Event-Webhook-Signature header string true "Event signature"
+// @Param X-Twilio-Email-Event-Webhook-Timestamp header string true "Event timestamp"
+// @Param events body []email.SendGridEvent true "SendGrid events"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/sendgrid/{tenant_id} [post]
+func (h *WebhookHandler) SendGridEvents(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseInt(c.Params("tenant_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID",
+			"code":  "INVALID_TENANT_ID",
+		})
+	}
+
+	smtpConfig, err := h.smtpConfig(tenantID)
+	if err != nil || !email.VerifySendGridSignature(
+		smtpConfig.SendGridWebhookPublicKey,
+		c.Get("X-Twilio-Email-Event-Webhook-Signature"),
+		c.Get("X-Twilio-Email-Event-Webhook-Timestamp"),
+		c.Body(),
+	) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid signature",
+			"code":  "INVALID_SIGNATURE",
+		})
+	}
+
+	events, err := email.ParseSendGridEvents(tenantID, c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid SendGrid events",
+			"code":  "INVALID_REQUEST",
+		})
+	}
+
+	return h.applyEmailEvents(c, tenantID, events)
+}
+
+// applyEmailEvents applies parsed email events, acknowledging events of unknown messages
+func (h *WebhookHandler) applyEmailEvents(c *fiber.Ctx, tenantID int64, events []*models.EmailEvent) error {
+	for _, event := range events {
+		if err := h.receipts.ApplyEmailEvent(context.Background(), event); err != nil {
+			if errors.Is(err, services.ErrUnknownProviderMessage) {
+				continue
+			}
+
+			logger.WithTenant(tenantID).Error("Failed to apply email event", err, map[string]interface{}{
+				"source":     event.Source,
+				"message_id": event.MessageID,
+				"event":      event.Type,
+			})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to process email events",
+				"code":  "INTERNAL_ERROR",
+			})
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// smtpConfig returns the config of the tenant's enabled SMTP provider
+func (h *WebhookHandler) smtpConfig(tenantID int64) (schema.SMTPConfig, error) {
+	partnerConfig, err := h.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return schema.SMTPConfig{}, err
+	}
+
+	for _, provider := range partnerConfig.EmailProviders {
+		if provider.Enabled && provider.Type == "smtp" {
+			return email.ParseSMTPConfig(provider.Config)
+		}
+	}
+
+	return schema.SMTPConfig{}, fmt.Errorf("no enabled SMTP provider for tenant %d", tenantID)
+}
+
+// nikitaProvider returns the tenant's enabled Nikita (custom) SMS provider
+func (h *WebhookHandler) nikitaProvider(tenantID int64) (*sms.CustomProvider, error) {
+	partnerConfig, err := h.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, provider := range partnerConfig.SMSProviders {
+		if provider.Enabled && provider.Type == "custom" {
+			return sms.NewCustomProvider(provider.Config)
+		}
+	}
+
+	return nil, fmt.Errorf("no enabled Nikita provider for tenant %d", tenantID)
+}
+
+// viberProvider returns the tenant's enabled Nikita Viber provider
+func (h *WebhookHandler) viberProvider(tenantID int64) (*viber.NikitaProvider, error) {
+	partnerConfig, err := h.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, provider := range partnerConfig.ViberProviders {
+		if provider.Enabled && provider.Type == "nikita" {
+			return viber.NewNikitaProvider(provider.Config)
+		}
+	}
+
+	return nil, fmt.Errorf("no enabled Viber provider for tenant %d", tenantID)
+}
+
+// whatsappProvider returns the tenant's enabled WhatsApp Cloud API provider
+func (h *WebhookHandler) whatsappProvider(tenantID int64) (*whatsapp.CloudProvider, error) {
+	partnerConfig, err := h.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, provider := range partnerConfig.WhatsAppProviders {
+		if provider.Enabled && provider.Type == "cloud" {
+			return whatsapp.NewCloudProvider(provider.Config)
+		}
+	}
+
+	return nil, fmt.Errorf("no enabled WhatsApp provider for tenant %d", tenantID)
+}
+
+// twilioAuthToken returns the auth token of the tenant's enabled Twilio provider
+func (h *WebhookHandler) twilioAuthToken(tenantID int64) (string, error) {
+	partnerConfig, err := h.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, provider := range partnerConfig.SMSProviders {
+		if !provider.Enabled || provider.Type != "twilio" {
+			continue
+		}
+		if authToken, ok := provider.Config["auth_token"].(string); ok && authToken != "" {
+			return authToken, nil
+		}
+	}
+
+	return "", fmt.Errorf("no enabled Twilio provider for tenant %d", tenantID)
+}
+
+// callbackURL rebuilds the URL Twilio posted to, which is part of the signed payload
+func (h *WebhookHandler) callbackURL(c *fiber.Ctx) string {
+	if callbackURL := h.config.GetWebhookURL(c.OriginalURL()); callbackURL != "" {
+		return callbackURL
+	}
+	return c.BaseURL() + c.OriginalURL()
+}
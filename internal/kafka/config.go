@@ -10,18 +10,20 @@ type KafkaConfig struct {
 }
 
 type Topics struct {
-	Notifications string `json:"notifications"`
-	Events        string `json:"events"`
-	DeadLetter    string `json:"dead_letter"`
+	Notifications  string `json:"notifications"`
+	Events         string `json:"events"`
+	DeadLetter     string `json:"dead_letter"`
+	SelfExclusions string `json:"self_exclusions"`
 }
 
 func NewKafkaConfig(cfg *config.Config) *KafkaConfig {
 	return &KafkaConfig{
 		Brokers: cfg.Kafka.Brokers,
 		Topics: Topics{
-			Notifications: cfg.Kafka.Topics.Notifications,
-			Events:        cfg.Kafka.Topics.Events,
-			DeadLetter:    cfg.Kafka.Topics.DeadLetter,
+			Notifications:  cfg.Kafka.Topics.Notifications,
+			Events:         cfg.Kafka.Topics.Events,
+			DeadLetter:     cfg.Kafka.Topics.DeadLetter,
+			SelfExclusions: cfg.Kafka.Topics.SelfExclusions,
 		},
 	}
 }
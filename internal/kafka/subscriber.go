@@ -19,11 +19,23 @@ type Subscriber struct {
 }
 
 func NewSubscriber(cfg *config.Config) (*Subscriber, error) {
-	logger := watermill.NewStdLogger(false, false)
+	saramaConfig := newSaramaConfig()
+	saramaConfig.Consumer.Offsets.Initial = -1
 
-	saramaConfig := sarama.NewConfig()
+	return newSubscriber(cfg, cfg.Kafka.ConsumerGroup, saramaConfig)
+}
 
-	saramaConfig.Consumer.Offsets.Initial = -1
+// NewBroadcastSubscriber creates a subscriber outside of any consumer group, so every instance
+// receives every message of the topics it subscribes to. Only messages published after it starts are consumed.
+func NewBroadcastSubscriber(cfg *config.Config) (*Subscriber, error) {
+	saramaConfig := newSaramaConfig()
+	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetNewest
+
+	return newSubscriber(cfg, "", saramaConfig)
+}
+
+func newSaramaConfig() *sarama.Config {
+	saramaConfig := sarama.NewConfig()
 
 	if os.Getenv("LOCAL") != "true" {
 		saramaConfig.Net.SASL.Enable = true
@@ -39,10 +51,16 @@ func NewSubscriber(cfg *config.Config) (*Subscriber, error) {
 	saramaConfig.Consumer.Return.Errors = true
 	saramaConfig.ClientID = "watermill"
 
+	return saramaConfig
+}
+
+func newSubscriber(cfg *config.Config, consumerGroup string, saramaConfig *sarama.Config) (*Subscriber, error) {
+	logger := watermill.NewStdLogger(false, false)
+
 	subscriberConfig := kafka.SubscriberConfig{
 		Brokers:               cfg.Kafka.Brokers,
 		Unmarshaler:           kafka.DefaultMarshaler{},
-		ConsumerGroup:         cfg.Kafka.ConsumerGroup,
+		ConsumerGroup:         consumerGroup,
 		OverwriteSaramaConfig: saramaConfig,
 	}
 
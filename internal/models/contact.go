@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// ContactRequest stores the addresses and settings of a tenant's user. Upserting a contact
+// replaces all of its fields.
+type ContactRequest struct {
+	UserID string `json:"user_id,omitempty" example:"player-42"`
+	Email  string `json:"email,omitempty" example:"player@example.com"`
+	Phone  string `json:"phone,omitempty" example:"+37499123456"`
+	// Chat ID of the user with the tenant's Telegram bot
+	TelegramChatID string `json:"telegram_chat_id,omitempty" example:"123456789"`
+	Locale         string `json:"locale,omitempty" example:"hy-AM"`
+	TimeZone       string `json:"time_zone,omitempty" example:"Asia/Yerevan"`
+	// Push tokens moved to the device registry, requests still sending them are rejected
+	PushTokens []string `json:"push_tokens,omitempty" swaggerignore:"true"`
+}
+
+// ContactBulkRequest upserts many contacts of a tenant at once
+type ContactBulkRequest struct {
+	Contacts []*ContactRequest `json:"contacts"`
+}
+
+// ContactBulkResponse reports the result of a bulk upsert
+type ContactBulkResponse struct {
+	TenantID int64                 `json:"tenant_id" example:"1001"`
+	Upserted int                   `json:"upserted" example:"998"`
+	Invalid  []*InvalidContactInfo `json:"invalid,omitempty"`
+}
+
+// InvalidContactInfo explains why a contact of a bulk upsert was skipped
+type InvalidContactInfo struct {
+	Index  int    `json:"index" example:"3"`
+	UserID string `json:"user_id,omitempty" example:"player-43"`
+	Error  string `json:"error" example:"Invalid email"`
+}
+
+// ContactResponse represents a contact
+type ContactResponse struct {
+	ID             int       `json:"id" example:"1"`
+	TenantID       int64     `json:"tenant_id" example:"1001"`
+	UserID         string    `json:"user_id" example:"player-42"`
+	Email          string    `json:"email,omitempty" example:"player@example.com"`
+	Phone          string    `json:"phone,omitempty" example:"+37499123456"`
+	TelegramChatID string    `json:"telegram_chat_id,omitempty" example:"123456789"`
+	Locale         string    `json:"locale,omitempty" example:"hy-AM"`
+	TimeZone       string    `json:"time_zone,omitempty" example:"Asia/Yerevan"`
+	CreatedAt      time.Time `json:"created_at" example:"2023-01-01T00:00:00Z"`
+	UpdatedAt      time.Time `json:"updated_at" example:"2023-01-01T00:00:00Z"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// DevicePlatform is the platform a push token belongs to
+type DevicePlatform string
+
+const (
+	PlatformIOS     DevicePlatform = "ios"
+	PlatformAndroid DevicePlatform = "android"
+	PlatformWeb     DevicePlatform = "web"
+	// PlatformUnknown is the platform of tokens migrated from the push_tokens of contacts, it
+	// can't be registered
+	PlatformUnknown DevicePlatform = "unknown"
+)
+
+// IsValid reports whether p is one of the known platforms
+func (p DevicePlatform) IsValid() bool {
+	switch p {
+	case PlatformIOS, PlatformAndroid, PlatformWeb:
+		return true
+	}
+	return false
+}
+
+// DeviceRegisterRequest registers the push token of a tenant's user. Registering a known token
+// moves it to the user and reactivates it.
+type DeviceRegisterRequest struct {
+	UserID     string         `json:"user_id" example:"player-42"`
+	Platform   DevicePlatform `json:"platform" example:"android" enums:"ios,android,web"`
+	Token      string         `json:"token" example:"fcm-token-1"`
+	AppVersion string         `json:"app_version,omitempty" example:"2.4.1"`
+}
+
+// DeviceUnregisterRequest deactivates a push token of a tenant
+type DeviceUnregisterRequest struct {
+	Token string `json:"token" example:"fcm-token-1"`
+}
+
+// DeviceResponse represents a registered device
+type DeviceResponse struct {
+	ID                 int            `json:"id" example:"1"`
+	TenantID           int64          `json:"tenant_id" example:"1001"`
+	UserID             string         `json:"user_id" example:"player-42"`
+	Platform           DevicePlatform `json:"platform" example:"android"`
+	Token              string         `json:"token" example:"fcm-token-1"`
+	AppVersion         string         `json:"app_version,omitempty" example:"2.4.1"`
+	LastSeenAt         time.Time      `json:"last_seen_at" example:"2023-01-01T00:00:00Z"`
+	Active             bool           `json:"active" example:"true"`
+	DeactivatedAt      *time.Time     `json:"deactivated_at,omitempty" example:"2023-01-02T00:00:00Z"`
+	DeactivationReason string         `json:"deactivation_reason,omitempty" example:"invalid_token"`
+	CreatedAt          time.Time      `json:"created_at" example:"2023-01-01T00:00:00Z"`
+	UpdatedAt          time.Time      `json:"updated_at" example:"2023-01-01T00:00:00Z"`
+}
+
+// DeviceListResponse lists the devices of a user
+type DeviceListResponse struct {
+	TenantID int64             `json:"tenant_id" example:"1001"`
+	UserID   string            `json:"user_id" example:"player-42"`
+	Devices  []*DeviceResponse `json:"devices"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"text/template"
+	"time"
+
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+)
+
+const (
+	// MaxDigestWindow is the longest a notification is held for a digest
+	MaxDigestWindow = 24 * time.Hour
+	// MaxDigestItems is the most notifications a digest may collect
+	MaxDigestItems = 100
+
+	// DefaultDigestHeadline is the headline template of policies without one
+	DefaultDigestHeadline = "{{.Count}} new notifications"
+	// DefaultDigestBody is the body template of policies without one, the bodies of the items
+	// separated by blank lines
+	DefaultDigestBody = "{{range $i, $item := .Items}}{{if $i}}\n\n{{end}}{{$item.Body}}{{end}}"
+)
+
+// DigestItem is a held notification as seen by digest templates
+type DigestItem struct {
+	RequestID string
+	Headline  string
+	Body      string
+	Tag       string
+	Params    map[string]interface{}
+	CreatedAt time.Time
+}
+
+// DigestTemplateData is what digest templates are rendered with
+type DigestTemplateData struct {
+	Count int
+	Items []DigestItem
+	// Replaced with the recipient's unsubscribe link when the digest is sent
+	UnsubscribeURL string
+}
+
+// ParseDigestTemplate parses a headline or body template of a digest policy, falling back
+// to the default when it's empty
+func ParseDigestTemplate(name, text, fallback string) (*template.Template, error) {
+	if text == "" {
+		text = fallback
+	}
+	return template.New(name).Option("missingkey=zero").Parse(text)
+}
+
+// ValidateDigestPolicies checks the digest policies of a configuration
+func ValidateDigestPolicies(policies []schema.DigestPolicy) error {
+	for i, policy := range policies {
+		switch MessageType(policy.MessageType) {
+		case MessageTypeBonus, MessageTypePromo, MessageTypeReport, MessageTypeSystem, MessageTypePayment, MessageTypeSupport:
+		default:
+			return fmt.Errorf("digest policy %d has an invalid message type", i+1)
+		}
+
+		switch NotificationType(policy.Channel) {
+		case "", TypeEmail, TypeSMS, TypePush, TypeInApp, TypeTelegram, TypeViber, TypeWhatsApp:
+		default:
+			return fmt.Errorf("digest policy %d has an invalid channel", i+1)
+		}
+
+		window, err := time.ParseDuration(policy.Window)
+		if err != nil || window <= 0 || window > MaxDigestWindow {
+			return fmt.Errorf("digest policy %d needs a window like 1h of at most 24h", i+1)
+		}
+
+		if policy.MaxItems <= 0 || policy.MaxItems > MaxDigestItems {
+			return fmt.Errorf("digest policy %d needs max items between 1 and %d", i+1, MaxDigestItems)
+		}
+
+		if _, err := ParseDigestTemplate("headline", policy.Headline, DefaultDigestHeadline); err != nil {
+			return fmt.Errorf("digest policy %d has an invalid headline template: %w", i+1, err)
+		}
+		if _, err := ParseDigestTemplate("body", policy.Body, DefaultDigestBody); err != nil {
+			return fmt.Errorf("digest policy %d has an invalid body template: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+)
+
+func TestValidateDigestPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  schema.DigestPolicy
+		wantErr bool
+	}{
+		{name: "valid", policy: schema.DigestPolicy{MessageType: "bonus", Window: "1h", MaxItems: 10}},
+		{name: "channel and templates", policy: schema.DigestPolicy{MessageType: "promo", Channel: "EMAIL", Window: "24h", MaxItems: 100, Headline: "{{.Count}} offers"}},
+		{name: "invalid message type", policy: schema.DigestPolicy{MessageType: "news", Window: "1h", MaxItems: 10}, wantErr: true},
+		{name: "invalid channel", policy: schema.DigestPolicy{MessageType: "bonus", Channel: "FAX", Window: "1h", MaxItems: 10}, wantErr: true},
+		{name: "window too long", policy: schema.DigestPolicy{MessageType: "bonus", Window: "25h", MaxItems: 10}, wantErr: true},
+		{name: "no max items", policy: schema.DigestPolicy{MessageType: "bonus", Window: "1h"}, wantErr: true},
+		{name: "too many items", policy: schema.DigestPolicy{MessageType: "bonus", Window: "1h", MaxItems: 101}, wantErr: true},
+		{name: "invalid template", policy: schema.DigestPolicy{MessageType: "bonus", Window: "1h", MaxItems: 10, Body: "{{range .Items}}"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateDigestPolicies([]schema.DigestPolicy{tt.policy}); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDigestPolicies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
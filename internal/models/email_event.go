@@ -0,0 +1,27 @@
+package models
+
+// EmailEventType is what a bounce, complaint or engagement report says about a sent email
+type EmailEventType string
+
+const (
+	EmailEventDelivered  EmailEventType = "delivered"
+	EmailEventHardBounce EmailEventType = "hard_bounce"
+	EmailEventSoftBounce EmailEventType = "soft_bounce"
+	EmailEventComplaint  EmailEventType = "complaint"
+	EmailEventOpened     EmailEventType = "opened"
+	EmailEventClicked    EmailEventType = "clicked"
+)
+
+// EmailEvent is a report about a sent email, parsed from a DSN in the bounce mailbox or
+// from an SES or SendGrid event webhook
+type EmailEvent struct {
+	TenantID int64
+	// Source is where the event came from: dsn, arf, ses or sendgrid
+	Source string
+	// MessageID is the Message-ID header of the original email, including angle brackets
+	MessageID  string
+	Recipient  string
+	Type       EmailEventType
+	StatusCode string
+	Diagnostic string
+}
@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// InboxMessage represents an in-app notification in the inbox of its user
+type InboxMessage struct {
+	RequestID   string                 `json:"request_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Headline    string                 `json:"headline,omitempty" example:"Bonus credited"`
+	Body        string                 `json:"body" example:"Your 50 free spins are waiting."`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	Tag         string                 `json:"tag,omitempty" example:"bonus"`
+	MessageType string                 `json:"message_type,omitempty" example:"bonus"`
+	Read        bool                   `json:"read" example:"false"`
+	Archived    bool                   `json:"archived" example:"false"`
+	SentAt      *time.Time             `json:"sent_at,omitempty" example:"2023-01-01T00:00:00Z"`
+	ReadAt      *time.Time             `json:"read_at,omitempty" example:"2023-01-01T00:05:00Z"`
+	ArchivedAt  *time.Time             `json:"archived_at,omitempty" example:"2023-01-02T00:00:00Z"`
+}
+
+// InboxListResponse lists a page of a user's inbox
+type InboxListResponse struct {
+	TenantID int64           `json:"tenant_id" example:"1001"`
+	UserID   string          `json:"user_id" example:"player-42"`
+	Total    int             `json:"total" example:"42"`
+	Unread   int             `json:"unread" example:"3"`
+	Limit    int             `json:"limit" example:"20"`
+	Offset   int             `json:"offset" example:"0"`
+	Messages []*InboxMessage `json:"messages"`
+}
+
+// InboxUpdateRequest names the messages of an inbox to mark read, unread or archive
+type InboxUpdateRequest struct {
+	RequestIDs []string `json:"request_ids" example:"550e8400-e29b-41d4-a716-446655440000"`
+}
+
+// InboxUpdateResponse reports how many messages an inbox update changed
+type InboxUpdateResponse struct {
+	TenantID int64  `json:"tenant_id" example:"1001"`
+	UserID   string `json:"user_id" example:"player-42"`
+	Updated  int    `json:"updated" example:"2"`
+	Unread   int    `json:"unread" example:"1"`
+}
+
+// InboxUnreadCountResponse reports the unread messages of a user's inbox
+type InboxUnreadCountResponse struct {
+	TenantID int64  `json:"tenant_id" example:"1001"`
+	UserID   string `json:"user_id" example:"player-42"`
+	Unread   int    `json:"unread" example:"3"`
+}
+
+// InboxEvent announces a new in-app notification to every instance holding streams of its user
+type InboxEvent struct {
+	TenantID int64         `json:"tenant_id"`
+	UserID   string        `json:"user_id"`
+	Message  *InboxMessage `json:"message"`
+}
+
+// StreamEventNotification is the type of stream events carrying a new inbox message
+const StreamEventNotification = "notification"
+
+// StreamEvent is pushed to users over their WebSocket or SSE stream
+type StreamEvent struct {
+	Type    string        `json:"type" example:"notification"`
+	Message *InboxMessage `json:"message"`
+}
+
+// StreamTokenResponse holds a token opening the real-time stream of a user's inbox
+type StreamTokenResponse struct {
+	TenantID  int64     `json:"tenant_id" example:"1001"`
+	UserID    string    `json:"user_id" example:"player-42"`
+	Token     string    `json:"token" example:"eyJ0IjoxMDAxLCJ1IjoicGxheWVyLTQyIiwiZSI6MTcwMDAwMDAwMH0.c2lnbmF0dXJl"`
+	ExpiresAt time.Time `json:"expires_at" example:"2023-01-01T01:00:00Z"`
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 )
 
@@ -12,19 +14,85 @@ const (
 	TypeEmail NotificationType = "EMAIL"
 	TypeSMS   NotificationType = "SMS"
 	TypePush  NotificationType = "PUSH"
+	// Kept in the inbox of a user, the recipients are user IDs
+	TypeInApp NotificationType = "INAPP"
+	// Sent by the tenant's Telegram bot, the recipients are chat IDs
+	TypeTelegram NotificationType = "TELEGRAM"
+	// Sent as Viber business messages, the recipients are phone numbers
+	TypeViber NotificationType = "VIBER"
+	// Sent through the WhatsApp Cloud API, the recipients are phone numbers
+	TypeWhatsApp NotificationType = "WHATSAPP"
 )
 
 // NotificationStatus represents the status of a notification
 type NotificationStatus string
 
 const (
-	StatusActive    NotificationStatus = "ACTIVE"
-	StatusCompleted NotificationStatus = "COMPLETED"
-	StatusCancel    NotificationStatus = "CANCEL"
-	StatusPending   NotificationStatus = "PENDING"
-	StatusFailed    NotificationStatus = "FAILED"
+	StatusPending NotificationStatus = "PENDING" // queued
+	StatusActive  NotificationStatus = "ACTIVE"  // picked up for sending
+	StatusSent    NotificationStatus = "SENT"    // accepted by the provider
+	StatusCancel  NotificationStatus = "CANCEL"
+	StatusFailed  NotificationStatus = "FAILED"
+
+	// Not sent because the address is on the tenant's suppression list
+	StatusSuppressed NotificationStatus = "SUPPRESSED"
+	// Not sent because the recipient reached a frequency cap of the tenant
+	StatusCapped NotificationStatus = "CAPPED"
+	// Not sent because the same content went to the recipient within the tenant's dedup window
+	StatusDuplicate NotificationStatus = "DUPLICATE"
+
+	// Held for a digest of the tenant, and sent as part of the digest
+	StatusHeld     NotificationStatus = "HELD"
+	StatusDigested NotificationStatus = "DIGESTED"
+
+	// Reported by provider delivery receipts and engagement tracking after the notification was sent
+	StatusDelivered   NotificationStatus = "DELIVERED"
+	StatusBounced     NotificationStatus = "BOUNCED"
+	StatusUndelivered NotificationStatus = "UNDELIVERED"
+	StatusOpened      NotificationStatus = "OPENED"
+	StatusClicked     NotificationStatus = "CLICKED"
 )
 
+// statusTransitions lists the statuses a notification may move to from each status.
+// Statuses only move forward, so late or out-of-order provider reports can't regress them.
+var statusTransitions = map[NotificationStatus][]NotificationStatus{
+	StatusPending:   {StatusActive, StatusSent, StatusFailed, StatusCancel, StatusSuppressed, StatusCapped, StatusDuplicate, StatusHeld},
+	StatusActive:    {StatusSent, StatusFailed, StatusCancel, StatusSuppressed, StatusCapped, StatusDuplicate, StatusHeld},
+	StatusHeld:      {StatusDigested, StatusFailed, StatusCancel},
+	StatusDigested:  {StatusFailed},
+	StatusSent:      {StatusDelivered, StatusBounced, StatusUndelivered, StatusOpened, StatusClicked},
+	StatusDelivered: {StatusOpened, StatusClicked},
+	StatusOpened:    {StatusClicked},
+}
+
+// CanTransitionTo reports whether a notification may move from status s to next
+func (s NotificationStatus) CanTransitionTo(next NotificationStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// PreviousStatuses returns the statuses a notification may move to status s from
+func (s NotificationStatus) PreviousStatuses() []NotificationStatus {
+	var previous []NotificationStatus
+	for from, allowed := range statusTransitions {
+		for _, next := range allowed {
+			if next == s {
+				previous = append(previous, from)
+			}
+		}
+	}
+	return previous
+}
+
+// IsFinal reports whether no further status changes are possible
+func (s NotificationStatus) IsFinal() bool {
+	return len(statusTransitions[s]) == 0
+}
+
 // MessageType represents the category of message for routing
 type MessageType string
 
@@ -37,12 +105,31 @@ const (
 	MessageTypeSupport MessageType = "support"
 )
 
+// IsPromotional reports whether the message type is marketing traffic rather than transactional
+func (t MessageType) IsPromotional() bool {
+	return t == MessageTypePromo || t == MessageTypeBonus
+}
+
+// AllowsOptOut reports whether recipients may opt out of the message type. Payment, system and
+// support messages are always delivered, as are notifications without a message type.
+func (t MessageType) AllowsOptOut() bool {
+	switch t {
+	case MessageTypeBonus, MessageTypePromo, MessageTypeReport:
+		return true
+	}
+	return false
+}
+
+// OptionalMessageTypes lists the message types recipients may opt out of
+var OptionalMessageTypes = []MessageType{MessageTypeBonus, MessageTypePromo, MessageTypeReport}
+
 // NotificationRequest represents the incoming notification request
 type NotificationRequest struct {
 	// Public fields for API
 	TenantID    int64                  `json:"tenant_id" example:"1001"`
 	Type        NotificationType       `json:"type" example:"EMAIL"`
 	Recipients  []string               `json:"recipients" example:"user@example.com"`
+	UserIDs     []string               `json:"user_ids,omitempty" example:"player-42"`
 	Body        string                 `json:"body" example:"Hello World! This is your notification."`
 	Headline    string                 `json:"headline,omitempty" example:"Important Notification"`
 	From        string                 `json:"from,omitempty" example:"noreply@example.com"`
@@ -51,6 +138,9 @@ type NotificationRequest struct {
 	ScheduleTS  *int64                 `json:"schedule_ts,omitempty" example:"1640995200"`
 	MessageType MessageType            `json:"message_type,omitempty" example:"bonus"`
 	Data        map[string]interface{} `json:"data,omitempty"`
+	Attachments []*Attachment          `json:"attachments,omitempty"`
+	// Channels to try one after the other for each user until one delivers, instead of type
+	Cascade []*CascadeStep `json:"cascade,omitempty"`
 
 	// Internal fields (not exposed in API)
 	RequestID string            `json:"request_id,omitempty" swaggerignore:"true"`
@@ -58,11 +148,82 @@ type NotificationRequest struct {
 	Meta      *NotificationMeta `json:"meta,omitempty" swaggerignore:"true"`
 }
 
+// AllAttachments returns the attachments of the request together with the ones of its meta
+func (r *NotificationRequest) AllAttachments() []*Attachment {
+	if r.Meta == nil {
+		return r.Attachments
+	}
+
+	attachments := make([]*Attachment, 0, len(r.Attachments)+len(r.Meta.Attachments)+1)
+	if r.Meta.Attachment != nil {
+		attachments = append(attachments, r.Meta.Attachment)
+	}
+	attachments = append(attachments, r.Meta.Attachments...)
+	return append(attachments, r.Attachments...)
+}
+
+// MaxCascadeSteps is the number of channels a cascade may list
+const MaxCascadeSteps = 5
+
+// MaxCascadeTimeoutSeconds is the longest a cascade step may wait for a delivery report
+const MaxCascadeTimeoutSeconds = 7 * 24 * 60 * 60
+
+// CascadeStep is a channel of a cascade. The next step is sent when the notification of this
+// step isn't delivered within the timeout, fails or the user has no address on the channel.
+type CascadeStep struct {
+	Type NotificationType `json:"type" example:"PUSH"`
+	// Time to wait for a delivery report before moving on, not used for the last step
+	TimeoutSeconds int `json:"timeout_seconds,omitempty" example:"300"`
+}
+
+// ValidateCascade checks the cascade of a request. Cascades target users by ID, each user
+// getting its own chain of notifications.
+func (r *NotificationRequest) ValidateCascade() error {
+	if len(r.Cascade) == 0 {
+		return nil
+	}
+	if len(r.Cascade) < 2 || len(r.Cascade) > MaxCascadeSteps {
+		return fmt.Errorf("cascade must have between 2 and %d steps", MaxCascadeSteps)
+	}
+	if len(r.UserIDs) == 0 || len(r.Recipients) > 0 {
+		return errors.New("cascades target user IDs only, recipients are not allowed")
+	}
+	if len(r.AllAttachments()) > 0 {
+		return errors.New("attachments are not supported in cascades")
+	}
+	if r.Type != "" && r.Type != r.Cascade[0].Type {
+		return errors.New("type must be empty or the type of the first cascade step")
+	}
+
+	seen := make(map[NotificationType]bool, len(r.Cascade))
+	for i, step := range r.Cascade {
+		if step == nil {
+			return fmt.Errorf("cascade step %d is empty", i+1)
+		}
+		switch step.Type {
+		case TypeEmail, TypeSMS, TypePush, TypeInApp, TypeTelegram, TypeViber, TypeWhatsApp:
+		default:
+			return fmt.Errorf("cascade step %d has an invalid type", i+1)
+		}
+		if seen[step.Type] {
+			return fmt.Errorf("cascade lists %s more than once", step.Type)
+		}
+		seen[step.Type] = true
+
+		last := i == len(r.Cascade)-1
+		if !last && (step.TimeoutSeconds <= 0 || step.TimeoutSeconds > MaxCascadeTimeoutSeconds) {
+			return fmt.Errorf("cascade step %d needs a timeout between 1 and %d seconds", i+1, MaxCascadeTimeoutSeconds)
+		}
+	}
+	return nil
+}
+
 // BatchNotificationRequest represents a batch notification request
 type BatchNotificationRequest struct {
 	TenantID    int64                  `json:"tenant_id" example:"1001"`
 	Type        NotificationType       `json:"type" example:"EMAIL"`
 	Recipients  []string               `json:"recipients" example:"user1@example.com,user2@example.com"`
+	UserIDs     []string               `json:"user_ids,omitempty" example:"player-42,player-43"`
 	Body        string                 `json:"body" example:"Hello! This is a batch notification."`
 	Headline    string                 `json:"headline,omitempty" example:"Batch Notification"`
 	From        string                 `json:"from,omitempty" example:"noreply@example.com"`
@@ -71,6 +232,7 @@ type BatchNotificationRequest struct {
 	ScheduleTS  *int64                 `json:"schedule_ts,omitempty" example:"1640995200"`
 	MessageType MessageType            `json:"message_type,omitempty" example:"promo"`
 	Data        map[string]interface{} `json:"data,omitempty"`
+	Attachments []*Attachment          `json:"attachments,omitempty"`
 }
 
 // KafkaNotificationRequest represents the Kafka message structure
@@ -78,6 +240,7 @@ type KafkaNotificationRequest struct {
 	TenantID    int64                  `json:"tenant_id" example:"1001"`
 	Type        NotificationType       `json:"type" example:"EMAIL"`
 	Recipients  []string               `json:"recipients" example:"user@example.com"`
+	UserIDs     []string               `json:"user_ids,omitempty" example:"player-42"`
 	Body        string                 `json:"body" example:"Direct Kafka notification"`
 	Headline    string                 `json:"headline,omitempty" example:"Kafka Notification"`
 	MessageType MessageType            `json:"message_type,omitempty" example:"system"`
@@ -89,6 +252,8 @@ type NotificationResponse struct {
 	RequestID string `json:"request_id" example:"550e8400-e29b-41d4-a716-446655440000"`
 	Status    string `json:"status" example:"queued"`
 	Message   string `json:"message" example:"Notification queued for processing"`
+	// User IDs without a contact address for the channel, they are skipped
+	UnresolvedUserIDs []string `json:"unresolved_user_ids,omitempty" example:"player-43"`
 }
 
 // BatchNotificationResponse represents the response for batch notification requests
@@ -97,33 +262,170 @@ type BatchNotificationResponse struct {
 	TotalRecipients  int    `json:"total_recipients" example:"100"`
 	QueuedRecipients int    `json:"queued_recipients" example:"100"`
 	Status           string `json:"status" example:"processing"`
+	// User IDs without a contact address for the channel, they are skipped
+	UnresolvedUserIDs []string `json:"unresolved_user_ids,omitempty" example:"player-43"`
 }
 
 // NotificationStatusResponse represents the status response for a notification
 type NotificationStatusResponse struct {
 	RequestID    string    `json:"request_id" example:"550e8400-e29b-41d4-a716-446655440000"`
-	Status       string    `json:"status" example:"COMPLETED"`
+	Status       string    `json:"status" example:"DELIVERED" enums:"PENDING,ACTIVE,SENT,DELIVERED,BOUNCED,UNDELIVERED,OPENED,CLICKED,FAILED,CANCEL,SUPPRESSED,CAPPED,DUPLICATE,HELD,DIGESTED"`
 	Type         string    `json:"type" example:"EMAIL"`
 	TenantID     int64     `json:"tenant_id" example:"1001"`
 	CreatedAt    time.Time `json:"created_at" example:"2023-01-01T00:00:00Z"`
 	UpdatedAt    time.Time `json:"updated_at" example:"2023-01-01T00:01:00Z"`
 	ErrorMessage *string   `json:"error_message,omitempty" example:"SMTP connection failed"`
 	ScheduleTS   *int64    `json:"schedule_ts,omitempty" example:"1640995200"`
+
+	// Time the notification reached each status, omitted for statuses it didn't reach
+	SentAt        *time.Time `json:"sent_at,omitempty" example:"2023-01-01T00:00:01Z"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty" example:"2023-01-01T00:00:05Z"`
+	BouncedAt     *time.Time `json:"bounced_at,omitempty" example:"2023-01-01T00:00:05Z"`
+	UndeliveredAt *time.Time `json:"undelivered_at,omitempty" example:"2023-01-01T00:00:05Z"`
+	OpenedAt      *time.Time `json:"opened_at,omitempty" example:"2023-01-01T00:10:00Z"`
+	ClickedAt     *time.Time `json:"clicked_at,omitempty" example:"2023-01-01T00:11:00Z"`
+	FailedAt      *time.Time `json:"failed_at,omitempty" example:"2023-01-01T00:00:01Z"`
+	CancelledAt   *time.Time `json:"cancelled_at,omitempty" example:"2023-01-01T00:00:01Z"`
+	SuppressedAt  *time.Time `json:"suppressed_at,omitempty" example:"2023-01-01T00:00:01Z"`
+	CappedAt      *time.Time `json:"capped_at,omitempty" example:"2023-01-01T00:00:01Z"`
+	DuplicatedAt  *time.Time `json:"duplicated_at,omitempty" example:"2023-01-01T00:00:01Z"`
+	HeldAt        *time.Time `json:"held_at,omitempty" example:"2023-01-01T00:00:01Z"`
+	DigestedAt    *time.Time `json:"digested_at,omitempty" example:"2023-01-01T01:00:00Z"`
+
+	// Set for held notifications once they were sent in a digest
+	DigestRequestID string `json:"digest_request_id,omitempty" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"`
+
+	// Set for notifications of a channel cascade
+	CascadeID   string `json:"cascade_id,omitempty" example:"550e8400-e29b-41d4-a716-446655440000:player-42"`
+	CascadeStep *int   `json:"cascade_step,omitempty" example:"0"`
+
+	ProviderMessageID string                     `json:"provider_message_id,omitempty" example:"SM1234567890abcdef1234567890abcdef"`
+	Attempts          []*DeliveryAttemptResponse `json:"attempts,omitempty"`
+}
+
+// DeliveryAttemptResponse represents a single hand-over of a notification to a provider
+type DeliveryAttemptResponse struct {
+	Attempt           int       `json:"attempt" example:"1"`
+	ProviderName      string    `json:"provider_name,omitempty" example:"smtp_primary"`
+	ProviderType      string    `json:"provider_type" example:"twilio"`
+	ProviderMessageID string    `json:"provider_message_id,omitempty" example:"SM1234567890abcdef1234567890abcdef"`
+	Outcome           string    `json:"outcome" example:"SENT" enums:"SENT,FAILED"`
+	ProviderStatus    string    `json:"provider_status,omitempty" example:"delivered"`
+	OperatorErrorCode string    `json:"operator_error_code,omitempty" example:"30005"`
+	ErrorClass        string    `json:"error_class,omitempty" example:"temporary"`
+	ErrorMessage      string    `json:"error_message,omitempty" example:"Twilio API error: Too Many Requests (code: 20429)"`
+	RequestSummary    string    `json:"request_summary,omitempty" example:"POST Messages.json To=+37499123456 From=+15005550006"`
+	ResponseSummary   string    `json:"response_summary,omitempty" example:"status=queued sid=SM1234567890abcdef1234567890abcdef"`
+	LatencyMS         int64     `json:"latency_ms" example:"245"`
+	CreatedAt         time.Time `json:"created_at" example:"2023-01-01T00:00:01Z"`
 }
 
 // BatchNotificationStatusResponse represents the status response for a batch of notifications
 type BatchNotificationStatusResponse struct {
 	RequestID      string    `json:"request_id,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
 	BatchID        string    `json:"batch_id,omitempty" example:"batch_123"`
-	Status         string    `json:"status" example:"COMPLETED"`
+	Status         string    `json:"status" example:"SENT" enums:"PENDING,SENT,PARTIALLY_FAILED,FAILED"` // SENT once nothing is pending and nothing failed
 	Type           string    `json:"type" example:"EMAIL"`
 	TenantID       int64     `json:"tenant_id" example:"1001"`
 	CreatedAt      time.Time `json:"created_at" example:"2023-01-01T00:00:00Z"`
 	UpdatedAt      time.Time `json:"updated_at" example:"2023-01-01T00:01:00Z"`
 	TotalCount     int       `json:"total_count" example:"100"`
-	CompletedCount int       `json:"completed_count" example:"95"`
-	FailedCount    int       `json:"failed_count" example:"3"`
-	PendingCount   int       `json:"pending_count" example:"2"`
+	CompletedCount int       `json:"completed_count" example:"97"` // sent or further: SENT, DELIVERED, OPENED, CLICKED or DIGESTED
+	FailedCount    int       `json:"failed_count" example:"0"`     // FAILED, UNDELIVERED or BOUNCED
+	PendingCount   int       `json:"pending_count" example:"0"`
+	SkippedCount   int       `json:"skipped_count" example:"3"` // not sent on purpose, e.g. to suppressed addresses, over a frequency cap or duplicates
+}
+
+// CascadeStatusResponse reports the progress of the cascades of a notification request, one per user
+type CascadeStatusResponse struct {
+	RequestID string                `json:"request_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Cascades  []*CascadeChainStatus `json:"cascades"`
+}
+
+// CascadeChainStatus reports the progress of the cascade of a user. Status is DELIVERED once a
+// step is delivered, PENDING while steps are waiting, SENT when the last sent step has no
+// delivery report yet and FAILED when no step got through.
+type CascadeChainStatus struct {
+	CascadeID string               `json:"cascade_id" example:"550e8400-e29b-41d4-a716-446655440000:player-42"`
+	UserID    string               `json:"user_id" example:"player-42"`
+	Status    string               `json:"status" example:"DELIVERED" enums:"DELIVERED,PENDING,SENT,FAILED"`
+	Steps     []*CascadeStepStatus `json:"steps"`
+}
+
+// CascadeStepStatus is a notification of a cascade
+type CascadeStepStatus struct {
+	Step         int       `json:"step" example:"0"`
+	Type         string    `json:"type" example:"PUSH"`
+	RequestID    string    `json:"request_id" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"`
+	Status       string    `json:"status" example:"DELIVERED"`
+	ScheduleTS   *int64    `json:"schedule_ts,omitempty" example:"1640995500"`
+	ErrorMessage *string   `json:"error_message,omitempty" example:"cascade step 0 was delivered"`
+	UpdatedAt    time.Time `json:"updated_at" example:"2023-01-01T00:01:00Z"`
+}
+
+// DeliveryStatsResponse represents delivery rates of a tenant's notifications per message type
+type DeliveryStatsResponse struct {
+	TenantID      int64                       `json:"tenant_id" example:"1001"`
+	Type          string                      `json:"type,omitempty" example:"SMS"`
+	From          time.Time                   `json:"from" example:"2023-01-01T00:00:00Z"`
+	To            time.Time                   `json:"to" example:"2023-01-08T00:00:00Z"`
+	MessageTypes  []*MessageTypeDeliveryStats `json:"message_types"`
+	Promotional   *DeliveryStats              `json:"promotional"`
+	Transactional *DeliveryStats              `json:"transactional"`
+}
+
+// MessageTypeDeliveryStats holds the delivery counts of one message type
+type MessageTypeDeliveryStats struct {
+	MessageType string `json:"message_type" example:"promo"`
+	Promotional bool   `json:"promotional" example:"true"`
+	DeliveryStats
+}
+
+// DeliveryStats counts notifications by outcome. Sent covers every notification the provider
+// accepted, delivery rate is the share of delivered ones among those with a final delivery report.
+type DeliveryStats struct {
+	Total        int     `json:"total" example:"1000"`
+	Sent         int     `json:"sent" example:"980"`
+	Delivered    int     `json:"delivered" example:"930"`
+	Undelivered  int     `json:"undelivered" example:"30"`
+	Failed       int     `json:"failed" example:"20"`
+	Pending      int     `json:"pending" example:"0"`
+	Suppressed   int     `json:"suppressed" example:"5"`
+	Capped       int     `json:"capped" example:"12"`
+	Duplicate    int     `json:"duplicate" example:"3"`
+	Digested     int     `json:"digested" example:"40"`
+	DeliveryRate float64 `json:"delivery_rate" example:"0.96875"`
+}
+
+// Add counts notifications with the given status
+func (s *DeliveryStats) Add(status NotificationStatus, count int) {
+	s.Total += count
+	switch status {
+	case StatusSent:
+		s.Sent += count
+	case StatusDelivered, StatusOpened, StatusClicked:
+		s.Sent += count
+		s.Delivered += count
+	case StatusUndelivered, StatusBounced:
+		s.Sent += count
+		s.Undelivered += count
+	case StatusFailed:
+		s.Failed += count
+	case StatusSuppressed:
+		s.Suppressed += count
+	case StatusCapped:
+		s.Capped += count
+	case StatusDuplicate:
+		s.Duplicate += count
+	case StatusDigested:
+		s.Digested += count
+	default:
+		s.Pending += count
+	}
+
+	if reported := s.Delivered + s.Undelivered; reported > 0 {
+		s.DeliveryRate = float64(s.Delivered) / float64(reported)
+	}
 }
 
 // KafkaResponse represents the response for Kafka publishing
@@ -161,17 +463,77 @@ type HealthCheckDetail struct {
 
 // NotificationMeta contains additional metadata for the notification
 type NotificationMeta struct {
-	Service    string                 `json:"service,omitempty" example:"user-service"`
-	TemplateID string                 `json:"template_id,omitempty" example:"welcome_template"`
-	Params     map[string]interface{} `json:"params,omitempty"`
-	Attachment *Attachment            `json:"attachment,omitempty"`
-	Data       json.RawMessage        `json:"data,omitempty"`
+	Service     string                 `json:"service,omitempty" example:"user-service"`
+	TemplateID  string                 `json:"template_id,omitempty" example:"welcome_template"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+	Attachment  *Attachment            `json:"attachment,omitempty"`
+	Attachments []*Attachment          `json:"attachments,omitempty"`
+	Data        json.RawMessage        `json:"data,omitempty"`
+	TextBody    string                 `json:"text_body,omitempty" example:"Hello World! This is your notification."`
+	Headers     map[string]string      `json:"headers,omitempty"`
 }
 
-// Attachment represents file attachments for notifications
+// Attachment represents file attachments for notifications.
+// Exactly one of content (base64), url or object_key must be set.
 type Attachment struct {
 	Filename    string `json:"filename" example:"document.pdf"`
-	Content     string `json:"content" example:"base64_encoded_content"`
-	Disposition string `json:"disposition" example:"attachment"`
+	Content     string `json:"content,omitempty" example:"base64_encoded_content"`
+	URL         string `json:"url,omitempty" example:"https://cdn.example.com/files/document.pdf"`
+	Bucket      string `json:"bucket,omitempty" example:"notification-attachments"`
+	ObjectKey   string `json:"object_key,omitempty" example:"invoices/2023/01/document.pdf"`
+	Disposition string `json:"disposition" example:"attachment" enums:"attachment,inline"`
 	Type        string `json:"type" example:"application/pdf"`
+	ContentID   string `json:"content_id,omitempty" example:"logo"`
+}
+
+// ErrorClass classifies why a provider failed to send a notification
+type ErrorClass string
+
+const (
+	ErrorClassTemporary        ErrorClass = "temporary"
+	ErrorClassInvalidRecipient ErrorClass = "invalid_recipient"
+	ErrorClassRejected         ErrorClass = "rejected"
+	ErrorClassAuthentication   ErrorClass = "authentication"
+	ErrorClassInvalidContent   ErrorClass = "invalid_content"
+	ErrorClassUnknown          ErrorClass = "unknown"
+)
+
+// SendResult is the outcome of sending a single notification through a provider
+type SendResult struct {
+	NotificationID    int
+	Status            NotificationStatus
+	ProviderMessageID string
+	ErrorClass        ErrorClass
+	Error             error
+
+	// Attempt details recorded in the delivery history
+	ProviderName    string
+	ProviderType    string
+	RequestSummary  string
+	ResponseSummary string
+	Latency         time.Duration
+}
+
+// Failed reports whether the notification wasn't accepted by the provider
+func (r *SendResult) Failed() bool {
+	return r.Status == StatusFailed
+}
+
+// NewSentResult creates the result of a notification accepted by the provider
+func NewSentResult(notificationID int, providerMessageID string) *SendResult {
+	return &SendResult{
+		NotificationID:    notificationID,
+		Status:            StatusSent,
+		ProviderMessageID: providerMessageID,
+	}
+}
+
+// NewFailedResult creates the result of a notification the provider failed to send
+func NewFailedResult(notificationID int, errorClass ErrorClass, err error) *SendResult {
+	return &SendResult{
+		NotificationID: notificationID,
+		Status:         StatusFailed,
+		ErrorClass:     errorClass,
+		Error:          err,
+	}
 }
@@ -0,0 +1,160 @@
+package models
+
+import "testing"
+
+func TestCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from NotificationStatus
+		to   NotificationStatus
+		want bool
+	}{
+		{from: StatusPending, to: StatusSent, want: true},
+		{from: StatusPending, to: StatusSuppressed, want: true},
+		{from: StatusActive, to: StatusCapped, want: true},
+		{from: StatusPending, to: StatusDuplicate, want: true},
+		{from: StatusPending, to: StatusHeld, want: true},
+		{from: StatusHeld, to: StatusDigested, want: true},
+		{from: StatusActive, to: StatusFailed, want: true},
+		{from: StatusSent, to: StatusDelivered, want: true},
+		{from: StatusSent, to: StatusClicked, want: true},
+		{from: StatusDelivered, to: StatusOpened, want: true},
+		{from: StatusOpened, to: StatusClicked, want: true},
+
+		// Late or out-of-order reports can't move a notification back
+		{from: StatusDelivered, to: StatusSent},
+		{from: StatusOpened, to: StatusDelivered},
+		{from: StatusClicked, to: StatusOpened},
+		{from: StatusBounced, to: StatusDelivered},
+		{from: StatusSent, to: StatusPending},
+		{from: StatusSent, to: StatusFailed},
+		{from: StatusPending, to: StatusDelivered},
+		{from: StatusFailed, to: StatusSent},
+		{from: StatusSent, to: StatusSent},
+		{from: StatusCapped, to: StatusSent},
+		{from: StatusDuplicate, to: StatusSent},
+		{from: StatusHeld, to: StatusSent},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("CanTransitionTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviousStatuses(t *testing.T) {
+	for from, allowed := range statusTransitions {
+		for _, to := range allowed {
+			found := false
+			for _, previous := range to.PreviousStatuses() {
+				if previous == from {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("PreviousStatuses(%s) doesn't contain %s", to, from)
+			}
+		}
+	}
+
+	if previous := StatusPending.PreviousStatuses(); len(previous) != 0 {
+		t.Errorf("PreviousStatuses(PENDING) = %v, want none", previous)
+	}
+}
+
+func TestIsFinal(t *testing.T) {
+	tests := []struct {
+		status NotificationStatus
+		want   bool
+	}{
+		{status: StatusPending},
+		{status: StatusHeld},
+		{status: StatusSent},
+		{status: StatusDelivered},
+		{status: StatusClicked, want: true},
+		{status: StatusBounced, want: true},
+		{status: StatusUndelivered, want: true},
+		{status: StatusFailed, want: true},
+		{status: StatusCancel, want: true},
+		{status: StatusSuppressed, want: true},
+		{status: StatusCapped, want: true},
+		{status: StatusDuplicate, want: true},
+		{status: StatusDigested},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			if got := tt.status.IsFinal(); got != tt.want {
+				t.Errorf("IsFinal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCascade(t *testing.T) {
+	push := &CascadeStep{Type: TypePush, TimeoutSeconds: 300}
+	sms := &CascadeStep{Type: TypeSMS}
+
+	tests := []struct {
+		name    string
+		req     NotificationRequest
+		wantErr bool
+	}{
+		{name: "valid", req: NotificationRequest{UserIDs: []string{"player-42"}, Cascade: []*CascadeStep{push, sms}}},
+		{name: "type of the first step", req: NotificationRequest{Type: TypePush, UserIDs: []string{"player-42"}, Cascade: []*CascadeStep{push, sms}}},
+		{name: "no cascade", req: NotificationRequest{Recipients: []string{"player@example.org"}}},
+		{name: "single step", req: NotificationRequest{UserIDs: []string{"player-42"}, Cascade: []*CascadeStep{push}}, wantErr: true},
+		{name: "recipients", req: NotificationRequest{Recipients: []string{"+37499123456"}, UserIDs: []string{"player-42"}, Cascade: []*CascadeStep{push, sms}}, wantErr: true},
+		{name: "other type", req: NotificationRequest{Type: TypeSMS, UserIDs: []string{"player-42"}, Cascade: []*CascadeStep{push, sms}}, wantErr: true},
+		{name: "repeated channel", req: NotificationRequest{UserIDs: []string{"player-42"}, Cascade: []*CascadeStep{push, push}}, wantErr: true},
+		{name: "no timeout", req: NotificationRequest{UserIDs: []string{"player-42"}, Cascade: []*CascadeStep{{Type: TypePush}, sms}}, wantErr: true},
+		{name: "empty step", req: NotificationRequest{UserIDs: []string{"player-42"}, Cascade: []*CascadeStep{push, nil}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.ValidateCascade(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCascade() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAllAttachments(t *testing.T) {
+	logo := &Attachment{Filename: "logo.png"}
+	invoice := &Attachment{Filename: "invoice.pdf"}
+	terms := &Attachment{Filename: "terms.pdf"}
+
+	tests := []struct {
+		name string
+		req  NotificationRequest
+		want []*Attachment
+	}{
+		{name: "none"},
+		{name: "request", req: NotificationRequest{Attachments: []*Attachment{invoice}}, want: []*Attachment{invoice}},
+		{
+			name: "meta and request",
+			req: NotificationRequest{
+				Attachments: []*Attachment{terms},
+				Meta:        &NotificationMeta{Attachment: logo, Attachments: []*Attachment{invoice}},
+			},
+			want: []*Attachment{logo, invoice, terms},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.req.AllAttachments()
+			if len(got) != len(tt.want) {
+				t.Fatalf("AllAttachments() = %d attachments, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("attachment %d = %s, want %s", i, got[i].Filename, tt.want[i].Filename)
+				}
+			}
+		})
+	}
+}
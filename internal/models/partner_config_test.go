@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+)
+
+func TestValidateFrequencyCaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		cap     schema.FrequencyCap
+		wantErr bool
+	}{
+		{name: "valid", cap: schema.FrequencyCap{Channel: "SMS", MessageType: "promo", Limit: 2, Window: "24h"}},
+		{name: "invalid channel", cap: schema.FrequencyCap{Channel: "FAX", MessageType: "promo", Limit: 2, Window: "24h"}, wantErr: true},
+		{name: "invalid message type", cap: schema.FrequencyCap{Channel: "SMS", MessageType: "news", Limit: 2, Window: "24h"}, wantErr: true},
+		{name: "no limit", cap: schema.FrequencyCap{Channel: "SMS", MessageType: "promo", Window: "24h"}, wantErr: true},
+		{name: "invalid window", cap: schema.FrequencyCap{Channel: "SMS", MessageType: "promo", Limit: 2, Window: "1 day"}, wantErr: true},
+		{name: "window too long", cap: schema.FrequencyCap{Channel: "SMS", MessageType: "promo", Limit: 2, Window: "721h"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateFrequencyCaps([]schema.FrequencyCap{tt.cap}); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateFrequencyCaps() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// ChannelPreference says whether a recipient receives a message type on a channel
+type ChannelPreference struct {
+	Channel     NotificationType `json:"channel" example:"SMS"`
+	MessageType MessageType      `json:"message_type" example:"promo" enums:"bonus,promo,report"`
+	OptedIn     bool             `json:"opted_in" example:"false"`
+	Source      string           `json:"source,omitempty" example:"api"`
+	UpdatedAt   *time.Time       `json:"updated_at,omitempty" example:"2023-01-01T00:00:00Z"`
+}
+
+// PreferencesRequest updates the preferences of a recipient, known by user ID, address or both
+type PreferencesRequest struct {
+	UserID      string               `json:"user_id,omitempty" example:"player-42"`
+	Address     string               `json:"address,omitempty" example:"+37499123456"`
+	Preferences []*ChannelPreference `json:"preferences"`
+}
+
+// PreferencesResponse lists the preferences of a recipient for every channel and optional
+// message type. Message types without a stored preference are opted in.
+type PreferencesResponse struct {
+	TenantID    int64                `json:"tenant_id" example:"1001"`
+	UserID      string               `json:"user_id,omitempty" example:"player-42"`
+	Address     string               `json:"address,omitempty" example:"+37499123456"`
+	Preferences []*ChannelPreference `json:"preferences"`
+}
+
+// UnsubscribeLinkRequest asks for an unsubscribe link of a recipient
+type UnsubscribeLinkRequest struct {
+	Channel     NotificationType `json:"channel" example:"EMAIL"`
+	Address     string           `json:"address" example:"user@example.com"`
+	MessageType MessageType      `json:"message_type" example:"promo" enums:"bonus,promo,report"`
+}
+
+// UnsubscribeLinkResponse holds a signed unsubscribe link
+type UnsubscribeLinkResponse struct {
+	URL string `json:"url" example:"https://notifications.example.com/u/eyJ0IjoxMDAxfQ.c2lnbmF0dXJl"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// MaxSelfExclusionAddresses bounds the addresses of one self-excluded player
+const MaxSelfExclusionAddresses = 100
+
+// SelfExclusionRequest excludes a player from promotional notifications. Every address of
+// the player is excluded for the same period; an exclusion is lifted by setting ends_at.
+type SelfExclusionRequest struct {
+	UserID    string     `json:"user_id,omitempty" example:"player-42"`
+	Addresses []string   `json:"addresses" example:"player@example.com,+37499123456"`
+	StartsAt  *time.Time `json:"starts_at,omitempty" example:"2024-01-01T00:00:00Z"`
+	EndsAt    *time.Time `json:"ends_at,omitempty" example:"2024-07-01T00:00:00Z"`
+	Detail    string     `json:"detail,omitempty" example:"6 month self-exclusion"`
+}
+
+// Validate checks that the request names at least one address and a consistent period
+func (r *SelfExclusionRequest) Validate() error {
+	if len(r.Addresses) == 0 {
+		return errors.New("addresses list cannot be empty")
+	}
+	if len(r.Addresses) > MaxSelfExclusionAddresses {
+		return errors.New("too many addresses, at most 100 can be excluded at once")
+	}
+	for _, address := range r.Addresses {
+		if strings.TrimSpace(address) == "" {
+			return errors.New("addresses cannot be empty")
+		}
+	}
+	if len(r.UserID) > 128 {
+		return errors.New("user_id is too long")
+	}
+	if r.StartsAt != nil && r.EndsAt != nil && !r.EndsAt.After(*r.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// SelfExclusionEvent is a self-exclusion synced through Kafka
+type SelfExclusionEvent struct {
+	TenantID int64 `json:"tenant_id" example:"1001"`
+	SelfExclusionRequest
+}
+
+// SelfExclusionResponse represents the self-exclusion of an address
+type SelfExclusionResponse struct {
+	ID        int        `json:"id" example:"1"`
+	TenantID  int64      `json:"tenant_id" example:"1001"`
+	UserID    string     `json:"user_id,omitempty" example:"player-42"`
+	Address   string     `json:"address" example:"player@example.com"`
+	StartsAt  time.Time  `json:"starts_at" example:"2024-01-01T00:00:00Z"`
+	EndsAt    *time.Time `json:"ends_at,omitempty" example:"2024-07-01T00:00:00Z"`
+	Active    bool       `json:"active" example:"true"`
+	Source    string     `json:"source,omitempty" example:"kafka"`
+	Detail    string     `json:"detail,omitempty" example:"6 month self-exclusion"`
+	CreatedAt time.Time  `json:"created_at" example:"2023-01-01T00:00:00Z"`
+	UpdatedAt time.Time  `json:"updated_at" example:"2023-01-01T00:00:00Z"`
+}
+
+// SelfExclusionListResponse is a page of a tenant's self-exclusion registry
+type SelfExclusionListResponse struct {
+	TenantID       int64                    `json:"tenant_id" example:"1001"`
+	Total          int                      `json:"total" example:"120"`
+	Limit          int                      `json:"limit" example:"50"`
+	Offset         int                      `json:"offset" example:"0"`
+	SelfExclusions []*SelfExclusionResponse `json:"self_exclusions"`
+}
+
+// SelfExclusionSyncResponse reports the result of syncing a self-exclusion
+type SelfExclusionSyncResponse struct {
+	TenantID       int64                    `json:"tenant_id" example:"1001"`
+	SelfExclusions []*SelfExclusionResponse `json:"self_exclusions"`
+}
+
+// SelfExclusionBlockResponse is an audit entry of a notification withheld from a self-excluded player
+type SelfExclusionBlockResponse struct {
+	ID              int              `json:"id" example:"1"`
+	NotificationID  int              `json:"notification_id" example:"42"`
+	RequestID       string           `json:"request_id" example:"req-123"`
+	Channel         NotificationType `json:"channel" example:"EMAIL"`
+	Address         string           `json:"address" example:"player@example.com"`
+	MessageType     MessageType      `json:"message_type" example:"promo"`
+	SelfExclusionID int              `json:"self_exclusion_id" example:"1"`
+	UserID          string           `json:"user_id,omitempty" example:"player-42"`
+	BlockedAt       time.Time        `json:"blocked_at" example:"2024-01-02T10:00:00Z"`
+}
+
+// SelfExclusionBlockListResponse is a page of a tenant's self-exclusion audit log
+type SelfExclusionBlockListResponse struct {
+	TenantID int64                         `json:"tenant_id" example:"1001"`
+	Total    int                           `json:"total" example:"3"`
+	Limit    int                           `json:"limit" example:"50"`
+	Offset   int                           `json:"offset" example:"0"`
+	Blocks   []*SelfExclusionBlockResponse `json:"blocks"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// SuppressionReason explains why notifications to an address are suppressed
+type SuppressionReason string
+
+const (
+	SuppressionReasonHardBounce  SuppressionReason = "hard_bounce"
+	SuppressionReasonComplaint   SuppressionReason = "complaint"
+	SuppressionReasonUnsubscribe SuppressionReason = "unsubscribe"
+	SuppressionReasonManual      SuppressionReason = "manual"
+)
+
+// SuppressionRequest adds an address to the suppression list of a tenant
+type SuppressionRequest struct {
+	TenantID  int64             `json:"tenant_id" example:"1001"`
+	Channel   NotificationType  `json:"channel" example:"EMAIL"`
+	Address   string            `json:"address" example:"user@example.com"`
+	Reason    SuppressionReason `json:"reason" example:"manual" enums:"hard_bounce,complaint,unsubscribe,manual"`
+	Detail    string            `json:"detail,omitempty" example:"Requested by support"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty" example:"2024-01-01T00:00:00Z"`
+
+	// Internal fields (not exposed in API)
+	Source         string `json:"-" swaggerignore:"true"`
+	NotificationID *int   `json:"-" swaggerignore:"true"`
+}
+
+// IsValid reports whether r is one of the known suppression reasons
+func (r SuppressionReason) IsValid() bool {
+	switch r {
+	case SuppressionReasonHardBounce, SuppressionReasonComplaint, SuppressionReasonUnsubscribe, SuppressionReasonManual:
+		return true
+	}
+	return false
+}
+
+// SuppressionImportRequest adds many addresses of one channel to the suppression list of a tenant
+type SuppressionImportRequest struct {
+	Channel   NotificationType  `json:"channel" example:"EMAIL"`
+	Reason    SuppressionReason `json:"reason" example:"unsubscribe" enums:"hard_bounce,complaint,unsubscribe,manual"`
+	Detail    string            `json:"detail,omitempty" example:"Imported from the old ESP"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty" example:"2024-01-01T00:00:00Z"`
+	Addresses []string          `json:"addresses" example:"user1@example.com,user2@example.com"`
+}
+
+// SuppressionImportResponse reports the result of a bulk import
+type SuppressionImportResponse struct {
+	TenantID int64    `json:"tenant_id" example:"1001"`
+	Imported int      `json:"imported" example:"998"`
+	Invalid  []string `json:"invalid,omitempty" example:"not-an-address"`
+}
+
+// SuppressionResponse represents a suppressed address
+type SuppressionResponse struct {
+	ID             int               `json:"id" example:"1"`
+	TenantID       int64             `json:"tenant_id" example:"1001"`
+	Channel        NotificationType  `json:"channel" example:"EMAIL"`
+	Address        string            `json:"address" example:"user@example.com"`
+	Reason         SuppressionReason `json:"reason" example:"hard_bounce"`
+	Source         string            `json:"source,omitempty" example:"ses"`
+	Detail         string            `json:"detail,omitempty" example:"5.1.1 smtp; 550 User unknown"`
+	NotificationID *int              `json:"notification_id,omitempty" example:"42"`
+	ExpiresAt      *time.Time        `json:"expires_at,omitempty" example:"2024-01-01T00:00:00Z"`
+	CreatedAt      time.Time         `json:"created_at" example:"2023-01-01T00:00:00Z"`
+	UpdatedAt      time.Time         `json:"updated_at" example:"2023-01-01T00:00:00Z"`
+}
+
+// SuppressionListResponse is a page of a tenant's suppression list
+type SuppressionListResponse struct {
+	TenantID     int64                  `json:"tenant_id" example:"1001"`
+	Total        int                    `json:"total" example:"1250"`
+	Limit        int                    `json:"limit" example:"50"`
+	Offset       int                    `json:"offset" example:"0"`
+	Suppressions []*SuppressionResponse `json:"suppressions"`
+}
@@ -0,0 +1,412 @@
+package email
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+)
+
+const (
+	defaultMaxAttachmentSize      = 10 * 1024 * 1024
+	defaultMaxTotalAttachmentSize = 25 * 1024 * 1024
+	defaultAttachmentRegion       = "eu-central-1"
+)
+
+// nonPublicNetworks are the ranges net.IP doesn't classify itself that attachments are never
+// downloaded from: "this network", carrier-grade NAT and benchmarking addresses
+var nonPublicNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("198.18.0.0/15"),
+}
+
+// attachmentLoader resolves notification attachments into message parts, fetching
+// attachments referenced by URL or object key and enforcing the configured size limits.
+// URLs are limited to https on the configured hosts and objects to the configured bucket,
+// so callers can't make the service read internal endpoints or other buckets.
+type attachmentLoader struct {
+	config     *schema.SMTPConfig
+	httpClient *http.Client
+
+	s3Once   sync.Once
+	s3Client *s3.S3
+	s3Err    error
+}
+
+func newAttachmentLoader(config *schema.SMTPConfig) *attachmentLoader {
+	loader := &attachmentLoader{
+		config: config,
+	}
+
+	// Addresses are checked after DNS resolution, right before connecting, so hosts resolving
+	// to private addresses and redirects to them are refused as well
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("address %s is not public", host)
+			}
+			return nil
+		},
+	}
+
+	loader.httpClient = &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("too many redirects")
+			}
+			return loader.checkURL(req.URL)
+		},
+	}
+
+	return loader
+}
+
+// attachmentCache shares downloaded attachments between the messages of a batch, which usually
+// reference the same files
+type attachmentCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedAttachment
+}
+
+type cachedAttachment struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+func newAttachmentCache() *attachmentCache {
+	return &attachmentCache{entries: make(map[string]*cachedAttachment)}
+}
+
+// get returns the data stored under key, loading it the first time it's asked for. A nil
+// cache loads every time.
+func (c *attachmentCache) get(key string, load func() ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return load()
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cachedAttachment{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.data, entry.err = load()
+	})
+	return entry.data, entry.err
+}
+
+// Load resolves all attachments, failing when any of them can't be loaded or the limits are
+// exceeded. Downloads are shared through the cache when one is given.
+func (l *attachmentLoader) Load(ctx context.Context, attachments []*schema.Attachment, cache *attachmentCache) ([]MessageAttachment, error) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	maxSize := l.maxAttachmentSize()
+	maxTotal := l.maxTotalAttachmentSize()
+
+	result := make([]MessageAttachment, 0, len(attachments))
+	var total int64
+
+	for _, attachment := range attachments {
+		if attachment == nil {
+			continue
+		}
+
+		data, err := l.loadData(ctx, attachment, maxSize, cache)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load attachment %s: %w", attachment.Filename, err)
+		}
+
+		if int64(len(data)) > maxSize {
+			return nil, fmt.Errorf("attachment %s exceeds the size limit of %d bytes", attachment.Filename, maxSize)
+		}
+
+		total += int64(len(data))
+		if total > maxTotal {
+			return nil, fmt.Errorf("attachments exceed the total size limit of %d bytes", maxTotal)
+		}
+
+		inline := strings.EqualFold(attachment.Disposition, "inline")
+		contentID := attachment.ContentID
+		if inline && contentID == "" {
+			contentID = attachment.Filename
+		}
+
+		result = append(result, MessageAttachment{
+			Filename:    attachment.Filename,
+			ContentType: detectContentType(attachment, data),
+			ContentID:   contentID,
+			Inline:      inline,
+			Data:        data,
+		})
+	}
+
+	return result, nil
+}
+
+// ValidateAttachments checks attachments against an SMTP config before they're accepted: the
+// declared types, the size of base64 content and where URL and S3 attachments come from.
+// Downloaded attachments are only measured once they're loaded.
+func ValidateAttachments(config *schema.SMTPConfig, attachments []*schema.Attachment) error {
+	loader := &attachmentLoader{config: config}
+	maxSize := loader.maxAttachmentSize()
+	maxTotal := loader.maxTotalAttachmentSize()
+
+	var total int64
+	for _, attachment := range attachments {
+		if attachment == nil {
+			continue
+		}
+
+		if attachment.Type != "" {
+			if _, _, err := mime.ParseMediaType(attachment.Type); err != nil {
+				return fmt.Errorf("attachment %s has an invalid type %q", attachment.Filename, attachment.Type)
+			}
+		}
+
+		switch {
+		case attachment.Content != "":
+			data, err := base64.StdEncoding.DecodeString(attachment.Content)
+			if err != nil {
+				return fmt.Errorf("attachment %s has invalid base64 content: %w", attachment.Filename, err)
+			}
+			size := int64(len(data))
+			if size > maxSize {
+				return fmt.Errorf("attachment %s exceeds the size limit of %d bytes", attachment.Filename, maxSize)
+			}
+			total += size
+			if total > maxTotal {
+				return fmt.Errorf("attachments exceed the total size limit of %d bytes", maxTotal)
+			}
+
+		case attachment.URL != "":
+			parsed, err := url.Parse(attachment.URL)
+			if err != nil {
+				return fmt.Errorf("attachment %s has an invalid url: %w", attachment.Filename, err)
+			}
+			if err := loader.checkURL(parsed); err != nil {
+				return fmt.Errorf("attachment %s: %w", attachment.Filename, err)
+			}
+
+		case attachment.ObjectKey != "":
+			if _, err := loader.checkObject(attachment.Bucket, attachment.ObjectKey); err != nil {
+				return fmt.Errorf("attachment %s: %w", attachment.Filename, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadData returns the raw attachment bytes from whichever source the attachment uses
+func (l *attachmentLoader) loadData(ctx context.Context, attachment *schema.Attachment, maxSize int64, cache *attachmentCache) ([]byte, error) {
+	switch {
+	case attachment.Content != "":
+		data, err := base64.StdEncoding.DecodeString(attachment.Content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 content: %w", err)
+		}
+		return data, nil
+
+	case attachment.URL != "":
+		return cache.get("url:"+attachment.URL, func() ([]byte, error) {
+			return l.fetchURL(ctx, attachment.URL, maxSize)
+		})
+
+	case attachment.ObjectKey != "":
+		return cache.get("s3:"+attachment.Bucket+"/"+attachment.ObjectKey, func() ([]byte, error) {
+			return l.fetchObject(ctx, attachment.Bucket, attachment.ObjectKey, maxSize)
+		})
+
+	default:
+		return nil, fmt.Errorf("attachment has no content, url or object key")
+	}
+}
+
+// fetchURL downloads an attachment over HTTPS from an allowed host, reading at most one byte
+// past the size limit
+func (l *attachmentLoader) fetchURL(ctx context.Context, rawURL string, maxSize int64) ([]byte, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if err := l.checkURL(parsed); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+}
+
+// checkURL allows https URLs on the configured attachment hosts only
+func (l *attachmentLoader) checkURL(target *url.URL) error {
+	if target.Scheme != "https" {
+		return fmt.Errorf("only https urls are allowed")
+	}
+
+	host := strings.ToLower(target.Hostname())
+	for _, allowed := range l.config.AttachmentURLHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s is not an allowed attachment host", host)
+}
+
+// fetchObject downloads an attachment from the configured S3 bucket. A bucket given with the
+// attachment must be the configured one and the key must start with the configured prefix.
+func (l *attachmentLoader) fetchObject(ctx context.Context, requestedBucket, key string, maxSize int64) ([]byte, error) {
+	bucket, err := l.checkObject(requestedBucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := l.getS3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s/%s: %w", bucket, key, err)
+	}
+	defer output.Body.Close()
+
+	if output.ContentLength != nil && *output.ContentLength > maxSize {
+		return nil, fmt.Errorf("object %s/%s exceeds the size limit of %d bytes", bucket, key, maxSize)
+	}
+
+	return io.ReadAll(io.LimitReader(output.Body, maxSize+1))
+}
+
+// checkObject allows keys under the configured prefix of the configured bucket only and returns the bucket
+func (l *attachmentLoader) checkObject(requestedBucket, key string) (string, error) {
+	bucket := l.config.AttachmentBucket
+	if bucket == "" {
+		return "", fmt.Errorf("no bucket configured for object key %s", key)
+	}
+	if requestedBucket != "" && requestedBucket != bucket {
+		return "", fmt.Errorf("bucket %s is not the attachment bucket", requestedBucket)
+	}
+	if !strings.HasPrefix(key, l.config.AttachmentKeyPrefix) {
+		return "", fmt.Errorf("object key %s is outside the attachment prefix", key)
+	}
+	return bucket, nil
+}
+
+// getS3Client lazily creates the S3 client the first time an object is requested
+func (l *attachmentLoader) getS3Client() (*s3.S3, error) {
+	l.s3Once.Do(func() {
+		region := l.config.AttachmentRegion
+		if region == "" {
+			region = defaultAttachmentRegion
+		}
+
+		sess, err := session.NewSession(&aws.Config{
+			Region: aws.String(region),
+		})
+		if err != nil {
+			l.s3Err = fmt.Errorf("failed to create AWS session: %w", err)
+			return
+		}
+
+		l.s3Client = s3.New(sess)
+	})
+
+	return l.s3Client, l.s3Err
+}
+
+func (l *attachmentLoader) maxAttachmentSize() int64 {
+	if l.config.MaxAttachmentSize > 0 {
+		return l.config.MaxAttachmentSize
+	}
+	return defaultMaxAttachmentSize
+}
+
+func (l *attachmentLoader) maxTotalAttachmentSize() int64 {
+	if l.config.MaxTotalAttachmentSize > 0 {
+		return l.config.MaxTotalAttachmentSize
+	}
+	return defaultMaxTotalAttachmentSize
+}
+
+// detectContentType uses the declared type, then the file extension, then content sniffing
+func detectContentType(attachment *schema.Attachment, data []byte) string {
+	if attachment.Type != "" {
+		return attachment.Type
+	}
+	if byExtension := mime.TypeByExtension(filepath.Ext(attachment.Filename)); byExtension != "" {
+		return byExtension
+	}
+	return http.DetectContentType(data)
+}
+
+// isPublicIP reports whether attachments may be downloaded from the address
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
@@ -0,0 +1,302 @@
+package email
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+)
+
+func TestAttachmentLoaderLoad(t *testing.T) {
+	pdf := base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 report"))
+	png := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\nlogo"))
+
+	tests := []struct {
+		name        string
+		config      schema.SMTPConfig
+		attachments []*schema.Attachment
+		want        []MessageAttachment
+		wantErr     string
+	}{
+		{
+			name:        "declared type",
+			attachments: []*schema.Attachment{{Filename: "report", Content: pdf, Type: "application/pdf"}},
+			want:        []MessageAttachment{{Filename: "report", ContentType: "application/pdf", Data: []byte("%PDF-1.4 report")}},
+		},
+		{
+			name:        "type from extension",
+			attachments: []*schema.Attachment{{Filename: "report.pdf", Content: pdf}},
+			want:        []MessageAttachment{{Filename: "report.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4 report")}},
+		},
+		{
+			name:        "sniffed type",
+			attachments: []*schema.Attachment{{Filename: "logo", Content: png}},
+			want:        []MessageAttachment{{Filename: "logo", ContentType: "image/png", Data: []byte("\x89PNG\r\n\x1a\nlogo")}},
+		},
+		{
+			name:        "inline uses the filename as Content-ID",
+			attachments: []*schema.Attachment{{Filename: "logo.png", Content: png, Disposition: "inline"}},
+			want:        []MessageAttachment{{Filename: "logo.png", ContentType: "image/png", ContentID: "logo.png", Inline: true, Data: []byte("\x89PNG\r\n\x1a\nlogo")}},
+		},
+		{
+			name:        "nil attachments are skipped",
+			attachments: []*schema.Attachment{nil, {Filename: "report.pdf", Content: pdf}},
+			want:        []MessageAttachment{{Filename: "report.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4 report")}},
+		},
+		{
+			name:        "invalid base64",
+			attachments: []*schema.Attachment{{Filename: "report.pdf", Content: "not base64!"}},
+			wantErr:     "invalid base64 content",
+		},
+		{
+			name:        "no source",
+			attachments: []*schema.Attachment{{Filename: "report.pdf"}},
+			wantErr:     "no content, url or object key",
+		},
+		{
+			name:        "too large",
+			config:      schema.SMTPConfig{MaxAttachmentSize: 4},
+			attachments: []*schema.Attachment{{Filename: "report.pdf", Content: pdf}},
+			wantErr:     "exceeds the size limit",
+		},
+		{
+			name:        "object without a bucket",
+			attachments: []*schema.Attachment{{Filename: "report.pdf", ObjectKey: "invoices/report.pdf"}},
+			wantErr:     "no bucket configured",
+		},
+		{
+			name:        "object in another bucket",
+			config:      schema.SMTPConfig{AttachmentBucket: "attachments"},
+			attachments: []*schema.Attachment{{Filename: "report.pdf", Bucket: "backups", ObjectKey: "invoices/report.pdf"}},
+			wantErr:     "is not the attachment bucket",
+		},
+		{
+			name:        "object outside the prefix",
+			config:      schema.SMTPConfig{AttachmentBucket: "attachments", AttachmentKeyPrefix: "notifications/"},
+			attachments: []*schema.Attachment{{Filename: "report.pdf", ObjectKey: "invoices/report.pdf"}},
+			wantErr:     "outside the attachment prefix",
+		},
+		{
+			name:        "total too large",
+			config:      schema.SMTPConfig{MaxTotalAttachmentSize: 20},
+			attachments: []*schema.Attachment{{Filename: "a.pdf", Content: pdf}, {Filename: "b.pdf", Content: pdf}},
+			wantErr:     "total size limit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := newAttachmentLoader(&tt.config)
+			got, err := loader.Load(context.Background(), tt.attachments, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d attachments, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i].Filename != tt.want[i].Filename || got[i].ContentType != tt.want[i].ContentType ||
+					got[i].ContentID != tt.want[i].ContentID || got[i].Inline != tt.want[i].Inline ||
+					string(got[i].Data) != string(tt.want[i].Data) {
+					t.Errorf("attachment %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAttachmentLoaderFetchURL(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/report.pdf":
+			w.Write([]byte("%PDF-1.4 report"))
+		case "/large.pdf":
+			w.Write([]byte(strings.Repeat("x", 64)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		// dial uses the loader's own client, which refuses non-public addresses
+		dial    bool
+		want    string
+		wantErr string
+	}{
+		{name: "downloaded", url: server.URL + "/report.pdf", want: "%PDF-1.4 report"},
+		{name: "not found", url: server.URL + "/missing.pdf", wantErr: "status 404"},
+		{name: "too large", url: server.URL + "/large.pdf", wantErr: "exceeds the size limit"},
+		{name: "plain http", url: strings.Replace(server.URL, "https://", "http://", 1) + "/report.pdf", wantErr: "only https urls are allowed"},
+		{name: "other host", url: "https://example.com/report.pdf", wantErr: "not an allowed attachment host"},
+		{name: "private address", url: server.URL + "/report.pdf", dial: true, wantErr: "is not public"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := newAttachmentLoader(&schema.SMTPConfig{MaxAttachmentSize: 32, AttachmentURLHosts: []string{"127.0.0.1"}})
+			if !tt.dial {
+				loader.httpClient = server.Client()
+			}
+			got, err := loader.Load(context.Background(), []*schema.Attachment{{Filename: "report.pdf", URL: tt.url}}, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if string(got[0].Data) != tt.want {
+				t.Errorf("data = %q, want %q", got[0].Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttachmentCacheDownloadsOnce(t *testing.T) {
+	var downloads atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		w.Write([]byte("%PDF-1.4 report"))
+	}))
+	defer server.Close()
+
+	loader := newAttachmentLoader(&schema.SMTPConfig{AttachmentURLHosts: []string{"127.0.0.1"}})
+	loader.httpClient = server.Client()
+	attachments := []*schema.Attachment{{Filename: "report.pdf", URL: server.URL + "/report.pdf"}}
+
+	cache := newAttachmentCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := loader.Load(context.Background(), attachments, cache); err != nil {
+				t.Errorf("Load() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := downloads.Load(); got != 1 {
+		t.Errorf("downloaded %d times, want 1", got)
+	}
+}
+
+func TestValidateAttachments(t *testing.T) {
+	config := &schema.SMTPConfig{
+		MaxAttachmentSize:      8,
+		MaxTotalAttachmentSize: 12,
+		AttachmentURLHosts:     []string{"cdn.example.com"},
+		AttachmentBucket:       "attachments",
+		AttachmentKeyPrefix:    "invoices/",
+	}
+	small := base64.StdEncoding.EncodeToString([]byte("1234567"))
+
+	tests := []struct {
+		name        string
+		attachments []*schema.Attachment
+		wantErr     string
+	}{
+		{name: "valid", attachments: []*schema.Attachment{
+			nil,
+			{Filename: "a.pdf", Content: small, Type: "application/pdf"},
+			{Filename: "b.pdf", URL: "https://cdn.example.com/b.pdf"},
+			{Filename: "c.pdf", ObjectKey: "invoices/c.pdf"},
+		}},
+		{name: "too large", attachments: []*schema.Attachment{{Filename: "a.pdf", Content: base64.StdEncoding.EncodeToString([]byte("123456789"))}}, wantErr: "exceeds the size limit"},
+		{name: "total too large", attachments: []*schema.Attachment{{Filename: "a.pdf", Content: small}, {Filename: "b.pdf", Content: small}}, wantErr: "total size limit"},
+		{name: "invalid type", attachments: []*schema.Attachment{{Filename: "a.pdf", Content: small, Type: "pdf;;"}}, wantErr: "invalid type"},
+		{name: "other host", attachments: []*schema.Attachment{{Filename: "b.pdf", URL: "https://example.com/b.pdf"}}, wantErr: "not an allowed attachment host"},
+		{name: "other bucket", attachments: []*schema.Attachment{{Filename: "c.pdf", Bucket: "backups", ObjectKey: "invoices/c.pdf"}}, wantErr: "not the attachment bucket"},
+		{name: "outside prefix", attachments: []*schema.Attachment{{Filename: "c.pdf", ObjectKey: "private/c.pdf"}}, wantErr: "outside the attachment prefix"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAttachments(config, tt.attachments)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateAttachments() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateAttachments() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckURLHosts(t *testing.T) {
+	loader := newAttachmentLoader(&schema.SMTPConfig{AttachmentURLHosts: []string{"cdn.example.com", "*.files.example.com"}})
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://cdn.example.com/report.pdf", want: true},
+		{url: "https://CDN.example.com/report.pdf", want: true},
+		{url: "https://eu.files.example.com/report.pdf", want: true},
+		{url: "https://files.example.com/report.pdf"},
+		{url: "https://evilfiles.example.com/report.pdf"},
+		{url: "https://cdn.example.com.evil.org/report.pdf"},
+		{url: "http://cdn.example.com/report.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			target, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatalf("invalid url: %v", err)
+			}
+			if err := loader.checkURL(target); (err == nil) != tt.want {
+				t.Errorf("checkURL() error = %v, want allowed %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "93.184.216.34", want: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{ip: "127.0.0.1"},
+		{ip: "10.0.0.1"},
+		{ip: "192.168.1.1"},
+		{ip: "169.254.169.254"},
+		{ip: "100.64.0.1"},
+		{ip: "0.0.0.0"},
+		{ip: "::1"},
+		{ip: "fd00::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("isPublicIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// ErrNotBounceReport is returned for messages that aren't RFC 3464 delivery status
+// notifications or RFC 5965 abuse reports
+var ErrNotBounceReport = errors.New("message is not a bounce or complaint report")
+
+// BounceReport is a parsed delivery status notification (DSN) or abuse feedback report (ARF)
+type BounceReport struct {
+	// OriginalMessageID is the Message-ID of the email the report is about, empty when the
+	// report doesn't include the original headers
+	OriginalMessageID string
+	Complaint         bool
+	FeedbackType      string
+	Recipients        []*BounceRecipient
+}
+
+// BounceRecipient holds the per-recipient fields of a DSN
+type BounceRecipient struct {
+	Recipient      string
+	Action         string
+	Status         string
+	DiagnosticCode string
+}
+
+// EventType classifies the recipient's report. Permanent failures are hard bounces except
+// for statuses that don't say the address is bad (mailbox full, message too large, policy
+// rejections), which are treated as soft bounces like temporary failures and delays.
+func (r *BounceRecipient) EventType() (models.EmailEventType, bool) {
+	switch strings.ToLower(r.Action) {
+	case "delivered", "relayed", "expanded":
+		return models.EmailEventDelivered, true
+	case "delayed":
+		return models.EmailEventSoftBounce, true
+	case "failed":
+		return ClassifyBounceStatus(r.Status), true
+	default:
+		return "", false
+	}
+}
+
+// ClassifyBounceStatus maps an enhanced status code (RFC 3463) of a failed delivery to a bounce type
+func ClassifyBounceStatus(status string) models.EmailEventType {
+	parts := strings.SplitN(strings.TrimSpace(status), ".", 3)
+	if len(parts) != 3 || parts[0] != "5" {
+		return models.EmailEventSoftBounce
+	}
+
+	switch {
+	case parts[1] == "2" && parts[2] == "2": // mailbox full
+		return models.EmailEventSoftBounce
+	case parts[1] == "3" && parts[2] == "4": // message too big
+		return models.EmailEventSoftBounce
+	case parts[1] == "7": // security or policy rejection
+		return models.EmailEventSoftBounce
+	default:
+		return models.EmailEventHardBounce
+	}
+}
+
+// Events converts the report to one email event per recipient
+func (r *BounceReport) Events(tenantID int64) []*models.EmailEvent {
+	source := "dsn"
+	if r.Complaint {
+		source = "arf"
+	}
+
+	var events []*models.EmailEvent
+	for _, recipient := range r.Recipients {
+		event := &models.EmailEvent{
+			TenantID:   tenantID,
+			Source:     source,
+			MessageID:  r.OriginalMessageID,
+			Recipient:  recipient.Recipient,
+			StatusCode: recipient.Status,
+			Diagnostic: recipient.DiagnosticCode,
+		}
+
+		if r.Complaint {
+			event.Type = models.EmailEventComplaint
+			event.Diagnostic = r.FeedbackType
+		} else if eventType, ok := recipient.EventType(); ok {
+			event.Type = eventType
+		} else {
+			continue
+		}
+
+		events = append(events, event)
+	}
+	return events
+}
+
+// ParseBounceReport parses a multipart/report message with a message/delivery-status
+// (RFC 3464) or message/feedback-report (RFC 5965) part
+func ParseBounceReport(raw []byte) (*BounceReport, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || params["boundary"] == "" {
+		return nil, ErrNotBounceReport
+	}
+
+	report := &BounceReport{}
+	found := false
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report part: %w", err)
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		body := decodePart(part)
+
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			recipients, err := parseDeliveryStatus(body)
+			if err != nil {
+				return nil, err
+			}
+			report.Recipients = append(report.Recipients, recipients...)
+			found = true
+
+		case "message/feedback-report":
+			fields, err := textproto.NewReader(bufio.NewReader(body)).ReadMIMEHeader()
+			if err != nil && err != io.EOF {
+				return nil, fmt.Errorf("failed to parse feedback report: %w", err)
+			}
+			report.Complaint = true
+			report.FeedbackType = fields.Get("Feedback-Type")
+			report.Recipients = append(report.Recipients, &BounceRecipient{
+				Recipient: stripAddressType(fields.Get("Original-Rcpt-To")),
+			})
+			found = true
+
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			if original, err := mail.ReadMessage(body); err == nil {
+				report.OriginalMessageID = NormalizeMessageID(original.Header.Get("Message-ID"))
+				// ARF reports without Original-Rcpt-To name the recipient in the original headers
+				for _, recipient := range report.Recipients {
+					if recipient.Recipient != "" {
+						continue
+					}
+					if to, err := original.Header.AddressList("To"); err == nil && len(to) > 0 {
+						recipient.Recipient = to[0].Address
+					}
+				}
+			}
+		}
+	}
+
+	if !found {
+		return nil, ErrNotBounceReport
+	}
+
+	return report, nil
+}
+
+// parseDeliveryStatus parses the per-message fields followed by the per-recipient field groups
+func parseDeliveryStatus(body io.Reader) ([]*BounceRecipient, error) {
+	reader := textproto.NewReader(bufio.NewReader(body))
+
+	var recipients []*BounceRecipient
+	first := true
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to parse delivery status: %w", err)
+		}
+
+		// The first group holds the per-message fields
+		if len(fields) > 0 && !first {
+			recipient := fields.Get("Final-Recipient")
+			if recipient == "" {
+				recipient = fields.Get("Original-Recipient")
+			}
+			recipients = append(recipients, &BounceRecipient{
+				Recipient:      stripAddressType(recipient),
+				Action:         strings.TrimSpace(fields.Get("Action")),
+				Status:         statusCode(fields.Get("Status")),
+				DiagnosticCode: stripAddressType(fields.Get("Diagnostic-Code")),
+			})
+		}
+		if len(fields) > 0 {
+			first = false
+		}
+
+		if err == io.EOF {
+			return recipients, nil
+		}
+	}
+}
+
+// decodePart undoes a base64 transfer encoding, quoted-printable is decoded by the multipart reader
+func decodePart(part *multipart.Part) io.Reader {
+	if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+		return base64.NewDecoder(base64.StdEncoding, part)
+	}
+	return part
+}
+
+// stripAddressType removes the type prefix of DSN fields such as "rfc822; user@example.com"
+func stripAddressType(value string) string {
+	if semicolon := strings.Index(value, ";"); semicolon >= 0 {
+		value = value[semicolon+1:]
+	}
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+// statusCode returns the enhanced status code without a trailing comment
+func statusCode(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// NormalizeMessageID returns a Message-ID in the angle bracket form it is stored in
+func NormalizeMessageID(messageID string) string {
+	messageID = strings.TrimSpace(messageID)
+	if messageID == "" {
+		return ""
+	}
+	return "<" + strings.Trim(messageID, "<>") + ">"
+}
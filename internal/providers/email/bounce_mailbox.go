@@ -0,0 +1,204 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+)
+
+// BounceMailbox reads the bounce mailbox of an SMTP provider over POP3
+type BounceMailbox struct {
+	config schema.SMTPConfig
+}
+
+// NewBounceMailbox creates the bounce mailbox of an SMTP provider config, nil when it has none
+func NewBounceMailbox(config map[string]interface{}) (*BounceMailbox, error) {
+	smtpConfig, err := ParseSMTPConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if smtpConfig.BounceMailboxHost == "" {
+		return nil, nil
+	}
+	if smtpConfig.BounceMailboxUsername == "" || smtpConfig.BounceMailboxPassword == "" {
+		return nil, fmt.Errorf("bounce mailbox username and password are required")
+	}
+
+	return &BounceMailbox{config: smtpConfig}, nil
+}
+
+// Poll retrieves up to limit messages and passes each to handle. Messages are deleted
+// from the mailbox when handle succeeds and kept for the next poll otherwise.
+// It returns the number of handled messages.
+func (b *BounceMailbox) Poll(ctx context.Context, limit int, handle func(raw []byte) error) (int, error) {
+	client, err := b.dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.quit()
+
+	if err := client.auth(b.config.BounceMailboxUsername, b.config.BounceMailboxPassword); err != nil {
+		return 0, err
+	}
+
+	count, err := client.stat()
+	if err != nil {
+		return 0, err
+	}
+	if count > limit {
+		count = limit
+	}
+
+	handled := 0
+	for number := 1; number <= count; number++ {
+		if err := ctx.Err(); err != nil {
+			return handled, err
+		}
+
+		raw, err := client.retr(number)
+		if err != nil {
+			return handled, err
+		}
+
+		if err := handle(raw); err != nil {
+			continue
+		}
+
+		if err := client.dele(number); err != nil {
+			return handled, err
+		}
+		handled++
+	}
+
+	// Deletions only take effect when the session ends with QUIT
+	return handled, client.quit()
+}
+
+// dial connects using implicit TLS for "ssl" or port 995, STLS for "tls" and plain POP3 otherwise
+func (b *BounceMailbox) dial(ctx context.Context) (*pop3Client, error) {
+	port := b.config.BounceMailboxPort
+	if port == "" {
+		port = "995"
+	}
+	addr := net.JoinHostPort(b.config.BounceMailboxHost, port)
+	tlsConfig := &tls.Config{
+		ServerName: b.config.BounceMailboxHost,
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	implicitTLS := b.config.BounceMailboxSecure == "ssl" || port == "995"
+
+	var conn net.Conn
+	var err error
+	if implicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to bounce mailbox: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(messageTimeout))
+
+	client := &pop3Client{conn: conn, text: textproto.NewConn(conn)}
+	if _, err := client.readResponse(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if !implicitTLS && b.config.BounceMailboxSecure == "tls" {
+		if _, err := client.cmd("STLS"); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("STLS failed: %w", err)
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("STLS failed: %w", err)
+		}
+		client.conn = tlsConn
+		client.text = textproto.NewConn(tlsConn)
+	}
+
+	return client, nil
+}
+
+// pop3Client implements the subset of POP3 (RFC 1939) needed to drain a mailbox
+type pop3Client struct {
+	conn net.Conn
+	text *textproto.Conn
+	done bool
+}
+
+func (c *pop3Client) cmd(format string, args ...interface{}) (string, error) {
+	c.conn.SetDeadline(time.Now().Add(messageTimeout))
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+	return c.readResponse()
+}
+
+func (c *pop3Client) readResponse() (string, error) {
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(line, "+OK") {
+		return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+	}
+	return "", fmt.Errorf("POP3 error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+}
+
+func (c *pop3Client) auth(username, password string) error {
+	if _, err := c.cmd("USER %s", username); err != nil {
+		return fmt.Errorf("POP3 authentication failed: %w", err)
+	}
+	if _, err := c.cmd("PASS %s", password); err != nil {
+		return fmt.Errorf("POP3 authentication failed: %w", err)
+	}
+	return nil
+}
+
+func (c *pop3Client) stat() (int, error) {
+	response, err := c.cmd("STAT")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(response)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("invalid STAT response: %q", response)
+	}
+	return strconv.Atoi(fields[0])
+}
+
+func (c *pop3Client) retr(number int) ([]byte, error) {
+	if _, err := c.cmd("RETR %d", number); err != nil {
+		return nil, err
+	}
+	return c.text.ReadDotBytes()
+}
+
+func (c *pop3Client) dele(number int) error {
+	_, err := c.cmd("DELE %d", number)
+	return err
+}
+
+// quit ends the session once, committing deletions
+func (c *pop3Client) quit() error {
+	if c.done {
+		return nil
+	}
+	c.done = true
+	defer c.text.Close()
+	_, err := c.cmd("QUIT")
+	return err
+}
@@ -0,0 +1,163 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// crlf converts a readable fixture to the line endings of a real message
+func crlf(message string) []byte {
+	return []byte(strings.ReplaceAll(message, "\n", "\r\n"))
+}
+
+const deliveryStatusNotification = `From: MAILER-DAEMON@mx.example.org
+To: bounces@example.com
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="b1"
+
+--b1
+Content-Type: text/plain
+
+The mail system could not deliver your message.
+
+--b1
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.org
+
+Final-Recipient: rfc822; gone@example.org
+Action: failed
+Status: 5.1.1 (user unknown)
+Diagnostic-Code: smtp; 550 5.1.1 user unknown
+
+Final-Recipient: rfc822; full@example.org
+Action: failed
+Status: 5.2.2
+Diagnostic-Code: smtp; 552 mailbox full
+
+Original-Recipient: rfc822; slow@example.org
+Action: delayed
+Status: 4.4.1
+
+--b1
+Content-Type: text/rfc822-headers
+
+Message-ID: <abc@example.com>
+From: noreply@example.com
+To: gone@example.org
+Subject: Hello
+
+--b1--
+`
+
+const abuseReport = `From: feedback@isp.example.net
+To: abuse@example.com
+Subject: Abuse report
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report; boundary="b2"
+
+--b2
+Content-Type: text/plain
+
+This is an email abuse report.
+
+--b2
+Content-Type: message/feedback-report
+
+Feedback-Type: abuse
+User-Agent: ISP/1.0
+Version: 1
+
+--b2
+Content-Type: message/rfc822
+
+Message-ID: <promo@example.com>
+From: noreply@example.com
+To: Player <player@example.org>
+Subject: Bonus
+
+Claim your bonus.
+--b2--
+`
+
+func TestParseBounceReport(t *testing.T) {
+	report, err := ParseBounceReport(crlf(deliveryStatusNotification))
+	if err != nil {
+		t.Fatalf("ParseBounceReport() error = %v", err)
+	}
+	if report.Complaint || report.OriginalMessageID != "<abc@example.com>" {
+		t.Errorf("report = %+v, want a DSN about <abc@example.com>", report)
+	}
+
+	events := report.Events(7)
+	want := []struct {
+		recipient string
+		eventType models.EmailEventType
+		status    string
+	}{
+		{recipient: "gone@example.org", eventType: models.EmailEventHardBounce, status: "5.1.1"},
+		{recipient: "full@example.org", eventType: models.EmailEventSoftBounce, status: "5.2.2"},
+		{recipient: "slow@example.org", eventType: models.EmailEventSoftBounce, status: "4.4.1"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, event := range events {
+		if event.Source != "dsn" || event.MessageID != "<abc@example.com>" || event.Recipient != want[i].recipient ||
+			event.Type != want[i].eventType || event.StatusCode != want[i].status {
+			t.Errorf("event %d = %+v, want %+v", i, event, want[i])
+		}
+	}
+}
+
+func TestParseBounceReportComplaint(t *testing.T) {
+	report, err := ParseBounceReport(crlf(abuseReport))
+	if err != nil {
+		t.Fatalf("ParseBounceReport() error = %v", err)
+	}
+
+	events := report.Events(7)
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	// The recipient comes from the original headers when the report has no Original-Rcpt-To
+	event := events[0]
+	if event.Source != "arf" || event.Type != models.EmailEventComplaint || event.Recipient != "player@example.org" ||
+		event.MessageID != "<promo@example.com>" || event.Diagnostic != "abuse" {
+		t.Errorf("event = %+v", event)
+	}
+}
+
+func TestParseBounceReportNotReport(t *testing.T) {
+	message := crlf("From: player@example.org\nTo: bounces@example.com\nSubject: Out of office\nContent-Type: text/plain\n\nI'm away.\n")
+	if _, err := ParseBounceReport(message); !errors.Is(err, ErrNotBounceReport) {
+		t.Errorf("ParseBounceReport() error = %v, want ErrNotBounceReport", err)
+	}
+}
+
+func TestClassifyBounceStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   models.EmailEventType
+	}{
+		{status: "5.1.1", want: models.EmailEventHardBounce},
+		{status: "5.1.10", want: models.EmailEventHardBounce},
+		{status: "5.2.2", want: models.EmailEventSoftBounce},
+		{status: "5.3.4", want: models.EmailEventSoftBounce},
+		{status: "5.7.1", want: models.EmailEventSoftBounce},
+		{status: "4.2.2", want: models.EmailEventSoftBounce},
+		{status: "", want: models.EmailEventSoftBounce},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if got := ClassifyBounceStatus(tt.status); got != tt.want {
+				t.Errorf("ClassifyBounceStatus() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,384 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultDKIMSignedHeaders are signed when the provider config doesn't list its own
+var defaultDKIMSignedHeaders = []string{
+	"From", "Reply-To", "To", "Subject", "Date", "Message-ID",
+	"MIME-Version", "Content-Type", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+var whitespacePattern = regexp.MustCompile(`[ \t]+`)
+
+// DKIMSigner signs outgoing messages with relaxed/relaxed canonicalization (RFC 6376)
+type DKIMSigner struct {
+	domain   string
+	selector string
+	signer   crypto.Signer
+	headers  []string
+}
+
+// NewDKIMSigner creates a signer from a PEM encoded RSA or Ed25519 private key
+func NewDKIMSigner(domain, selector, privateKey string, headers []string) (*DKIMSigner, error) {
+	if domain == "" || selector == "" {
+		return nil, fmt.Errorf("DKIM domain and selector are required")
+	}
+
+	signer, err := parseDKIMPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(headers) == 0 {
+		headers = defaultDKIMSignedHeaders
+	}
+
+	// From must always be signed
+	hasFrom := false
+	for _, header := range headers {
+		if strings.EqualFold(header, "From") {
+			hasFrom = true
+			break
+		}
+	}
+	if !hasFrom {
+		headers = append([]string{"From"}, headers...)
+	}
+
+	return &DKIMSigner{
+		domain:   domain,
+		selector: selector,
+		signer:   signer,
+		headers:  headers,
+	}, nil
+}
+
+// Sign returns the message with a DKIM-Signature header prepended
+func (d *DKIMSigner) Sign(message []byte) ([]byte, error) {
+	headers, body := splitMessage(message)
+	fields := parseHeaderFields(headers)
+
+	bodyHash := sha256.Sum256(canonicalizeBodyRelaxed(body))
+
+	algorithm := "rsa-sha256"
+	if _, ok := d.signer.Public().(ed25519.PublicKey); ok {
+		algorithm = "ed25519-sha256"
+	}
+
+	signedNames := make([]string, 0, len(d.headers))
+	for _, name := range d.headers {
+		signedNames = append(signedNames, strings.ToLower(name))
+	}
+
+	tags := []string{
+		"v=1",
+		"a=" + algorithm,
+		"c=relaxed/relaxed",
+		"d=" + d.domain,
+		"s=" + d.selector,
+		"t=" + strconv.FormatInt(time.Now().Unix(), 10),
+		"h=" + strings.Join(signedNames, ":"),
+		"bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]),
+		"b=",
+	}
+	signatureValue := strings.Join(tags, "; ")
+
+	hash := dkimHeaderHash(fields, signedNames, signatureValue)
+
+	var signature []byte
+	var err error
+	if algorithm == "ed25519-sha256" {
+		signature, err = d.signer.Sign(rand.Reader, hash, crypto.Hash(0))
+	} else {
+		signature, err = d.signer.Sign(rand.Reader, hash, crypto.SHA256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DKIM signature: %w", err)
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "DKIM-Signature", strings.TrimSuffix(signatureValue, " b="))
+	// The signature goes on its own folded line, relaxed canonicalization turns the fold back into "; b="
+	buf.WriteString(" b=")
+	writeFoldedBase64(&buf, base64.StdEncoding.EncodeToString(signature))
+	buf.WriteString("\r\n")
+	buf.Write(message)
+
+	return buf.Bytes(), nil
+}
+
+// DKIMKeyLookup resolves the public key published for a domain and selector
+type DKIMKeyLookup func(domain, selector string) (crypto.PublicKey, error)
+
+// VerifyDKIM checks the first DKIM-Signature of a message signed with relaxed/relaxed canonicalization.
+// The lookup defaults to DNS when nil, tests can pass a static key instead.
+func VerifyDKIM(message []byte, lookup DKIMKeyLookup) error {
+	if lookup == nil {
+		lookup = LookupDKIMKey
+	}
+
+	headers, body := splitMessage(message)
+	fields := parseHeaderFields(headers)
+
+	var signatureField *headerField
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, "DKIM-Signature") {
+			signatureField = &fields[i]
+			break
+		}
+	}
+	if signatureField == nil {
+		return fmt.Errorf("message has no DKIM-Signature header")
+	}
+
+	tags := parseTagList(signatureField.value())
+	if tags["v"] != "1" {
+		return fmt.Errorf("unsupported DKIM version %q", tags["v"])
+	}
+	if tags["c"] != "relaxed/relaxed" {
+		return fmt.Errorf("unsupported DKIM canonicalization %q", tags["c"])
+	}
+
+	bodyHash := sha256.Sum256(canonicalizeBodyRelaxed(body))
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != stripWhitespace(tags["bh"]) {
+		return fmt.Errorf("DKIM body hash mismatch")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(stripWhitespace(tags["b"]))
+	if err != nil {
+		return fmt.Errorf("invalid DKIM signature encoding: %w", err)
+	}
+
+	signedNames := strings.Split(strings.ToLower(stripWhitespace(tags["h"])), ":")
+	unsignedValue := removeSignatureValue(signatureField.value())
+
+	// The signature header itself must not take part in header selection
+	remaining := make([]headerField, 0, len(fields)-1)
+	for i := range fields {
+		if &fields[i] != signatureField {
+			remaining = append(remaining, fields[i])
+		}
+	}
+	hash := dkimHeaderHash(remaining, signedNames, unsignedValue)
+
+	publicKey, err := lookup(tags["d"], tags["s"])
+	if err != nil {
+		return fmt.Errorf("failed to get DKIM public key: %w", err)
+	}
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if tags["a"] != "rsa-sha256" {
+			return fmt.Errorf("DKIM algorithm %q doesn't match RSA key", tags["a"])
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash, signature); err != nil {
+			return fmt.Errorf("DKIM signature verification failed: %w", err)
+		}
+	case ed25519.PublicKey:
+		if tags["a"] != "ed25519-sha256" {
+			return fmt.Errorf("DKIM algorithm %q doesn't match Ed25519 key", tags["a"])
+		}
+		if !ed25519.Verify(key, hash, signature) {
+			return fmt.Errorf("DKIM signature verification failed")
+		}
+	default:
+		return fmt.Errorf("unsupported DKIM public key type %T", publicKey)
+	}
+
+	return nil
+}
+
+// LookupDKIMKey fetches the public key from the selector._domainkey TXT record
+func LookupDKIMKey(domain, selector string) (crypto.PublicKey, error) {
+	records, err := net.LookupTXT(selector + "._domainkey." + domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no DKIM record for %s._domainkey.%s", selector, domain)
+	}
+
+	tags := parseTagList(strings.Join(records, ""))
+	keyData, err := base64.StdEncoding.DecodeString(stripWhitespace(tags["p"]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DKIM public key encoding: %w", err)
+	}
+	if len(keyData) == 0 {
+		return nil, fmt.Errorf("DKIM key for %s has been revoked", domain)
+	}
+
+	if tags["k"] == "ed25519" {
+		if len(keyData) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 DKIM public key size")
+		}
+		return ed25519.PublicKey(keyData), nil
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(keyData); err == nil {
+		return key, nil
+	}
+	return x509.ParsePKCS1PublicKey(keyData)
+}
+
+// headerField is a single header field including its continuation lines
+type headerField struct {
+	name string
+	raw  string
+}
+
+func (f headerField) value() string {
+	return f.raw[strings.Index(f.raw, ":")+1:]
+}
+
+// splitMessage separates the header block from the body
+func splitMessage(message []byte) (string, []byte) {
+	if index := bytes.Index(message, []byte("\r\n\r\n")); index >= 0 {
+		return string(message[:index+2]), message[index+4:]
+	}
+	return string(message), nil
+}
+
+// parseHeaderFields splits a header block into fields, keeping folded lines together
+func parseHeaderFields(headers string) []headerField {
+	var fields []headerField
+	for _, line := range strings.SplitAfter(headers, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].raw += line
+			continue
+		}
+		name := line
+		if colon := strings.Index(line, ":"); colon >= 0 {
+			name = line[:colon]
+		}
+		fields = append(fields, headerField{name: strings.TrimSpace(name), raw: line})
+	}
+	return fields
+}
+
+// dkimHeaderHash hashes the signed headers followed by the signature header without its b= value.
+// Multiple instances of a header are consumed from the bottom up as required by RFC 6376.
+func dkimHeaderHash(fields []headerField, signedNames []string, signatureValue string) []byte {
+	used := make(map[int]bool)
+	hasher := sha256.New()
+
+	for _, name := range signedNames {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(fields[i].name, name) {
+				continue
+			}
+			used[i] = true
+			hasher.Write([]byte(canonicalizeHeaderRelaxed(fields[i].name, fields[i].value())))
+			hasher.Write([]byte("\r\n"))
+			break
+		}
+	}
+
+	hasher.Write([]byte(canonicalizeHeaderRelaxed("DKIM-Signature", signatureValue)))
+	return hasher.Sum(nil)
+}
+
+// canonicalizeHeaderRelaxed applies the relaxed header canonicalization algorithm
+func canonicalizeHeaderRelaxed(name, value string) string {
+	value = strings.NewReplacer("\r\n", "", "\n", "").Replace(value)
+	value = whitespacePattern.ReplaceAllString(value, " ")
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.TrimSpace(value)
+}
+
+// canonicalizeBodyRelaxed applies the relaxed body canonicalization algorithm
+func canonicalizeBodyRelaxed(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(whitespacePattern.ReplaceAllString(line, " "), " ")
+	}
+
+	// Ignore all empty lines at the end of the body
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	if end == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines[:end], "\r\n") + "\r\n")
+}
+
+// parseTagList parses a DKIM tag=value list
+func parseTagList(value string) map[string]string {
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(value, ";") {
+		key, val, found := strings.Cut(pair, "=")
+		if !found {
+			continue
+		}
+		tags[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return tags
+}
+
+var signatureValuePattern = regexp.MustCompile(`(^|;)(\s*b\s*=)[^;]*`)
+
+// removeSignatureValue empties the b= tag of a DKIM-Signature header value
+func removeSignatureValue(value string) string {
+	return signatureValuePattern.ReplaceAllString(value, "$1$2")
+}
+
+func stripWhitespace(value string) string {
+	return strings.Join(strings.Fields(value), "")
+}
+
+// writeFoldedBase64 writes a base64 value split across folded header lines
+func writeFoldedBase64(buf *bytes.Buffer, value string) {
+	const chunkSize = 72
+	for len(value) > chunkSize {
+		buf.WriteString(value[:chunkSize])
+		buf.WriteString("\r\n ")
+		value = value[chunkSize:]
+	}
+	buf.WriteString(value)
+}
+
+// parseDKIMPrivateKey parses PKCS#1 RSA and PKCS#8 RSA or Ed25519 PEM keys
+func parseDKIMPrivateKey(privateKey string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return nil, fmt.Errorf("DKIM private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DKIM private key: %w", err)
+	}
+
+	switch signer := key.(type) {
+	case *rsa.PrivateKey:
+		return signer, nil
+	case ed25519.PrivateKey:
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported DKIM private key type %T", key)
+	}
+}
@@ -0,0 +1,205 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKeys holds a PEM encoded private key together with its public key
+type testKeys struct {
+	private string
+	public  crypto.PublicKey
+}
+
+func rsaTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	return testKeys{private: string(pem.EncodeToMemory(block)), public: &key.PublicKey}
+}
+
+func ed25519TestKeys(t *testing.T) testKeys {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("failed to marshal Ed25519 key: %v", err)
+	}
+	block := &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	return testKeys{private: string(pem.EncodeToMemory(block)), public: public}
+}
+
+// staticLookup returns a key lookup serving one public key for the selector of the domain
+func staticLookup(domain, selector string, public crypto.PublicKey) DKIMKeyLookup {
+	return func(d, s string) (crypto.PublicKey, error) {
+		if d != domain || s != selector {
+			return nil, fmt.Errorf("no DKIM record for %s._domainkey.%s", s, d)
+		}
+		return public, nil
+	}
+}
+
+func testMessage(t *testing.T) []byte {
+	t.Helper()
+	message := &Message{
+		From:     "noreply@example.com",
+		FromName: "Example",
+		To:       "player@example.org",
+		Subject:  "Your bonus is waiting",
+		HTMLBody: "<p>Claim your <b>bonus</b> today</p>",
+		Date:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	built, err := message.Build()
+	if err != nil {
+		t.Fatalf("failed to build message: %v", err)
+	}
+	return built
+}
+
+func TestNewDKIMSigner(t *testing.T) {
+	rsaKeys := rsaTestKeys(t)
+
+	tests := []struct {
+		name        string
+		domain      string
+		selector    string
+		privateKey  string
+		headers     []string
+		wantErr     bool
+		wantHeaders []string
+	}{
+		{name: "missing domain", selector: "s1", privateKey: rsaKeys.private, wantErr: true},
+		{name: "missing selector", domain: "example.com", privateKey: rsaKeys.private, wantErr: true},
+		{name: "not PEM", domain: "example.com", selector: "s1", privateKey: "not a key", wantErr: true},
+		{name: "default headers", domain: "example.com", selector: "s1", privateKey: rsaKeys.private, wantHeaders: defaultDKIMSignedHeaders},
+		{name: "From added", domain: "example.com", selector: "s1", privateKey: rsaKeys.private, headers: []string{"Subject"}, wantHeaders: []string{"From", "Subject"}},
+		{name: "From kept", domain: "example.com", selector: "s1", privateKey: rsaKeys.private, headers: []string{"Subject", "from"}, wantHeaders: []string{"Subject", "from"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewDKIMSigner(tt.domain, tt.selector, tt.privateKey, tt.headers)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(signer.headers, ",") != strings.Join(tt.wantHeaders, ",") {
+				t.Errorf("signed headers = %v, want %v", signer.headers, tt.wantHeaders)
+			}
+		})
+	}
+}
+
+func TestDKIMSignVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      testKeys
+		algorithm string
+	}{
+		{name: "RSA", keys: rsaTestKeys(t), algorithm: "a=rsa-sha256"},
+		{name: "Ed25519", keys: ed25519TestKeys(t), algorithm: "a=ed25519-sha256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewDKIMSigner("example.com", "s1", tt.keys.private, nil)
+			if err != nil {
+				t.Fatalf("failed to create signer: %v", err)
+			}
+
+			signed, err := signer.Sign(testMessage(t))
+			if err != nil {
+				t.Fatalf("failed to sign message: %v", err)
+			}
+			if !bytes.HasPrefix(signed, []byte("DKIM-Signature:")) {
+				t.Fatalf("signed message doesn't start with a DKIM-Signature header")
+			}
+			if !bytes.Contains(signed, []byte(tt.algorithm)) {
+				t.Errorf("signature doesn't announce %s", tt.algorithm)
+			}
+
+			lookup := staticLookup("example.com", "s1", tt.keys.public)
+			if err := VerifyDKIM(signed, lookup); err != nil {
+				t.Fatalf("signature doesn't verify: %v", err)
+			}
+
+			// Relaxed canonicalization tolerates whitespace changes in transit
+			relaxed := bytes.Replace(signed, []byte("Subject: "), []byte("Subject:   "), 1)
+			if err := VerifyDKIM(relaxed, lookup); err != nil {
+				t.Errorf("signature doesn't verify after whitespace change: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyDKIMRejectsTampering(t *testing.T) {
+	keys := rsaTestKeys(t)
+	signer, err := NewDKIMSigner("example.com", "s1", keys.private, nil)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	signed, err := signer.Sign(testMessage(t))
+	if err != nil {
+		t.Fatalf("failed to sign message: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		message []byte
+		lookup  DKIMKeyLookup
+	}{
+		{
+			name:    "modified body",
+			message: append(bytes.Clone(signed), []byte("Claim it twice\r\n")...),
+			lookup:  staticLookup("example.com", "s1", keys.public),
+		},
+		{
+			name:    "modified subject",
+			message: bytes.Replace(signed, []byte("Subject: "), []byte("Subject: Re: "), 1),
+			lookup:  staticLookup("example.com", "s1", keys.public),
+		},
+		{
+			name:    "other key",
+			message: signed,
+			lookup:  staticLookup("example.com", "s1", rsaTestKeys(t).public),
+		},
+		{
+			name:    "unknown selector",
+			message: signed,
+			lookup:  staticLookup("example.com", "s2", keys.public),
+		},
+		{
+			name:    "unsigned",
+			message: testMessage(t),
+			lookup:  staticLookup("example.com", "s1", keys.public),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyDKIM(tt.message, tt.lookup); err == nil {
+				t.Error("expected verification to fail")
+			}
+		})
+	}
+}
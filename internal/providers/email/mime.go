@@ -0,0 +1,419 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxHeaderLineLength is the soft line length limit recommended by RFC 5322
+	maxHeaderLineLength = 78
+	// maxBase64LineLength is the encoded line length limit from RFC 2045
+	maxBase64LineLength = 76
+)
+
+// reservedHeaders are set by the builder and can't be overridden from notification meta
+var reservedHeaders = map[string]bool{
+	"From":                      true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Reply-To":                  true,
+	"Subject":                   true,
+	"Date":                      true,
+	"Message-Id":                true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+}
+
+// Message describes an outgoing email before MIME encoding
+type Message struct {
+	From        string
+	FromName    string
+	To          string
+	ReplyTo     string
+	Subject     string
+	HTMLBody    string
+	TextBody    string
+	Headers     map[string]string
+	Attachments []MessageAttachment
+	MessageID   string
+	Date        time.Time
+
+	// ListUnsubscribeURL is announced as an RFC 8058 one-click unsubscribe link when set
+	ListUnsubscribeURL string
+}
+
+// MessageAttachment is a file attached to a message, either as a regular attachment
+// or as an inline part referenced from the HTML body by its Content-ID
+type MessageAttachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string
+	Inline      bool
+	Data        []byte
+}
+
+// mimeEntity is a MIME part with its headers and already encoded body
+type mimeEntity struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// Build renders the message as an RFC 5322 document. Messages with an HTML body are sent
+// as multipart/alternative with a text/plain part, derived from the HTML when TextBody is empty.
+// Inline attachments wrap the body in multipart/related and regular ones in multipart/mixed.
+func (m *Message) Build() ([]byte, error) {
+	if m.MessageID == "" {
+		m.MessageID = generateMessageID(m.From)
+	}
+	if m.Date.IsZero() {
+		m.Date = time.Now()
+	}
+
+	var buf bytes.Buffer
+
+	writeHeader(&buf, "From", formatAddress(m.FromName, m.From))
+	writeHeader(&buf, "To", formatAddress("", m.To))
+	if m.ReplyTo != "" {
+		writeHeader(&buf, "Reply-To", formatAddress("", m.ReplyTo))
+	}
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("UTF-8", m.Subject))
+	writeHeader(&buf, "Date", m.Date.Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", m.MessageID)
+	writeHeader(&buf, "MIME-Version", "1.0")
+	if m.ListUnsubscribeURL != "" {
+		writeHeader(&buf, "List-Unsubscribe", "<"+m.ListUnsubscribeURL+">")
+		writeHeader(&buf, "List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+
+	if err := m.writeCustomHeaders(&buf); err != nil {
+		return nil, err
+	}
+
+	body, err := m.buildBody()
+	if err != nil {
+		return nil, err
+	}
+
+	writeHeader(&buf, "Content-Type", body.header.Get("Content-Type"))
+	if encoding := body.header.Get("Content-Transfer-Encoding"); encoding != "" {
+		writeHeader(&buf, "Content-Transfer-Encoding", encoding)
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body.body)
+
+	return buf.Bytes(), nil
+}
+
+// buildBody assembles the MIME tree for the message content and attachments
+func (m *Message) buildBody() (*mimeEntity, error) {
+	var content *mimeEntity
+	var err error
+
+	if m.HTMLBody == "" {
+		// Plain text only message
+		content, err = textEntity("text/plain; charset=UTF-8", m.TextBody)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		textBody := m.TextBody
+		if textBody == "" {
+			textBody = HTMLToText(m.HTMLBody)
+		}
+
+		textPart, err := textEntity("text/plain; charset=UTF-8", textBody)
+		if err != nil {
+			return nil, err
+		}
+		htmlPart, err := textEntity("text/html; charset=UTF-8", m.HTMLBody)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err = multipartEntity("alternative", textPart, htmlPart)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var inline, attached []*mimeEntity
+	for _, attachment := range m.Attachments {
+		if attachment.Inline {
+			inline = append(inline, attachmentEntity(attachment))
+		} else {
+			attached = append(attached, attachmentEntity(attachment))
+		}
+	}
+
+	if len(inline) > 0 {
+		content, err = multipartEntity("related", append([]*mimeEntity{content}, inline...)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(attached) > 0 {
+		content, err = multipartEntity("mixed", append([]*mimeEntity{content}, attached...)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return content, nil
+}
+
+// writeCustomHeaders writes the headers supplied in notification meta in a stable order
+func (m *Message) writeCustomHeaders(buf *bytes.Buffer) error {
+	names := make([]string, 0, len(m.Headers))
+	for name := range m.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := m.Headers[name]
+		if !isValidHeaderName(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if reservedHeaders[canonical] {
+			return fmt.Errorf("header %q can't be overridden", name)
+		}
+		// The one-click link takes the place of unsubscribe headers supplied by the caller
+		if m.ListUnsubscribeURL != "" && (canonical == "List-Unsubscribe" || canonical == "List-Unsubscribe-Post") {
+			continue
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("header %q contains line breaks", name)
+		}
+		writeHeader(buf, name, mime.QEncoding.Encode("UTF-8", value))
+	}
+
+	return nil
+}
+
+// textEntity creates a quoted-printable encoded text part
+func textEntity(contentType, content string) (*mimeEntity, error) {
+	var encoded bytes.Buffer
+	if err := writeQuotedPrintable(&encoded, content); err != nil {
+		return nil, err
+	}
+
+	return &mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		body: encoded.Bytes(),
+	}, nil
+}
+
+// multipartEntity combines parts into a multipart entity of the given subtype
+func multipartEntity(subtype string, parts ...*mimeEntity) (*mimeEntity, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, part := range parts {
+		partWriter, err := writer.CreatePart(part.header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create multipart/%s part: %w", subtype, err)
+		}
+		if _, err := partWriter.Write(part.body); err != nil {
+			return nil, fmt.Errorf("failed to write multipart/%s part: %w", subtype, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close multipart/%s writer: %w", subtype, err)
+	}
+
+	return &mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type": {mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": writer.Boundary()})},
+		},
+		body: body.Bytes(),
+	}, nil
+}
+
+// attachmentEntity creates a base64 encoded attachment part
+func attachmentEntity(attachment MessageAttachment) *mimeEntity {
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	disposition := "attachment"
+	if attachment.Inline {
+		disposition = "inline"
+	}
+
+	header := textproto.MIMEHeader{
+		"Content-Type":              {formatMediaType(contentType, map[string]string{"name": attachment.Filename})},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {formatMediaType(disposition, map[string]string{"filename": attachment.Filename})},
+	}
+	if attachment.ContentID != "" {
+		header.Set("Content-ID", "<"+strings.Trim(attachment.ContentID, "<>")+">")
+	}
+
+	return &mimeEntity{
+		header: header,
+		body:   encodeBase64Lines(attachment.Data),
+	}
+}
+
+// formatMediaType formats a media type with parameters, falling back to the bare type when
+// the type itself is malformed. Non-ASCII parameter values are RFC 2231 encoded.
+func formatMediaType(mediaType string, params map[string]string) string {
+	if params["name"] == "" && params["filename"] == "" {
+		return mediaType
+	}
+	if formatted := mime.FormatMediaType(mediaType, params); formatted != "" {
+		return formatted
+	}
+	return mediaType
+}
+
+// encodeBase64Lines base64 encodes data wrapped at 76 characters per line
+func encodeBase64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	var buf bytes.Buffer
+	for len(encoded) > maxBase64LineLength {
+		buf.WriteString(encoded[:maxBase64LineLength])
+		buf.WriteString("\r\n")
+		encoded = encoded[maxBase64LineLength:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
+
+// writeQuotedPrintable encodes content as quoted-printable with CRLF line endings
+func writeQuotedPrintable(buf *bytes.Buffer, content string) error {
+	qp := quotedprintable.NewWriter(buf)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return fmt.Errorf("failed to encode body: %w", err)
+	}
+	return qp.Close()
+}
+
+// writeHeader writes a header field, folding it at whitespace when it exceeds the line limit
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	line := name + ": "
+	lineLen := len(line)
+	buf.WriteString(line)
+
+	for i, word := range strings.Split(value, " ") {
+		if i > 0 {
+			if lineLen+1+len(word) > maxHeaderLineLength {
+				buf.WriteString("\r\n")
+				lineLen = 0
+			}
+			buf.WriteString(" ")
+			lineLen++
+		}
+		buf.WriteString(word)
+		lineLen += len(word)
+	}
+
+	buf.WriteString("\r\n")
+}
+
+// formatAddress renders an address with an optional display name, RFC 2047 encoding non-ASCII names
+func formatAddress(name, address string) string {
+	if name == "" {
+		return address
+	}
+	return (&mail.Address{Name: name, Address: address}).String()
+}
+
+// generateMessageID creates a globally unique Message-ID in the sender's domain
+func generateMessageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", uuid.New().String(), domain)
+}
+
+// isValidHeaderName checks that a header field name only contains printable ASCII without colons
+func isValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r > '~' || r == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	htmlHeadPattern      = regexp.MustCompile(`(?is)<head[^>]*>.*?</head>`)
+	htmlStylePattern     = regexp.MustCompile(`(?is)<style[^>]*>.*?</style>`)
+	htmlScriptPattern    = regexp.MustCompile(`(?is)<script[^>]*>.*?</script>`)
+	htmlCommentPattern   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlLinkPattern      = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+	htmlLineBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlBlockEndPattern  = regexp.MustCompile(`(?i)</(p|div|h[1-6]|tr|table|ul|ol|blockquote)\s*>`)
+	htmlListItemPattern  = regexp.MustCompile(`(?i)<li[^>]*>`)
+	htmlTagPattern       = regexp.MustCompile(`(?s)<[^>]*>`)
+	spacesPattern        = regexp.MustCompile(`[ \t\f\v]+`)
+	blankLinesPattern    = regexp.MustCompile(`\n{3,}`)
+)
+
+// HTMLToText derives a readable plain-text version of an HTML body
+func HTMLToText(body string) string {
+	text := htmlHeadPattern.ReplaceAllString(body, "")
+	text = htmlStylePattern.ReplaceAllString(text, "")
+	text = htmlScriptPattern.ReplaceAllString(text, "")
+	text = htmlCommentPattern.ReplaceAllString(text, "")
+	text = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(text)
+
+	text = htmlLinkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		match := htmlLinkPattern.FindStringSubmatch(link)
+		href := strings.TrimSpace(match[1])
+		label := strings.TrimSpace(htmlTagPattern.ReplaceAllString(match[2], ""))
+		if href == "" || strings.HasPrefix(href, "#") || label == href || strings.TrimPrefix(href, "mailto:") == label {
+			return label
+		}
+		if label == "" {
+			return href
+		}
+		return fmt.Sprintf("%s (%s)", label, href)
+	})
+
+	text = htmlLineBreakPattern.ReplaceAllString(text, "\n")
+	text = htmlBlockEndPattern.ReplaceAllString(text, "\n\n")
+	text = htmlListItemPattern.ReplaceAllString(text, "\n- ")
+	text = htmlTagPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spacesPattern.ReplaceAllString(line, " "))
+	}
+	text = blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,268 @@
+package email
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// mimePart is a decoded leaf part of a message
+type mimePart struct {
+	contentType string
+	disposition string
+	contentID   string
+	body        string
+}
+
+// parseMessage parses a built message and returns its top level content type and leaf parts
+func parseMessage(t *testing.T, built []byte) (*mail.Message, string, []mimePart) {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(built))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("invalid Content-Type: %v", err)
+	}
+
+	var parts []mimePart
+	collectParts(t, mediaType, params, msg.Body, &parts)
+	return msg, mediaType, parts
+}
+
+func collectParts(t *testing.T, mediaType string, params map[string]string, body io.Reader, parts *[]mimePart) {
+	t.Helper()
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		content, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		*parts = append(*parts, mimePart{contentType: mediaType, body: string(content)})
+		return
+	}
+
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("failed to read %s part: %v", mediaType, err)
+		}
+		partType, partParams, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatalf("invalid part Content-Type: %v", err)
+		}
+		if strings.HasPrefix(partType, "multipart/") {
+			collectParts(t, partType, partParams, part, parts)
+			continue
+		}
+		// multipart.Reader decodes quoted-printable parts itself
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		*parts = append(*parts, mimePart{
+			contentType: partType,
+			disposition: part.Header.Get("Content-Disposition"),
+			contentID:   part.Header.Get("Content-ID"),
+			body:        string(content),
+		})
+	}
+}
+
+func TestMessageBuild(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   Message
+		wantType  string
+		wantParts []string
+	}{
+		{
+			name:      "plain text",
+			message:   Message{TextBody: "Hello"},
+			wantType:  "text/plain",
+			wantParts: []string{"text/plain"},
+		},
+		{
+			name:      "HTML",
+			message:   Message{HTMLBody: "<p>Hello</p>"},
+			wantType:  "multipart/alternative",
+			wantParts: []string{"text/plain", "text/html"},
+		},
+		{
+			name: "inline image",
+			message: Message{
+				HTMLBody:    `<img src="cid:logo">`,
+				Attachments: []MessageAttachment{{Filename: "logo.png", ContentType: "image/png", ContentID: "logo", Inline: true, Data: []byte("png")}},
+			},
+			wantType:  "multipart/related",
+			wantParts: []string{"text/plain", "text/html", "image/png"},
+		},
+		{
+			name: "attachment",
+			message: Message{
+				TextBody:    "See attached",
+				Attachments: []MessageAttachment{{Filename: "report.pdf", Data: []byte("pdf")}},
+			},
+			wantType:  "multipart/mixed",
+			wantParts: []string{"text/plain", "application/octet-stream"},
+		},
+		{
+			name: "inline image and attachment",
+			message: Message{
+				HTMLBody: `<img src="cid:logo">`,
+				Attachments: []MessageAttachment{
+					{Filename: "report.pdf", ContentType: "application/pdf", Data: []byte("pdf")},
+					{Filename: "logo.png", ContentType: "image/png", ContentID: "logo", Inline: true, Data: []byte("png")},
+				},
+			},
+			wantType:  "multipart/mixed",
+			wantParts: []string{"text/plain", "text/html", "image/png", "application/pdf"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.message.From = "noreply@example.com"
+			tt.message.To = "player@example.org"
+			tt.message.Subject = "Hello"
+
+			built, err := tt.message.Build()
+			if err != nil {
+				t.Fatalf("failed to build message: %v", err)
+			}
+
+			msg, mediaType, parts := parseMessage(t, built)
+			if mediaType != tt.wantType {
+				t.Errorf("Content-Type = %s, want %s", mediaType, tt.wantType)
+			}
+			var partTypes []string
+			for _, part := range parts {
+				partTypes = append(partTypes, part.contentType)
+			}
+			if strings.Join(partTypes, ",") != strings.Join(tt.wantParts, ",") {
+				t.Errorf("parts = %v, want %v", partTypes, tt.wantParts)
+			}
+			if msg.Header.Get("Message-ID") == "" || msg.Header.Get("Date") == "" {
+				t.Error("Message-ID and Date must be set")
+			}
+		})
+	}
+}
+
+func TestMessageBuildParts(t *testing.T) {
+	message := &Message{
+		From:     "noreply@example.com",
+		To:       "player@example.org",
+		Subject:  "Ваш бонус",
+		HTMLBody: `<p>Hello <b>player</b></p><img src="cid:logo">`,
+		Attachments: []MessageAttachment{
+			{Filename: "logo.png", ContentType: "image/png", ContentID: "<logo>", Inline: true, Data: []byte("png")},
+		},
+		Headers:            map[string]string{"List-Unsubscribe": "<mailto:unsubscribe@example.com>"},
+		ListUnsubscribeURL: "https://example.com/unsubscribe/abc",
+	}
+
+	built, err := message.Build()
+	if err != nil {
+		t.Fatalf("failed to build message: %v", err)
+	}
+	msg, _, parts := parseMessage(t, built)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Ваш бонус" {
+		t.Errorf("Subject = %q (%v), want the encoded original", subject, err)
+	}
+	// The one-click link replaces the caller's List-Unsubscribe header
+	if got := msg.Header["List-Unsubscribe"]; len(got) != 1 || got[0] != "<https://example.com/unsubscribe/abc>" {
+		t.Errorf("List-Unsubscribe = %q", got)
+	}
+	if got := msg.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q", got)
+	}
+
+	if len(parts) != 3 {
+		t.Fatalf("got %d parts, want 3", len(parts))
+	}
+	if !strings.Contains(parts[0].body, "Hello player") || strings.Contains(parts[0].body, "<b>") {
+		t.Errorf("text part = %q, want the HTML converted to text", parts[0].body)
+	}
+	if parts[1].body != message.HTMLBody {
+		t.Errorf("HTML part = %q, want %q", parts[1].body, message.HTMLBody)
+	}
+	if parts[2].contentID != "<logo>" || !strings.HasPrefix(parts[2].disposition, "inline") {
+		t.Errorf("inline part has Content-ID %q and disposition %q", parts[2].contentID, parts[2].disposition)
+	}
+}
+
+func TestMessageBuildHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantErr bool
+	}{
+		{name: "custom header", headers: map[string]string{"X-Campaign": "spring"}},
+		{name: "reserved header", headers: map[string]string{"from": "attacker@example.com"}, wantErr: true},
+		{name: "reserved Content-Type", headers: map[string]string{"Content-Type": "text/html"}, wantErr: true},
+		{name: "invalid name", headers: map[string]string{"X Campaign": "spring"}, wantErr: true},
+		{name: "line break", headers: map[string]string{"X-Campaign": "spring\r\nBcc: attacker@example.com"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := &Message{
+				From:     "noreply@example.com",
+				To:       "player@example.org",
+				TextBody: "Hello",
+				Headers:  tt.headers,
+			}
+			built, err := message.Build()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			msg, _, _ := parseMessage(t, built)
+			for name, value := range tt.headers {
+				if got := msg.Header.Get(name); got != value {
+					t.Errorf("%s = %q, want %q", name, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{name: "paragraphs", html: "<p>First</p><p>Second</p>", want: "First\n\nSecond"},
+		{name: "line breaks", html: "One<br>Two<br/>Three", want: "One\nTwo\nThree"},
+		{name: "list", html: "<ul><li>Bonus</li><li>Free spins</li></ul>", want: "- Bonus\n- Free spins"},
+		{name: "link", html: `<a href="https://example.com/claim">Claim</a>`, want: "Claim (https://example.com/claim)"},
+		{name: "link with its URL as label", html: `<a href="https://example.com">https://example.com</a>`, want: "https://example.com"},
+		{name: "entities", html: "Terms &amp; conditions&nbsp;apply", want: "Terms & conditions apply"},
+		{name: "head, style and script", html: "<head><title>T</title></head><style>p{}</style><script>x()</script><p>Body</p>", want: "Body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTMLToText(tt.html); got != tt.want {
+				t.Errorf("HTMLToText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+)
+
+const (
+	defaultMaxConnections           = 4
+	defaultMaxMessagesPerConnection = 100
+	defaultConnectionIdleTimeout    = 30 * time.Second
+	dialTimeout                     = 15 * time.Second
+	messageTimeout                  = 60 * time.Second
+)
+
+// smtpConn is an authenticated SMTP session that can carry several messages
+type smtpConn struct {
+	conn     net.Conn
+	client   *smtp.Client
+	messages int
+	lastUsed time.Time
+}
+
+// close ends the session politely, falling back to dropping the connection
+func (c *smtpConn) close() {
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := c.client.Quit(); err != nil {
+		c.client.Close()
+	}
+}
+
+// smtpPool keeps authenticated SMTP sessions for reuse across messages
+type smtpPool struct {
+	config *schema.SMTPConfig
+
+	slots  chan struct{}
+	mu     sync.Mutex
+	idle   []*smtpConn
+	closed bool
+}
+
+func newSMTPPool(config *schema.SMTPConfig) *smtpPool {
+	maxConnections := config.MaxConnections
+	if maxConnections <= 0 {
+		maxConnections = defaultMaxConnections
+	}
+
+	return &smtpPool{
+		config: config,
+		slots:  make(chan struct{}, maxConnections),
+	}
+}
+
+// size returns the maximum number of concurrent connections
+func (p *smtpPool) size() int {
+	return cap(p.slots)
+}
+
+// Send delivers a message over a pooled connection. A reused connection that turns out to
+// be closed by the server is replaced once by a fresh one before giving up, unless the message
+// was already handed over with DATA and the server may have accepted it.
+func (p *smtpPool) Send(ctx context.Context, from string, to []string, message []byte) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-p.slots }()
+
+	conn, reused, err := p.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	dataSent, err := p.deliver(conn, from, to, message)
+	if err != nil && reused && !dataSent && isConnectionError(err) {
+		conn.close()
+
+		conn, err = p.dial(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = p.deliver(conn, from, to, message)
+	}
+
+	p.put(conn, err)
+	return err
+}
+
+// Close ends all idle sessions, sessions still in use are ended when their message is done
+func (p *smtpPool) Close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, conn := range idle {
+		conn.close()
+	}
+}
+
+// get returns an idle session when one is still fresh, otherwise dials a new one
+func (p *smtpPool) get(ctx context.Context) (*smtpConn, bool, error) {
+	idleTimeout := p.idleTimeout()
+
+	p.mu.Lock()
+	for len(p.idle) > 0 {
+		conn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+
+		if time.Since(conn.lastUsed) < idleTimeout {
+			p.mu.Unlock()
+			return conn, true, nil
+		}
+
+		// Expired sessions are most likely closed by the server already
+		go conn.close()
+	}
+	p.mu.Unlock()
+
+	conn, err := p.dial(ctx)
+	return conn, false, err
+}
+
+// put returns a session to the pool unless it is broken or reached its message limit
+func (p *smtpPool) put(conn *smtpConn, sendErr error) {
+	// A failed transaction is aborted by the RSET that precedes the next message
+	if sendErr != nil && isConnectionError(sendErr) {
+		conn.client.Close()
+		return
+	}
+
+	if conn.messages >= p.maxMessagesPerConnection() {
+		conn.close()
+		return
+	}
+
+	conn.lastUsed = time.Now()
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		conn.close()
+		return
+	}
+	p.idle = append(p.idle, conn)
+	p.mu.Unlock()
+}
+
+// deliver runs a single mail transaction, resetting the session first when it was used before.
+// It reports whether the server accepted the DATA command, after which a failure doesn't tell
+// whether the message was taken.
+func (p *smtpPool) deliver(conn *smtpConn, from string, to []string, message []byte) (bool, error) {
+	conn.conn.SetDeadline(time.Now().Add(messageTimeout))
+
+	if conn.messages > 0 {
+		if err := conn.client.Reset(); err != nil {
+			return false, fmt.Errorf("failed to reset SMTP session: %w", err)
+		}
+	}
+	conn.messages++
+
+	if err := conn.client.Mail(from); err != nil {
+		return false, fmt.Errorf("failed to set sender: %w", err)
+	}
+
+	for _, recipient := range to {
+		if err := conn.client.Rcpt(recipient); err != nil {
+			return false, &RecipientError{Recipient: recipient, Err: err}
+		}
+	}
+
+	writer, err := conn.client.Data()
+	if err != nil {
+		return false, fmt.Errorf("failed to get data writer: %w", err)
+	}
+
+	if _, err := writer.Write(message); err != nil {
+		return true, fmt.Errorf("failed to write message: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return true, fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return true, nil
+}
+
+// dial opens a new session using implicit TLS for "ssl" or port 465, STARTTLS for "tls"
+// and plain SMTP otherwise, authenticating when SMTPAuth is enabled
+func (p *smtpPool) dial(ctx context.Context) (*smtpConn, error) {
+	addr := net.JoinHostPort(p.config.Host, p.config.Port)
+	tlsConfig := &tls.Config{
+		ServerName: p.config.Host,
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+
+	var conn net.Conn
+	var err error
+	if p.config.SMTPSecure == "ssl" || (p.config.SMTPSecure == "tls" && p.config.Port == "465") {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect with TLS: %w", err)
+		}
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+	}
+
+	conn.SetDeadline(time.Now().Add(messageTimeout))
+
+	client, err := smtp.NewClient(conn, p.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create SMTP client: %w", err)
+	}
+
+	if p.config.SMTPSecure == "tls" && p.config.Port != "465" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+
+	if p.config.SMTPAuth == "1" {
+		auth := smtp.PlainAuth("", p.config.Username, p.config.Password, p.config.Host)
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	return &smtpConn{
+		conn:     conn,
+		client:   client,
+		lastUsed: time.Now(),
+	}, nil
+}
+
+func (p *smtpPool) maxMessagesPerConnection() int {
+	if p.config.MaxMessagesPerConnection > 0 {
+		return p.config.MaxMessagesPerConnection
+	}
+	return defaultMaxMessagesPerConnection
+}
+
+func (p *smtpPool) idleTimeout() time.Duration {
+	if p.config.ConnectionIdleTimeout > 0 {
+		return time.Duration(p.config.ConnectionIdleTimeout) * time.Second
+	}
+	return defaultConnectionIdleTimeout
+}
+
+// RecipientError is returned when the server rejects a recipient of a message
+type RecipientError struct {
+	Recipient string
+	Err       error
+}
+
+func (e *RecipientError) Error() string {
+	return fmt.Sprintf("recipient %s rejected: %v", e.Recipient, e.Err)
+}
+
+func (e *RecipientError) Unwrap() error {
+	return e.Err
+}
+
+// isConnectionError reports whether the session can't be used anymore. SMTP replies other
+// than 421 (service closing) leave the session usable, anything else means the connection broke.
+func isConnectionError(err error) bool {
+	var protocolErr *textproto.Error
+	if errors.As(err, &protocolErr) {
+		return protocolErr.Code == 421
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+)
+
+// fakeSMTPServer accepts every message. It can hang up after the first message of a
+// connection was accepted, or take the second message of a connection and hang up without
+// replying to it.
+type fakeSMTPServer struct {
+	listener         net.Listener
+	hangUpAfterFirst bool
+	dropSecond       bool
+
+	mu          sync.Mutex
+	connections int
+	messages    int
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &fakeSMTPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeSMTPServer) config() *schema.SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return &schema.SMTPConfig{Host: host, Port: port}
+}
+
+func (s *fakeSMTPServer) counts() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections, s.messages
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	s.mu.Lock()
+	s.connections++
+	s.mu.Unlock()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 fake ESMTP")
+
+	received := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"), command == "RSET":
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 go ahead")
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+			}
+			received++
+			s.mu.Lock()
+			s.messages++
+			s.mu.Unlock()
+
+			if s.dropSecond && received == 2 {
+				return
+			}
+			reply("250 queued")
+			if s.hangUpAfterFirst && received == 1 {
+				return
+			}
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unknown command")
+		}
+	}
+}
+
+func TestSMTPPoolRetry(t *testing.T) {
+	tests := []struct {
+		name             string
+		hangUpAfterFirst bool
+		dropSecond       bool
+		wantErr          bool
+		wantConnections  int
+		wantMessages     int
+	}{
+		// The reused session is gone before the transaction starts, the message goes out on a new one
+		{name: "closed before the transaction", hangUpAfterFirst: true, wantConnections: 2, wantMessages: 2},
+		// The server may have taken the message, sending it again could deliver it twice
+		{name: "closed after DATA", dropSecond: true, wantErr: true, wantConnections: 1, wantMessages: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t)
+			server.hangUpAfterFirst = tt.hangUpAfterFirst
+			server.dropSecond = tt.dropSecond
+
+			pool := newSMTPPool(server.config())
+			defer pool.Close()
+
+			ctx := context.Background()
+			message := []byte("Subject: test\r\n\r\nHello\r\n")
+			if err := pool.Send(ctx, "noreply@example.com", []string{"player@example.com"}, message); err != nil {
+				t.Fatalf("first Send() error = %v", err)
+			}
+
+			err := pool.Send(ctx, "noreply@example.com", []string{"player@example.com"}, message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("second Send() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			connections, messages := server.counts()
+			if connections != tt.wantConnections || messages != tt.wantMessages {
+				t.Errorf("server saw %d connections and %d messages, want %d and %d",
+					connections, messages, tt.wantConnections, tt.wantMessages)
+			}
+		})
+	}
+}
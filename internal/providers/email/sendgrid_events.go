@@ -0,0 +1,112 @@
+package email
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// sendGridTimestampTolerance is how far the timestamp of a signed request may be from now, older
+// requests are rejected as replays
+const sendGridTimestampTolerance = 5 * time.Minute
+
+// SendGridEvent is a single event of the SendGrid Event Webhook
+type SendGridEvent struct {
+	Email       string `json:"email"`
+	Timestamp   int64  `json:"timestamp"`
+	SMTPID      string `json:"smtp-id"`
+	Event       string `json:"event"`
+	Type        string `json:"type,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	Status      string `json:"status,omitempty"`
+	SGMessageID string `json:"sg_message_id,omitempty"`
+}
+
+// VerifySendGridSignature checks the signature of a signed Event Webhook request. SendGrid signs
+// the timestamp header followed by the raw payload with ECDSA P-256 over SHA-256. Requests with a
+// timestamp more than a few minutes from now are rejected, so captured requests can't be replayed.
+func VerifySendGridSignature(publicKey, signature, timestamp string, payload []byte) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(seconds, 0))
+	if age > sendGridTimestampTolerance || age < -sendGridTimestampTolerance {
+		return false
+	}
+
+	keyBytes, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return false
+	}
+	key, err := x509.ParsePKIXPublicKey(keyBytes)
+	if err != nil {
+		return false
+	}
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return false
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	digest := sha256.Sum256(append([]byte(timestamp), payload...))
+	return ecdsa.VerifyASN1(ecdsaKey, digest[:], signatureBytes)
+}
+
+// ParseSendGridEvents converts an Event Webhook payload to email events. Events are correlated by
+// smtp-id, the Message-ID header the email was submitted with; events SendGrid only records
+// for its own bookkeeping (processed, dropped, unsubscribes) are skipped.
+func ParseSendGridEvents(tenantID int64, payload []byte) ([]*models.EmailEvent, error) {
+	var sendGridEvents []SendGridEvent
+	if err := json.Unmarshal(payload, &sendGridEvents); err != nil {
+		return nil, fmt.Errorf("failed to parse SendGrid events: %w", err)
+	}
+
+	var events []*models.EmailEvent
+	for _, sendGridEvent := range sendGridEvents {
+		event := &models.EmailEvent{
+			TenantID:   tenantID,
+			Source:     "sendgrid",
+			MessageID:  NormalizeMessageID(sendGridEvent.SMTPID),
+			Recipient:  sendGridEvent.Email,
+			StatusCode: sendGridEvent.Status,
+			Diagnostic: sendGridEvent.Reason,
+		}
+
+		switch sendGridEvent.Event {
+		case "delivered":
+			event.Type = models.EmailEventDelivered
+		case "bounce":
+			// Blocks are rejections of the message, not of the address
+			event.Type = models.EmailEventHardBounce
+			if sendGridEvent.Type == "blocked" {
+				event.Type = models.EmailEventSoftBounce
+			}
+		case "deferred":
+			event.Type = models.EmailEventSoftBounce
+		case "spamreport":
+			event.Type = models.EmailEventComplaint
+		case "open":
+			event.Type = models.EmailEventOpened
+		case "click":
+			event.Type = models.EmailEventClicked
+		default:
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,116 @@
+package email
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func TestVerifySendGridSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	publicKey := base64.StdEncoding.EncodeToString(der)
+
+	sign := func(timestamp string, payload []byte) string {
+		digest := sha256.Sum256(append([]byte(timestamp), payload...))
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		return base64.StdEncoding.EncodeToString(signature)
+	}
+
+	payload := []byte(`[{"email":"player@example.org","event":"delivered"}]`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		publicKey string
+		signature string
+		timestamp string
+		payload   []byte
+		want      bool
+	}{
+		{name: "valid", publicKey: publicKey, signature: sign(now, payload), timestamp: now, payload: payload, want: true},
+		{name: "modified payload", publicKey: publicKey, signature: sign(now, payload), timestamp: now, payload: []byte(`[]`)},
+		{name: "other timestamp", publicKey: publicKey, signature: sign(now, payload), timestamp: strconv.FormatInt(time.Now().Unix()-1, 10), payload: payload},
+		{name: "invalid signature", publicKey: publicKey, signature: "not base64", timestamp: now, payload: payload},
+		{name: "invalid public key", publicKey: "bm90IGEga2V5", signature: sign(now, payload), timestamp: now, payload: payload},
+		{name: "stale timestamp", publicKey: publicKey, signature: sign(stale, payload), timestamp: stale, payload: payload},
+		{name: "future timestamp", publicKey: publicKey, signature: sign(future, payload), timestamp: future, payload: payload},
+		{name: "invalid timestamp", publicKey: publicKey, signature: sign("abc", payload), timestamp: "abc", payload: payload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifySendGridSignature(tt.publicKey, tt.signature, tt.timestamp, tt.payload); got != tt.want {
+				t.Errorf("VerifySendGridSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSendGridEvents(t *testing.T) {
+	payload := []byte(`[
+		{"email":"a@example.org","event":"delivered","smtp-id":"<m1@example.com>"},
+		{"email":"b@example.org","event":"bounce","type":"bounce","status":"5.1.1","reason":"unknown user","smtp-id":"m2@example.com"},
+		{"email":"c@example.org","event":"bounce","type":"blocked","status":"5.7.1","smtp-id":"<m3@example.com>"},
+		{"email":"d@example.org","event":"deferred","smtp-id":"<m4@example.com>"},
+		{"email":"e@example.org","event":"spamreport","smtp-id":"<m5@example.com>"},
+		{"email":"f@example.org","event":"open","smtp-id":"<m6@example.com>"},
+		{"email":"g@example.org","event":"click","smtp-id":"<m7@example.com>"},
+		{"email":"h@example.org","event":"processed","smtp-id":"<m8@example.com>"},
+		{"email":"i@example.org","event":"unsubscribe","smtp-id":"<m9@example.com>"}
+	]`)
+
+	events, err := ParseSendGridEvents(7, payload)
+	if err != nil {
+		t.Fatalf("ParseSendGridEvents() error = %v", err)
+	}
+
+	want := []struct {
+		recipient string
+		messageID string
+		eventType models.EmailEventType
+	}{
+		{recipient: "a@example.org", messageID: "<m1@example.com>", eventType: models.EmailEventDelivered},
+		{recipient: "b@example.org", messageID: "<m2@example.com>", eventType: models.EmailEventHardBounce},
+		{recipient: "c@example.org", messageID: "<m3@example.com>", eventType: models.EmailEventSoftBounce},
+		{recipient: "d@example.org", messageID: "<m4@example.com>", eventType: models.EmailEventSoftBounce},
+		{recipient: "e@example.org", messageID: "<m5@example.com>", eventType: models.EmailEventComplaint},
+		{recipient: "f@example.org", messageID: "<m6@example.com>", eventType: models.EmailEventOpened},
+		{recipient: "g@example.org", messageID: "<m7@example.com>", eventType: models.EmailEventClicked},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, event := range events {
+		if event.TenantID != 7 || event.Source != "sendgrid" || event.Recipient != want[i].recipient ||
+			event.MessageID != want[i].messageID || event.Type != want[i].eventType {
+			t.Errorf("event %d = %+v, want %+v", i, event, want[i])
+		}
+	}
+	if events[1].StatusCode != "5.1.1" || events[1].Diagnostic != "unknown user" {
+		t.Errorf("bounce status = %q (%q), want 5.1.1 (unknown user)", events[1].StatusCode, events[1].Diagnostic)
+	}
+
+	if _, err := ParseSendGridEvents(7, []byte(`{"event":"delivered"}`)); err == nil {
+		t.Error("ParseSendGridEvents() of a non-array payload must fail")
+	}
+}
@@ -0,0 +1,312 @@
+package email
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// snsHostPattern matches the hosts SNS signing certificates and subscription URLs are served from
+var snsHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// SNSMessage is the envelope SNS posts to HTTP subscribers
+type SNSMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token,omitempty"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject,omitempty"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	SubscribeURL     string `json:"SubscribeURL,omitempty"`
+}
+
+// SNSVerifier checks SNS message signatures, caching the signing certificates
+type SNSVerifier struct {
+	client *http.Client
+	mu     sync.Mutex
+	certs  map[string]*x509.Certificate
+}
+
+func NewSNSVerifier() *SNSVerifier {
+	return &SNSVerifier{
+		client: &http.Client{Timeout: 10 * time.Second},
+		certs:  make(map[string]*x509.Certificate),
+	}
+}
+
+// Verify checks the signature of an SNS message against the certificate it references
+func (v *SNSVerifier) Verify(ctx context.Context, msg *SNSMessage) error {
+	if err := checkSNSURL(msg.SigningCertURL); err != nil {
+		return fmt.Errorf("invalid signing certificate URL: %w", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(msg.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	cert, err := v.certificate(ctx, msg.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported signing certificate key type %T", cert.PublicKey)
+	}
+
+	payload := snsStringToSign(msg)
+	switch msg.SignatureVersion {
+	case "1":
+		digest := sha1.Sum([]byte(payload))
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA1, digest[:], signature)
+	case "2":
+		digest := sha256.Sum256([]byte(payload))
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature)
+	default:
+		return fmt.Errorf("unsupported signature version %q", msg.SignatureVersion)
+	}
+}
+
+// ConfirmSubscription visits the SubscribeURL of a SubscriptionConfirmation message
+func (v *SNSVerifier) ConfirmSubscription(ctx context.Context, msg *SNSMessage) error {
+	if err := checkSNSURL(msg.SubscribeURL); err != nil {
+		return fmt.Errorf("invalid subscribe URL: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", msg.SubscribeURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to confirm subscription: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to confirm subscription: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (v *SNSVerifier) certificate(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	v.mu.Lock()
+	cert, ok := v.certs[certURL]
+	v.mu.Unlock()
+	if ok {
+		return cert, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", certURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing certificate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch signing certificate: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing certificate is not PEM encoded")
+	}
+	cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing certificate: %w", err)
+	}
+
+	v.mu.Lock()
+	v.certs[certURL] = cert
+	v.mu.Unlock()
+
+	return cert, nil
+}
+
+// checkSNSURL makes sure a URL from a message points at SNS over HTTPS
+func checkSNSURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "https" || !snsHostPattern.MatchString(parsed.Host) {
+		return fmt.Errorf("%s is not an SNS URL", rawURL)
+	}
+	return nil
+}
+
+// snsStringToSign builds the canonical form of the message SNS signs
+func snsStringToSign(msg *SNSMessage) string {
+	var fields [][2]string
+	if msg.Type == "Notification" {
+		fields = append(fields, [2]string{"Message", msg.Message}, [2]string{"MessageId", msg.MessageID})
+		if msg.Subject != "" {
+			fields = append(fields, [2]string{"Subject", msg.Subject})
+		}
+		fields = append(fields, [2]string{"Timestamp", msg.Timestamp}, [2]string{"TopicArn", msg.TopicArn}, [2]string{"Type", msg.Type})
+	} else {
+		fields = append(fields,
+			[2]string{"Message", msg.Message},
+			[2]string{"MessageId", msg.MessageID},
+			[2]string{"SubscribeURL", msg.SubscribeURL},
+			[2]string{"Timestamp", msg.Timestamp},
+			[2]string{"Token", msg.Token},
+			[2]string{"TopicArn", msg.TopicArn},
+			[2]string{"Type", msg.Type},
+		)
+	}
+
+	var payload strings.Builder
+	for _, field := range fields {
+		payload.WriteString(field[0])
+		payload.WriteString("\n")
+		payload.WriteString(field[1])
+		payload.WriteString("\n")
+	}
+	return payload.String()
+}
+
+// sesNotification is an SES bounce, complaint or delivery notification, or an SES event
+// publishing record which names its type in eventType instead of notificationType
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+	Mail             struct {
+		MessageID     string `json:"messageId"`
+		CommonHeaders struct {
+			MessageID string `json:"messageId"`
+		} `json:"commonHeaders"`
+		Headers []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+	} `json:"mail"`
+	Bounce *struct {
+		BounceType        string `json:"bounceType"`
+		BounceSubType     string `json:"bounceSubType"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			Status         string `json:"status"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint *struct {
+		ComplaintFeedbackType string `json:"complaintFeedbackType"`
+		ComplainedRecipients  []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+	} `json:"complaint"`
+	Delivery *struct {
+		Recipients []string `json:"recipients"`
+	} `json:"delivery"`
+}
+
+// ParseSESNotification converts the Message of an SES SNS notification to email events.
+// Events are correlated by the Message-ID header the email was sent with, SES replaces it
+// with its own ID on delivery, so the original headers are preferred over the common headers.
+func ParseSESNotification(tenantID int64, message string) ([]*models.EmailEvent, error) {
+	var notification sesNotification
+	if err := json.Unmarshal([]byte(message), &notification); err != nil {
+		return nil, fmt.Errorf("failed to parse SES notification: %w", err)
+	}
+
+	messageID := ""
+	for _, header := range notification.Mail.Headers {
+		if strings.EqualFold(header.Name, "Message-ID") {
+			messageID = header.Value
+			break
+		}
+	}
+	if messageID == "" {
+		messageID = notification.Mail.CommonHeaders.MessageID
+	}
+	messageID = NormalizeMessageID(messageID)
+
+	newEvent := func(recipient string, eventType models.EmailEventType) *models.EmailEvent {
+		return &models.EmailEvent{
+			TenantID:  tenantID,
+			Source:    "ses",
+			MessageID: messageID,
+			Recipient: recipient,
+			Type:      eventType,
+		}
+	}
+
+	notificationType := notification.NotificationType
+	if notificationType == "" {
+		notificationType = notification.EventType
+	}
+
+	var events []*models.EmailEvent
+	switch notificationType {
+	case "Bounce":
+		if notification.Bounce == nil {
+			return nil, fmt.Errorf("SES bounce notification without bounce details")
+		}
+		// Only permanent bounces say the address is bad, undetermined ones are retried like transient ones
+		eventType := models.EmailEventSoftBounce
+		if notification.Bounce.BounceType == "Permanent" {
+			eventType = models.EmailEventHardBounce
+		}
+		for _, recipient := range notification.Bounce.BouncedRecipients {
+			event := newEvent(recipient.EmailAddress, eventType)
+			event.StatusCode = recipient.Status
+			event.Diagnostic = recipient.DiagnosticCode
+			if event.Diagnostic == "" {
+				event.Diagnostic = notification.Bounce.BounceType + "/" + notification.Bounce.BounceSubType
+			}
+			events = append(events, event)
+		}
+
+	case "Complaint":
+		if notification.Complaint == nil {
+			return nil, fmt.Errorf("SES complaint notification without complaint details")
+		}
+		for _, recipient := range notification.Complaint.ComplainedRecipients {
+			event := newEvent(recipient.EmailAddress, models.EmailEventComplaint)
+			event.Diagnostic = notification.Complaint.ComplaintFeedbackType
+			events = append(events, event)
+		}
+
+	case "Delivery":
+		if notification.Delivery == nil {
+			return nil, fmt.Errorf("SES delivery notification without delivery details")
+		}
+		for _, recipient := range notification.Delivery.Recipients {
+			events = append(events, newEvent(recipient, models.EmailEventDelivered))
+		}
+	}
+
+	return events, nil
+}
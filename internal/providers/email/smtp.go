@@ -2,49 +2,80 @@ package email
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/smtp"
+	"net/textproto"
 	"strconv"
-	"strings"
+	"sync"
+	"time"
 
 	"gitlab.smartbet.am/golang/notification/ent"
 	"gitlab.smartbet.am/golang/notification/ent/schema"
 	"gitlab.smartbet.am/golang/notification/internal/logger"
 	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/unsubscribe"
 )
 
 // SMTPProvider implements the EmailProvider interface for SMTP
 type SMTPProvider struct {
-	config schema.SMTPConfig
+	config      schema.SMTPConfig
+	attachments *attachmentLoader
+	dkim        *DKIMSigner
+	pool        *smtpPool
+	unsubscribe *unsubscribe.Links
 }
 
 // NewSMTPProvider creates a new SMTP provider
 func NewSMTPProvider(config map[string]interface{}) (*SMTPProvider, error) {
-	configBytes, err := json.Marshal(config)
+	smtpConfig, err := ParseSMTPConfig(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal SMTP config: %w", err)
-	}
-
-	var smtpConfig schema.SMTPConfig
-	if err := json.Unmarshal(configBytes, &smtpConfig); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal SMTP config: %w", err)
+		return nil, err
 	}
 
 	provider := &SMTPProvider{
 		config: smtpConfig,
 	}
+	provider.pool = newSMTPPool(&provider.config)
+	provider.attachments = newAttachmentLoader(&provider.config)
 
 	if err := provider.ValidateConfig(); err != nil {
 		return nil, fmt.Errorf("invalid SMTP config: %w", err)
 	}
 
+	if smtpConfig.DKIMPrivateKey != "" {
+		provider.dkim, err = NewDKIMSigner(smtpConfig.DKIMDomain, smtpConfig.DKIMSelector, smtpConfig.DKIMPrivateKey, smtpConfig.DKIMSignedHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DKIM config: %w", err)
+		}
+	}
+
 	return provider, nil
 }
 
+// ParseSMTPConfig decodes the provider config of an SMTP provider
+func ParseSMTPConfig(config map[string]interface{}) (schema.SMTPConfig, error) {
+	var smtpConfig schema.SMTPConfig
+
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return smtpConfig, fmt.Errorf("failed to marshal SMTP config: %w", err)
+	}
+
+	if err := json.Unmarshal(configBytes, &smtpConfig); err != nil {
+		return smtpConfig, fmt.Errorf("failed to unmarshal SMTP config: %w", err)
+	}
+
+	return smtpConfig, nil
+}
+
 // Send sends a single email via SMTP
-func (s *SMTPProvider) Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) error {
+func (s *SMTPProvider) Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) (*models.SendResult, error) {
+	return s.send(ctx, notification, messageType, nil)
+}
+
+// send sends an email, loading its downloaded attachments through the cache when one is given
+func (s *SMTPProvider) send(ctx context.Context, notification *ent.Notification, messageType models.MessageType, cache *attachmentCache) (*models.SendResult, error) {
 	log := logger.WithRequest(notification.RequestID)
 
 	// Get appropriate from address and name based on message type
@@ -63,51 +94,113 @@ func (s *SMTPProvider) Send(ctx context.Context, notification *ent.Notification,
 		subject = notification.Headline
 	}
 
-	var replyTo *string
-	if notification.ReplyTo != "" {
-		replyTo = &notification.ReplyTo
+	message, messageID, err := s.buildEmailMessage(ctx, fromAddr, fromName, to, subject, notification, messageType, cache)
+	if err != nil {
+		log.Error("Failed to build SMTP email", err, map[string]interface{}{
+			"notification_id": notification.ID,
+			"to":              to,
+		})
+		err = fmt.Errorf("failed to build SMTP email: %w", err)
+		return models.NewFailedResult(notification.ID, models.ErrorClassInvalidContent, err), err
+	}
+
+	if s.dkim != nil {
+		message, err = s.dkim.Sign(message)
+		if err != nil {
+			log.Error("Failed to sign SMTP email", err, map[string]interface{}{
+				"notification_id": notification.ID,
+				"dkim_domain":     s.config.DKIMDomain,
+			})
+			err = fmt.Errorf("failed to sign SMTP email: %w", err)
+			return models.NewFailedResult(notification.ID, models.ErrorClassUnknown, err), err
+		}
 	}
 
-	message := s.buildEmailMessage(fromAddr, fromName, to, subject, notification.Body, replyTo)
+	// Bounces go to the bounce mailbox when one is configured
+	envelopeFrom := fromAddr
+	if s.config.BounceAddress != "" {
+		envelopeFrom = s.config.BounceAddress
+	}
 
 	// Send email
-	if err := s.sendEmail(fromAddr, []string{to}, message); err != nil {
+	requestSummary := fmt.Sprintf("MAIL FROM:<%s> RCPT TO:<%s> size=%d", envelopeFrom, to, len(message))
+	start := time.Now()
+	err = s.pool.Send(ctx, envelopeFrom, []string{to}, message)
+	latency := time.Since(start)
+
+	if err != nil {
 		log.Error("Failed to send SMTP email", err, map[string]interface{}{
 			"notification_id": notification.ID,
 			"to":              to,
 			"from":            fromAddr,
 		})
-		return fmt.Errorf("failed to send SMTP email: %w", err)
+		sendErr := fmt.Errorf("failed to send SMTP email: %w", err)
+		result := models.NewFailedResult(notification.ID, classifySMTPError(err), sendErr)
+		result.RequestSummary = requestSummary
+		result.ResponseSummary = err.Error()
+		result.Latency = latency
+		return result, sendErr
 	}
 
 	log.Info("SMTP email sent successfully", map[string]interface{}{
 		"notification_id": notification.ID,
 		"to":              to,
 		"from":            fromAddr,
+		"message_id":      messageID,
 	})
 
-	return nil
+	result := models.NewSentResult(notification.ID, messageID)
+	result.RequestSummary = requestSummary
+	result.ResponseSummary = "250 message accepted"
+	result.Latency = latency
+	return result, nil
 }
 
-// SendBatch sends multiple emails via SMTP
-func (s *SMTPProvider) SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) error {
+// SendBatch sends multiple emails via SMTP, spreading them over the pooled connections
+func (s *SMTPProvider) SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) ([]*models.SendResult, error) {
 	log := logger.To("smtp_batch")
 
-	for _, notification := range notifications {
-		if err := s.Send(ctx, notification, messageType); err != nil {
-			log.Error("Failed to send email in batch", err, map[string]interface{}{
-				"notification_id": notification.ID,
-				"batch_size":      len(notifications),
-			})
-			// Continue with other emails even if one fails
+	workers := s.pool.size()
+	if workers > len(notifications) {
+		workers = len(notifications)
+	}
+
+	// Each worker writes only the result slots of the notifications it sends. The notifications
+	// of a batch share their attachments, those are downloaded once.
+	results := make([]*models.SendResult, len(notifications))
+	cache := newAttachmentCache()
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				results[index], _ = s.send(ctx, notifications[index], messageType, cache)
+			}
+		}()
+	}
+
+	for index := range notifications {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.Failed() {
+			failed++
 		}
 	}
 
 	log.Info("SMTP batch processing completed", map[string]interface{}{
 		"batch_size": len(notifications),
+		"failed":     failed,
 	})
 
-	return nil
+	return results, nil
 }
 
 // ValidateConfig validates the SMTP configuration
@@ -118,12 +211,18 @@ func (s *SMTPProvider) ValidateConfig() error {
 	if s.config.Port == "" {
 		return fmt.Errorf("SMTP port is required")
 	}
+	if _, err := strconv.Atoi(s.config.Port); err != nil {
+		return fmt.Errorf("invalid SMTP port: %w", err)
+	}
 	if s.config.Username == "" {
 		return fmt.Errorf("SMTP username is required")
 	}
 	if s.config.Password == "" {
 		return fmt.Errorf("SMTP password is required")
 	}
+	if s.config.DKIMPrivateKey != "" && (s.config.DKIMDomain == "" || s.config.DKIMSelector == "") {
+		return fmt.Errorf("DKIM domain and selector are required when a DKIM private key is set")
+	}
 	return nil
 }
 
@@ -132,6 +231,11 @@ func (s *SMTPProvider) GetType() string {
 	return "smtp"
 }
 
+// ReportsDelivery tells whether SES or SendGrid delivery events are received for the account
+func (s *SMTPProvider) ReportsDelivery() bool {
+	return s.config.SESTopicARN != "" || s.config.SendGridWebhookPublicKey != ""
+}
+
 // getFromAddress returns the appropriate from address based on message type
 func (s *SMTPProvider) getFromAddress(messageType models.MessageType) string {
 	switch messageType {
@@ -198,110 +302,82 @@ func (s *SMTPProvider) getFromName(messageType models.MessageType) string {
 	return s.config.MSGSystemFromName
 }
 
-// buildEmailMessage constructs the email message
-func (s *SMTPProvider) buildEmailMessage(from, fromName, to, subject, body string, replyTo *string) []byte {
-	var message strings.Builder
-
-	// Headers
-	if fromName != "" {
-		message.WriteString(fmt.Sprintf("From: %s <%s>\r\n", fromName, from))
-	} else {
-		message.WriteString(fmt.Sprintf("From: %s\r\n", from))
+// buildEmailMessage constructs the MIME encoded email message and returns it together with its Message-ID
+func (s *SMTPProvider) buildEmailMessage(ctx context.Context, from, fromName, to, subject string, notification *ent.Notification, messageType models.MessageType, cache *attachmentCache) ([]byte, string, error) {
+	message := &Message{
+		From:               from,
+		FromName:           fromName,
+		To:                 to,
+		ReplyTo:            notification.ReplyTo,
+		Subject:            subject,
+		HTMLBody:           notification.Body,
+		ListUnsubscribeURL: s.listUnsubscribeURL(notification, messageType),
 	}
 
-	message.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	if notification.Meta != nil {
+		message.TextBody = notification.Meta.TextBody
+		message.Headers = notification.Meta.Headers
 
-	if replyTo != nil && *replyTo != "" {
-		message.WriteString(fmt.Sprintf("Reply-To: %s\r\n", *replyTo))
+		attachments, err := s.attachments.Load(ctx, notification.Meta.GetAttachments(), cache)
+		if err != nil {
+			return nil, "", err
+		}
+		message.Attachments = attachments
 	}
 
-	message.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
-	message.WriteString("MIME-Version: 1.0\r\n")
-	message.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
-	message.WriteString("\r\n")
-
-	// Body
-	message.WriteString(body)
-
-	return []byte(message.String())
-}
-
-// sendEmail sends the email using SMTP
-func (s *SMTPProvider) sendEmail(from string, to []string, message []byte) error {
-	// Parse port
-	port, err := strconv.Atoi(s.config.Port)
+	data, err := message.Build()
 	if err != nil {
-		return fmt.Errorf("invalid port: %w", err)
-	}
-
-	// Server address
-	addr := fmt.Sprintf("%s:%d", s.config.Host, port)
-
-	// Setup authentication if required
-	var auth smtp.Auth
-	if s.config.SMTPAuth == "1" {
-		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+		return nil, "", err
 	}
 
-	// Handle SSL/TLS
-	if s.config.SMTPSecure == "ssl" || s.config.SMTPSecure == "tls" {
-		return s.sendEmailTLS(addr, auth, from, to, message)
-	}
+	return data, message.MessageID, nil
+}
 
-	// Plain SMTP
-	return smtp.SendMail(addr, auth, from, to, message)
+// SetUnsubscribeLinks enables one-click unsubscribe headers for mail recipients may opt out of
+func (s *SMTPProvider) SetUnsubscribeLinks(links *unsubscribe.Links) {
+	s.unsubscribe = links
 }
 
-// sendEmailTLS sends email using TLS connection
-func (s *SMTPProvider) sendEmailTLS(addr string, auth smtp.Auth, from string, to []string, message []byte) error {
-	// Create TLS config
-	tlsConfig := &tls.Config{
-		ServerName:         s.config.Host,
-		InsecureSkipVerify: false,
+// listUnsubscribeURL returns the one-click unsubscribe link of a non-transactional email,
+// empty when the message type can't be opted out of or links aren't configured
+func (s *SMTPProvider) listUnsubscribeURL(notification *ent.Notification, messageType models.MessageType) string {
+	if s.unsubscribe == nil || !messageType.AllowsOptOut() {
+		return ""
 	}
 
-	// Connect to server
-	conn, err := tls.Dial("tcp", addr, tlsConfig)
-	if err != nil {
-		return fmt.Errorf("failed to connect with TLS: %w", err)
-	}
-	defer conn.Close()
-
-	// Create SMTP client
-	client, err := smtp.NewClient(conn, s.config.Host)
+	link, err := s.unsubscribe.URL(&unsubscribe.Token{
+		TenantID:    notification.TenantID,
+		Channel:     models.TypeEmail,
+		Address:     string(notification.Address),
+		MessageType: messageType,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create SMTP client: %w", err)
-	}
-	defer client.Quit()
-
-	// Authenticate if required
-	if auth != nil {
-		if err := client.Auth(auth); err != nil {
-			return fmt.Errorf("SMTP authentication failed: %w", err)
-		}
-	}
-
-	// Set sender
-	if err := client.Mail(from); err != nil {
-		return fmt.Errorf("failed to set sender: %w", err)
-	}
-
-	// Set recipients
-	for _, recipient := range to {
-		if err := client.Rcpt(recipient); err != nil {
-			return fmt.Errorf("failed to set recipient %s: %w", recipient, err)
-		}
+		return ""
 	}
+	return link
+}
 
-	// Send message
-	writer, err := client.Data()
-	if err != nil {
-		return fmt.Errorf("failed to get data writer: %w", err)
+// classifySMTPError maps SMTP reply codes to error classes, connection problems are temporary
+func classifySMTPError(err error) models.ErrorClass {
+	var protocolErr *textproto.Error
+	if !errors.As(err, &protocolErr) {
+		return models.ErrorClassTemporary
 	}
 
-	if _, err := writer.Write(message); err != nil {
-		return fmt.Errorf("failed to write message: %w", err)
+	var recipientErr *RecipientError
+	switch {
+	case protocolErr.Code >= 400 && protocolErr.Code < 500:
+		return models.ErrorClassTemporary
+	case protocolErr.Code == 530 || protocolErr.Code == 534 || protocolErr.Code == 535:
+		return models.ErrorClassAuthentication
+	case errors.As(err, &recipientErr):
+		return models.ErrorClassInvalidRecipient
+	default:
+		return models.ErrorClassRejected
 	}
+}
 
-	return writer.Close()
+// Close ends the pooled SMTP sessions
+func (s *SMTPProvider) Close() {
+	s.pool.Close()
 }
@@ -5,28 +5,70 @@ import (
 
 	"gitlab.smartbet.am/golang/notification/ent"
 	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/unsubscribe"
 )
 
+// Providers return one SendResult per notification. Send also returns an error when the
+// notification failed, SendBatch only when the batch couldn't be attempted at all.
+
 // EmailProvider defines the interface for email providers
 type EmailProvider interface {
-	Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) error
-	SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) error
+	Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) (*models.SendResult, error)
+	SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) ([]*models.SendResult, error)
 	ValidateConfig() error
 	GetType() string
 }
 
+// UnsubscribeLinker is implemented by email providers that announce one-click unsubscribe
+// links in the headers of messages recipients may opt out of
+type UnsubscribeLinker interface {
+	SetUnsubscribeLinks(links *unsubscribe.Links)
+}
+
+// DeliveryReporter is implemented by providers whose configuration can report delivery of sent
+// messages. Messages sent through other providers are final once they're sent.
+type DeliveryReporter interface {
+	ReportsDelivery() bool
+}
+
 // SMSProvider defines the interface for SMS providers
 type SMSProvider interface {
-	Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) error
-	SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) error
+	Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) (*models.SendResult, error)
+	SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) ([]*models.SendResult, error)
 	ValidateConfig() error
 	GetType() string
 }
 
 // PushProvider defines the interface for push notification providers
 type PushProvider interface {
-	Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) error
-	SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) error
+	Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) (*models.SendResult, error)
+	SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) ([]*models.SendResult, error)
+	ValidateConfig() error
+	GetType() string
+}
+
+// TelegramProvider defines the interface for Telegram bot providers
+type TelegramProvider interface {
+	Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) (*models.SendResult, error)
+	SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) ([]*models.SendResult, error)
+	ValidateConfig() error
+	GetType() string
+}
+
+// ViberProvider defines the interface for Viber business message providers. The SMS fallback
+// is only attached for recipients the callers cleared for SMS.
+type ViberProvider interface {
+	Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType, smsFallback bool) (*models.SendResult, error)
+	SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType, smsFallbacks map[int]bool) ([]*models.SendResult, error)
+	ValidateConfig() error
+	GetType() string
+}
+
+// WhatsAppProvider defines the interface for WhatsApp providers. Free-form messages can only be
+// sent to users within the customer service window, the callers tell which users are.
+type WhatsAppProvider interface {
+	Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType, sessionOpen bool) (*models.SendResult, error)
+	SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType, openSessions map[int]bool) ([]*models.SendResult, error)
 	ValidateConfig() error
 	GetType() string
 }
@@ -35,3 +77,6 @@ type PushProvider interface {
 type EmailProviderFactory func(config map[string]interface{}) (EmailProvider, error)
 type SMSProviderFactory func(config map[string]interface{}) (SMSProvider, error)
 type PushProviderFactory func(config map[string]interface{}) (PushProvider, error)
+type TelegramProviderFactory func(config map[string]interface{}) (TelegramProvider, error)
+type ViberProviderFactory func(config map[string]interface{}) (ViberProvider, error)
+type WhatsAppProviderFactory func(config map[string]interface{}) (WhatsAppProvider, error)
@@ -4,30 +4,40 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
 	_ "gitlab.smartbet.am/golang/notification/ent/schema"
+	"gitlab.smartbet.am/golang/notification/internal/config"
+	"gitlab.smartbet.am/golang/notification/internal/providers/whatsapp"
 	"gitlab.smartbet.am/golang/notification/internal/repository"
+	"gitlab.smartbet.am/golang/notification/internal/unsubscribe"
 )
 
 type EmailProviderManager struct {
-	registry   *ProviderRegistry
-	configRepo *repository.PartnerConfigRepository
-	providers  map[int64]EmailProvider
-	mu         sync.RWMutex
-	logger     *logrus.Logger
+	registry    *ProviderRegistry
+	configRepo  *repository.PartnerConfigRepository
+	unsubscribe *unsubscribe.Links
+	providers   map[int64]EmailProvider
+	names       map[int64]string
+	mu          sync.RWMutex
+	logger      *logrus.Logger
 }
 
 func NewEmailProviderManager(
 	registry *ProviderRegistry,
 	configRepo *repository.PartnerConfigRepository,
+	unsubscribeLinks *unsubscribe.Links,
 	logger *logrus.Logger,
 ) *EmailProviderManager {
 	return &EmailProviderManager{
-		registry:   registry,
-		configRepo: configRepo,
-		providers:  make(map[int64]EmailProvider),
-		logger:     logger,
+		registry:    registry,
+		configRepo:  configRepo,
+		unsubscribe: unsubscribeLinks,
+		providers:   make(map[int64]EmailProvider),
+		names:       make(map[int64]string),
+		logger:      logger,
 	}
 }
 
@@ -54,9 +64,13 @@ func (m *EmailProviderManager) GetProvider(tenantID int64) (EmailProvider, error
 			if err != nil {
 				continue
 			}
+			if linker, ok := provider.(UnsubscribeLinker); ok {
+				linker.SetUnsubscribeLinks(m.unsubscribe)
+			}
 
 			m.mu.Lock()
 			m.providers[tenantID] = provider
+			m.names[tenantID] = providerConfig.Name
 			m.mu.Unlock()
 
 			return provider, nil
@@ -66,10 +80,53 @@ func (m *EmailProviderManager) GetProvider(tenantID int64) (EmailProvider, error
 	return nil, fmt.Errorf("no enabled email provider found for tenant %d", tenantID)
 }
 
+// GetProviderName returns the configured name of the tenant's loaded email provider
+func (m *EmailProviderManager) GetProviderName(tenantID int64) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.names[tenantID]
+}
+
+// Invalidate drops the tenant's loaded email provider so the next send loads the current
+// configuration, and releases the connections the dropped provider holds
+func (m *EmailProviderManager) Invalidate(tenantID int64) {
+	m.mu.Lock()
+	provider, exists := m.providers[tenantID]
+	delete(m.providers, tenantID)
+	delete(m.names, tenantID)
+	m.mu.Unlock()
+
+	if exists {
+		closeProvider(provider)
+	}
+}
+
+// Close releases the connections of all loaded email providers
+func (m *EmailProviderManager) Close() {
+	m.mu.Lock()
+	loaded := m.providers
+	m.providers = make(map[int64]EmailProvider)
+	m.names = make(map[int64]string)
+	m.mu.Unlock()
+
+	for _, provider := range loaded {
+		closeProvider(provider)
+	}
+}
+
+// closeProvider closes providers that keep connections open, like the pooled SMTP provider
+func closeProvider(provider interface{}) {
+	if closer, ok := provider.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
+
 type SMSProviderManager struct {
 	registry   *ProviderRegistry
 	configRepo *repository.PartnerConfigRepository
+	config     *config.Config
 	providers  map[int64]SMSProvider
+	names      map[int64]string
 	mu         sync.RWMutex
 	logger     *logrus.Logger
 }
@@ -77,12 +134,15 @@ type SMSProviderManager struct {
 func NewSMSProviderManager(
 	registry *ProviderRegistry,
 	configRepo *repository.PartnerConfigRepository,
+	config *config.Config,
 	logger *logrus.Logger,
 ) *SMSProviderManager {
 	return &SMSProviderManager{
 		registry:   registry,
 		configRepo: configRepo,
+		config:     config,
 		providers:  make(map[int64]SMSProvider),
+		names:      make(map[int64]string),
 		logger:     logger,
 	}
 }
@@ -106,13 +166,14 @@ func (m *SMSProviderManager) GetProvider(tenantID int64) (SMSProvider, error) {
 	for _, providerConfig := range config.SMSProviders {
 		if providerConfig.Enabled {
 			// Convert schema.ProviderConfig to the format expected by registry
-			provider, err := m.registry.CreateSMSProvider(providerConfig.Config, providerConfig.Type)
+			provider, err := m.registry.CreateSMSProvider(m.withCallbackURLs(tenantID, providerConfig.Type, providerConfig.Config), providerConfig.Type)
 			if err != nil {
 				continue
 			}
 
 			m.mu.Lock()
 			m.providers[tenantID] = provider
+			m.names[tenantID] = providerConfig.Name
 			m.mu.Unlock()
 
 			return provider, nil
@@ -121,3 +182,318 @@ func (m *SMSProviderManager) GetProvider(tenantID int64) (SMSProvider, error) {
 
 	return nil, fmt.Errorf("no enabled SMS provider found for tenant %d", tenantID)
 }
+
+// withCallbackURLs points provider delivery receipts at our webhooks unless the tenant configured its own
+func (m *SMSProviderManager) withCallbackURLs(tenantID int64, providerType string, providerConfig map[string]interface{}) map[string]interface{} {
+	if providerType != "twilio" {
+		return providerConfig
+	}
+	if _, exists := providerConfig["status_callback_url"]; exists {
+		return providerConfig
+	}
+
+	callbackURL := m.config.GetWebhookURL(fmt.Sprintf("/webhooks/twilio/%d/status", tenantID))
+	if callbackURL == "" {
+		return providerConfig
+	}
+
+	// Copy so the stored config isn't modified
+	result := make(map[string]interface{}, len(providerConfig)+1)
+	for key, value := range providerConfig {
+		result[key] = value
+	}
+	result["status_callback_url"] = callbackURL
+	return result
+}
+
+// GetProviderName returns the configured name of the tenant's loaded SMS provider
+func (m *SMSProviderManager) GetProviderName(tenantID int64) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.names[tenantID]
+}
+
+type PushProviderManager struct {
+	registry   *ProviderRegistry
+	configRepo *repository.PartnerConfigRepository
+	providers  map[int64]PushProvider
+	names      map[int64]string
+	mu         sync.RWMutex
+	logger     *logrus.Logger
+}
+
+func NewPushProviderManager(
+	registry *ProviderRegistry,
+	configRepo *repository.PartnerConfigRepository,
+	logger *logrus.Logger,
+) *PushProviderManager {
+	return &PushProviderManager{
+		registry:   registry,
+		configRepo: configRepo,
+		providers:  make(map[int64]PushProvider),
+		names:      make(map[int64]string),
+		logger:     logger,
+	}
+}
+
+func (m *PushProviderManager) GetProvider(tenantID int64) (PushProvider, error) {
+	m.mu.RLock()
+	provider, exists := m.providers[tenantID]
+	m.mu.RUnlock()
+
+	if exists {
+		return provider, nil
+	}
+
+	// Load provider configuration
+	config, err := m.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find enabled push provider
+	for _, providerConfig := range config.PushProviders {
+		if providerConfig.Enabled {
+			provider, err := m.registry.CreatePushProvider(providerConfig.Config, providerConfig.Type)
+			if err != nil {
+				continue
+			}
+
+			m.mu.Lock()
+			m.providers[tenantID] = provider
+			m.names[tenantID] = providerConfig.Name
+			m.mu.Unlock()
+
+			return provider, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no enabled push provider found for tenant %d", tenantID)
+}
+
+// GetProviderName returns the configured name of the tenant's loaded push provider
+func (m *PushProviderManager) GetProviderName(tenantID int64) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.names[tenantID]
+}
+
+type TelegramProviderManager struct {
+	registry   *ProviderRegistry
+	configRepo *repository.PartnerConfigRepository
+	providers  map[int64]TelegramProvider
+	names      map[int64]string
+	mu         sync.RWMutex
+	logger     *logrus.Logger
+}
+
+func NewTelegramProviderManager(
+	registry *ProviderRegistry,
+	configRepo *repository.PartnerConfigRepository,
+	logger *logrus.Logger,
+) *TelegramProviderManager {
+	return &TelegramProviderManager{
+		registry:   registry,
+		configRepo: configRepo,
+		providers:  make(map[int64]TelegramProvider),
+		names:      make(map[int64]string),
+		logger:     logger,
+	}
+}
+
+func (m *TelegramProviderManager) GetProvider(tenantID int64) (TelegramProvider, error) {
+	m.mu.RLock()
+	provider, exists := m.providers[tenantID]
+	m.mu.RUnlock()
+
+	if exists {
+		return provider, nil
+	}
+
+	// Load provider configuration
+	config, err := m.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find enabled Telegram provider
+	for _, providerConfig := range config.TelegramProviders {
+		if providerConfig.Enabled {
+			provider, err := m.registry.CreateTelegramProvider(providerConfig.Config, providerConfig.Type)
+			if err != nil {
+				continue
+			}
+
+			m.mu.Lock()
+			m.providers[tenantID] = provider
+			m.names[tenantID] = providerConfig.Name
+			m.mu.Unlock()
+
+			return provider, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no enabled Telegram provider found for tenant %d", tenantID)
+}
+
+// GetProviderName returns the configured name of the tenant's loaded Telegram provider
+func (m *TelegramProviderManager) GetProviderName(tenantID int64) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.names[tenantID]
+}
+
+type ViberProviderManager struct {
+	registry   *ProviderRegistry
+	configRepo *repository.PartnerConfigRepository
+	providers  map[int64]ViberProvider
+	names      map[int64]string
+	mu         sync.RWMutex
+	logger     *logrus.Logger
+}
+
+func NewViberProviderManager(
+	registry *ProviderRegistry,
+	configRepo *repository.PartnerConfigRepository,
+	logger *logrus.Logger,
+) *ViberProviderManager {
+	return &ViberProviderManager{
+		registry:   registry,
+		configRepo: configRepo,
+		providers:  make(map[int64]ViberProvider),
+		names:      make(map[int64]string),
+		logger:     logger,
+	}
+}
+
+func (m *ViberProviderManager) GetProvider(tenantID int64) (ViberProvider, error) {
+	m.mu.RLock()
+	provider, exists := m.providers[tenantID]
+	m.mu.RUnlock()
+
+	if exists {
+		return provider, nil
+	}
+
+	// Load provider configuration
+	config, err := m.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find enabled Viber provider
+	for _, providerConfig := range config.ViberProviders {
+		if providerConfig.Enabled {
+			provider, err := m.registry.CreateViberProvider(providerConfig.Config, providerConfig.Type)
+			if err != nil {
+				continue
+			}
+
+			m.mu.Lock()
+			m.providers[tenantID] = provider
+			m.names[tenantID] = providerConfig.Name
+			m.mu.Unlock()
+
+			return provider, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no enabled Viber provider found for tenant %d", tenantID)
+}
+
+// GetProviderName returns the configured name of the tenant's loaded Viber provider
+func (m *ViberProviderManager) GetProviderName(tenantID int64) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.names[tenantID]
+}
+
+type WhatsAppProviderManager struct {
+	registry   *ProviderRegistry
+	configRepo *repository.PartnerConfigRepository
+	sessions   *repository.WhatsAppSessionRepository
+	providers  map[int64]WhatsAppProvider
+	names      map[int64]string
+	mu         sync.RWMutex
+	logger     *logrus.Logger
+}
+
+func NewWhatsAppProviderManager(
+	registry *ProviderRegistry,
+	configRepo *repository.PartnerConfigRepository,
+	sessions *repository.WhatsAppSessionRepository,
+	logger *logrus.Logger,
+) *WhatsAppProviderManager {
+	return &WhatsAppProviderManager{
+		registry:   registry,
+		configRepo: configRepo,
+		sessions:   sessions,
+		providers:  make(map[int64]WhatsAppProvider),
+		names:      make(map[int64]string),
+		logger:     logger,
+	}
+}
+
+func (m *WhatsAppProviderManager) GetProvider(tenantID int64) (WhatsAppProvider, error) {
+	m.mu.RLock()
+	provider, exists := m.providers[tenantID]
+	m.mu.RUnlock()
+
+	if exists {
+		return provider, nil
+	}
+
+	// Load provider configuration
+	config, err := m.configRepo.GetByTenantID(context.Background(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find enabled WhatsApp provider
+	for _, providerConfig := range config.WhatsAppProviders {
+		if providerConfig.Enabled {
+			provider, err := m.registry.CreateWhatsAppProvider(providerConfig.Config, providerConfig.Type)
+			if err != nil {
+				continue
+			}
+
+			m.mu.Lock()
+			m.providers[tenantID] = provider
+			m.names[tenantID] = providerConfig.Name
+			m.mu.Unlock()
+
+			return provider, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no enabled WhatsApp provider found for tenant %d", tenantID)
+}
+
+// GetProviderName returns the configured name of the tenant's loaded WhatsApp provider
+func (m *WhatsAppProviderManager) GetProviderName(tenantID int64) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.names[tenantID]
+}
+
+// OpenSessions returns the IDs of the notifications whose recipients wrote to the tenant within
+// the customer service window, free-form messages can only be sent to them
+func (m *WhatsAppProviderManager) OpenSessions(ctx context.Context, tenantID int64, notifications []*ent.Notification) (map[int]bool, error) {
+	phones := make([]string, 0, len(notifications))
+	for _, notification := range notifications {
+		phones = append(phones, string(notification.Address))
+	}
+
+	lastInbound, err := m.sessions.LastInbound(ctx, tenantID, phones)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WhatsApp sessions: %w", err)
+	}
+
+	open := make(map[int]bool, len(notifications))
+	for _, notification := range notifications {
+		if at, ok := lastInbound[string(notification.Address)]; ok && time.Since(at) < whatsapp.SessionWindow {
+			open[notification.ID] = true
+		}
+	}
+	return open, nil
+}
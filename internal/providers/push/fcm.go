@@ -0,0 +1,415 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+const (
+	defaultAPIBaseURL = "https://fcm.googleapis.com"
+	defaultTokenURL   = "https://oauth2.googleapis.com/token"
+	messagingScope    = "https://www.googleapis.com/auth/firebase.messaging"
+	// tokenRefreshMargin renews access tokens a little before they expire
+	tokenRefreshMargin = time.Minute
+)
+
+// FCMProvider sends push notifications through the Firebase Cloud Messaging HTTP v1 API,
+// authenticated as a service account of the tenant's Firebase project
+type FCMProvider struct {
+	config     FCMConfig
+	account    ServiceAccount
+	privateKey *rsa.PrivateKey
+	client     *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// FCMConfig represents FCM configuration
+type FCMConfig struct {
+	ProjectID string `json:"project_id"`
+	// ServiceAccount is the JSON key of a service account allowed to send messages, either as
+	// object or as string
+	ServiceAccount json.RawMessage `json:"service_account"`
+	// APIBaseURL points at the FCM API, e.g. a mock while testing
+	APIBaseURL string `json:"api_base_url"`
+}
+
+// ServiceAccount holds the fields of a service account key used to get access tokens
+type ServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// Message is the message of a messages:send request
+type Message struct {
+	Token        string            `json:"token"`
+	Notification *Notification     `json:"notification,omitempty"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+// Notification is the part of a message the device shows
+type Notification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+// APIError is returned when FCM rejects a message. ErrorCode is the FcmError code of the
+// details, e.g. UNREGISTERED, when there is one.
+type APIError struct {
+	StatusCode int
+	Status     string
+	ErrorCode  string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	code := e.ErrorCode
+	if code == "" {
+		code = e.Status
+	}
+	return fmt.Sprintf("FCM API error: %s (code: %d, status: %s)", e.Message, e.StatusCode, code)
+}
+
+// NewFCMProvider creates a new FCM provider
+func NewFCMProvider(config map[string]interface{}) (*FCMProvider, error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal FCM config: %w", err)
+	}
+
+	var fcmConfig FCMConfig
+	if err := json.Unmarshal(configBytes, &fcmConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal FCM config: %w", err)
+	}
+
+	if fcmConfig.APIBaseURL == "" {
+		fcmConfig.APIBaseURL = defaultAPIBaseURL
+	}
+	fcmConfig.APIBaseURL = strings.TrimSuffix(fcmConfig.APIBaseURL, "/")
+
+	provider := &FCMProvider{
+		config: fcmConfig,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+
+	if err := provider.ValidateConfig(); err != nil {
+		return nil, fmt.Errorf("invalid FCM config: %w", err)
+	}
+
+	return provider, nil
+}
+
+// Send sends a single push notification to the device token the notification is addressed to
+func (f *FCMProvider) Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) (*models.SendResult, error) {
+	log := logger.WithRequest(notification.RequestID)
+
+	message := buildMessage(notification)
+	requestSummary := fmt.Sprintf("POST v1/projects/%s/messages:send title_length=%d body_length=%d data_keys=%d",
+		f.config.ProjectID, len(message.Notification.Title), len(message.Notification.Body), len(message.Data))
+
+	start := time.Now()
+	messageID, err := f.sendMessage(ctx, message)
+	latency := time.Since(start)
+
+	if err != nil {
+		log.Error("Failed to send push notification", err, map[string]interface{}{
+			"notification_id": notification.ID,
+		})
+		sendErr := fmt.Errorf("failed to send push notification: %w", err)
+		result := models.NewFailedResult(notification.ID, classifyError(err), sendErr)
+		result.RequestSummary = requestSummary
+		result.ResponseSummary = err.Error()
+		result.Latency = latency
+		return result, sendErr
+	}
+
+	log.Info("Push notification sent successfully", map[string]interface{}{
+		"notification_id": notification.ID,
+		"message_id":      messageID,
+	})
+
+	result := models.NewSentResult(notification.ID, messageID)
+	result.RequestSummary = requestSummary
+	result.ResponseSummary = "name=" + messageID
+	result.Latency = latency
+	return result, nil
+}
+
+// SendBatch sends multiple push notifications, one messages:send call each
+func (f *FCMProvider) SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) ([]*models.SendResult, error) {
+	log := logger.To("push_batch")
+
+	// The HTTP v1 API has no batch endpoint
+	results := make([]*models.SendResult, 0, len(notifications))
+	errorCount := 0
+
+	for _, notification := range notifications {
+		result, err := f.Send(ctx, notification, messageType)
+		if err != nil {
+			errorCount++
+		}
+		results = append(results, result)
+	}
+
+	log.Info("Push batch processing completed", map[string]interface{}{
+		"batch_size":    len(notifications),
+		"success_count": len(notifications) - errorCount,
+		"error_count":   errorCount,
+	})
+
+	return results, nil
+}
+
+// ValidateConfig validates the FCM configuration and reads the service account key
+func (f *FCMProvider) ValidateConfig() error {
+	if f.config.ProjectID == "" {
+		return fmt.Errorf("FCM project ID is required")
+	}
+	if len(f.config.ServiceAccount) == 0 {
+		return fmt.Errorf("FCM service account is required")
+	}
+
+	// The key is accepted as JSON object or as string holding the JSON of the key file
+	raw := []byte(f.config.ServiceAccount)
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		raw = []byte(encoded)
+	}
+	if err := json.Unmarshal(raw, &f.account); err != nil {
+		return fmt.Errorf("FCM service account is not a valid key: %w", err)
+	}
+	if f.account.ClientEmail == "" || f.account.PrivateKey == "" {
+		return fmt.Errorf("FCM service account needs client_email and private_key")
+	}
+	if f.account.TokenURI == "" {
+		f.account.TokenURI = defaultTokenURL
+	}
+
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(f.account.PrivateKey))
+	if err != nil {
+		return fmt.Errorf("FCM service account private key is invalid: %w", err)
+	}
+	f.privateKey = privateKey
+
+	return nil
+}
+
+// GetType returns the provider type
+func (f *FCMProvider) GetType() string {
+	return "fcm"
+}
+
+// buildMessage turns a notification into an FCM message. The headline is the title and the
+// body the text shown, the notification data is passed to the app as data payload.
+func buildMessage(notification *ent.Notification) *Message {
+	message := &Message{
+		Token: string(notification.Address),
+		Notification: &Notification{
+			Title: notification.Headline,
+			Body:  notification.Body,
+		},
+	}
+
+	// FCM only takes string values in the data payload
+	if len(notification.Data) > 0 {
+		message.Data = make(map[string]string, len(notification.Data))
+		for key, value := range notification.Data {
+			if text, ok := value.(string); ok {
+				message.Data[key] = text
+				continue
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				continue
+			}
+			message.Data[key] = string(encoded)
+		}
+	}
+
+	return message
+}
+
+// sendMessage calls messages:send and returns the name FCM gave the message
+func (f *FCMProvider) sendMessage(ctx context.Context, message *Message) (string, error) {
+	accessToken, err := f.token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(map[string]*Message{"message": message})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/messages:send", f.config.APIBaseURL, url.PathEscape(f.config.ProjectID))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return "", parseAPIError(resp.StatusCode, body)
+	}
+
+	var response struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return response.Name, nil
+}
+
+// token returns an access token of the service account, getting a new one when the cached
+// token is about to expire
+func (f *FCMProvider) token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.accessToken != "" && time.Now().Add(tokenRefreshMargin).Before(f.expiresAt) {
+		return f.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   f.account.ClientEmail,
+		"scope": messagingScope,
+		"aud":   f.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(f.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", f.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var response struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil && resp.StatusCode < 400 {
+		return "", fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if resp.StatusCode >= 400 || response.AccessToken == "" {
+		return "", &APIError{
+			StatusCode: http.StatusUnauthorized,
+			Status:     "UNAUTHENTICATED",
+			Message:    fmt.Sprintf("failed to get access token: %s %s", response.Error, response.ErrorDescription),
+		}
+	}
+
+	f.accessToken = response.AccessToken
+	f.expiresAt = now.Add(time.Duration(response.ExpiresIn) * time.Second)
+	return f.accessToken, nil
+}
+
+// parseAPIError reads the error of a failed messages:send call
+func parseAPIError(statusCode int, body []byte) error {
+	var response struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+			Details []struct {
+				Type      string `json:"@type"`
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return &APIError{StatusCode: statusCode, Message: strings.TrimSpace(string(body))}
+	}
+
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Status:     response.Error.Status,
+		Message:    response.Error.Message,
+	}
+	for _, detail := range response.Error.Details {
+		if strings.HasSuffix(detail.Type, "google.firebase.fcm.v1.FcmError") {
+			apiErr.ErrorCode = detail.ErrorCode
+		}
+	}
+	return apiErr
+}
+
+// classifyError maps FCM errors to error classes, see
+// https://firebase.google.com/docs/reference/fcm/rest/v1/ErrorCode
+func classifyError(err error) models.ErrorClass {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return models.ErrorClassTemporary
+	}
+
+	switch {
+	case apiErr.ErrorCode == "UNREGISTERED" || apiErr.ErrorCode == "SENDER_ID_MISMATCH":
+		// The app was uninstalled, the token expired or belongs to another project
+		return models.ErrorClassInvalidRecipient
+	case apiErr.ErrorCode == "INVALID_ARGUMENT" && strings.Contains(strings.ToLower(apiErr.Message), "registration token"):
+		return models.ErrorClassInvalidRecipient
+	case apiErr.ErrorCode == "INVALID_ARGUMENT" || apiErr.Status == "INVALID_ARGUMENT":
+		return models.ErrorClassInvalidContent
+	case apiErr.ErrorCode == "THIRD_PARTY_AUTH_ERROR" || apiErr.StatusCode == http.StatusUnauthorized ||
+		apiErr.StatusCode == http.StatusForbidden:
+		return models.ErrorClassAuthentication
+	case apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500:
+		return models.ErrorClassTemporary
+	default:
+		return models.ErrorClassRejected
+	}
+}
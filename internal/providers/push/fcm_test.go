@@ -0,0 +1,166 @@
+package push
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/types"
+)
+
+// newTestFCM returns a provider sending to a mock FCM API that rejects the tokens of errors
+// with the given error responses, and a counter of the access tokens it handed out
+func newTestFCM(t *testing.T, errors map[string]string) (*FCMProvider, *int, *[]Message) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	tokens := 0
+	var messages []Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+				t.Errorf("invalid token request: %v", r.Form)
+			}
+			claims := jwt.MapClaims{}
+			if _, err := jwt.ParseWithClaims(r.Form.Get("assertion"), claims, func(*jwt.Token) (interface{}, error) {
+				return &key.PublicKey, nil
+			}); err != nil || claims["iss"] != "sender@demo.iam.gserviceaccount.com" || claims["scope"] != messagingScope {
+				t.Errorf("invalid assertion: %v (%v)", err, claims)
+			}
+			tokens++
+			w.Write([]byte(`{"access_token":"access-token","expires_in":3600,"token_type":"Bearer"}`))
+		case "/v1/projects/demo/messages:send":
+			if r.Header.Get("Authorization") != "Bearer access-token" {
+				t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+			}
+			var request struct {
+				Message Message `json:"message"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Errorf("invalid request: %v", err)
+			}
+			messages = append(messages, request.Message)
+			if response, ok := errors[request.Message.Token]; ok {
+				var status struct {
+					Error struct {
+						Code int `json:"code"`
+					} `json:"error"`
+				}
+				_ = json.Unmarshal([]byte(response), &status)
+				w.WriteHeader(status.Error.Code)
+				w.Write([]byte(response))
+				return
+			}
+			w.Write([]byte(`{"name":"projects/demo/messages/` + request.Message.Token + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := NewFCMProvider(map[string]interface{}{
+		"project_id": "demo",
+		"service_account": map[string]interface{}{
+			"client_email": "sender@demo.iam.gserviceaccount.com",
+			"private_key":  string(privateKey),
+			"token_uri":    server.URL + "/token",
+		},
+		"api_base_url": server.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewFCMProvider() error = %v", err)
+	}
+	return provider, &tokens, &messages
+}
+
+func TestFCMProviderSendBatch(t *testing.T) {
+	provider, tokens, messages := newTestFCM(t, map[string]string{
+		"token-gone": `{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND",
+			"details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"UNREGISTERED"}]}}`,
+		"token-malformed": `{"error":{"code":400,"message":"The registration token is not a valid FCM registration token","status":"INVALID_ARGUMENT",
+			"details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"INVALID_ARGUMENT"}]}}`,
+		"token-busy": `{"error":{"code":503,"message":"The service is currently unavailable.","status":"UNAVAILABLE"}}`,
+	})
+
+	notifications := []*ent.Notification{
+		{ID: 1, Address: types.Address("token-ok"), Headline: "Bonus", Body: "Your bonus is ready",
+			Data: map[string]interface{}{"screen": "bonuses", "amount": 50}},
+		{ID: 2, Address: types.Address("token-gone"), Body: "Your bonus is ready"},
+		{ID: 3, Address: types.Address("token-malformed"), Body: "Your bonus is ready"},
+		{ID: 4, Address: types.Address("token-busy"), Body: "Your bonus is ready"},
+	}
+
+	results, err := provider.SendBatch(context.Background(), notifications, models.MessageTypeBonus)
+	if err != nil {
+		t.Fatalf("SendBatch() error = %v", err)
+	}
+
+	want := []struct {
+		status     models.NotificationStatus
+		errorClass models.ErrorClass
+	}{
+		{status: models.StatusSent},
+		{status: models.StatusFailed, errorClass: models.ErrorClassInvalidRecipient},
+		{status: models.StatusFailed, errorClass: models.ErrorClassInvalidRecipient},
+		{status: models.StatusFailed, errorClass: models.ErrorClassTemporary},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, result := range results {
+		if result.NotificationID != notifications[i].ID || result.Status != want[i].status || result.ErrorClass != want[i].errorClass {
+			t.Errorf("result %d = %s/%s, want %s/%s", i, result.Status, result.ErrorClass, want[i].status, want[i].errorClass)
+		}
+	}
+	if results[0].ProviderMessageID != "projects/demo/messages/token-ok" {
+		t.Errorf("message ID = %q", results[0].ProviderMessageID)
+	}
+
+	// The access token is reused until it expires
+	if *tokens != 1 {
+		t.Errorf("requested %d access tokens, want 1", *tokens)
+	}
+
+	sent := (*messages)[0]
+	if sent.Notification == nil || sent.Notification.Title != "Bonus" || sent.Notification.Body != "Your bonus is ready" {
+		t.Errorf("notification = %+v", sent.Notification)
+	}
+	if sent.Data["screen"] != "bonuses" || sent.Data["amount"] != "50" {
+		t.Errorf("data = %v, want string values", sent.Data)
+	}
+}
+
+func TestNewFCMProviderInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+	}{
+		{name: "no project", config: map[string]interface{}{"service_account": map[string]interface{}{"client_email": "a", "private_key": "b"}}},
+		{name: "no service account", config: map[string]interface{}{"project_id": "demo"}},
+		{name: "invalid key", config: map[string]interface{}{"project_id": "demo", "service_account": `{"client_email":"a","private_key":"not a key"}`}},
+		{name: "no client email", config: map[string]interface{}{"project_id": "demo", "service_account": map[string]interface{}{"private_key": "b"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFCMProvider(tt.config); err == nil {
+				t.Error("NewFCMProvider() accepted an invalid config")
+			}
+		})
+	}
+}
@@ -5,21 +5,31 @@ import (
 	"sync"
 
 	"gitlab.smartbet.am/golang/notification/internal/providers/email"
+	"gitlab.smartbet.am/golang/notification/internal/providers/push"
 	"gitlab.smartbet.am/golang/notification/internal/providers/sms"
+	"gitlab.smartbet.am/golang/notification/internal/providers/telegram"
+	"gitlab.smartbet.am/golang/notification/internal/providers/viber"
+	"gitlab.smartbet.am/golang/notification/internal/providers/whatsapp"
 )
 
 type ProviderRegistry struct {
-	emailFactories map[string]EmailProviderFactory
-	smsFactories   map[string]SMSProviderFactory
-	pushFactories  map[string]PushProviderFactory
-	mu             sync.RWMutex
+	emailFactories    map[string]EmailProviderFactory
+	smsFactories      map[string]SMSProviderFactory
+	pushFactories     map[string]PushProviderFactory
+	telegramFactories map[string]TelegramProviderFactory
+	viberFactories    map[string]ViberProviderFactory
+	whatsappFactories map[string]WhatsAppProviderFactory
+	mu                sync.RWMutex
 }
 
 func NewProviderRegistry() *ProviderRegistry {
 	registry := &ProviderRegistry{
-		emailFactories: make(map[string]EmailProviderFactory),
-		smsFactories:   make(map[string]SMSProviderFactory),
-		pushFactories:  make(map[string]PushProviderFactory),
+		emailFactories:    make(map[string]EmailProviderFactory),
+		smsFactories:      make(map[string]SMSProviderFactory),
+		pushFactories:     make(map[string]PushProviderFactory),
+		telegramFactories: make(map[string]TelegramProviderFactory),
+		viberFactories:    make(map[string]ViberProviderFactory),
+		whatsappFactories: make(map[string]WhatsAppProviderFactory),
 	}
 
 	// Register email providers
@@ -48,14 +58,41 @@ func NewProviderRegistry() *ProviderRegistry {
 		return provider, nil
 	})
 
-	// TODO: Register push providers when implemented
-	// registry.RegisterPushProvider("fcm", func(config map[string]interface{}) (PushProvider, error) {
-	//     provider, err := push.NewFCMProvider(config)
-	//     if err != nil {
-	//         return nil, err
-	//     }
-	//     return provider, nil
-	// })
+	// Register Telegram providers
+	registry.RegisterTelegramProvider("telegram", func(config map[string]interface{}) (TelegramProvider, error) {
+		provider, err := telegram.NewBotProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
+
+	// Register Viber providers
+	registry.RegisterViberProvider("nikita", func(config map[string]interface{}) (ViberProvider, error) {
+		provider, err := viber.NewNikitaProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
+
+	// Register WhatsApp providers
+	registry.RegisterWhatsAppProvider("cloud", func(config map[string]interface{}) (WhatsAppProvider, error) {
+		provider, err := whatsapp.NewCloudProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
+
+	// Register push providers
+	registry.RegisterPushProvider("fcm", func(config map[string]interface{}) (PushProvider, error) {
+		provider, err := push.NewFCMProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
 
 	return registry
 }
@@ -78,6 +115,24 @@ func (r *ProviderRegistry) RegisterPushProvider(name string, factory PushProvide
 	r.pushFactories[name] = factory
 }
 
+func (r *ProviderRegistry) RegisterTelegramProvider(name string, factory TelegramProviderFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.telegramFactories[name] = factory
+}
+
+func (r *ProviderRegistry) RegisterViberProvider(name string, factory ViberProviderFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.viberFactories[name] = factory
+}
+
+func (r *ProviderRegistry) RegisterWhatsAppProvider(name string, factory WhatsAppProviderFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.whatsappFactories[name] = factory
+}
+
 // Updated methods to accept config and type separately
 func (r *ProviderRegistry) CreateEmailProvider(config map[string]interface{}, providerType string) (EmailProvider, error) {
 	r.mu.RLock()
@@ -115,6 +170,42 @@ func (r *ProviderRegistry) CreatePushProvider(config map[string]interface{}, pro
 	return factory(config)
 }
 
+func (r *ProviderRegistry) CreateTelegramProvider(config map[string]interface{}, providerType string) (TelegramProvider, error) {
+	r.mu.RLock()
+	factory, exists := r.telegramFactories[providerType]
+	r.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("telegram provider %s not registered", providerType)
+	}
+
+	return factory(config)
+}
+
+func (r *ProviderRegistry) CreateViberProvider(config map[string]interface{}, providerType string) (ViberProvider, error) {
+	r.mu.RLock()
+	factory, exists := r.viberFactories[providerType]
+	r.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("viber provider %s not registered", providerType)
+	}
+
+	return factory(config)
+}
+
+func (r *ProviderRegistry) CreateWhatsAppProvider(config map[string]interface{}, providerType string) (WhatsAppProvider, error) {
+	r.mu.RLock()
+	factory, exists := r.whatsappFactories[providerType]
+	r.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("whatsapp provider %s not registered", providerType)
+	}
+
+	return factory(config)
+}
+
 // Legacy methods for backward compatibility (if needed elsewhere)
 // These can be removed if not used anywhere else
 func (r *ProviderRegistry) CreateEmailProviderLegacy(providerConfig interface{}) (EmailProvider, error) {
@@ -164,3 +255,39 @@ func (r *ProviderRegistry) GetRegisteredPushProviders() []string {
 	}
 	return providers
 }
+
+// GetRegisteredTelegramProviders returns a list of registered Telegram provider types
+func (r *ProviderRegistry) GetRegisteredTelegramProviders() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	providers := make([]string, 0, len(r.telegramFactories))
+	for name := range r.telegramFactories {
+		providers = append(providers, name)
+	}
+	return providers
+}
+
+// GetRegisteredViberProviders returns a list of registered Viber provider types
+func (r *ProviderRegistry) GetRegisteredViberProviders() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	providers := make([]string, 0, len(r.viberFactories))
+	for name := range r.viberFactories {
+		providers = append(providers, name)
+	}
+	return providers
+}
+
+// GetRegisteredWhatsAppProviders returns a list of registered WhatsApp provider types
+func (r *ProviderRegistry) GetRegisteredWhatsAppProviders() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	providers := make([]string, 0, len(r.whatsappFactories))
+	for name := range r.whatsappFactories {
+		providers = append(providers, name)
+	}
+	return providers
+}
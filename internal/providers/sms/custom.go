@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -32,6 +33,10 @@ type CustomConfig struct {
 	UsernameTrans   string `json:"username_trans"`
 	OriginatorTrans string `json:"originator_trans"`
 
+	// Delivery reports: DLRToken authenticates pushed reports, DLRPollURL enables polling for them (optional)
+	DLRToken   string `json:"dlr_token"`
+	DLRPollURL string `json:"dlr_poll_url"`
+
 	// Message type specific originators (optional)
 	MSGBonusOriginator   string `json:"MSGBonusOriginator"`
 	MSGPromoOriginator   string `json:"MSGPromoOriginator"`
@@ -67,6 +72,25 @@ type SMSResponse struct {
 	ErrorDescription string `json:"error-description,omitempty"`
 }
 
+// NikitaAPIError is returned when the Nikita API doesn't accept a request
+type NikitaAPIError struct {
+	StatusCode  int
+	Description string
+	Response    string
+}
+
+func (e *NikitaAPIError) Error() string {
+	if e.StatusCode == http.StatusOK {
+		return fmt.Sprintf("SMS API error: unexpected response: %s", e.Response)
+	}
+
+	errorMsg := fmt.Sprintf("SMS API error %d", e.StatusCode)
+	if e.Description != "" {
+		errorMsg = e.Description
+	}
+	return fmt.Sprintf("Nikita SMS API error: %s, Response: %s", errorMsg, e.Response)
+}
+
 // NewCustomProvider creates a new Custom SMS provider
 func NewCustomProvider(config map[string]interface{}) (*CustomProvider, error) {
 	configBytes, err := json.Marshal(config)
@@ -94,7 +118,7 @@ func NewCustomProvider(config map[string]interface{}) (*CustomProvider, error) {
 }
 
 // Send sends a single SMS via Nikita API
-func (c *CustomProvider) Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) error {
+func (c *CustomProvider) Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) (*models.SendResult, error) {
 	log := logger.WithRequest(notification.RequestID)
 
 	// Determine which endpoint and credentials to use based on message type
@@ -103,11 +127,16 @@ func (c *CustomProvider) Send(ctx context.Context, notification *ent.Notificatio
 	// Clean phone number (remove + if present)
 	phoneNumber := strings.TrimPrefix(string(notification.Address), "+")
 
-	// Generate message ID
-	messageID := fmt.Sprintf("%s:%d:%d", phoneNumber, time.Now().Unix(), time.Now().Nanosecond()/1000000)
+	// Delivery reports are matched to attempts by the message ID, the notification ID keeps
+	// messages to the same number apart
+	messageID := fmt.Sprintf("%s:%d:%d", phoneNumber, notification.ID, time.Now().Unix())
 
 	// Send SMS
+	requestSummary := fmt.Sprintf("POST %s/broker-api/send recipient=%s originator=%s message-id=%s", apiURL, phoneNumber, originator, messageID)
+	start := time.Now()
 	response, err := c.sendSMS(ctx, apiURL, username, password, originator, phoneNumber, notification.Body, messageID)
+	latency := time.Since(start)
+
 	if err != nil {
 		log.Error("Failed to send Nikita SMS", err, map[string]interface{}{
 			"notification_id": notification.ID,
@@ -116,7 +145,12 @@ func (c *CustomProvider) Send(ctx context.Context, notification *ent.Notificatio
 			"api_url":         apiURL,
 			"message_id":      messageID,
 		})
-		return fmt.Errorf("failed to send Nikita SMS: %w", err)
+		sendErr := fmt.Errorf("failed to send Nikita SMS: %w", err)
+		result := models.NewFailedResult(notification.ID, classifyNikitaError(err), sendErr)
+		result.RequestSummary = requestSummary
+		result.ResponseSummary = err.Error()
+		result.Latency = latency
+		return result, sendErr
 	}
 
 	log.Info("Nikita SMS sent successfully", map[string]interface{}{
@@ -127,11 +161,15 @@ func (c *CustomProvider) Send(ctx context.Context, notification *ent.Notificatio
 		"response":        response,
 	})
 
-	return nil
+	result := models.NewSentResult(notification.ID, messageID)
+	result.RequestSummary = requestSummary
+	result.ResponseSummary = fmt.Sprint(response)
+	result.Latency = latency
+	return result, nil
 }
 
 // SendBatch sends multiple SMS messages
-func (c *CustomProvider) SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) error {
+func (c *CustomProvider) SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) ([]*models.SendResult, error) {
 	log := logger.To("nikita_sms_batch")
 
 	// Group notifications by message type to use appropriate endpoint
@@ -149,7 +187,8 @@ func (c *CustomProvider) SendBatch(ctx context.Context, notifications []*ent.Not
 		typeGroups[msgType] = append(typeGroups[msgType], notif)
 	}
 
-	var errors []error
+	resultByID := make(map[int]*models.SendResult, len(notifications))
+	errorCount := 0
 	successCount := 0
 
 	// Process each group with appropriate endpoint
@@ -159,9 +198,10 @@ func (c *CustomProvider) SendBatch(ctx context.Context, notifications []*ent.Not
 
 		// Build batch request
 		var messages []SMSMessage
+		messageIDs := make([]string, 0, len(groupNotifications))
 		for _, notif := range groupNotifications {
 			phoneNumber := strings.TrimPrefix(string(notif.Address), "+")
-			messageID := fmt.Sprintf("%s:%d:%d", phoneNumber, time.Now().Unix(), time.Now().Nanosecond()/1000000)
+			messageID := fmt.Sprintf("%s:%d:%d", phoneNumber, notif.ID, time.Now().Unix())
 
 			messages = append(messages, SMSMessage{
 				Recipient: phoneNumber,
@@ -172,12 +212,32 @@ func (c *CustomProvider) SendBatch(ctx context.Context, notifications []*ent.Not
 				},
 				MessageID: messageID,
 			})
+			messageIDs = append(messageIDs, messageID)
+		}
+
+		// Send batch, the API accepts or rejects the request as a whole
+		start := time.Now()
+		response, err := c.sendBatchSMS(ctx, apiURL, username, password, messages)
+		latency := time.Since(start)
+
+		for i, notif := range groupNotifications {
+			var result *models.SendResult
+			if err != nil {
+				sendErr := fmt.Errorf("failed to send Nikita SMS: %w", err)
+				result = models.NewFailedResult(notif.ID, classifyNikitaError(err), sendErr)
+				result.ResponseSummary = err.Error()
+			} else {
+				result = models.NewSentResult(notif.ID, messageIDs[i])
+				result.ResponseSummary = fmt.Sprint(response)
+			}
+			result.RequestSummary = fmt.Sprintf("POST %s/broker-api/send recipient=%s originator=%s message-id=%s batch=%d",
+				apiURL, messages[i].Recipient, originator, messageIDs[i], len(messages))
+			result.Latency = latency
+			resultByID[notif.ID] = result
 		}
 
-		// Send batch
-		_, err := c.sendBatchSMS(ctx, apiURL, username, password, messages)
 		if err != nil {
-			errors = append(errors, err)
+			errorCount += len(groupNotifications)
 			log.Error("Failed to send SMS batch", err, map[string]interface{}{
 				"message_type": msgType,
 				"batch_size":   len(groupNotifications),
@@ -194,14 +254,16 @@ func (c *CustomProvider) SendBatch(ctx context.Context, notifications []*ent.Not
 	log.Info("Nikita SMS batch processing completed", map[string]interface{}{
 		"total_notifications": len(notifications),
 		"success_count":       successCount,
-		"error_count":         len(errors),
+		"error_count":         errorCount,
 	})
 
-	if len(errors) > 0 {
-		return fmt.Errorf("batch sending completed with %d errors out of %d SMS messages", len(errors), len(notifications))
+	// Keep results in the order of the notifications
+	results := make([]*models.SendResult, 0, len(notifications))
+	for _, notif := range notifications {
+		results = append(results, resultByID[notif.ID])
 	}
 
-	return nil
+	return results, nil
 }
 
 // ValidateConfig validates the Custom SMS configuration
@@ -232,10 +294,15 @@ func (c *CustomProvider) GetType() string {
 	return "custom"
 }
 
+// ReportsDelivery tells whether Nikita delivery reports are pushed or polled for
+func (c *CustomProvider) ReportsDelivery() bool {
+	return c.config.DLRToken != "" || c.config.DLRPollURL != ""
+}
+
 // getEndpointConfig returns the appropriate endpoint and credentials based on message type
 func (c *CustomProvider) getEndpointConfig(messageType models.MessageType) (string, string, string, string) {
 	// Use MRK endpoint for promotional messages, Trans for everything else
-	if messageType == models.MessageTypePromo || messageType == models.MessageTypeBonus {
+	if messageType.IsPromotional() {
 		originator := c.getOriginator(messageType, c.config.OriginatorMRK)
 		return c.config.URLMRK, c.config.UsernameMRK, c.config.PasswordMRK, originator
 	}
@@ -340,18 +407,37 @@ func (c *CustomProvider) sendBatchSMS(ctx context.Context, apiURL, username, pas
 		var errorResp SMSResponse
 		json.Unmarshal(body, &errorResp)
 
-		errorMsg := fmt.Sprintf("SMS API error %d", resp.StatusCode)
-		if errorResp.ErrorDescription != "" {
-			errorMsg = errorResp.ErrorDescription
+		return responseStr, &NikitaAPIError{
+			StatusCode:  resp.StatusCode,
+			Description: errorResp.ErrorDescription,
+			Response:    responseStr,
 		}
-
-		return responseStr, fmt.Errorf("Nikita SMS API error: %s, Response: %s", errorMsg, responseStr)
 	}
 
 	// Check if response indicates success (your curl returned "OK")
 	if !strings.Contains(responseStr, "OK") {
-		return responseStr, fmt.Errorf("SMS API error: unexpected response: %s", responseStr)
+		return responseStr, &NikitaAPIError{
+			StatusCode: resp.StatusCode,
+			Response:   responseStr,
+		}
 	}
 
 	return responseStr, nil
 }
+
+// classifyNikitaError maps Nikita API failures to error classes, transport problems are temporary
+func classifyNikitaError(err error) models.ErrorClass {
+	var apiErr *NikitaAPIError
+	if !errors.As(err, &apiErr) {
+		return models.ErrorClassTemporary
+	}
+
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+		return models.ErrorClassAuthentication
+	case apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500:
+		return models.ErrorClassTemporary
+	default:
+		return models.ErrorClassRejected
+	}
+}
@@ -0,0 +1,57 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/types"
+)
+
+func TestCustomProviderMessageIDs(t *testing.T) {
+	var sent []SMSMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req SMSRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		sent = append(sent, req.Messages...)
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+
+	provider := &CustomProvider{
+		config: CustomConfig{URLTrans: server.URL, UsernameTrans: "transactional", OriginatorTrans: "Casino"},
+		client: server.Client(),
+	}
+
+	// Two messages to the same number sent in the same second
+	notifications := []*ent.Notification{
+		{ID: 41, Address: types.Address("+37499123456"), Body: "Deposit received"},
+		{ID: 42, Address: types.Address("+37499123456"), Body: "Withdrawal sent"},
+	}
+	results, err := provider.SendBatch(context.Background(), notifications, models.MessageTypePayment)
+	if err != nil {
+		t.Fatalf("SendBatch() error = %v", err)
+	}
+	result, err := provider.Send(context.Background(), &ent.Notification{ID: 43, Address: types.Address("+37499123456"), Body: "Bonus"}, models.MessageTypePayment)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	results = append(results, result)
+
+	if len(sent) != 3 || len(results) != 3 {
+		t.Fatalf("sent %d messages with %d results, want 3", len(sent), len(results))
+	}
+	for i, id := range []string{"37499123456:41:", "37499123456:42:", "37499123456:43:"} {
+		if !strings.HasPrefix(sent[i].MessageID, id) {
+			t.Errorf("message ID = %q, want the number and notification ID %q", sent[i].MessageID, id)
+		}
+		if results[i].ProviderMessageID != sent[i].MessageID {
+			t.Errorf("result message ID = %q, want %q", results[i].ProviderMessageID, sent[i].MessageID)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// NikitaDeliveryReport is a delivery report (DLR) of a message sent through the Nikita API.
+// Reports are pushed to the DLR webhook or fetched from the poll URL.
+type NikitaDeliveryReport struct {
+	MessageID string `json:"message-id" form:"message-id" query:"message-id"`
+	Status    string `json:"status" form:"status" query:"status"`
+	ErrorCode string `json:"error-code" form:"error-code" query:"error-code"`
+	DoneDate  string `json:"done-date,omitempty" form:"done-date" query:"done-date"`
+}
+
+// nikitaPollRequest asks the Nikita API for the reports of the given message IDs
+type nikitaPollRequest struct {
+	MessageIDs []string `json:"message-ids"`
+}
+
+// nikitaPollResponse holds the reports returned by the Nikita API
+type nikitaPollResponse struct {
+	Reports []NikitaDeliveryReport `json:"reports"`
+}
+
+// NikitaDeliveryStatus maps a Nikita (SMPP style) delivery status to the notification status it leads to.
+// Intermediate statuses (ACCEPTD, ENROUTE, SENT, ...) don't change the notification.
+func NikitaDeliveryStatus(status string) (models.NotificationStatus, bool) {
+	switch strings.ToUpper(strings.TrimSpace(status)) {
+	case "DELIVRD", "DELIVERED":
+		return models.StatusDelivered, true
+	case "UNDELIV", "UNDELIVERED", "EXPIRED", "REJECTD", "REJECTED", "DELETED", "FAILED":
+		return models.StatusUndelivered, true
+	default:
+		return "", false
+	}
+}
+
+// OperatorErrorCode returns the operator error code of the report, empty when it reports no error
+func (r *NikitaDeliveryReport) OperatorErrorCode() string {
+	code := strings.TrimSpace(r.ErrorCode)
+	if strings.Trim(code, "0") == "" {
+		return ""
+	}
+	return code
+}
+
+// VerifyDLRToken checks the token a pushed delivery report was sent with
+func (c *CustomProvider) VerifyDLRToken(token string) bool {
+	return c.config.DLRToken != "" && subtle.ConstantTimeCompare([]byte(c.config.DLRToken), []byte(token)) == 1
+}
+
+// PollsDeliveryReports reports whether delivery reports can be fetched from the Nikita API
+func (c *CustomProvider) PollsDeliveryReports() bool {
+	return c.config.DLRPollURL != ""
+}
+
+// FetchDeliveryReports fetches the delivery reports of messages sent with the given message type.
+// Promotional and transactional messages are sent from different accounts, so their reports are
+// fetched with the credentials they were sent with.
+func (c *CustomProvider) FetchDeliveryReports(ctx context.Context, messageType models.MessageType, messageIDs []string) ([]NikitaDeliveryReport, error) {
+	if !c.PollsDeliveryReports() {
+		return nil, fmt.Errorf("Nikita DLR poll URL is not configured")
+	}
+
+	_, username, password, _ := c.getEndpointConfig(messageType)
+
+	jsonData, err := json.Marshal(nikitaPollRequest{MessageIDs: messageIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.config.DLRPollURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(username, password)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errorResp SMSResponse
+		json.Unmarshal(body, &errorResp)
+
+		return nil, &NikitaAPIError{
+			StatusCode:  resp.StatusCode,
+			Description: errorResp.ErrorDescription,
+			Response:    string(body),
+		}
+	}
+
+	var pollResp nikitaPollResponse
+	if err := json.Unmarshal(body, &pollResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return pollResp.Reports, nil
+}
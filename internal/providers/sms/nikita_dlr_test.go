@@ -0,0 +1,122 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func TestNikitaDeliveryStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   models.NotificationStatus
+		wantOK bool
+	}{
+		{status: "DELIVRD", want: models.StatusDelivered, wantOK: true},
+		{status: " delivered ", want: models.StatusDelivered, wantOK: true},
+		{status: "UNDELIV", want: models.StatusUndelivered, wantOK: true},
+		{status: "EXPIRED", want: models.StatusUndelivered, wantOK: true},
+		{status: "REJECTD", want: models.StatusUndelivered, wantOK: true},
+		{status: "ACCEPTD"},
+		{status: "ENROUTE"},
+		{status: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			got, ok := NikitaDeliveryStatus(tt.status)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("NikitaDeliveryStatus() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNikitaOperatorErrorCode(t *testing.T) {
+	tests := []struct {
+		errorCode string
+		want      string
+	}{
+		{errorCode: "", want: ""},
+		{errorCode: "000", want: ""},
+		{errorCode: " 0 ", want: ""},
+		{errorCode: "034", want: "034"},
+		{errorCode: "100", want: "100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.errorCode, func(t *testing.T) {
+			report := &NikitaDeliveryReport{ErrorCode: tt.errorCode}
+			if got := report.OperatorErrorCode(); got != tt.want {
+				t.Errorf("OperatorErrorCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomProviderVerifyDLRToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		token      string
+		want       bool
+	}{
+		{name: "valid", configured: "dlr-secret", token: "dlr-secret", want: true},
+		{name: "wrong token", configured: "dlr-secret", token: "dlr-secreT"},
+		{name: "missing token", configured: "dlr-secret"},
+		{name: "not configured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &CustomProvider{config: CustomConfig{DLRToken: tt.configured}}
+			if got := provider.VerifyDLRToken(tt.token); got != tt.want {
+				t.Errorf("VerifyDLRToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchDeliveryReports(t *testing.T) {
+	var gotUser string
+	var gotIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _, _ = r.BasicAuth()
+		var req nikitaPollRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		gotIDs = req.MessageIDs
+		json.NewEncoder(w).Encode(nikitaPollResponse{Reports: []NikitaDeliveryReport{
+			{MessageID: req.MessageIDs[0], Status: "DELIVRD", ErrorCode: "000"},
+		}})
+	}))
+	defer server.Close()
+
+	provider := &CustomProvider{
+		config: CustomConfig{
+			DLRPollURL:    server.URL,
+			UsernameMRK:   "marketing",
+			UsernameTrans: "transactional",
+		},
+		client: server.Client(),
+	}
+
+	reports, err := provider.FetchDeliveryReports(context.Background(), models.MessageTypePromo, []string{"msg-1"})
+	if err != nil {
+		t.Fatalf("FetchDeliveryReports() error = %v", err)
+	}
+	if len(reports) != 1 || reports[0].MessageID != "msg-1" || reports[0].Status != "DELIVRD" {
+		t.Errorf("reports = %+v", reports)
+	}
+	// Promotional reports are fetched with the marketing account they were sent from
+	if gotUser != "marketing" || len(gotIDs) != 1 || gotIDs[0] != "msg-1" {
+		t.Errorf("request used account %q for message IDs %v", gotUser, gotIDs)
+	}
+
+	if _, err := (&CustomProvider{}).FetchDeliveryReports(context.Background(), models.MessageTypePromo, []string{"msg-1"}); err == nil {
+		t.Error("FetchDeliveryReports() without a poll URL must fail")
+	}
+}
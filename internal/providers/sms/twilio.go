@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -33,6 +34,9 @@ type TwilioConfig struct {
 	FromNumber string `json:"from_number"`
 	BaseURL    string `json:"base_url"`
 
+	// StatusCallbackURL receives delivery receipts, filled in from the webhook config when empty
+	StatusCallbackURL string `json:"status_callback_url"`
+
 	// Message type specific from numbers (optional)
 	MSGBonusFrom   string `json:"MSGBonusFrom"`
 	MSGPromoFrom   string `json:"MSGPromoFrom"`
@@ -76,6 +80,21 @@ type TwilioErrorResponse struct {
 	Status   int    `json:"status"`
 }
 
+// TwilioAPIError is returned when the Twilio API rejects a request
+type TwilioAPIError struct {
+	StatusCode int
+	Code       int
+	Message    string
+	Body       string
+}
+
+func (e *TwilioAPIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("Twilio API error: %s (code: %d)", e.Message, e.Code)
+	}
+	return fmt.Sprintf("Twilio API error: status %d, body: %s", e.StatusCode, e.Body)
+}
+
 // NewTwilioProvider creates a new Twilio SMS provider
 func NewTwilioProvider(config map[string]interface{}) (*TwilioProvider, error) {
 	configBytes, err := json.Marshal(config)
@@ -112,7 +131,7 @@ func NewTwilioProvider(config map[string]interface{}) (*TwilioProvider, error) {
 }
 
 // Send sends a single SMS via Twilio API
-func (t *TwilioProvider) Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) error {
+func (t *TwilioProvider) Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) (*models.SendResult, error) {
 	log := logger.WithRequest(notification.RequestID)
 
 	// Get appropriate from number based on message type
@@ -124,16 +143,28 @@ func (t *TwilioProvider) Send(ctx context.Context, notification *ent.Notificatio
 		"From": {fromNumber},
 		"Body": {notification.Body},
 	}
+	if t.config.StatusCallbackURL != "" {
+		data.Set("StatusCallback", t.config.StatusCallbackURL)
+	}
 
 	// Send SMS
+	requestSummary := fmt.Sprintf("POST Messages.json To=%s From=%s length=%d", notification.Address, fromNumber, len(notification.Body))
+	start := time.Now()
 	response, err := t.sendSMS(ctx, data)
+	latency := time.Since(start)
+
 	if err != nil {
 		log.Error("Failed to send Twilio SMS", err, map[string]interface{}{
 			"notification_id": notification.ID,
 			"to":              string(notification.Address),
 			"from":            fromNumber,
 		})
-		return fmt.Errorf("failed to send Twilio SMS: %w", err)
+		sendErr := fmt.Errorf("failed to send Twilio SMS: %w", err)
+		result := models.NewFailedResult(notification.ID, classifyTwilioError(err), sendErr)
+		result.RequestSummary = requestSummary
+		result.ResponseSummary = err.Error()
+		result.Latency = latency
+		return result, sendErr
 	}
 
 	log.Info("Twilio SMS sent successfully", map[string]interface{}{
@@ -144,41 +175,41 @@ func (t *TwilioProvider) Send(ctx context.Context, notification *ent.Notificatio
 		"status":          response.Status,
 	})
 
-	return nil
+	result := models.NewSentResult(notification.ID, response.SID)
+	result.RequestSummary = requestSummary
+	result.ResponseSummary = fmt.Sprintf("status=%s sid=%s", response.Status, response.SID)
+	result.Latency = latency
+	return result, nil
 }
 
 // SendBatch sends multiple SMS messages via Twilio API
-func (t *TwilioProvider) SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) error {
+func (t *TwilioProvider) SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) ([]*models.SendResult, error) {
 	log := logger.To("twilio_batch")
 
 	// Twilio doesn't have a native batch SMS API, so we send individually
 	// We could optimize this with goroutines, but keeping it simple for now
-	var errors []error
-	successCount := 0
+	results := make([]*models.SendResult, 0, len(notifications))
+	errorCount := 0
 
 	for _, notification := range notifications {
-		if err := t.Send(ctx, notification, messageType); err != nil {
-			errors = append(errors, err)
+		result, err := t.Send(ctx, notification, messageType)
+		if err != nil {
+			errorCount++
 			log.Error("Failed to send SMS in batch", err, map[string]interface{}{
 				"notification_id": notification.ID,
 				"batch_size":      len(notifications),
 			})
-		} else {
-			successCount++
 		}
+		results = append(results, result)
 	}
 
 	log.Info("Twilio batch processing completed", map[string]interface{}{
 		"batch_size":    len(notifications),
-		"success_count": successCount,
-		"error_count":   len(errors),
+		"success_count": len(notifications) - errorCount,
+		"error_count":   errorCount,
 	})
 
-	if len(errors) > 0 {
-		return fmt.Errorf("batch sending completed with %d errors out of %d SMS messages", len(errors), len(notifications))
-	}
-
-	return nil
+	return results, nil
 }
 
 // ValidateConfig validates the Twilio configuration
@@ -206,6 +237,11 @@ func (t *TwilioProvider) GetType() string {
 	return "twilio"
 }
 
+// ReportsDelivery tells whether messages are sent with a status callback
+func (t *TwilioProvider) ReportsDelivery() bool {
+	return t.config.StatusCallbackURL != ""
+}
+
 // sendSMS sends the SMS via Twilio API
 func (t *TwilioProvider) sendSMS(ctx context.Context, data url.Values) (*TwilioResponse, error) {
 	// Create request URL
@@ -239,11 +275,13 @@ func (t *TwilioProvider) sendSMS(ctx context.Context, data url.Values) (*TwilioR
 
 	// Check for errors
 	if resp.StatusCode >= 400 {
+		apiErr := &TwilioAPIError{StatusCode: resp.StatusCode, Body: string(body)}
 		var errorResp TwilioErrorResponse
 		if err := json.Unmarshal(body, &errorResp); err == nil {
-			return nil, fmt.Errorf("Twilio API error: %s (code: %d)", errorResp.Message, errorResp.Code)
+			apiErr.Code = errorResp.Code
+			apiErr.Message = errorResp.Message
 		}
-		return nil, fmt.Errorf("Twilio API error: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, apiErr
 	}
 
 	// Parse successful response
@@ -287,3 +325,24 @@ func (t *TwilioProvider) getFromNumber(messageType models.MessageType) string {
 	// Default to main from number
 	return t.fromNumber
 }
+
+// classifyTwilioError maps Twilio error codes to error classes, see https://www.twilio.com/docs/api/errors
+func classifyTwilioError(err error) models.ErrorClass {
+	var apiErr *TwilioAPIError
+	if !errors.As(err, &apiErr) {
+		return models.ErrorClassTemporary
+	}
+
+	switch {
+	case apiErr.Code == 21211 || apiErr.Code == 21614 || apiErr.Code == 21612 || apiErr.Code == 21408:
+		return models.ErrorClassInvalidRecipient
+	case apiErr.Code == 20003 || apiErr.StatusCode == http.StatusUnauthorized:
+		return models.ErrorClassAuthentication
+	case apiErr.Code == 21617 || apiErr.Code == 21602:
+		return models.ErrorClassInvalidContent
+	case apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500:
+		return models.ErrorClassTemporary
+	default:
+		return models.ErrorClassRejected
+	}
+}
@@ -0,0 +1,55 @@
+package sms
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// ValidateTwilioSignature checks the X-Twilio-Signature of a webhook request. Twilio signs the
+// full callback URL followed by every POST parameter name and value, sorted by name, with
+// HMAC-SHA1 keyed by the account auth token.
+func ValidateTwilioSignature(authToken, callbackURL string, params url.Values, signature string) bool {
+	if authToken == "" || signature == "" {
+		return false
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var payload strings.Builder
+	payload.WriteString(callbackURL)
+	for _, key := range keys {
+		for _, value := range params[key] {
+			payload.WriteString(key)
+			payload.WriteString(value)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(payload.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// TwilioDeliveryStatus maps a Twilio MessageStatus to the notification status it leads to.
+// Intermediate statuses (queued, sending, sent) don't change the notification.
+func TwilioDeliveryStatus(messageStatus string) (models.NotificationStatus, bool) {
+	switch messageStatus {
+	case "delivered", "read":
+		return models.StatusDelivered, true
+	case "undelivered", "failed":
+		return models.StatusUndelivered, true
+	default:
+		return "", false
+	}
+}
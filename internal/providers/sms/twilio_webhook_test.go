@@ -0,0 +1,78 @@
+package sms
+
+import (
+	"net/url"
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func TestValidateTwilioSignature(t *testing.T) {
+	// Example request from the Twilio webhook security documentation
+	const (
+		authToken   = "12345"
+		callbackURL = "https://mycompany.com/myapp.php?foo=1&bar=2"
+		signature   = "0/KCTR6DLpKmkAf8muzZqo1nDgQ="
+	)
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	modified := url.Values{}
+	for key, values := range params {
+		modified[key] = values
+	}
+	modified.Set("Digits", "4321")
+
+	tests := []struct {
+		name        string
+		authToken   string
+		callbackURL string
+		params      url.Values
+		signature   string
+		want        bool
+	}{
+		{name: "valid", authToken: authToken, callbackURL: callbackURL, params: params, signature: signature, want: true},
+		{name: "modified parameter", authToken: authToken, callbackURL: callbackURL, params: modified, signature: signature},
+		{name: "other URL", authToken: authToken, callbackURL: "https://mycompany.com/myapp.php", params: params, signature: signature},
+		{name: "other auth token", authToken: "54321", callbackURL: callbackURL, params: params, signature: signature},
+		{name: "missing auth token", callbackURL: callbackURL, params: params, signature: signature},
+		{name: "missing signature", authToken: authToken, callbackURL: callbackURL, params: params},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateTwilioSignature(tt.authToken, tt.callbackURL, tt.params, tt.signature); got != tt.want {
+				t.Errorf("ValidateTwilioSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTwilioDeliveryStatus(t *testing.T) {
+	tests := []struct {
+		messageStatus string
+		want          models.NotificationStatus
+		wantOK        bool
+	}{
+		{messageStatus: "delivered", want: models.StatusDelivered, wantOK: true},
+		{messageStatus: "read", want: models.StatusDelivered, wantOK: true},
+		{messageStatus: "undelivered", want: models.StatusUndelivered, wantOK: true},
+		{messageStatus: "failed", want: models.StatusUndelivered, wantOK: true},
+		{messageStatus: "queued"},
+		{messageStatus: "sending"},
+		{messageStatus: "sent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.messageStatus, func(t *testing.T) {
+			got, ok := TwilioDeliveryStatus(tt.messageStatus)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("TwilioDeliveryStatus() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,505 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+const (
+	// maxMessageLength is the longest text sendMessage accepts, in characters after entity parsing
+	maxMessageLength = 4096
+	// maxCallbackDataLength is the longest callback_data of an inline keyboard button, in bytes
+	maxCallbackDataLength = 64
+
+	parseModeHTML       = "HTML"
+	parseModeMarkdownV2 = "MarkdownV2"
+	parseModeMarkdown   = "Markdown"
+	parseModePlain      = "plain"
+)
+
+// BotProvider sends Telegram messages through the Bot API of the tenant's bot
+type BotProvider struct {
+	botToken string
+	baseURL  string
+	client   *http.Client
+	config   BotConfig
+}
+
+// BotConfig represents Telegram bot configuration
+type BotConfig struct {
+	BotToken string `json:"bot_token"`
+	// APIBaseURL points at the Bot API, e.g. a local Bot API server or a mock while testing
+	APIBaseURL string `json:"api_base_url"`
+	// ParseMode formats the messages, HTML, MarkdownV2, Markdown or plain. Defaults to HTML.
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+	// MaxRetries is how often a message is retried after Telegram throttled it
+	MaxRetries int `json:"max_retries"`
+	// MaxRetryAfterSeconds is the longest throttling wait honored, longer ones fail the message
+	MaxRetryAfterSeconds int `json:"max_retry_after_seconds"`
+}
+
+// InlineKeyboardButton opens a URL or sends callback data to the bot when pressed
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	URL          string `json:"url,omitempty"`
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+// InlineKeyboardMarkup lays buttons out in rows below the message
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+// SendMessageRequest represents the request payload of sendMessage
+type SendMessageRequest struct {
+	ChatID                string                `json:"chat_id"`
+	Text                  string                `json:"text"`
+	ParseMode             string                `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool                  `json:"disable_web_page_preview,omitempty"`
+	ReplyMarkup           *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// Response represents the envelope of every Bot API response
+type Response struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	ErrorCode   int             `json:"error_code,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Parameters  *struct {
+		RetryAfter      int   `json:"retry_after,omitempty"`
+		MigrateToChatID int64 `json:"migrate_to_chat_id,omitempty"`
+	} `json:"parameters,omitempty"`
+}
+
+// Message represents the sent message returned by sendMessage
+type Message struct {
+	MessageID int64 `json:"message_id"`
+}
+
+// APIError is returned when the Bot API rejects a request
+type APIError struct {
+	StatusCode  int
+	Description string
+	// RetryAfter is how many seconds to wait before retrying a throttled request
+	RetryAfter int
+}
+
+func (e *APIError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("Telegram API error: %s (code: %d, retry after %ds)", e.Description, e.StatusCode, e.RetryAfter)
+	}
+	return fmt.Sprintf("Telegram API error: %s (code: %d)", e.Description, e.StatusCode)
+}
+
+// contentError is returned for messages that can't be sent as they are
+type contentError struct {
+	message string
+}
+
+func (e *contentError) Error() string {
+	return e.message
+}
+
+// NewBotProvider creates a new Telegram bot provider
+func NewBotProvider(config map[string]interface{}) (*BotProvider, error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Telegram config: %w", err)
+	}
+
+	var botConfig BotConfig
+	if err := json.Unmarshal(configBytes, &botConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Telegram config: %w", err)
+	}
+
+	if botConfig.APIBaseURL == "" {
+		botConfig.APIBaseURL = "https://api.telegram.org"
+	}
+	if botConfig.ParseMode == "" {
+		botConfig.ParseMode = parseModeHTML
+	}
+	if botConfig.MaxRetries == 0 {
+		botConfig.MaxRetries = 3
+	}
+	if botConfig.MaxRetryAfterSeconds == 0 {
+		botConfig.MaxRetryAfterSeconds = 30
+	}
+
+	provider := &BotProvider{
+		botToken: botConfig.BotToken,
+		baseURL:  strings.TrimSuffix(botConfig.APIBaseURL, "/"),
+		config:   botConfig,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+
+	if err := provider.ValidateConfig(); err != nil {
+		return nil, fmt.Errorf("invalid Telegram config: %w", err)
+	}
+
+	return provider, nil
+}
+
+// Send sends a single message to the chat the notification is addressed to
+func (t *BotProvider) Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType) (*models.SendResult, error) {
+	log := logger.WithRequest(notification.RequestID)
+	chatID := string(notification.Address)
+
+	request, err := t.buildRequest(notification)
+	if err != nil {
+		log.Error("Invalid Telegram message", err, map[string]interface{}{
+			"notification_id": notification.ID,
+			"chat_id":         chatID,
+		})
+		sendErr := fmt.Errorf("invalid Telegram message: %w", err)
+		result := models.NewFailedResult(notification.ID, models.ErrorClassInvalidContent, sendErr)
+		result.ResponseSummary = err.Error()
+		return result, sendErr
+	}
+
+	requestSummary := fmt.Sprintf("POST sendMessage chat_id=%s parse_mode=%s length=%d", chatID, request.ParseMode, utf8.RuneCountInString(request.Text))
+	start := time.Now()
+	message, err := t.sendWithRetry(ctx, request)
+	latency := time.Since(start)
+
+	if err != nil {
+		log.Error("Failed to send Telegram message", err, map[string]interface{}{
+			"notification_id": notification.ID,
+			"chat_id":         chatID,
+		})
+		sendErr := fmt.Errorf("failed to send Telegram message: %w", err)
+		result := models.NewFailedResult(notification.ID, classifyError(err), sendErr)
+		result.RequestSummary = requestSummary
+		result.ResponseSummary = err.Error()
+		result.Latency = latency
+		return result, sendErr
+	}
+
+	log.Info("Telegram message sent successfully", map[string]interface{}{
+		"notification_id": notification.ID,
+		"chat_id":         chatID,
+		"message_id":      message.MessageID,
+	})
+
+	// Message IDs are only unique within their chat
+	messageID := fmt.Sprintf("%s:%d", chatID, message.MessageID)
+	result := models.NewSentResult(notification.ID, messageID)
+	result.RequestSummary = requestSummary
+	result.ResponseSummary = fmt.Sprintf("ok message_id=%d", message.MessageID)
+	result.Latency = latency
+	return result, nil
+}
+
+// SendBatch sends multiple messages, one sendMessage call each
+func (t *BotProvider) SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) ([]*models.SendResult, error) {
+	log := logger.To("telegram_batch")
+
+	// The Bot API has no batch endpoint, throttling is handled per message
+	results := make([]*models.SendResult, 0, len(notifications))
+	errorCount := 0
+
+	for _, notification := range notifications {
+		result, err := t.Send(ctx, notification, messageType)
+		if err != nil {
+			errorCount++
+		}
+		results = append(results, result)
+	}
+
+	log.Info("Telegram batch processing completed", map[string]interface{}{
+		"batch_size":    len(notifications),
+		"success_count": len(notifications) - errorCount,
+		"error_count":   errorCount,
+	})
+
+	return results, nil
+}
+
+// ValidateConfig validates the Telegram configuration
+func (t *BotProvider) ValidateConfig() error {
+	if t.botToken == "" {
+		return fmt.Errorf("Telegram bot token is required")
+	}
+
+	switch t.config.ParseMode {
+	case parseModeHTML, parseModeMarkdownV2, parseModeMarkdown, parseModePlain:
+	default:
+		return fmt.Errorf("Telegram parse mode must be HTML, MarkdownV2, Markdown or plain")
+	}
+
+	if t.config.MaxRetries < 0 || t.config.MaxRetryAfterSeconds < 0 {
+		return fmt.Errorf("Telegram retry settings can't be negative")
+	}
+
+	return nil
+}
+
+// GetType returns the provider type
+func (t *BotProvider) GetType() string {
+	return "telegram"
+}
+
+// buildRequest turns a notification into a sendMessage request. The body is sent in the parse
+// mode as is, the headline is escaped and set in bold above it. The parse_mode and buttons
+// keys of the notification data override the parse mode and add an inline keyboard.
+func (t *BotProvider) buildRequest(notification *ent.Notification) (*SendMessageRequest, error) {
+	parseMode := t.config.ParseMode
+	if value, ok := notification.Data["parse_mode"].(string); ok && value != "" {
+		parseMode = value
+	}
+
+	var text string
+	switch parseMode {
+	case parseModeHTML:
+		text = joinHeadline("<b>"+html.EscapeString(notification.Headline)+"</b>", notification.Headline, notification.Body)
+	case parseModeMarkdownV2:
+		text = joinHeadline("*"+escapeMarkdownV2(notification.Headline)+"*", notification.Headline, notification.Body)
+	case parseModeMarkdown:
+		text = joinHeadline("*"+escapeMarkdown(notification.Headline)+"*", notification.Headline, notification.Body)
+	case parseModePlain:
+		text = joinHeadline(notification.Headline, notification.Headline, notification.Body)
+		parseMode = ""
+	default:
+		return nil, &contentError{message: fmt.Sprintf("unsupported parse mode %q", parseMode)}
+	}
+
+	if utf8.RuneCountInString(text) > maxMessageLength {
+		return nil, &contentError{message: fmt.Sprintf("message is longer than %d characters", maxMessageLength)}
+	}
+
+	keyboard, err := parseKeyboard(notification.Data["buttons"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &SendMessageRequest{
+		ChatID:                string(notification.Address),
+		Text:                  text,
+		ParseMode:             parseMode,
+		DisableWebPagePreview: t.config.DisableWebPagePreview,
+		ReplyMarkup:           keyboard,
+	}, nil
+}
+
+// sendWithRetry sends the message, waiting out throttling Telegram reports with retry_after
+func (t *BotProvider) sendWithRetry(ctx context.Context, request *SendMessageRequest) (*Message, error) {
+	for attempt := 0; ; attempt++ {
+		message, err := t.sendMessage(ctx, request)
+
+		var apiErr *APIError
+		if err == nil || !errors.As(err, &apiErr) || apiErr.RetryAfter == 0 ||
+			attempt >= t.config.MaxRetries || apiErr.RetryAfter > t.config.MaxRetryAfterSeconds {
+			return message, err
+		}
+
+		timer := time.NewTimer(time.Duration(apiErr.RetryAfter) * time.Second)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// sendMessage calls sendMessage of the Bot API
+func (t *BotProvider) sendMessage(ctx context.Context, request *SendMessageRequest) (*Message, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", t.baseURL, t.botToken)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		// The URL carries the bot token, keep it out of errors and logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var response Response
+	if err := json.Unmarshal(body, &response); err != nil {
+		if resp.StatusCode >= 400 {
+			return nil, &APIError{StatusCode: resp.StatusCode, Description: strings.TrimSpace(string(body))}
+		}
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !response.OK {
+		apiErr := &APIError{StatusCode: response.ErrorCode, Description: response.Description}
+		if apiErr.StatusCode == 0 {
+			apiErr.StatusCode = resp.StatusCode
+		}
+		if response.Parameters != nil {
+			apiErr.RetryAfter = response.Parameters.RetryAfter
+		}
+		return nil, apiErr
+	}
+
+	var message Message
+	if err := json.Unmarshal(response.Result, &message); err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	return &message, nil
+}
+
+// joinHeadline sets the formatted headline above the body, when there is one
+func joinHeadline(formatted, headline, body string) string {
+	if headline == "" {
+		return body
+	}
+	return formatted + "\n" + body
+}
+
+// parseKeyboard reads inline keyboard buttons from the buttons data of a notification, either a
+// list of buttons shown one per row or a list of rows
+func parseKeyboard(value interface{}) (*InlineKeyboardMarkup, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, &contentError{message: "buttons must be a list"}
+	}
+
+	keyboard := &InlineKeyboardMarkup{}
+	for _, item := range items {
+		if row, ok := item.([]interface{}); ok {
+			buttons := make([]InlineKeyboardButton, 0, len(row))
+			for _, entry := range row {
+				button, err := parseButton(entry)
+				if err != nil {
+					return nil, err
+				}
+				buttons = append(buttons, *button)
+			}
+			if len(buttons) > 0 {
+				keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, buttons)
+			}
+			continue
+		}
+
+		button, err := parseButton(item)
+		if err != nil {
+			return nil, err
+		}
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []InlineKeyboardButton{*button})
+	}
+
+	if len(keyboard.InlineKeyboard) == 0 {
+		return nil, nil
+	}
+	return keyboard, nil
+}
+
+func parseButton(value interface{}) (*InlineKeyboardButton, error) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, &contentError{message: "button must be an object with text and url or callback_data"}
+	}
+
+	text, _ := fields["text"].(string)
+	link, _ := fields["url"].(string)
+	callbackData, _ := fields["callback_data"].(string)
+
+	switch {
+	case strings.TrimSpace(text) == "":
+		return nil, &contentError{message: "button text is required"}
+	case (link == "") == (callbackData == ""):
+		return nil, &contentError{message: fmt.Sprintf("button %q needs either a url or callback_data", text)}
+	case len(callbackData) > maxCallbackDataLength:
+		return nil, &contentError{message: fmt.Sprintf("callback_data of button %q is longer than %d bytes", text, maxCallbackDataLength)}
+	}
+
+	return &InlineKeyboardButton{
+		Text:         text,
+		URL:          link,
+		CallbackData: callbackData,
+	}, nil
+}
+
+// escapeMarkdownV2 escapes the characters MarkdownV2 reserves
+func escapeMarkdownV2(text string) string {
+	var builder strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("_*[]()~`>#+-=|{}.!\\", r) {
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// escapeMarkdown escapes the characters legacy Markdown reserves
+func escapeMarkdown(text string) string {
+	var builder strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("_*`[", r) {
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// classifyError maps Bot API errors to error classes, see https://core.telegram.org/api/errors
+func classifyError(err error) models.ErrorClass {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return models.ErrorClassTemporary
+	}
+
+	description := strings.ToLower(apiErr.Description)
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusNotFound:
+		return models.ErrorClassAuthentication
+	case apiErr.StatusCode == http.StatusForbidden:
+		// The user blocked the bot, deleted their account or the bot was removed from the chat
+		return models.ErrorClassInvalidRecipient
+	case apiErr.StatusCode == http.StatusBadRequest && (strings.Contains(description, "chat not found") ||
+		strings.Contains(description, "user not found") || strings.Contains(description, "chat_id is empty") ||
+		strings.Contains(description, "group chat was upgraded")):
+		return models.ErrorClassInvalidRecipient
+	case apiErr.StatusCode == http.StatusBadRequest && (strings.Contains(description, "can't parse entities") ||
+		strings.Contains(description, "message is too long") || strings.Contains(description, "message text is empty") ||
+		strings.Contains(description, "button")):
+		return models.ErrorClassInvalidContent
+	case apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500:
+		return models.ErrorClassTemporary
+	default:
+		return models.ErrorClassRejected
+	}
+}
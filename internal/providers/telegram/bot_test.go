@@ -0,0 +1,234 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/types"
+)
+
+// newTestBot returns a provider sending to a mock Bot API answering with the responses in order
+func newTestBot(t *testing.T, config map[string]interface{}, responses ...string) (*BotProvider, *[]SendMessageRequest) {
+	t.Helper()
+
+	var requests []SendMessageRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bottest-token/sendMessage" {
+			t.Errorf("request to %s, want sendMessage of the bot", r.URL.Path)
+		}
+		var request SendMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		requests = append(requests, request)
+
+		response := responses[0]
+		if len(responses) > 1 {
+			responses = responses[1:]
+		}
+		var status struct {
+			ErrorCode int `json:"error_code"`
+		}
+		_ = json.Unmarshal([]byte(response), &status)
+		if status.ErrorCode != 0 {
+			w.WriteHeader(status.ErrorCode)
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	config["bot_token"] = "test-token"
+	config["api_base_url"] = server.URL
+
+	provider, err := NewBotProvider(config)
+	if err != nil {
+		t.Fatalf("NewBotProvider() error = %v", err)
+	}
+	return provider, &requests
+}
+
+func TestBotProviderSend(t *testing.T) {
+	provider, requests := newTestBot(t, nil, `{"ok":true,"result":{"message_id":77}}`)
+
+	notification := &ent.Notification{
+		ID:        1,
+		RequestID: "req-1",
+		Address:   types.Address("123456789"),
+		Headline:  "Bonus <credited>",
+		Body:      "Your <b>50</b> free spins are waiting",
+		Data: map[string]interface{}{
+			"buttons": []interface{}{
+				map[string]interface{}{"text": "Play", "url": "https://example.com/play"},
+				[]interface{}{
+					map[string]interface{}{"text": "Yes", "callback_data": "yes"},
+					map[string]interface{}{"text": "No", "callback_data": "no"},
+				},
+			},
+		},
+	}
+
+	result, err := provider.Send(context.Background(), notification, models.MessageTypeBonus)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if result.Status != models.StatusSent || result.ProviderMessageID != "123456789:77" {
+		t.Errorf("Send() = %+v, want sent with the chat scoped message ID", result)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("sent %d requests, want 1", len(*requests))
+	}
+	request := (*requests)[0]
+	if request.ChatID != "123456789" || request.ParseMode != parseModeHTML {
+		t.Errorf("request = %+v", request)
+	}
+	if want := "<b>Bonus &lt;credited&gt;</b>\nYour <b>50</b> free spins are waiting"; request.Text != want {
+		t.Errorf("text = %q, want %q", request.Text, want)
+	}
+	if request.ReplyMarkup == nil || len(request.ReplyMarkup.InlineKeyboard) != 2 ||
+		len(request.ReplyMarkup.InlineKeyboard[1]) != 2 || request.ReplyMarkup.InlineKeyboard[0][0].URL != "https://example.com/play" {
+		t.Errorf("reply markup = %+v, want a URL row and a row of two callback buttons", request.ReplyMarkup)
+	}
+}
+
+func TestBotProviderSendErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         map[string]interface{}
+		data           map[string]interface{}
+		responses      []string
+		wantClass      models.ErrorClass
+		wantRequests   int
+		wantRetryAfter bool
+	}{
+		{
+			name:         "blocked by the user",
+			responses:    []string{`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`},
+			wantClass:    models.ErrorClassInvalidRecipient,
+			wantRequests: 1,
+		},
+		{
+			name:         "chat not found",
+			responses:    []string{`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`},
+			wantClass:    models.ErrorClassInvalidRecipient,
+			wantRequests: 1,
+		},
+		{
+			name:         "unparsable entities",
+			responses:    []string{`{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`},
+			wantClass:    models.ErrorClassInvalidContent,
+			wantRequests: 1,
+		},
+		{
+			name:         "invalid token",
+			responses:    []string{`{"ok":false,"error_code":401,"description":"Unauthorized"}`},
+			wantClass:    models.ErrorClassAuthentication,
+			wantRequests: 1,
+		},
+		{
+			name:         "throttled longer than honored",
+			config:       map[string]interface{}{"max_retry_after_seconds": 5},
+			responses:    []string{`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 60","parameters":{"retry_after":60}}`},
+			wantClass:    models.ErrorClassTemporary,
+			wantRequests: 1,
+		},
+		{
+			name:         "button without an action",
+			data:         map[string]interface{}{"buttons": []interface{}{map[string]interface{}{"text": "Play"}}},
+			wantClass:    models.ErrorClassInvalidContent,
+			wantRequests: 0,
+		},
+		{
+			name:         "unsupported parse mode",
+			data:         map[string]interface{}{"parse_mode": "BBCode"},
+			wantClass:    models.ErrorClassInvalidContent,
+			wantRequests: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := tt.responses
+			if len(responses) == 0 {
+				responses = []string{`{"ok":true,"result":{"message_id":1}}`}
+			}
+			provider, requests := newTestBot(t, tt.config, responses...)
+
+			notification := &ent.Notification{ID: 1, Address: types.Address("123456789"), Body: "Hello", Data: tt.data}
+			result, err := provider.Send(context.Background(), notification, models.MessageTypeBonus)
+			if err == nil {
+				t.Fatal("Send() error = nil, want an error")
+			}
+			if result.Status != models.StatusFailed || result.ErrorClass != tt.wantClass {
+				t.Errorf("Send() = %s/%s, want FAILED/%s", result.Status, result.ErrorClass, tt.wantClass)
+			}
+			if len(*requests) != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", len(*requests), tt.wantRequests)
+			}
+			if strings.Contains(err.Error(), "test-token") {
+				t.Errorf("error %q leaks the bot token", err)
+			}
+		})
+	}
+}
+
+func TestBotProviderSendRetriesThrottled(t *testing.T) {
+	provider, requests := newTestBot(t, nil,
+		`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`,
+		`{"ok":true,"result":{"message_id":78}}`,
+	)
+
+	notification := &ent.Notification{ID: 1, Address: types.Address("123456789"), Body: "Hello"}
+	result, err := provider.Send(context.Background(), notification, models.MessageTypeBonus)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if result.ProviderMessageID != "123456789:78" || len(*requests) != 2 {
+		t.Errorf("Send() = %+v after %d requests, want sent on the retry", result, len(*requests))
+	}
+}
+
+func TestBuildRequestParseModes(t *testing.T) {
+	tests := []struct {
+		name          string
+		parseMode     string
+		headline      string
+		wantText      string
+		wantParseMode string
+	}{
+		{name: "MarkdownV2", parseMode: parseModeMarkdownV2, headline: "50% off. Today!", wantText: "*50% off\\. Today\\!*\nBody", wantParseMode: parseModeMarkdownV2},
+		{name: "Markdown", parseMode: parseModeMarkdown, headline: "free_spins", wantText: "*free\\_spins*\nBody", wantParseMode: parseModeMarkdown},
+		{name: "plain", parseMode: parseModePlain, headline: "<b>Hi</b>", wantText: "<b>Hi</b>\nBody"},
+		{name: "no headline", parseMode: parseModeHTML, wantText: "Body", wantParseMode: parseModeHTML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewBotProvider(map[string]interface{}{"bot_token": "test-token", "parse_mode": tt.parseMode})
+			if err != nil {
+				t.Fatalf("NewBotProvider() error = %v", err)
+			}
+			request, err := provider.buildRequest(&ent.Notification{Address: types.Address("1"), Headline: tt.headline, Body: "Body"})
+			if err != nil {
+				t.Fatalf("buildRequest() error = %v", err)
+			}
+			if request.Text != tt.wantText || request.ParseMode != tt.wantParseMode {
+				t.Errorf("buildRequest() = %q (%q), want %q (%q)", request.Text, request.ParseMode, tt.wantText, tt.wantParseMode)
+			}
+		})
+	}
+
+	provider, _ := NewBotProvider(map[string]interface{}{"bot_token": "test-token"})
+	if _, err := provider.buildRequest(&ent.Notification{Body: strings.Repeat("a", maxMessageLength+1)}); err == nil {
+		t.Error("buildRequest() accepted a message longer than Telegram allows")
+	}
+}
@@ -0,0 +1,496 @@
+package viber
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+const (
+	// maxTextLength is the longest text of a Viber business message
+	maxTextLength = 1000
+	// maxButtonTextLength is the longest caption of the button below a message
+	maxButtonTextLength = 30
+	// defaultTTLSeconds is how long Viber tries to deliver a message before it is undelivered
+	// and, with the SMS fallback, sent as SMS instead
+	defaultTTLSeconds = 3600
+	// smsFallbackSuffix marks the IDs of messages sent with an SMS fallback, so their delivery
+	// reports can be told apart from ones of messages that end on Viber
+	smsFallbackSuffix = ":sms"
+)
+
+// NikitaProvider sends Viber business messages through the Nikita broker API, the same API
+// the custom SMS provider uses. Messages can fall back to SMS when the recipient isn't on Viber
+// or doesn't receive the message within its TTL.
+type NikitaProvider struct {
+	config NikitaConfig
+	client *http.Client
+}
+
+// NikitaConfig represents Viber configuration of the Nikita broker API
+type NikitaConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Originator is the Viber sender name registered for the account
+	Originator string `json:"originator"`
+	TTLSeconds int    `json:"ttl_seconds"`
+
+	// SMSFallback sends the message as SMS when Viber can't deliver it, from SMSOriginator
+	SMSFallback   bool   `json:"sms_fallback"`
+	SMSOriginator string `json:"sms_originator"`
+
+	// DLRToken authenticates pushed delivery reports
+	DLRToken string `json:"dlr_token"`
+
+	// Message type specific sender names (optional)
+	MSGBonusOriginator   string `json:"MSGBonusOriginator"`
+	MSGPromoOriginator   string `json:"MSGPromoOriginator"`
+	MSGSystemOriginator  string `json:"MSGSystemOriginator"`
+	MSGReportOriginator  string `json:"MSGReportOriginator"`
+	MSGPaymentOriginator string `json:"MSGPaymentOriginator"`
+	MSGSupportOriginator string `json:"MSGSupportOriginator"`
+}
+
+// Request represents the request structure of the Nikita broker API
+type Request struct {
+	Messages []Message `json:"messages"`
+}
+
+type Message struct {
+	Recipient string    `json:"recipient"`
+	Priority  string    `json:"priority"`
+	Viber     ViberPart `json:"viber"`
+	SMS       *SMSPart  `json:"sms,omitempty"`
+	MessageID string    `json:"message-id"`
+}
+
+// ViberPart is the Viber message, text, image and button can be combined
+type ViberPart struct {
+	Originator string       `json:"originator"`
+	TTL        int          `json:"ttl"`
+	Content    ViberContent `json:"content"`
+}
+
+type ViberContent struct {
+	Text       string `json:"text,omitempty"`
+	ImageURL   string `json:"image-url,omitempty"`
+	ButtonText string `json:"button-text,omitempty"`
+	ButtonURL  string `json:"button-url,omitempty"`
+}
+
+// SMSPart is the SMS sent in place of a Viber message that couldn't be delivered
+type SMSPart struct {
+	Originator string  `json:"originator"`
+	Content    SMSText `json:"content"`
+}
+
+type SMSText struct {
+	Text string `json:"text"`
+}
+
+// errorResponse represents an error response of the Nikita broker API
+type errorResponse struct {
+	ErrorDescription string `json:"error-description,omitempty"`
+}
+
+// APIError is returned when the Nikita broker API doesn't accept a request
+type APIError struct {
+	StatusCode  int
+	Description string
+	Response    string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == http.StatusOK {
+		return fmt.Sprintf("Viber API error: unexpected response: %s", e.Response)
+	}
+
+	errorMsg := fmt.Sprintf("Viber API error %d", e.StatusCode)
+	if e.Description != "" {
+		errorMsg = e.Description
+	}
+	return fmt.Sprintf("Nikita Viber API error: %s, Response: %s", errorMsg, e.Response)
+}
+
+// contentError is returned for notifications that can't be sent as a Viber message
+type contentError struct {
+	message string
+}
+
+func (e *contentError) Error() string {
+	return e.message
+}
+
+// NewNikitaProvider creates a new Viber provider for the Nikita broker API
+func NewNikitaProvider(config map[string]interface{}) (*NikitaProvider, error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Viber config: %w", err)
+	}
+
+	var nikitaConfig NikitaConfig
+	if err := json.Unmarshal(configBytes, &nikitaConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Viber config: %w", err)
+	}
+
+	if nikitaConfig.TTLSeconds == 0 {
+		nikitaConfig.TTLSeconds = defaultTTLSeconds
+	}
+
+	provider := &NikitaProvider{
+		config: nikitaConfig,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+
+	if err := provider.ValidateConfig(); err != nil {
+		return nil, fmt.Errorf("invalid Viber config: %w", err)
+	}
+
+	return provider, nil
+}
+
+// Send sends a single Viber message, with the SMS fallback when smsFallback is set
+func (n *NikitaProvider) Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType, smsFallback bool) (*models.SendResult, error) {
+	results, err := n.send(ctx, []*ent.Notification{notification}, messageType, map[int]bool{notification.ID: smsFallback})
+	if err != nil {
+		return nil, err
+	}
+
+	result := results[0]
+	if result.Failed() {
+		return result, result.Error
+	}
+	return result, nil
+}
+
+// SendBatch sends multiple Viber messages in one request, smsFallbacks has the IDs of the
+// notifications the SMS fallback may be sent for
+func (n *NikitaProvider) SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType, smsFallbacks map[int]bool) ([]*models.SendResult, error) {
+	log := logger.To("viber_batch")
+
+	results, err := n.send(ctx, notifications, messageType, smsFallbacks)
+	if err != nil {
+		return nil, err
+	}
+
+	errorCount := 0
+	for _, result := range results {
+		if result.Failed() {
+			errorCount++
+		}
+	}
+
+	log.Info("Viber batch processing completed", map[string]interface{}{
+		"batch_size":    len(notifications),
+		"success_count": len(notifications) - errorCount,
+		"error_count":   errorCount,
+	})
+
+	return results, nil
+}
+
+// ValidateConfig validates the Viber configuration
+func (n *NikitaProvider) ValidateConfig() error {
+	if n.config.URL == "" {
+		return fmt.Errorf("Viber URL is required")
+	}
+	if n.config.Username == "" {
+		return fmt.Errorf("Viber Username is required")
+	}
+	if n.config.Password == "" {
+		return fmt.Errorf("Viber Password is required")
+	}
+	if n.config.Originator == "" {
+		return fmt.Errorf("Viber Originator is required")
+	}
+	if n.config.SMSFallback && n.config.SMSOriginator == "" {
+		return fmt.Errorf("Viber SMS Originator is required for the SMS fallback")
+	}
+	if n.config.TTLSeconds < 0 {
+		return fmt.Errorf("Viber TTL can't be negative")
+	}
+	return nil
+}
+
+// GetType returns the provider type
+func (n *NikitaProvider) GetType() string {
+	return "nikita"
+}
+
+// ReportsDelivery tells whether delivery reports can be authenticated
+func (n *NikitaProvider) ReportsDelivery() bool {
+	return n.config.DLRToken != ""
+}
+
+// send sends the notifications that make valid Viber messages in one request and returns one
+// result per notification, in their order. The API accepts or rejects a request as a whole.
+func (n *NikitaProvider) send(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType, smsFallbacks map[int]bool) ([]*models.SendResult, error) {
+	originator := n.getOriginator(messageType)
+
+	results := make([]*models.SendResult, len(notifications))
+	messages := make([]Message, 0, len(notifications))
+	indexes := make([]int, 0, len(notifications))
+
+	for i, notification := range notifications {
+		message, err := n.buildMessage(notification, originator, smsFallbacks[notification.ID])
+		if err != nil {
+			logger.WithRequest(notification.RequestID).Error("Invalid Viber message", err, map[string]interface{}{
+				"notification_id": notification.ID,
+			})
+			sendErr := fmt.Errorf("invalid Viber message: %w", err)
+			results[i] = models.NewFailedResult(notification.ID, models.ErrorClassInvalidContent, sendErr)
+			results[i].ResponseSummary = err.Error()
+			continue
+		}
+		messages = append(messages, *message)
+		indexes = append(indexes, i)
+	}
+
+	if len(messages) == 0 {
+		return results, nil
+	}
+
+	start := time.Now()
+	response, err := n.sendMessages(ctx, messages)
+	latency := time.Since(start)
+
+	for j, i := range indexes {
+		notification := notifications[i]
+		message := messages[j]
+		log := logger.WithRequest(notification.RequestID)
+
+		var result *models.SendResult
+		if err != nil {
+			log.Error("Failed to send Viber message", err, map[string]interface{}{
+				"notification_id": notification.ID,
+				"to":              message.Recipient,
+				"originator":      originator,
+				"message_id":      message.MessageID,
+			})
+			sendErr := fmt.Errorf("failed to send Viber message: %w", err)
+			result = models.NewFailedResult(notification.ID, classifyError(err), sendErr)
+			result.ResponseSummary = err.Error()
+		} else {
+			log.Info("Viber message sent successfully", map[string]interface{}{
+				"notification_id": notification.ID,
+				"to":              message.Recipient,
+				"originator":      originator,
+				"message_id":      message.MessageID,
+				"sms_fallback":    message.SMS != nil,
+			})
+			result = models.NewSentResult(notification.ID, message.MessageID)
+			result.ResponseSummary = response
+		}
+
+		result.RequestSummary = fmt.Sprintf("POST %s/broker-api/send recipient=%s originator=%s message-id=%s sms_fallback=%t",
+			n.config.URL, message.Recipient, originator, message.MessageID, message.SMS != nil)
+		if len(messages) > 1 {
+			result.RequestSummary += fmt.Sprintf(" batch=%d", len(messages))
+		}
+		result.Latency = latency
+		results[i] = result
+	}
+
+	return results, nil
+}
+
+// buildMessage turns a notification into a Viber message. The body is the text, the image_url,
+// button_text and button_url keys of the notification data add an image and a button, and
+// sms_text replaces the body in the SMS fallback, which is only added when the configuration
+// enables it and smsFallback is set.
+func (n *NikitaProvider) buildMessage(notification *ent.Notification, originator string, smsFallback bool) (*Message, error) {
+	content := ViberContent{
+		Text:       notification.Body,
+		ImageURL:   dataString(notification.Data, "image_url"),
+		ButtonText: dataString(notification.Data, "button_text"),
+		ButtonURL:  dataString(notification.Data, "button_url"),
+	}
+
+	if content.Text == "" && content.ImageURL == "" {
+		return nil, &contentError{message: "message needs a text or an image"}
+	}
+	if utf8.RuneCountInString(content.Text) > maxTextLength {
+		return nil, &contentError{message: fmt.Sprintf("text is longer than %d characters", maxTextLength)}
+	}
+	if content.ImageURL != "" && !isHTTPURL(content.ImageURL) {
+		return nil, &contentError{message: "image_url must be an http(s) URL"}
+	}
+	if (content.ButtonText == "") != (content.ButtonURL == "") {
+		return nil, &contentError{message: "button_text and button_url go together"}
+	}
+	if content.ButtonURL != "" && !isHTTPURL(content.ButtonURL) {
+		return nil, &contentError{message: "button_url must be an http(s) URL"}
+	}
+	if utf8.RuneCountInString(content.ButtonText) > maxButtonTextLength {
+		return nil, &contentError{message: fmt.Sprintf("button_text is longer than %d characters", maxButtonTextLength)}
+	}
+	// Viber shows a button below a text, never below an image alone
+	if content.ButtonText != "" && content.Text == "" {
+		return nil, &contentError{message: "a button needs a text"}
+	}
+
+	phoneNumber := strings.TrimPrefix(string(notification.Address), "+")
+	message := &Message{
+		Recipient: phoneNumber,
+		Priority:  "2",
+		Viber: ViberPart{
+			Originator: originator,
+			TTL:        n.config.TTLSeconds,
+			Content:    content,
+		},
+		// Delivery reports are matched to attempts by the message ID, the notification ID keeps
+		// messages to the same number in one request apart
+		MessageID: fmt.Sprintf("%s:%d:%d", phoneNumber, notification.ID, time.Now().Unix()),
+	}
+
+	if n.config.SMSFallback && smsFallback {
+		text := dataString(notification.Data, "sms_text")
+		if text == "" {
+			text = fallbackText(content)
+		}
+		if text != "" {
+			message.SMS = &SMSPart{
+				Originator: n.config.SMSOriginator,
+				Content:    SMSText{Text: text},
+			}
+			message.MessageID += smsFallbackSuffix
+		}
+	}
+
+	return message, nil
+}
+
+// sendMessages sends messages via the Nikita broker API
+func (n *NikitaProvider) sendMessages(ctx context.Context, messages []Message) (string, error) {
+	fullURL := fmt.Sprintf("%s/broker-api/send", n.config.URL)
+
+	jsonData, err := json.Marshal(Request{Messages: messages})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fullURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("charset", "utf-8")
+	req.SetBasicAuth(n.config.Username, n.config.Password)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	responseStr := string(body)
+
+	if resp.StatusCode != http.StatusOK {
+		var errorResp errorResponse
+		json.Unmarshal(body, &errorResp)
+
+		return responseStr, &APIError{
+			StatusCode:  resp.StatusCode,
+			Description: errorResp.ErrorDescription,
+			Response:    responseStr,
+		}
+	}
+
+	// The API answers accepted requests with "OK"
+	if !strings.Contains(responseStr, "OK") {
+		return responseStr, &APIError{
+			StatusCode: resp.StatusCode,
+			Response:   responseStr,
+		}
+	}
+
+	return responseStr, nil
+}
+
+// getOriginator returns the sender name of the message type
+func (n *NikitaProvider) getOriginator(messageType models.MessageType) string {
+	switch messageType {
+	case models.MessageTypeBonus:
+		if n.config.MSGBonusOriginator != "" {
+			return n.config.MSGBonusOriginator
+		}
+	case models.MessageTypePromo:
+		if n.config.MSGPromoOriginator != "" {
+			return n.config.MSGPromoOriginator
+		}
+	case models.MessageTypeSystem:
+		if n.config.MSGSystemOriginator != "" {
+			return n.config.MSGSystemOriginator
+		}
+	case models.MessageTypeReport:
+		if n.config.MSGReportOriginator != "" {
+			return n.config.MSGReportOriginator
+		}
+	case models.MessageTypePayment:
+		if n.config.MSGPaymentOriginator != "" {
+			return n.config.MSGPaymentOriginator
+		}
+	case models.MessageTypeSupport:
+		if n.config.MSGSupportOriginator != "" {
+			return n.config.MSGSupportOriginator
+		}
+	}
+	return n.config.Originator
+}
+
+// fallbackText is the SMS sent in place of the Viber message, the button link is appended as
+// SMS can't show buttons
+func fallbackText(content ViberContent) string {
+	if content.ButtonURL == "" {
+		return content.Text
+	}
+	return content.Text + "\n" + content.ButtonURL
+}
+
+func dataString(data map[string]interface{}, key string) string {
+	value, _ := data[key].(string)
+	return strings.TrimSpace(value)
+}
+
+func isHTTPURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// classifyError maps Nikita broker API failures to error classes, transport problems are temporary
+func classifyError(err error) models.ErrorClass {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return models.ErrorClassTemporary
+	}
+
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+		return models.ErrorClassAuthentication
+	case apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500:
+		return models.ErrorClassTemporary
+	default:
+		return models.ErrorClassRejected
+	}
+}
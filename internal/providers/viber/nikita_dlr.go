@@ -0,0 +1,67 @@
+package viber
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// DeliveryReport is a delivery report of a Viber message pushed by the Nikita broker API.
+// Channel tells whether the report is about the Viber message or its SMS fallback.
+type DeliveryReport struct {
+	MessageID string `json:"message-id" form:"message-id" query:"message-id"`
+	Status    string `json:"status" form:"status" query:"status"`
+	Channel   string `json:"channel,omitempty" form:"channel" query:"channel"`
+	ErrorCode string `json:"error-code,omitempty" form:"error-code" query:"error-code"`
+	DoneDate  string `json:"done-date,omitempty" form:"done-date" query:"done-date"`
+}
+
+// DeliveryStatus maps the status of a Viber delivery report to the notification status it leads
+// to, seen Viber messages count as opened
+func DeliveryStatus(report *DeliveryReport) (models.NotificationStatus, bool) {
+	switch strings.ToUpper(strings.TrimSpace(report.Status)) {
+	case "DELIVRD", "DELIVERED":
+		return models.StatusDelivered, true
+	case "SEEN", "READ":
+		return models.StatusOpened, true
+	case "UNDELIV", "UNDELIVERED", "EXPIRED", "REJECTD", "REJECTED", "DELETED", "FAILED":
+		return models.StatusUndelivered, true
+	default:
+		return "", false
+	}
+}
+
+// ViaSMS reports whether the report is about the SMS fallback of the message
+func (r *DeliveryReport) ViaSMS() bool {
+	return strings.EqualFold(strings.TrimSpace(r.Channel), "sms")
+}
+
+// HasSMSFallback reports whether the message was sent with an SMS fallback, whose report
+// decides the delivery when the Viber message isn't delivered
+func (r *DeliveryReport) HasSMSFallback() bool {
+	return strings.HasSuffix(r.MessageID, smsFallbackSuffix)
+}
+
+// ProviderStatus returns the status recorded on the delivery attempt, prefixed with the
+// channel when the SMS fallback was used
+func (r *DeliveryReport) ProviderStatus() string {
+	if r.ViaSMS() {
+		return "sms:" + r.Status
+	}
+	return r.Status
+}
+
+// OperatorErrorCode returns the error code of the report, empty when it reports no error
+func (r *DeliveryReport) OperatorErrorCode() string {
+	code := strings.TrimSpace(r.ErrorCode)
+	if strings.Trim(code, "0") == "" {
+		return ""
+	}
+	return code
+}
+
+// VerifyDLRToken checks the token a pushed delivery report was sent with
+func (n *NikitaProvider) VerifyDLRToken(token string) bool {
+	return n.config.DLRToken != "" && subtle.ConstantTimeCompare([]byte(n.config.DLRToken), []byte(token)) == 1
+}
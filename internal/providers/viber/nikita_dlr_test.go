@@ -0,0 +1,94 @@
+package viber
+
+import (
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func TestVerifyDLRToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		token      string
+		want       bool
+	}{
+		{name: "valid", configured: "dlr-secret", token: "dlr-secret", want: true},
+		{name: "wrong token", configured: "dlr-secret", token: "dlr-secreT"},
+		{name: "missing token", configured: "dlr-secret"},
+		{name: "not configured", token: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &NikitaProvider{config: NikitaConfig{DLRToken: tt.configured}}
+			if got := provider.VerifyDLRToken(tt.token); got != tt.want {
+				t.Errorf("VerifyDLRToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryReport(t *testing.T) {
+	tests := []struct {
+		name            string
+		report          DeliveryReport
+		wantStatus      models.NotificationStatus
+		wantOK          bool
+		wantViaSMS      bool
+		wantSMSFallback bool
+		wantProvider    string
+		wantErrorCode   string
+	}{
+		{
+			name:         "delivered",
+			report:       DeliveryReport{MessageID: "37499123456:1:1700000000", Status: "DELIVRD", ErrorCode: "000"},
+			wantStatus:   models.StatusDelivered,
+			wantOK:       true,
+			wantProvider: "DELIVRD",
+		},
+		{
+			name:         "seen",
+			report:       DeliveryReport{MessageID: "37499123456:1:1700000000", Status: "seen"},
+			wantStatus:   models.StatusOpened,
+			wantOK:       true,
+			wantProvider: "seen",
+		},
+		{
+			name:            "SMS fallback undelivered",
+			report:          DeliveryReport{MessageID: "37499123456:1:1700000000" + smsFallbackSuffix, Status: "UNDELIV", Channel: "SMS", ErrorCode: "034"},
+			wantStatus:      models.StatusUndelivered,
+			wantOK:          true,
+			wantViaSMS:      true,
+			wantSMSFallback: true,
+			wantProvider:    "sms:UNDELIV",
+			wantErrorCode:   "034",
+		},
+		{
+			name:         "intermediate",
+			report:       DeliveryReport{MessageID: "37499123456:1:1700000000", Status: "ACCEPTD"},
+			wantProvider: "ACCEPTD",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, ok := DeliveryStatus(&tt.report)
+			if status != tt.wantStatus || ok != tt.wantOK {
+				t.Errorf("DeliveryStatus() = %q, %v, want %q, %v", status, ok, tt.wantStatus, tt.wantOK)
+			}
+			if got := tt.report.ViaSMS(); got != tt.wantViaSMS {
+				t.Errorf("ViaSMS() = %v, want %v", got, tt.wantViaSMS)
+			}
+			if got := tt.report.HasSMSFallback(); got != tt.wantSMSFallback {
+				t.Errorf("HasSMSFallback() = %v, want %v", got, tt.wantSMSFallback)
+			}
+			if got := tt.report.ProviderStatus(); got != tt.wantProvider {
+				t.Errorf("ProviderStatus() = %q, want %q", got, tt.wantProvider)
+			}
+			if got := tt.report.OperatorErrorCode(); got != tt.wantErrorCode {
+				t.Errorf("OperatorErrorCode() = %q, want %q", got, tt.wantErrorCode)
+			}
+		})
+	}
+}
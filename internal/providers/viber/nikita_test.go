@@ -0,0 +1,57 @@
+package viber
+
+import (
+	"strings"
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/types"
+)
+
+func TestBuildMessage(t *testing.T) {
+	provider := &NikitaProvider{config: NikitaConfig{SMSFallback: true, SMSOriginator: "Casino"}}
+
+	first, err := provider.buildMessage(&ent.Notification{ID: 7, Address: types.Address("+37499123456"), Body: "Bonus"}, "Casino", false)
+	if err != nil {
+		t.Fatalf("buildMessage() error = %v", err)
+	}
+	second, err := provider.buildMessage(&ent.Notification{ID: 8, Address: types.Address("+37499123456"), Body: "Bonus"}, "Casino", false)
+	if err != nil {
+		t.Fatalf("buildMessage() error = %v", err)
+	}
+
+	// Messages to the same number sent at once still get their own IDs
+	if !strings.HasPrefix(first.MessageID, "37499123456:7:") || !strings.HasPrefix(second.MessageID, "37499123456:8:") {
+		t.Errorf("message IDs = %q, %q, want the number and the notification ID", first.MessageID, second.MessageID)
+	}
+	if first.SMS != nil {
+		t.Error("the SMS fallback must only be added when requested")
+	}
+
+	withFallback, err := provider.buildMessage(&ent.Notification{
+		ID:      9,
+		Address: types.Address("+37499123456"),
+		Body:    "Bonus",
+		Data:    map[string]interface{}{"button_text": "Play", "button_url": "https://example.com/play"},
+	}, "Casino", true)
+	if err != nil {
+		t.Fatalf("buildMessage() error = %v", err)
+	}
+	if withFallback.SMS == nil || withFallback.SMS.Content.Text != "Bonus\nhttps://example.com/play" {
+		t.Errorf("SMS fallback = %+v, want the text with the button URL", withFallback.SMS)
+	}
+	if !strings.HasSuffix(withFallback.MessageID, smsFallbackSuffix) {
+		t.Errorf("message ID = %q, want the SMS fallback suffix", withFallback.MessageID)
+	}
+
+	invalid := []map[string]interface{}{
+		{"image_url": "ftp://example.com/banner.png"},
+		{"button_text": "Play"},
+		{"button_text": "Play", "button_url": "javascript:alert(1)"},
+	}
+	for _, data := range invalid {
+		if _, err := provider.buildMessage(&ent.Notification{ID: 10, Address: types.Address("+37499123456"), Body: "Bonus", Data: data}, "Casino", false); err == nil {
+			t.Errorf("buildMessage() accepted data %v", data)
+		}
+	}
+}
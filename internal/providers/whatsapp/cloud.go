@@ -0,0 +1,551 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+const (
+	// maxTextLength is the longest body of a text message
+	maxTextLength = 4096
+	// SessionWindow is how long after the last message of a user free-form messages can be sent
+	SessionWindow = 24 * time.Hour
+
+	headerText     = "text"
+	headerImage    = "image"
+	headerVideo    = "video"
+	headerDocument = "document"
+)
+
+// ErrSessionClosed is returned for free-form messages to users outside the customer service window
+var ErrSessionClosed = errors.New("no open customer service window, the message needs a template")
+
+// CloudProvider sends WhatsApp messages through the Cloud API of Meta's Graph API. Templates can
+// always be sent, free-form messages only within 24 hours of the user's last message.
+type CloudProvider struct {
+	config CloudConfig
+	client *http.Client
+}
+
+// CloudConfig represents WhatsApp Cloud API configuration
+type CloudConfig struct {
+	AccessToken   string `json:"access_token"`
+	PhoneNumberID string `json:"phone_number_id"`
+	// APIBaseURL points at the Graph API, e.g. a mock while testing
+	APIBaseURL string `json:"api_base_url"`
+	APIVersion string `json:"api_version"`
+	// AppSecret verifies the signature of status webhooks
+	AppSecret string `json:"app_secret"`
+	// VerifyToken answers the verification request when the webhook is subscribed
+	VerifyToken string `json:"verify_token"`
+	// Language of templates that don't set their own, defaults to en_US
+	Language   string `json:"language"`
+	PreviewURL bool   `json:"preview_url"`
+	// SessionTemplate is sent in place of free-form messages when the session is closed
+	SessionTemplate string `json:"session_template"`
+	// Templates maps the parameters of approved templates to keys of the notification data
+	Templates map[string]TemplateConfig `json:"templates"`
+}
+
+// TemplateConfig maps the parameters of an approved template, in order, to keys of the
+// notification data; the keys headline and body fall back to the notification's own
+type TemplateConfig struct {
+	Language string `json:"language"`
+	// HeaderType is text (default), image, video or document, media headers take a link
+	HeaderType string   `json:"header_type"`
+	Header     []string `json:"header"`
+	Body       []string `json:"body"`
+	// Buttons holds the URL suffix of each dynamic URL button, by button index
+	Buttons []string `json:"buttons"`
+}
+
+// MessageRequest represents the request payload of the messages endpoint
+type MessageRequest struct {
+	MessagingProduct string       `json:"messaging_product"`
+	RecipientType    string       `json:"recipient_type"`
+	To               string       `json:"to"`
+	Type             string       `json:"type"`
+	Text             *TextMessage `json:"text,omitempty"`
+	Template         *Template    `json:"template,omitempty"`
+}
+
+type TextMessage struct {
+	PreviewURL bool   `json:"preview_url"`
+	Body       string `json:"body"`
+}
+
+type Template struct {
+	Name       string      `json:"name"`
+	Language   Language    `json:"language"`
+	Components []Component `json:"components,omitempty"`
+}
+
+type Language struct {
+	Code string `json:"code"`
+}
+
+type Component struct {
+	Type       string      `json:"type"`
+	SubType    string      `json:"sub_type,omitempty"`
+	Index      string      `json:"index,omitempty"`
+	Parameters []Parameter `json:"parameters"`
+}
+
+type Parameter struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Image    *Media `json:"image,omitempty"`
+	Video    *Media `json:"video,omitempty"`
+	Document *Media `json:"document,omitempty"`
+}
+
+type Media struct {
+	Link string `json:"link"`
+}
+
+// MessageResponse represents the response of the messages endpoint
+type MessageResponse struct {
+	Messages []struct {
+		ID string `json:"id"`
+	} `json:"messages"`
+}
+
+// errorResponse represents an error response of the Graph API
+type errorResponse struct {
+	Error *struct {
+		Message   string `json:"message"`
+		Type      string `json:"type"`
+		Code      int    `json:"code"`
+		ErrorData *struct {
+			Details string `json:"details"`
+		} `json:"error_data,omitempty"`
+	} `json:"error"`
+}
+
+// APIError is returned when the Graph API rejects a request
+type APIError struct {
+	StatusCode int
+	Code       int
+	Message    string
+	Details    string
+}
+
+func (e *APIError) Error() string {
+	if e.Details != "" {
+		return fmt.Sprintf("WhatsApp API error: %s: %s (code: %d, status: %d)", e.Message, e.Details, e.Code, e.StatusCode)
+	}
+	return fmt.Sprintf("WhatsApp API error: %s (code: %d, status: %d)", e.Message, e.Code, e.StatusCode)
+}
+
+// contentError is returned for messages that can't be sent as they are
+type contentError struct {
+	message string
+}
+
+func (e *contentError) Error() string {
+	return e.message
+}
+
+// NewCloudProvider creates a new WhatsApp Cloud API provider
+func NewCloudProvider(config map[string]interface{}) (*CloudProvider, error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal WhatsApp config: %w", err)
+	}
+
+	var cloudConfig CloudConfig
+	if err := json.Unmarshal(configBytes, &cloudConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal WhatsApp config: %w", err)
+	}
+
+	if cloudConfig.APIBaseURL == "" {
+		cloudConfig.APIBaseURL = "https://graph.facebook.com"
+	}
+	cloudConfig.APIBaseURL = strings.TrimRight(cloudConfig.APIBaseURL, "/")
+	if cloudConfig.APIVersion == "" {
+		cloudConfig.APIVersion = "v21.0"
+	}
+	if cloudConfig.Language == "" {
+		cloudConfig.Language = "en_US"
+	}
+
+	provider := &CloudProvider{
+		config: cloudConfig,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+
+	if err := provider.ValidateConfig(); err != nil {
+		return nil, fmt.Errorf("invalid WhatsApp config: %w", err)
+	}
+
+	return provider, nil
+}
+
+// Send sends a single message to the phone number the notification is addressed to. sessionOpen
+// tells whether the user wrote to the tenant within the customer service window.
+func (w *CloudProvider) Send(ctx context.Context, notification *ent.Notification, messageType models.MessageType, sessionOpen bool) (*models.SendResult, error) {
+	log := logger.WithRequest(notification.RequestID)
+	to := strings.TrimPrefix(string(notification.Address), "+")
+
+	request, err := w.buildRequest(notification, sessionOpen)
+	if err != nil {
+		log.Error("Invalid WhatsApp message", err, map[string]interface{}{
+			"notification_id": notification.ID,
+			"to":              to,
+			"session_open":    sessionOpen,
+		})
+		sendErr := fmt.Errorf("invalid WhatsApp message: %w", err)
+		errorClass := models.ErrorClassInvalidContent
+		if errors.Is(err, ErrSessionClosed) {
+			errorClass = models.ErrorClassRejected
+		}
+		result := models.NewFailedResult(notification.ID, errorClass, sendErr)
+		result.ResponseSummary = err.Error()
+		return result, sendErr
+	}
+
+	requestSummary := fmt.Sprintf("POST %s/%s/messages to=%s type=%s", w.config.APIVersion, w.config.PhoneNumberID, to, request.Type)
+	if request.Template != nil {
+		requestSummary += fmt.Sprintf(" template=%s language=%s", request.Template.Name, request.Template.Language.Code)
+	}
+
+	start := time.Now()
+	messageID, err := w.sendMessage(ctx, request)
+	latency := time.Since(start)
+
+	if err != nil {
+		log.Error("Failed to send WhatsApp message", err, map[string]interface{}{
+			"notification_id": notification.ID,
+			"to":              to,
+			"type":            request.Type,
+		})
+		sendErr := fmt.Errorf("failed to send WhatsApp message: %w", err)
+		result := models.NewFailedResult(notification.ID, classifyError(err), sendErr)
+		result.RequestSummary = requestSummary
+		result.ResponseSummary = err.Error()
+		result.Latency = latency
+		return result, sendErr
+	}
+
+	log.Info("WhatsApp message sent successfully", map[string]interface{}{
+		"notification_id": notification.ID,
+		"to":              to,
+		"type":            request.Type,
+		"message_id":      messageID,
+	})
+
+	result := models.NewSentResult(notification.ID, messageID)
+	result.RequestSummary = requestSummary
+	result.ResponseSummary = fmt.Sprintf("ok message_id=%s", messageID)
+	result.Latency = latency
+	return result, nil
+}
+
+// SendBatch sends multiple messages, one request each. openSessions holds the IDs of the
+// notifications whose users are within the customer service window.
+func (w *CloudProvider) SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType, openSessions map[int]bool) ([]*models.SendResult, error) {
+	log := logger.To("whatsapp_batch")
+
+	// The Cloud API has no batch endpoint
+	results := make([]*models.SendResult, 0, len(notifications))
+	errorCount := 0
+
+	for _, notification := range notifications {
+		result, err := w.Send(ctx, notification, messageType, openSessions[notification.ID])
+		if err != nil {
+			errorCount++
+		}
+		results = append(results, result)
+	}
+
+	log.Info("WhatsApp batch processing completed", map[string]interface{}{
+		"batch_size":    len(notifications),
+		"success_count": len(notifications) - errorCount,
+		"error_count":   errorCount,
+	})
+
+	return results, nil
+}
+
+// ValidateConfig validates the WhatsApp configuration
+func (w *CloudProvider) ValidateConfig() error {
+	if w.config.AccessToken == "" {
+		return fmt.Errorf("WhatsApp access token is required")
+	}
+	if w.config.PhoneNumberID == "" {
+		return fmt.Errorf("WhatsApp phone number ID is required")
+	}
+
+	for name, template := range w.config.Templates {
+		switch template.HeaderType {
+		case "", headerText:
+		case headerImage, headerVideo, headerDocument:
+			if len(template.Header) != 1 {
+				return fmt.Errorf("WhatsApp template %s needs exactly one %s header parameter", name, template.HeaderType)
+			}
+		default:
+			return fmt.Errorf("WhatsApp template %s header type must be text, image, video or document", name)
+		}
+	}
+
+	return nil
+}
+
+// GetType returns the provider type
+func (w *CloudProvider) GetType() string {
+	return "cloud"
+}
+
+// ReportsDelivery tells whether status webhooks can be verified
+func (w *CloudProvider) ReportsDelivery() bool {
+	return w.config.AppSecret != ""
+}
+
+// buildRequest turns a notification into a message request. The template key of the notification
+// data sends an approved template, otherwise the headline and body are sent as text while the
+// session is open and the session template once it is closed.
+func (w *CloudProvider) buildRequest(notification *ent.Notification, sessionOpen bool) (*MessageRequest, error) {
+	request := &MessageRequest{
+		MessagingProduct: "whatsapp",
+		RecipientType:    "individual",
+		To:               strings.TrimPrefix(string(notification.Address), "+"),
+	}
+
+	templateName := dataString(notification.Data, "template")
+	if templateName == "" && !sessionOpen {
+		if w.config.SessionTemplate == "" {
+			return nil, ErrSessionClosed
+		}
+		templateName = w.config.SessionTemplate
+	}
+
+	if templateName != "" {
+		template, err := w.buildTemplate(notification, templateName)
+		if err != nil {
+			return nil, err
+		}
+		request.Type = "template"
+		request.Template = template
+		return request, nil
+	}
+
+	text := notification.Body
+	if notification.Headline != "" {
+		text = "*" + notification.Headline + "*\n" + notification.Body
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, &contentError{message: "message text is empty"}
+	}
+	if utf8.RuneCountInString(text) > maxTextLength {
+		return nil, &contentError{message: fmt.Sprintf("message is longer than %d characters", maxTextLength)}
+	}
+
+	request.Type = "text"
+	request.Text = &TextMessage{
+		PreviewURL: w.config.PreviewURL,
+		Body:       text,
+	}
+	return request, nil
+}
+
+// buildTemplate fills the parameters of a template from the notification data, following the
+// mapping of the template config. Templates without one are sent without parameters.
+func (w *CloudProvider) buildTemplate(notification *ent.Notification, name string) (*Template, error) {
+	config := w.config.Templates[name]
+
+	language := dataString(notification.Data, "template_language")
+	if language == "" {
+		language = config.Language
+	}
+	if language == "" {
+		language = w.config.Language
+	}
+
+	template := &Template{
+		Name:     name,
+		Language: Language{Code: language},
+	}
+
+	if len(config.Header) > 0 {
+		parameters, err := templateParameters(notification, config.Header, config.HeaderType)
+		if err != nil {
+			return nil, fmt.Errorf("template %s header: %w", name, err)
+		}
+		template.Components = append(template.Components, Component{Type: "header", Parameters: parameters})
+	}
+
+	if len(config.Body) > 0 {
+		parameters, err := templateParameters(notification, config.Body, headerText)
+		if err != nil {
+			return nil, fmt.Errorf("template %s body: %w", name, err)
+		}
+		template.Components = append(template.Components, Component{Type: "body", Parameters: parameters})
+	}
+
+	for index, key := range config.Buttons {
+		if key == "" {
+			continue
+		}
+		parameters, err := templateParameters(notification, []string{key}, headerText)
+		if err != nil {
+			return nil, fmt.Errorf("template %s button %d: %w", name, index, err)
+		}
+		template.Components = append(template.Components, Component{
+			Type:       "button",
+			SubType:    "url",
+			Index:      strconv.Itoa(index),
+			Parameters: parameters,
+		})
+	}
+
+	return template, nil
+}
+
+// templateParameters reads the values of the keys from the notification data
+func templateParameters(notification *ent.Notification, keys []string, parameterType string) ([]Parameter, error) {
+	parameters := make([]Parameter, 0, len(keys))
+	for _, key := range keys {
+		value := dataString(notification.Data, key)
+		if value == "" && key == "headline" {
+			value = notification.Headline
+		}
+		if value == "" && key == "body" {
+			value = notification.Body
+		}
+		if value == "" {
+			return nil, &contentError{message: fmt.Sprintf("data has no value for parameter %q", key)}
+		}
+
+		parameter := Parameter{Type: parameterType}
+		switch parameterType {
+		case headerImage:
+			parameter.Image = &Media{Link: value}
+		case headerVideo:
+			parameter.Video = &Media{Link: value}
+		case headerDocument:
+			parameter.Document = &Media{Link: value}
+		default:
+			parameter.Type = headerText
+			parameter.Text = value
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters, nil
+}
+
+// sendMessage calls the messages endpoint of the tenant's phone number
+func (w *CloudProvider) sendMessage(ctx context.Context, request *MessageRequest) (string, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/%s/messages", w.config.APIBaseURL, w.config.APIVersion, w.config.PhoneNumberID)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+w.config.AccessToken)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+		var errorResp errorResponse
+		if json.Unmarshal(body, &errorResp) == nil && errorResp.Error != nil {
+			apiErr.Code = errorResp.Error.Code
+			apiErr.Message = errorResp.Error.Message
+			if errorResp.Error.ErrorData != nil {
+				apiErr.Details = errorResp.Error.ErrorData.Details
+			}
+		}
+		return "", apiErr
+	}
+
+	var response MessageResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(response.Messages) == 0 || response.Messages[0].ID == "" {
+		return "", fmt.Errorf("response has no message ID: %s", strings.TrimSpace(string(body)))
+	}
+
+	return response.Messages[0].ID, nil
+}
+
+// dataString reads a string or number from the notification data
+func dataString(data map[string]interface{}, key string) string {
+	switch value := data[key].(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case json.Number:
+		return value.String()
+	case int, int64, bool:
+		return fmt.Sprint(value)
+	default:
+		return ""
+	}
+}
+
+// classifyError maps Graph API errors to error classes, see
+// https://developers.facebook.com/docs/whatsapp/cloud-api/support/error-codes
+func classifyError(err error) models.ErrorClass {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return models.ErrorClassTemporary
+	}
+
+	switch apiErr.Code {
+	case 10, 190, 200:
+		// Expired or invalid access token, missing permissions
+		return models.ErrorClassAuthentication
+	case 1, 2, 4, 80007, 130429, 131000, 131016, 131048, 131056, 133016:
+		// Service errors and throttling
+		return models.ErrorClassTemporary
+	case 131021, 131026, 131030:
+		// Recipient isn't on WhatsApp, can't receive the message or isn't allowed on a test number
+		return models.ErrorClassInvalidRecipient
+	case 100, 131008, 131009, 131051, 131052, 131053, 132000, 132001, 132005, 132007, 132012, 132015, 132016, 132068, 132069:
+		// Invalid parameters, media or templates
+		return models.ErrorClassInvalidContent
+	case 131047:
+		// The customer service window closed, only templates can be sent
+		return models.ErrorClassRejected
+	}
+
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized:
+		return models.ErrorClassAuthentication
+	case apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500:
+		return models.ErrorClassTemporary
+	default:
+		return models.ErrorClassRejected
+	}
+}
@@ -0,0 +1,158 @@
+package whatsapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// WebhookPayload is a webhook post of the WhatsApp Business Account
+type WebhookPayload struct {
+	Object string `json:"object"`
+	Entry  []struct {
+		ID      string `json:"id"`
+		Changes []struct {
+			Field string       `json:"field"`
+			Value WebhookValue `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+// WebhookValue holds the status updates of sent messages and the messages users sent
+type WebhookValue struct {
+	MessagingProduct string `json:"messaging_product"`
+	Metadata         struct {
+		PhoneNumberID string `json:"phone_number_id"`
+	} `json:"metadata"`
+	Statuses []MessageStatus  `json:"statuses,omitempty"`
+	Messages []InboundMessage `json:"messages,omitempty"`
+}
+
+// MessageStatus is a status update of a sent message
+type MessageStatus struct {
+	ID          string        `json:"id"`
+	Status      string        `json:"status"`
+	Timestamp   string        `json:"timestamp"`
+	RecipientID string        `json:"recipient_id"`
+	Errors      []StatusError `json:"errors,omitempty"`
+}
+
+type StatusError struct {
+	Code    int    `json:"code"`
+	Title   string `json:"title"`
+	Message string `json:"message,omitempty"`
+}
+
+// InboundMessage is a message a user sent to the phone number, it opens the customer service window
+type InboundMessage struct {
+	From      string `json:"from"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+}
+
+// VerifySignature checks the X-Hub-Signature-256 header of a webhook post, the HMAC-SHA256 of
+// the raw payload keyed with the app secret
+func VerifySignature(appSecret, signature string, payload []byte) bool {
+	if appSecret == "" {
+		return false
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// ParseWebhook reads the statuses and inbound messages of the phone number from a webhook post.
+// Changes of other phone numbers of the business account are skipped.
+func ParseWebhook(payload []byte, phoneNumberID string) ([]MessageStatus, []InboundMessage, error) {
+	var webhook WebhookPayload
+	if err := json.Unmarshal(payload, &webhook); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse WhatsApp webhook: %w", err)
+	}
+
+	var statuses []MessageStatus
+	var messages []InboundMessage
+	for _, entry := range webhook.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" || change.Value.Metadata.PhoneNumberID != phoneNumberID {
+				continue
+			}
+			statuses = append(statuses, change.Value.Statuses...)
+			messages = append(messages, change.Value.Messages...)
+		}
+	}
+	return statuses, messages, nil
+}
+
+// DeliveryStatus maps the status of a sent message to the notification status it leads to, read
+// messages count as opened. sent only confirms what the send response already reported.
+func DeliveryStatus(status string) (models.NotificationStatus, bool) {
+	switch status {
+	case "delivered":
+		return models.StatusDelivered, true
+	case "read":
+		return models.StatusOpened, true
+	case "failed":
+		return models.StatusUndelivered, true
+	default:
+		return "", false
+	}
+}
+
+// ErrorCode returns the code of the first error of a failed status, empty when there is none
+func (s *MessageStatus) ErrorCode() string {
+	if len(s.Errors) == 0 {
+		return ""
+	}
+	return strconv.Itoa(s.Errors[0].Code)
+}
+
+// ErrorTitle returns the title of the first error of a failed status
+func (s *MessageStatus) ErrorTitle() string {
+	if len(s.Errors) == 0 {
+		return ""
+	}
+	if s.Errors[0].Message != "" {
+		return s.Errors[0].Message
+	}
+	return s.Errors[0].Title
+}
+
+// SentAt returns when the user sent the message, falling back to now for unparseable timestamps
+func (m *InboundMessage) SentAt() time.Time {
+	seconds, err := strconv.ParseInt(m.Timestamp, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Now()
+	}
+	return time.Unix(seconds, 0)
+}
+
+// VerifySubscription checks the verify token of a webhook verification request
+func (w *CloudProvider) VerifySubscription(mode, token string) bool {
+	return mode == "subscribe" && w.config.VerifyToken != "" &&
+		subtle.ConstantTimeCompare([]byte(w.config.VerifyToken), []byte(token)) == 1
+}
+
+// VerifySignature checks the signature of a webhook post with the app secret of the config
+func (w *CloudProvider) VerifySignature(signature string, payload []byte) bool {
+	return VerifySignature(w.config.AppSecret, signature, payload)
+}
+
+// PhoneNumberID returns the ID of the phone number messages are sent from
+func (w *CloudProvider) PhoneNumberID() string {
+	return w.config.PhoneNumberID
+}
@@ -0,0 +1,118 @@
+package whatsapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func TestVerifySignature(t *testing.T) {
+	const appSecret = "app-secret"
+	payload := []byte(`{"object":"whatsapp_business_account","entry":[]}`)
+
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(payload)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name      string
+		appSecret string
+		signature string
+		payload   []byte
+		want      bool
+	}{
+		{name: "valid", appSecret: appSecret, signature: "sha256=" + signature, payload: payload, want: true},
+		{name: "valid without prefix", appSecret: appSecret, signature: signature, payload: payload, want: true},
+		{name: "modified payload", appSecret: appSecret, signature: "sha256=" + signature, payload: []byte(`{}`)},
+		{name: "other secret", appSecret: "other-secret", signature: "sha256=" + signature, payload: payload},
+		{name: "missing secret", signature: "sha256=" + signature, payload: payload},
+		{name: "missing signature", appSecret: appSecret, payload: payload},
+		{name: "not hex", appSecret: appSecret, signature: "sha256=xyz", payload: payload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifySignature(tt.appSecret, tt.signature, tt.payload); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	payload := []byte(`{
+		"object": "whatsapp_business_account",
+		"entry": [{
+			"id": "102290129340398",
+			"changes": [
+				{
+					"field": "messages",
+					"value": {
+						"messaging_product": "whatsapp",
+						"metadata": {"phone_number_id": "106540352242922"},
+						"statuses": [
+							{"id": "wamid.1", "status": "read", "timestamp": "1700000000", "recipient_id": "37499123456"},
+							{"id": "wamid.2", "status": "failed", "timestamp": "1700000000", "recipient_id": "37499123457",
+								"errors": [{"code": 131026, "title": "Message undeliverable"}]}
+						],
+						"messages": [{"from": "37499123458", "id": "wamid.3", "timestamp": "1700000100", "type": "text"}]
+					}
+				},
+				{
+					"field": "messages",
+					"value": {
+						"metadata": {"phone_number_id": "other"},
+						"statuses": [{"id": "wamid.4", "status": "delivered"}]
+					}
+				}
+			]
+		}]
+	}`)
+
+	statuses, messages, err := ParseWebhook(payload, "106540352242922")
+	if err != nil {
+		t.Fatalf("ParseWebhook() error = %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].ID != "wamid.1" || statuses[1].ID != "wamid.2" {
+		t.Fatalf("statuses = %+v, want the two of the phone number", statuses)
+	}
+	if code, title := statuses[1].ErrorCode(), statuses[1].ErrorTitle(); code != "131026" || title != "Message undeliverable" {
+		t.Errorf("error = %q %q", code, title)
+	}
+	if statuses[0].ErrorCode() != "" {
+		t.Errorf("ErrorCode() = %q without errors, want empty", statuses[0].ErrorCode())
+	}
+	if len(messages) != 1 || messages[0].From != "37499123458" || messages[0].SentAt().Unix() != 1700000100 {
+		t.Errorf("messages = %+v", messages)
+	}
+
+	if _, _, err := ParseWebhook([]byte(`{`), "106540352242922"); err == nil {
+		t.Error("ParseWebhook() accepted an invalid payload")
+	}
+}
+
+func TestDeliveryStatus(t *testing.T) {
+	tests := []struct {
+		status     string
+		wantStatus models.NotificationStatus
+		wantOK     bool
+	}{
+		{status: "sent"},
+		{status: "delivered", wantStatus: models.StatusDelivered, wantOK: true},
+		{status: "read", wantStatus: models.StatusOpened, wantOK: true},
+		{status: "failed", wantStatus: models.StatusUndelivered, wantOK: true},
+		{status: "deleted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			status, ok := DeliveryStatus(tt.status)
+			if status != tt.wantStatus || ok != tt.wantOK {
+				t.Errorf("DeliveryStatus() = %q, %v, want %q, %v", status, ok, tt.wantStatus, tt.wantOK)
+			}
+		})
+	}
+}
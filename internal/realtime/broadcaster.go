@@ -0,0 +1,53 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/config"
+	"gitlab.smartbet.am/golang/notification/internal/kafka"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// Broadcaster announces new in-app notifications to the streams of their users. With a realtime
+// topic configured events go through Kafka so streams connected to any instance receive them,
+// otherwise they are only delivered to the streams of this instance.
+type Broadcaster struct {
+	publisher *kafka.Publisher
+	hub       *Hub
+	topic     string
+	logger    *logrus.Logger
+}
+
+func NewBroadcaster(cfg *config.Config, publisher *kafka.Publisher, hub *Hub, logger *logrus.Logger) *Broadcaster {
+	return &Broadcaster{
+		publisher: publisher,
+		hub:       hub,
+		topic:     cfg.Kafka.Topics.Realtime,
+		logger:    logger,
+	}
+}
+
+// Publish announces a new message of the user's inbox
+func (b *Broadcaster) Publish(ctx context.Context, tenantID int64, userID string, message *models.InboxMessage) error {
+	event := &models.InboxEvent{
+		TenantID: tenantID,
+		UserID:   userID,
+		Message:  message,
+	}
+
+	if b.topic == "" {
+		b.hub.Deliver(event)
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%d:%s", tenantID, userID)
+	return b.publisher.Publish(ctx, b.topic, key, payload)
+}
@@ -0,0 +1,134 @@
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// streamBufferSize is how many events a stream holds for a slow client before dropping new ones
+const streamBufferSize = 32
+
+type streamKey struct {
+	tenantID int64
+	userID   string
+}
+
+// Stream receives the encoded events of one connected client. Events is closed when the
+// stream is closed or the hub shuts down.
+type Stream struct {
+	Events <-chan []byte
+
+	key    streamKey
+	events chan []byte
+}
+
+// Hub tracks the streams connected to this instance and delivers inbox events to them.
+// A user may hold several streams at once, e.g. one per open browser tab.
+type Hub struct {
+	mu      sync.RWMutex
+	streams map[streamKey]map[*Stream]struct{}
+	closed  bool
+	logger  *logrus.Logger
+}
+
+func NewHub(logger *logrus.Logger) *Hub {
+	return &Hub{
+		streams: make(map[streamKey]map[*Stream]struct{}),
+		logger:  logger,
+	}
+}
+
+// Open registers a stream for the user, nil once the hub is shut down
+func (h *Hub) Open(tenantID int64, userID string) *Stream {
+	events := make(chan []byte, streamBufferSize)
+	stream := &Stream{
+		Events: events,
+		key:    streamKey{tenantID: tenantID, userID: userID},
+		events: events,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil
+	}
+	if h.streams[stream.key] == nil {
+		h.streams[stream.key] = make(map[*Stream]struct{})
+	}
+	h.streams[stream.key][stream] = struct{}{}
+	return stream
+}
+
+// Close unregisters the stream and closes its events channel
+func (h *Hub) Close(stream *Stream) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	streams, ok := h.streams[stream.key]
+	if !ok {
+		return
+	}
+	if _, ok := streams[stream]; !ok {
+		return
+	}
+
+	delete(streams, stream)
+	if len(streams) == 0 {
+		delete(h.streams, stream.key)
+	}
+	close(stream.events)
+}
+
+// Deliver pushes the event to every stream of its user connected to this instance and returns
+// how many received it. Streams whose buffer is full miss the event rather than block the others.
+func (h *Hub) Deliver(event *models.InboxEvent) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	streams := h.streams[streamKey{tenantID: event.TenantID, userID: event.UserID}]
+	if len(streams) == 0 {
+		return 0
+	}
+
+	payload, err := json.Marshal(&models.StreamEvent{
+		Type:    models.StreamEventNotification,
+		Message: event.Message,
+	})
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to encode stream event")
+		return 0
+	}
+
+	delivered := 0
+	for stream := range streams {
+		select {
+		case stream.events <- payload:
+			delivered++
+		default:
+			h.logger.WithFields(logrus.Fields{
+				"tenant_id": event.TenantID,
+				"user_id":   event.UserID,
+			}).Warn("Stream buffer full, dropping event")
+		}
+	}
+	return delivered
+}
+
+// Shutdown closes every stream and refuses new ones, so long-lived connections end
+// before the server waits for open requests to finish
+func (h *Hub) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for key, streams := range h.streams {
+		for stream := range streams {
+			close(stream.events)
+		}
+		delete(h.streams, key)
+	}
+}
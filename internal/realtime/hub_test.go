@@ -0,0 +1,89 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func newTestEvent(tenantID int64, userID, requestID string) *models.InboxEvent {
+	return &models.InboxEvent{
+		TenantID: tenantID,
+		UserID:   userID,
+		Message:  &models.InboxMessage{RequestID: requestID, Body: "Hello"},
+	}
+}
+
+func TestHubDeliver(t *testing.T) {
+	hub := NewHub(logrus.New())
+
+	tab1 := hub.Open(1001, "player-1")
+	tab2 := hub.Open(1001, "player-1")
+	otherUser := hub.Open(1001, "player-2")
+	otherTenant := hub.Open(1002, "player-1")
+
+	if delivered := hub.Deliver(newTestEvent(1001, "player-1", "req-1")); delivered != 2 {
+		t.Fatalf("Deliver() = %d, want both streams of the user", delivered)
+	}
+	for _, stream := range []*Stream{tab1, tab2} {
+		var event models.StreamEvent
+		if err := json.Unmarshal(<-stream.Events, &event); err != nil {
+			t.Fatalf("invalid event: %v", err)
+		}
+		if event.Type != models.StreamEventNotification || event.Message.RequestID != "req-1" {
+			t.Errorf("event = %+v, want the notification of req-1", event)
+		}
+	}
+	for _, stream := range []*Stream{otherUser, otherTenant} {
+		if len(stream.Events) != 0 {
+			t.Error("a stream of another user received the event")
+		}
+	}
+
+	// Closed streams no longer receive events
+	hub.Close(tab1)
+	if _, open := <-tab1.Events; open {
+		t.Error("events of a closed stream must be closed")
+	}
+	hub.Close(tab1)
+	if delivered := hub.Deliver(newTestEvent(1001, "player-1", "req-2")); delivered != 1 {
+		t.Errorf("Deliver() = %d after closing a stream, want 1", delivered)
+	}
+	if delivered := hub.Deliver(newTestEvent(1001, "player-3", "req-3")); delivered != 0 {
+		t.Errorf("Deliver() = %d without streams, want 0", delivered)
+	}
+}
+
+func TestHubDeliverFullBuffer(t *testing.T) {
+	hub := NewHub(logrus.New())
+	slow := hub.Open(1001, "player-1")
+
+	for i := 0; i < streamBufferSize; i++ {
+		if delivered := hub.Deliver(newTestEvent(1001, "player-1", "req")); delivered != 1 {
+			t.Fatalf("Deliver() = %d with room in the buffer, want 1", delivered)
+		}
+	}
+	// A full stream misses the event instead of blocking
+	if delivered := hub.Deliver(newTestEvent(1001, "player-1", "req")); delivered != 0 {
+		t.Errorf("Deliver() = %d with a full buffer, want 0", delivered)
+	}
+	if len(slow.Events) != streamBufferSize {
+		t.Errorf("buffered %d events, want %d", len(slow.Events), streamBufferSize)
+	}
+}
+
+func TestHubShutdown(t *testing.T) {
+	hub := NewHub(logrus.New())
+	stream := hub.Open(1001, "player-1")
+
+	hub.Shutdown()
+	for range stream.Events {
+	}
+	if hub.Open(1001, "player-1") != nil {
+		t.Error("Open() after Shutdown() must return nil")
+	}
+	// Closing a stream the shutdown already closed is a no-op
+	hub.Close(stream)
+}
@@ -0,0 +1,88 @@
+package realtime
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.smartbet.am/golang/notification/internal/config"
+)
+
+func newTestTokens(secret, ttl string) *Tokens {
+	cfg := &config.Config{}
+	cfg.Realtime.Secret = secret
+	cfg.Realtime.TokenTTL = ttl
+	return NewTokens(cfg)
+}
+
+func TestTokens(t *testing.T) {
+	tokens := newTestTokens("secret", "10m")
+
+	signed, expiresAt, err := tokens.Issue(1001, "player-42")
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if ttl := time.Until(expiresAt); ttl < 9*time.Minute || ttl > 10*time.Minute {
+		t.Errorf("token expires in %v, want the configured 10m", ttl)
+	}
+
+	token, err := tokens.Verify(signed)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if token.TenantID != 1001 || token.UserID != "player-42" || token.ExpiresAt != expiresAt.Unix() {
+		t.Errorf("Verify() = %+v", token)
+	}
+
+	encoded, signature, _ := strings.Cut(signed, ".")
+	other, _, err := newTestTokens("other-secret", "10m").Issue(1001, "player-42")
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	fallback, _, err := newTestTokens("secret", "-1m").Issue(1001, "player-42")
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr error
+	}{
+		{name: "other secret", value: other, wantErr: ErrInvalidToken},
+		{name: "modified payload", value: encoded + "x." + signature, wantErr: ErrInvalidToken},
+		{name: "no signature", value: encoded, wantErr: ErrInvalidToken},
+		{name: "empty", value: "", wantErr: ErrInvalidToken},
+		// A negative TTL falls back to the default of an hour
+		{name: "invalid TTL", value: fallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tokens.Verify(tt.value); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, _, err := newTestTokens("", "").Issue(1001, "player-42"); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Issue() without a secret error = %v, want ErrNotConfigured", err)
+	}
+	if _, err := newTestTokens("", "").Verify(signed); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Verify() without a secret error = %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestTokensExpired(t *testing.T) {
+	tokens := newTestTokens("secret", "1s")
+	signed, _, err := tokens.Issue(1001, "player-42")
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	// Tokens expire on whole seconds
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(2 * time.Second)))
+	if _, err := tokens.Verify(signed); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("Verify() error = %v, want ErrExpiredToken", err)
+	}
+}
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/contact"
+	"gitlab.smartbet.am/golang/notification/ent/device"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+type ContactRepository struct {
+	client *ent.Client
+	logger *logrus.Logger
+}
+
+func NewContactRepository(client *ent.Client, logger *logrus.Logger) *ContactRepository {
+	return &ContactRepository{
+		client: client,
+		logger: logger,
+	}
+}
+
+// Upsert stores a contact of the tenant, replacing all fields of an existing one with the same user ID
+func (r *ContactRepository) Upsert(ctx context.Context, tenantID int64, req *models.ContactRequest) (*ent.Contact, error) {
+	existing, err := r.client.Contact.Query().
+		Where(
+			contact.TenantID(tenantID),
+			contact.UserID(req.UserID),
+		).
+		First(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		return nil, err
+	}
+
+	if existing != nil {
+		return r.update(existing, req).Save(ctx)
+	}
+	return r.create(tenantID, req).Save(ctx)
+}
+
+// UpsertBulk stores many contacts of the tenant like Upsert. Contacts are expected to have
+// distinct user IDs. It returns the number of stored contacts.
+func (r *ContactRepository) UpsertBulk(ctx context.Context, tenantID int64, reqs []*models.ContactRequest) (int, error) {
+	for start := 0; start < len(reqs); start += lookupChunkSize {
+		end := min(start+lookupChunkSize, len(reqs))
+		chunk := reqs[start:end]
+
+		userIDs := make([]string, 0, len(chunk))
+		for _, req := range chunk {
+			userIDs = append(userIDs, req.UserID)
+		}
+
+		existing, err := r.client.Contact.Query().
+			Where(
+				contact.TenantID(tenantID),
+				contact.UserIDIn(userIDs...),
+			).
+			All(ctx)
+		if err != nil {
+			return start, err
+		}
+
+		byUserID := make(map[string]*ent.Contact, len(existing))
+		for _, entry := range existing {
+			byUserID[entry.UserID] = entry
+		}
+
+		builders := make([]*ent.ContactCreate, 0, len(chunk)-len(existing))
+		for _, req := range chunk {
+			if entry, ok := byUserID[req.UserID]; ok {
+				if err := r.update(entry, req).Exec(ctx); err != nil {
+					return start, err
+				}
+				continue
+			}
+			builders = append(builders, r.create(tenantID, req))
+		}
+		if len(builders) > 0 {
+			if err := r.client.Contact.CreateBulk(builders...).Exec(ctx); err != nil {
+				return start, err
+			}
+		}
+	}
+
+	return len(reqs), nil
+}
+
+func (r *ContactRepository) create(tenantID int64, req *models.ContactRequest) *ent.ContactCreate {
+	return r.client.Contact.Create().
+		SetTenantID(tenantID).
+		SetUserID(req.UserID).
+		SetEmail(req.Email).
+		SetPhone(NormalizePhone(req.Phone)).
+		SetTelegramChatID(req.TelegramChatID).
+		SetLocale(req.Locale).
+		SetTimeZone(req.TimeZone)
+}
+
+func (r *ContactRepository) update(existing *ent.Contact, req *models.ContactRequest) *ent.ContactUpdateOne {
+	return existing.Update().
+		SetEmail(req.Email).
+		SetPhone(NormalizePhone(req.Phone)).
+		SetTelegramChatID(req.TelegramChatID).
+		SetLocale(req.Locale).
+		SetTimeZone(req.TimeZone)
+}
+
+// Get returns a contact of the tenant by user ID
+func (r *ContactRepository) Get(ctx context.Context, tenantID int64, userID string) (*ent.Contact, error) {
+	return r.client.Contact.Query().
+		Where(
+			contact.TenantID(tenantID),
+			contact.UserID(userID),
+		).
+		Only(ctx)
+}
+
+// Delete removes a contact of the tenant, reporting whether it existed
+func (r *ContactRepository) Delete(ctx context.Context, tenantID int64, userID string) (bool, error) {
+	deleted, err := r.client.Contact.Delete().
+		Where(
+			contact.TenantID(tenantID),
+			contact.UserID(userID),
+		).
+		Exec(ctx)
+	return deleted > 0, err
+}
+
+// ResolveRecipients appends the addresses of the given users on the channel to the recipients,
+// leaving out addresses that are already listed. Push notifications go to the tokens of all
+// active devices of a user. Users without an address for the channel are returned as unresolved.
+func (r *ContactRepository) ResolveRecipients(ctx context.Context, tenantID int64, channel models.NotificationType, recipients, userIDs []string) ([]string, []string, error) {
+	listed := make(map[string]bool, len(recipients))
+	for _, recipient := range recipients {
+		listed[NormalizeAddress(recipient)] = true
+	}
+
+	unique := UniqueUserIDs(userIDs)
+	addresses, err := r.AddressesByUser(ctx, tenantID, channel, unique)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resolved := append([]string{}, recipients...)
+	var unresolved []string
+	for _, userID := range unique {
+		if len(addresses[userID]) == 0 {
+			unresolved = append(unresolved, userID)
+			continue
+		}
+
+		for _, address := range addresses[userID] {
+			if normalized := NormalizeAddress(address); !listed[normalized] {
+				listed[normalized] = true
+				resolved = append(resolved, address)
+			}
+		}
+	}
+
+	return resolved, unresolved, nil
+}
+
+// AddressesByUser returns the addresses of the users on the channel, keyed by user ID. Users
+// without an address for the channel are left out.
+func (r *ContactRepository) AddressesByUser(ctx context.Context, tenantID int64, channel models.NotificationType, userIDs []string) (map[string][]string, error) {
+	addresses := make(map[string][]string, len(userIDs))
+	for start := 0; start < len(userIDs); start += lookupChunkSize {
+		end := min(start+lookupChunkSize, len(userIDs))
+
+		if err := r.lookupAddresses(ctx, tenantID, channel, userIDs[start:end], addresses); err != nil {
+			return nil, err
+		}
+	}
+	return addresses, nil
+}
+
+// UsersByAddress returns the users having one of the addresses as email, phone, Telegram chat
+// or active device token, keyed by normalized address. Phone numbers match in any format.
+func (r *ContactRepository) UsersByAddress(ctx context.Context, tenantID int64, addresses []string) (map[string][]string, error) {
+	users := make(map[string][]string)
+	add := func(address, userID string) {
+		for _, listed := range users[address] {
+			if listed == userID {
+				return
+			}
+		}
+		users[address] = append(users[address], userID)
+	}
+
+	for start := 0; start < len(addresses); start += lookupChunkSize {
+		end := min(start+lookupChunkSize, len(addresses))
+		chunk := addresses[start:end]
+
+		// Contacts are matched by the address as given, lower-cased and as E.164 phone number
+		keys := make(map[string]string, len(chunk)*3)
+		values := make([]string, 0, len(chunk)*3)
+		for _, address := range chunk {
+			normalized := NormalizeAddress(address)
+			for _, value := range []string{strings.TrimSpace(address), normalized, NormalizePhone(address)} {
+				if _, ok := keys[value]; !ok && value != "" {
+					keys[value] = normalized
+					values = append(values, value)
+				}
+			}
+		}
+
+		contacts, err := r.client.Contact.Query().
+			Where(
+				contact.TenantID(tenantID),
+				contact.Or(
+					contact.EmailIn(values...),
+					contact.PhoneIn(values...),
+					contact.TelegramChatIDIn(values...),
+				),
+			).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range contacts {
+			for _, value := range []string{NormalizeAddress(entry.Email), NormalizePhone(entry.Phone), entry.Phone, entry.TelegramChatID} {
+				if address, ok := keys[value]; ok {
+					add(address, entry.UserID)
+				}
+			}
+		}
+
+		devices, err := r.client.Device.Query().
+			Where(
+				device.TenantID(tenantID),
+				device.TokenIn(values...),
+				device.Active(true),
+			).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range devices {
+			if address, ok := keys[entry.Token]; ok {
+				add(address, entry.UserID)
+			}
+		}
+	}
+
+	return users, nil
+}
+
+// UniqueUserIDs trims the user IDs and drops empty and repeated ones, keeping their order
+func UniqueUserIDs(userIDs []string) []string {
+	seen := make(map[string]bool, len(userIDs))
+	unique := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		userID = strings.TrimSpace(userID)
+		if userID != "" && !seen[userID] {
+			seen[userID] = true
+			unique = append(unique, userID)
+		}
+	}
+	return unique
+}
+
+// lookupAddresses adds the addresses of the users on the channel to addresses, keyed by user ID
+func (r *ContactRepository) lookupAddresses(ctx context.Context, tenantID int64, channel models.NotificationType, userIDs []string, addresses map[string][]string) error {
+	// In-app notifications go to the inbox of the user itself
+	if channel == models.TypeInApp {
+		for _, userID := range userIDs {
+			addresses[userID] = []string{userID}
+		}
+		return nil
+	}
+
+	if channel == models.TypePush {
+		devices, err := r.client.Device.Query().
+			Where(
+				device.TenantID(tenantID),
+				device.UserIDIn(userIDs...),
+				device.Active(true),
+			).
+			Order(ent.Asc(device.FieldID)).
+			All(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range devices {
+			addresses[entry.UserID] = append(addresses[entry.UserID], entry.Token)
+		}
+		return nil
+	}
+
+	contacts, err := r.client.Contact.Query().
+		Where(
+			contact.TenantID(tenantID),
+			contact.UserIDIn(userIDs...),
+		).
+		All(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range contacts {
+		switch {
+		case channel == models.TypeEmail && entry.Email != "":
+			addresses[entry.UserID] = []string{entry.Email}
+		case (channel == models.TypeSMS || channel == models.TypeViber || channel == models.TypeWhatsApp) && entry.Phone != "":
+			addresses[entry.UserID] = []string{entry.Phone}
+		case channel == models.TypeTelegram && entry.TelegramChatID != "":
+			addresses[entry.UserID] = []string{entry.TelegramChatID}
+		}
+	}
+	return nil
+}
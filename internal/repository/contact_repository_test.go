@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func TestResolveRecipients(t *testing.T) {
+	ctx := context.Background()
+	repo := NewContactRepository(newTestClient(t), logrus.New())
+
+	devices := NewDeviceRepository(repo.client, logrus.New())
+
+	for _, req := range []*models.ContactRequest{
+		{UserID: "player-1", Email: "player1@example.org", Phone: "+37499000001"},
+		{UserID: "player-2", Email: "Player2@Example.org"},
+	} {
+		if _, err := repo.Upsert(ctx, 1001, req); err != nil {
+			t.Fatalf("failed to store contact: %v", err)
+		}
+	}
+	for _, token := range []string{"token-1a", "token-1b", "token-1c"} {
+		if _, err := devices.Register(ctx, 1001, &models.DeviceRegisterRequest{UserID: "player-1", Platform: models.PlatformAndroid, Token: token}); err != nil {
+			t.Fatalf("failed to register device: %v", err)
+		}
+	}
+	if _, err := devices.Deactivate(ctx, 1001, "token-1c", DeactivationUnregistered); err != nil {
+		t.Fatalf("failed to deactivate device: %v", err)
+	}
+	// Devices don't need a contact
+	if _, err := devices.Register(ctx, 1001, &models.DeviceRegisterRequest{UserID: "player-4", Platform: models.PlatformIOS, Token: "token-4"}); err != nil {
+		t.Fatalf("failed to register device: %v", err)
+	}
+	if _, err := repo.Upsert(ctx, 1002, &models.ContactRequest{UserID: "player-3", Phone: "+37499000003"}); err != nil {
+		t.Fatalf("failed to store contact: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		channel        models.NotificationType
+		recipients     []string
+		userIDs        []string
+		wantRecipients []string
+		wantUnresolved []string
+	}{
+		{
+			name:           "email",
+			channel:        models.TypeEmail,
+			recipients:     []string{"listed@example.org"},
+			userIDs:        []string{"player-1", "player-2"},
+			wantRecipients: []string{"listed@example.org", "player1@example.org", "Player2@Example.org"},
+		},
+		{
+			name:           "already listed",
+			channel:        models.TypeEmail,
+			recipients:     []string{"player2@example.org"},
+			userIDs:        []string{"player-2", " player-2 "},
+			wantRecipients: []string{"player2@example.org"},
+		},
+		{
+			name:           "without a phone",
+			channel:        models.TypeSMS,
+			userIDs:        []string{"player-1", "player-2"},
+			wantRecipients: []string{"+37499000001"},
+			wantUnresolved: []string{"player-2"},
+		},
+		{
+			name:           "every active device",
+			channel:        models.TypePush,
+			userIDs:        []string{"player-1", "player-2", "player-4"},
+			wantRecipients: []string{"token-1a", "token-1b", "token-4"},
+			wantUnresolved: []string{"player-2"},
+		},
+		{
+			name:           "contact of another tenant",
+			channel:        models.TypeSMS,
+			userIDs:        []string{"player-3", "unknown"},
+			wantRecipients: []string{},
+			wantUnresolved: []string{"player-3", "unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipients, unresolved, err := repo.ResolveRecipients(ctx, 1001, tt.channel, tt.recipients, tt.userIDs)
+			if err != nil {
+				t.Fatalf("ResolveRecipients() error = %v", err)
+			}
+			if fmt.Sprint(recipients) != fmt.Sprint(tt.wantRecipients) {
+				t.Errorf("recipients = %v, want %v", recipients, tt.wantRecipients)
+			}
+			if fmt.Sprint(unresolved) != fmt.Sprint(tt.wantUnresolved) {
+				t.Errorf("unresolved = %v, want %v", unresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
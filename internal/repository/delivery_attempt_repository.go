@@ -0,0 +1,178 @@
+// File: internal/repository/delivery_attempt_repository.go
+
+package repository
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/deliveryattempt"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// maxSummaryLength keeps stored request/response summaries from growing with provider payloads
+const maxSummaryLength = 1000
+
+type DeliveryAttemptRepository struct {
+	client *ent.Client
+	logger *logrus.Logger
+}
+
+func NewDeliveryAttemptRepository(client *ent.Client, logger *logrus.Logger) *DeliveryAttemptRepository {
+	return &DeliveryAttemptRepository{
+		client: client,
+		logger: logger,
+	}
+}
+
+// maxAttemptNumberRetries bounds the retries of an attempt whose number was taken concurrently
+const maxAttemptNumberRetries = 3
+
+// Create records a send result as the next attempt of its notification. Attempts of the same
+// notification recorded concurrently get distinct numbers, a taken number is retried.
+func (r *DeliveryAttemptRepository) Create(ctx context.Context, result *models.SendResult) (*ent.DeliveryAttempt, error) {
+	outcome := deliveryattempt.OutcomeSENT
+	if result.Failed() {
+		outcome = deliveryattempt.OutcomeFAILED
+	}
+
+	for retry := 0; ; retry++ {
+		number, err := r.nextAttemptNumber(ctx, result.NotificationID)
+		if err != nil {
+			return nil, err
+		}
+
+		create := r.client.DeliveryAttempt.Create().
+			SetNotificationID(result.NotificationID).
+			SetAttempt(number).
+			SetProviderName(result.ProviderName).
+			SetProviderType(result.ProviderType).
+			SetProviderMessageID(result.ProviderMessageID).
+			SetRequestSummary(truncateSummary(result.RequestSummary)).
+			SetResponseSummary(truncateSummary(result.ResponseSummary)).
+			SetLatencyMs(result.Latency.Milliseconds()).
+			SetOutcome(outcome)
+
+		if result.Error != nil {
+			create.SetErrorClass(string(result.ErrorClass)).
+				SetErrorMessage(result.Error.Error())
+		}
+
+		attempt, err := create.Save(ctx)
+		if ent.IsConstraintError(err) && retry < maxAttemptNumberRetries {
+			continue
+		}
+		return attempt, err
+	}
+}
+
+// nextAttemptNumber returns the number following the last recorded attempt of a notification
+func (r *DeliveryAttemptRepository) nextAttemptNumber(ctx context.Context, notificationID int) (int, error) {
+	last, err := r.client.DeliveryAttempt.Query().
+		Where(deliveryattempt.NotificationID(notificationID)).
+		Order(ent.Desc(deliveryattempt.FieldAttempt)).
+		First(ctx)
+	if ent.IsNotFound(err) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return last.Attempt + 1, nil
+}
+
+// GetByNotificationID returns the attempts of a notification in the order they were made
+func (r *DeliveryAttemptRepository) GetByNotificationID(ctx context.Context, notificationID int) ([]*ent.DeliveryAttempt, error) {
+	return r.client.DeliveryAttempt.Query().
+		Where(deliveryattempt.NotificationID(notificationID)).
+		Order(ent.Asc(deliveryattempt.FieldAttempt)).
+		All(ctx)
+}
+
+// GetByProviderMessageID finds the attempt a provider message ID was issued for to a notification
+// of the tenant. Message IDs are only unique per provider account, so other tenants' attempts
+// with the same ID are never returned.
+func (r *DeliveryAttemptRepository) GetByProviderMessageID(ctx context.Context, tenantID int64, providerType, providerMessageID string) (*ent.DeliveryAttempt, error) {
+	return r.client.DeliveryAttempt.Query().
+		Where(
+			deliveryattempt.ProviderType(providerType),
+			deliveryattempt.ProviderMessageID(providerMessageID),
+			deliveryattempt.HasNotificationWith(notification.TenantID(tenantID)),
+		).
+		Order(ent.Desc(deliveryattempt.FieldID)).
+		First(ctx)
+}
+
+// UpdateProviderStatus stores the latest status and error code the provider reported for an attempt
+func (r *DeliveryAttemptRepository) UpdateProviderStatus(ctx context.Context, id int, providerStatus, errorCode string, at time.Time) error {
+	update := r.client.DeliveryAttempt.UpdateOneID(id).
+		SetProviderStatus(providerStatus).
+		SetProviderStatusTime(at)
+
+	if errorCode != "" {
+		update.SetOperatorErrorCode(errorCode)
+	}
+
+	return update.Exec(ctx)
+}
+
+// GetAwaitingReceipt returns accepted attempts of a provider type for the given tenants made
+// within the given window whose notification is still waiting for a final delivery report,
+// together with their notification. Attempts polled maxPolls times or since polledBefore are
+// left out, so every call moves on to attempts that weren't asked for recently.
+func (r *DeliveryAttemptRepository) GetAwaitingReceipt(ctx context.Context, providerType string, tenantIDs []int64, from, to, polledBefore time.Time, maxPolls, limit int) ([]*ent.DeliveryAttempt, error) {
+	if len(tenantIDs) == 0 {
+		return nil, nil
+	}
+
+	return r.client.DeliveryAttempt.Query().
+		Where(
+			deliveryattempt.ProviderType(providerType),
+			deliveryattempt.OutcomeEQ(deliveryattempt.OutcomeSENT),
+			deliveryattempt.ProviderMessageIDNEQ(""),
+			deliveryattempt.CreateTimeGTE(from),
+			deliveryattempt.CreateTimeLT(to),
+			deliveryattempt.ReceiptPollsLT(maxPolls),
+			deliveryattempt.Or(
+				deliveryattempt.ReceiptPolledAtIsNil(),
+				deliveryattempt.ReceiptPolledAtLT(polledBefore),
+			),
+			deliveryattempt.HasNotificationWith(
+				notification.TenantIDIn(tenantIDs...),
+				notification.StatusEQ(notification.StatusSENT),
+			),
+		).
+		WithNotification().
+		Order(ent.Asc(deliveryattempt.FieldCreateTime)).
+		Limit(limit).
+		All(ctx)
+}
+
+// MarkReceiptPolled records that the provider was asked for delivery reports of the attempts
+func (r *DeliveryAttemptRepository) MarkReceiptPolled(ctx context.Context, ids []int, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return r.client.DeliveryAttempt.Update().
+		Where(deliveryattempt.IDIn(ids...)).
+		AddReceiptPolls(1).
+		SetReceiptPolledAt(at).
+		Exec(ctx)
+}
+
+func truncateSummary(summary string) string {
+	if len(summary) <= maxSummaryLength {
+		return summary
+	}
+	// Cut on a rune boundary so the stored text stays valid UTF-8
+	end := maxSummaryLength
+	for end > 0 && !utf8.RuneStart(summary[end]) {
+		end--
+	}
+	return summary[:end] + "..."
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func TestGetAwaitingReceipt(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+	notifications := NewNotificationRepository(client, logrus.New())
+	attempts := NewDeliveryAttemptRepository(client, logrus.New())
+
+	// send stores a notification sent through the custom SMS provider
+	send := func(tenantID int64, status notification.Status, messageID string) *ent.DeliveryAttempt {
+		t.Helper()
+		notif, err := notifications.Create(ctx, &models.NotificationRequest{TenantID: tenantID, Type: models.TypeSMS, Body: "Hello"}, "+37499123456")
+		if err != nil {
+			t.Fatalf("failed to create notification: %v", err)
+		}
+		path := []notification.Status{notification.StatusSENT}
+		if status != notification.StatusSENT {
+			path = append(path, status)
+		}
+		for _, next := range path {
+			if err := notifications.UpdateStatus(ctx, notif.ID, next, nil); err != nil {
+				t.Fatalf("failed to move notification to %s: %v", next, err)
+			}
+		}
+		attempt, err := attempts.Create(ctx, &models.SendResult{
+			NotificationID:    notif.ID,
+			Status:            models.StatusSent,
+			ProviderType:      "custom",
+			ProviderMessageID: messageID,
+		})
+		if err != nil {
+			t.Fatalf("failed to record attempt: %v", err)
+		}
+		return attempt
+	}
+
+	waiting := send(1, notification.StatusSENT, "37499123456:1:1700000000")
+	polled := send(1, notification.StatusSENT, "37499123456:2:1700000000")
+	send(1, notification.StatusDELIVERED, "37499123456:3:1700000000")
+	send(2, notification.StatusSENT, "37499123456:4:1700000000")
+
+	now := time.Now()
+	if err := attempts.MarkReceiptPolled(ctx, []int{polled.ID}, now); err != nil {
+		t.Fatalf("MarkReceiptPolled() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		tenantIDs    []int64
+		polledBefore time.Time
+		maxPolls     int
+		want         []int
+	}{
+		{name: "not polled recently", tenantIDs: []int64{1}, polledBefore: now.Add(-time.Hour), maxPolls: 5, want: []int{waiting.ID}},
+		{name: "due again", tenantIDs: []int64{1}, polledBefore: now.Add(time.Second), maxPolls: 5, want: []int{waiting.ID, polled.ID}},
+		{name: "polled too often", tenantIDs: []int64{1}, polledBefore: now.Add(time.Second), maxPolls: 1, want: []int{waiting.ID}},
+		{name: "no polling tenants", polledBefore: now.Add(time.Second), maxPolls: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := attempts.GetAwaitingReceipt(ctx, "custom", tt.tenantIDs, now.Add(-time.Hour), now.Add(time.Hour), tt.polledBefore, tt.maxPolls, 10)
+			if err != nil {
+				t.Fatalf("GetAwaitingReceipt() error = %v", err)
+			}
+			ids := make([]int, 0, len(got))
+			for _, attempt := range got {
+				ids = append(ids, attempt.ID)
+				if attempt.Edges.Notification == nil {
+					t.Errorf("attempt %d is missing its notification", attempt.ID)
+				}
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) && !(len(ids) == 0 && len(tt.want) == 0) {
+				t.Errorf("GetAwaitingReceipt() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryAttemptCreate(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+	notifications := NewNotificationRepository(client, logrus.New())
+	attempts := NewDeliveryAttemptRepository(client, logrus.New())
+
+	// Two tenants whose provider accounts issued the same message ID
+	var ids []int
+	for _, tenantID := range []int64{1, 2} {
+		notif, err := notifications.Create(ctx, &models.NotificationRequest{TenantID: tenantID, Type: models.TypeSMS, Body: "Hello"}, "+37499123456")
+		if err != nil {
+			t.Fatalf("failed to create notification: %v", err)
+		}
+		ids = append(ids, notif.ID)
+	}
+
+	var numbers []int
+	for _, result := range []*models.SendResult{
+		{NotificationID: ids[0], Status: models.StatusFailed, ProviderType: "custom", Error: fmt.Errorf("timeout")},
+		{NotificationID: ids[0], Status: models.StatusSent, ProviderType: "custom", ProviderMessageID: "msg-1"},
+		{NotificationID: ids[1], Status: models.StatusSent, ProviderType: "custom", ProviderMessageID: "msg-1"},
+	} {
+		attempt, err := attempts.Create(ctx, result)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		numbers = append(numbers, attempt.Attempt)
+	}
+	if fmt.Sprint(numbers) != fmt.Sprint([]int{1, 2, 1}) {
+		t.Errorf("attempt numbers = %v, want each notification counted on its own", numbers)
+	}
+
+	for i, tenantID := range []int64{1, 2} {
+		attempt, err := attempts.GetByProviderMessageID(ctx, tenantID, "custom", "msg-1")
+		if err != nil {
+			t.Fatalf("GetByProviderMessageID() error = %v", err)
+		}
+		if attempt.NotificationID != ids[i] {
+			t.Errorf("tenant %d got the attempt of notification %d, want %d", tenantID, attempt.NotificationID, ids[i])
+		}
+	}
+	if _, err := attempts.GetByProviderMessageID(ctx, 3, "custom", "msg-1"); !ent.IsNotFound(err) {
+		t.Errorf("GetByProviderMessageID() of another tenant error = %v, want not found", err)
+	}
+}
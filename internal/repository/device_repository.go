@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/device"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// Reasons a device is deactivated for
+const (
+	DeactivationUnregistered = "unregistered"
+	DeactivationInvalidToken = "invalid_token"
+)
+
+type DeviceRepository struct {
+	client *ent.Client
+	logger *logrus.Logger
+}
+
+func NewDeviceRepository(client *ent.Client, logger *logrus.Logger) *DeviceRepository {
+	return &DeviceRepository{
+		client: client,
+		logger: logger,
+	}
+}
+
+// Register stores a push token of the tenant. A token that is already known is moved to the
+// user of the request and reactivated. When the same token is registered concurrently the
+// request that loses the race updates the device the other one created.
+func (r *DeviceRepository) Register(ctx context.Context, tenantID int64, req *models.DeviceRegisterRequest) (*ent.Device, error) {
+	for retry := 0; ; retry++ {
+		existing, err := r.client.Device.Query().
+			Where(
+				device.TenantID(tenantID),
+				device.Token(req.Token),
+			).
+			First(ctx)
+		if err != nil && !ent.IsNotFound(err) {
+			return nil, err
+		}
+
+		now := time.Now()
+		if existing != nil {
+			return existing.Update().
+				SetUserID(req.UserID).
+				SetPlatform(device.Platform(req.Platform)).
+				SetAppVersion(req.AppVersion).
+				SetLastSeenAt(now).
+				SetActive(true).
+				ClearDeactivatedAt().
+				SetDeactivationReason("").
+				Save(ctx)
+		}
+
+		created, err := r.client.Device.Create().
+			SetTenantID(tenantID).
+			SetUserID(req.UserID).
+			SetPlatform(device.Platform(req.Platform)).
+			SetToken(req.Token).
+			SetAppVersion(req.AppVersion).
+			SetLastSeenAt(now).
+			Save(ctx)
+		if ent.IsConstraintError(err) && retry == 0 {
+			continue
+		}
+		return created, err
+	}
+}
+
+// Deactivate stops sending push notifications to a token of the tenant, reporting whether an
+// active device had it
+func (r *DeviceRepository) Deactivate(ctx context.Context, tenantID int64, token, reason string) (bool, error) {
+	updated, err := r.client.Device.Update().
+		Where(
+			device.TenantID(tenantID),
+			device.Token(token),
+			device.Active(true),
+		).
+		SetActive(false).
+		SetDeactivatedAt(time.Now()).
+		SetDeactivationReason(reason).
+		Save(ctx)
+	return updated > 0, err
+}
+
+// ListByUser returns the devices of a user of the tenant, most recently seen first
+func (r *DeviceRepository) ListByUser(ctx context.Context, tenantID int64, userID string, activeOnly bool) ([]*ent.Device, error) {
+	query := r.client.Device.Query().
+		Where(
+			device.TenantID(tenantID),
+			device.UserID(userID),
+		)
+
+	if activeOnly {
+		query.Where(device.Active(true))
+	}
+
+	return query.
+		Order(ent.Desc(device.FieldLastSeenAt)).
+		All(ctx)
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/device"
+	"gitlab.smartbet.am/golang/notification/ent/hook"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func TestDeviceRegister(t *testing.T) {
+	ctx := context.Background()
+	repo := NewDeviceRepository(newTestClient(t), logrus.New())
+
+	first, err := repo.Register(ctx, 1001, &models.DeviceRegisterRequest{UserID: "player-1", Platform: models.PlatformAndroid, Token: "token-1", AppVersion: "2.4.0"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if pruned, err := repo.Deactivate(ctx, 1001, "token-1", DeactivationInvalidToken); err != nil || !pruned {
+		t.Fatalf("Deactivate() = %v, %v, want the device deactivated", pruned, err)
+	}
+	if pruned, err := repo.Deactivate(ctx, 1001, "token-1", DeactivationInvalidToken); err != nil || pruned {
+		t.Errorf("Deactivate() of an inactive device = %v, %v, want nothing deactivated", pruned, err)
+	}
+
+	// Registering a known token moves it to the new user and reactivates it
+	moved, err := repo.Register(ctx, 1001, &models.DeviceRegisterRequest{UserID: "player-2", Platform: models.PlatformAndroid, Token: "token-1", AppVersion: "2.4.1"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if moved.ID != first.ID || moved.UserID != "player-2" || !moved.Active || moved.DeactivatedAt != nil || moved.DeactivationReason != "" || moved.AppVersion != "2.4.1" {
+		t.Errorf("re-registered device = %+v, want device %d of player-2 reactivated", moved, first.ID)
+	}
+
+	// Tokens are unique per tenant only
+	other, err := repo.Register(ctx, 1002, &models.DeviceRegisterRequest{UserID: "player-1", Platform: models.PlatformIOS, Token: "token-1"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if other.ID == first.ID {
+		t.Error("a token of another tenant must be a separate device")
+	}
+
+	devices, err := repo.ListByUser(ctx, 1001, "player-1", false)
+	if err != nil || len(devices) != 0 {
+		t.Errorf("ListByUser(player-1) = %d devices, %v, want none", len(devices), err)
+	}
+	devices, err = repo.ListByUser(ctx, 1001, "player-2", true)
+	if err != nil || len(devices) != 1 || devices[0].ID != first.ID {
+		t.Errorf("ListByUser(player-2) = %d devices, %v, want the moved device", len(devices), err)
+	}
+}
+
+func TestDeviceRegisterRace(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+	repo := NewDeviceRepository(client, logrus.New())
+
+	// Another request registers the token between the lookup and the insert of this one
+	raced := false
+	client.Device.Use(func(next ent.Mutator) ent.Mutator {
+		return hook.DeviceFunc(func(ctx context.Context, m *ent.DeviceMutation) (ent.Value, error) {
+			if m.Op().Is(ent.OpCreate) && !raced {
+				raced = true
+				if _, err := client.Device.Create().
+					SetTenantID(1001).
+					SetUserID("player-1").
+					SetPlatform(device.PlatformIos).
+					SetToken("token-1").
+					SetLastSeenAt(time.Now()).
+					Save(ctx); err != nil {
+					t.Fatalf("failed to register the racing device: %v", err)
+				}
+			}
+			return next.Mutate(ctx, m)
+		})
+	})
+
+	registered, err := repo.Register(ctx, 1001, &models.DeviceRegisterRequest{UserID: "player-2", Platform: models.PlatformAndroid, Token: "token-1"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if registered.UserID != "player-2" || registered.Platform != device.PlatformAndroid {
+		t.Errorf("registered device = %+v, want the racing device moved to player-2", registered)
+	}
+	if count := client.Device.Query().CountX(ctx); count != 1 {
+		t.Errorf("got %d devices, want 1", count)
+	}
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/ent/predicate"
+	"gitlab.smartbet.am/golang/notification/types"
+)
+
+// InboxFilter narrows down the messages of an inbox
+type InboxFilter struct {
+	UnreadOnly bool
+	Archived   bool
+	Limit      int
+	Offset     int
+}
+
+// InboxRepository reads and updates the inboxes of users, made of the INAPP notifications sent to them
+type InboxRepository struct {
+	client *ent.Client
+	logger *logrus.Logger
+}
+
+func NewInboxRepository(client *ent.Client, logger *logrus.Logger) *InboxRepository {
+	return &InboxRepository{
+		client: client,
+		logger: logger,
+	}
+}
+
+// List returns a page of a user's inbox, newest first, and the number of messages matching the filter
+func (r *InboxRepository) List(ctx context.Context, tenantID int64, userID string, filter InboxFilter) ([]*ent.Notification, int, error) {
+	query := r.client.Notification.Query().Where(inboxOf(tenantID, userID)...)
+
+	if filter.Archived {
+		query.Where(notification.ArchivedAtNotNil())
+	} else {
+		query.Where(notification.ArchivedAtIsNil())
+	}
+	if filter.UnreadOnly {
+		query.Where(notification.ReadAtIsNil())
+	}
+
+	total, err := query.Clone().Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	messages, err := query.
+		Order(ent.Desc(notification.FieldSentAt), ent.Desc(notification.FieldID)).
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		All(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return messages, total, nil
+}
+
+// CountUnread returns how many messages of a user's inbox are neither read nor archived
+func (r *InboxRepository) CountUnread(ctx context.Context, tenantID int64, userID string) (int, error) {
+	return r.client.Notification.Query().
+		Where(inboxOf(tenantID, userID)...).
+		Where(
+			notification.ReadAtIsNil(),
+			notification.ArchivedAtIsNil(),
+		).
+		Count(ctx)
+}
+
+// MarkRead marks messages of a user's inbox read and returns the ones that were unread
+func (r *InboxRepository) MarkRead(ctx context.Context, tenantID int64, userID string, requestIDs []string) ([]*ent.Notification, error) {
+	unread, err := r.client.Notification.Query().
+		Where(inboxOf(tenantID, userID)...).
+		Where(
+			notification.RequestIDIn(requestIDs...),
+			notification.ReadAtIsNil(),
+		).
+		All(ctx)
+	if err != nil || len(unread) == 0 {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(unread))
+	for _, message := range unread {
+		ids = append(ids, message.ID)
+	}
+
+	err = r.client.Notification.Update().
+		Where(
+			notification.IDIn(ids...),
+			notification.ReadAtIsNil(),
+		).
+		SetReadAt(time.Now()).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return unread, nil
+}
+
+// MarkUnread marks messages of a user's inbox unread again and returns how many were read
+func (r *InboxRepository) MarkUnread(ctx context.Context, tenantID int64, userID string, requestIDs []string) (int, error) {
+	return r.client.Notification.Update().
+		Where(inboxOf(tenantID, userID)...).
+		Where(
+			notification.RequestIDIn(requestIDs...),
+			notification.ReadAtNotNil(),
+		).
+		ClearReadAt().
+		Save(ctx)
+}
+
+// Archive moves messages of a user's inbox to the archive and returns how many weren't archived yet
+func (r *InboxRepository) Archive(ctx context.Context, tenantID int64, userID string, requestIDs []string) (int, error) {
+	return r.client.Notification.Update().
+		Where(inboxOf(tenantID, userID)...).
+		Where(
+			notification.RequestIDIn(requestIDs...),
+			notification.ArchivedAtIsNil(),
+		).
+		SetArchivedAt(time.Now()).
+		Save(ctx)
+}
+
+// inboxOf selects the INAPP notifications sent to a user. Queued, scheduled and withheld ones
+// aren't in the inbox.
+func inboxOf(tenantID int64, userID string) []predicate.Notification {
+	return []predicate.Notification{
+		notification.TenantID(tenantID),
+		notification.TypeEQ(notification.TypeINAPP),
+		notification.AddressEQ(types.Address(userID)),
+		notification.StatusIn(
+			notification.StatusSENT,
+			notification.StatusDELIVERED,
+			notification.StatusOPENED,
+			notification.StatusCLICKED,
+		),
+	}
+}
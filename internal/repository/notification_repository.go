@@ -4,17 +4,40 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"entgo.io/ent/dialect/sql"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"gitlab.smartbet.am/golang/notification/ent"
 	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/ent/predicate"
 	"gitlab.smartbet.am/golang/notification/ent/schema"
 	"gitlab.smartbet.am/golang/notification/internal/models"
 	"gitlab.smartbet.am/golang/notification/types"
+	"time"
 )
 
+// ErrInvalidStatusTransition is returned when a notification can't move to the requested status
+var ErrInvalidStatusTransition = errors.New("invalid notification status transition")
+
+// StatusTransitionError describes a rejected status change
+type StatusTransitionError struct {
+	From notification.Status
+	To   notification.Status
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("%s: %s -> %s", ErrInvalidStatusTransition, e.From, e.To)
+}
+
+func (e *StatusTransitionError) Unwrap() error {
+	return ErrInvalidStatusTransition
+}
+
 type NotificationRepository struct {
 	client *ent.Client
 	logger *logrus.Logger
@@ -38,7 +61,8 @@ func (r *NotificationRepository) Create(ctx context.Context, req *models.Notific
 		SetType(notification.Type(req.Type)).
 		SetBody(req.Body).
 		SetAddress(types.Address(address)).
-		SetStatus(notification.StatusPENDING)
+		SetStatus(notification.StatusPENDING).
+		SetContentHash(contentHash(req, req.Type, address))
 
 	if req.Headline != "" {
 		create.SetHeadline(req.Headline)
@@ -58,42 +82,16 @@ func (r *NotificationRepository) Create(ctx context.Context, req *models.Notific
 	if req.BatchID != "" {
 		create.SetBatchID(req.BatchID)
 	}
-	if req.Meta != nil {
-		// Use the schema structs
-		meta := &schema.NotificationMeta{
-			Service:    req.Meta.Service,
-			TemplateID: req.Meta.TemplateID,
-			Params:     req.Meta.Params,
-			Data:       req.Meta.Data,
-		}
-		if req.Meta.Attachment != nil {
-			meta.Attachment = &schema.Attachment{
-				Filename:    req.Meta.Attachment.Filename,
-				Content:     req.Meta.Attachment.Content,
-				Disposition: req.Meta.Attachment.Disposition,
-				Type:        req.Meta.Attachment.Type,
-			}
-		}
-
-		// Add the original request_id to meta for tracking
-		if meta.Params == nil {
-			meta.Params = make(map[string]interface{})
-		}
-		meta.Params["original_request_id"] = req.RequestID
-		meta.Params["message_type"] = string(req.MessageType)
-
-		create.SetMeta(meta)
-	} else {
-		// Create meta with original request_id even if no other meta exists
-		meta := &schema.NotificationMeta{
-			Params: map[string]interface{}{
-				"original_request_id": req.RequestID,
-				"message_type":        string(req.MessageType),
-			},
-		}
-		create.SetMeta(meta)
+	if req.MessageType != "" {
+		create.SetMessageType(string(req.MessageType))
+	}
+	if len(req.Data) > 0 {
+		create.SetData(req.Data)
 	}
 
+	// Add the original request_id to meta for tracking
+	create.SetMeta(buildMeta(req))
+
 	return create.Save(ctx)
 }
 
@@ -103,64 +101,15 @@ func (r *NotificationRepository) CreateBatch(ctx context.Context, req *models.No
 	}
 
 	// Build the base meta once
-	var baseMeta *schema.NotificationMeta
-	if req.Meta != nil {
-		baseMeta = &schema.NotificationMeta{
-			Service:    req.Meta.Service,
-			TemplateID: req.Meta.TemplateID,
-			Params:     req.Meta.Params,
-			Data:       req.Meta.Data,
-		}
-		if req.Meta.Attachment != nil {
-			baseMeta.Attachment = &schema.Attachment{
-				Filename:    req.Meta.Attachment.Filename,
-				Content:     req.Meta.Attachment.Content,
-				Disposition: req.Meta.Attachment.Disposition,
-				Type:        req.Meta.Attachment.Type,
-			}
-		}
-	} else {
-		baseMeta = &schema.NotificationMeta{}
-	}
-
-	if baseMeta.Params == nil {
-		baseMeta.Params = make(map[string]interface{})
-	}
-	baseMeta.Params["original_request_id"] = req.RequestID
-	baseMeta.Params["message_type"] = string(req.MessageType)
+	baseMeta := buildMeta(req)
 
 	builders := make([]*ent.NotificationCreate, 0, len(req.Recipients))
 
 	for _, recipient := range req.Recipients {
-		uniqueRequestID := uuid.New().String()
-
-		create := r.client.Notification.Create().
-			SetRequestID(uniqueRequestID).
-			SetTenantID(req.TenantID).
-			SetType(notification.Type(req.Type)).
-			SetBody(req.Body).
-			SetAddress(types.Address(recipient)).
-			SetStatus(notification.StatusPENDING).
-			SetMeta(baseMeta)
-
-		if req.Headline != "" {
-			create.SetHeadline(req.Headline)
-		}
-		if req.From != "" {
-			create.SetFrom(req.From)
-		}
-		if req.ReplyTo != "" {
-			create.SetReplyTo(req.ReplyTo)
-		}
-		if req.Tag != "" {
-			create.SetTag(req.Tag)
-		}
+		create := r.newNotification(req, req.Type, recipient, baseMeta)
 		if req.ScheduleTS != nil {
 			create.SetScheduleTs(*req.ScheduleTS)
 		}
-		if req.BatchID != "" {
-			create.SetBatchID(req.BatchID)
-		}
 
 		builders = append(builders, create)
 	}
@@ -179,6 +128,85 @@ func (r *NotificationRepository) CreateBatch(ctx context.Context, req *models.No
 	return notifications, nil
 }
 
+// CascadeNotification is a notification of a cascade step to create
+type CascadeNotification struct {
+	CascadeID string
+	Step      int
+	Type      models.NotificationType
+	Address   string
+	// ScheduleTS is nil for notifications sent right away
+	ScheduleTS *int64
+}
+
+// CreateCascade stores the notifications of the cascades of a request
+func (r *NotificationRepository) CreateCascade(ctx context.Context, req *models.NotificationRequest, entries []*CascadeNotification) ([]*ent.Notification, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no recipients provided")
+	}
+
+	baseMeta := buildMeta(req)
+
+	builders := make([]*ent.NotificationCreate, 0, len(entries))
+	for _, entry := range entries {
+		create := r.newNotification(req, entry.Type, entry.Address, baseMeta).
+			SetCascadeID(entry.CascadeID).
+			SetCascadeStep(entry.Step)
+		if entry.ScheduleTS != nil {
+			create.SetScheduleTs(*entry.ScheduleTS)
+		}
+
+		builders = append(builders, create)
+	}
+
+	notifications, err := r.client.Notification.CreateBulk(builders...).Save(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bulk create cascade notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// newNotification prepares a pending notification of the request to an address, each with its own request ID
+func (r *NotificationRepository) newNotification(req *models.NotificationRequest, notifType models.NotificationType, address string, meta *schema.NotificationMeta) *ent.NotificationCreate {
+	create := r.client.Notification.Create().
+		SetRequestID(uuid.New().String()).
+		SetTenantID(req.TenantID).
+		SetType(notification.Type(notifType)).
+		SetBody(req.Body).
+		SetAddress(types.Address(address)).
+		SetStatus(notification.StatusPENDING).
+		SetContentHash(contentHash(req, notifType, address)).
+		SetMeta(meta)
+
+	if req.Headline != "" {
+		create.SetHeadline(req.Headline)
+	}
+	if req.From != "" {
+		create.SetFrom(req.From)
+	}
+	if req.ReplyTo != "" {
+		create.SetReplyTo(req.ReplyTo)
+	}
+	if req.Tag != "" {
+		create.SetTag(req.Tag)
+	}
+	if req.BatchID != "" {
+		create.SetBatchID(req.BatchID)
+	}
+	if req.MessageType != "" {
+		create.SetMessageType(string(req.MessageType))
+	}
+	if len(req.Data) > 0 {
+		create.SetData(req.Data)
+	}
+
+	return create
+}
+
+func (r *NotificationRepository) GetByID(ctx context.Context, id int) (*ent.Notification, error) {
+	return r.client.Notification.Get(ctx, id)
+}
+
 func (r *NotificationRepository) GetByRequestID(ctx context.Context, requestID string) (*ent.Notification, error) {
 	return r.client.Notification.Query().
 		Where(notification.RequestID(requestID)).
@@ -232,15 +260,287 @@ func (r *NotificationRepository) GetPendingScheduled(ctx context.Context, timest
 		All(ctx)
 }
 
+// UpdateStatus moves a notification to a new status and records when it got there. The update
+// only applies when the transition is allowed from the current status, otherwise
+// ErrInvalidStatusTransition is returned and the notification is left as it is.
 func (r *NotificationRepository) UpdateStatus(ctx context.Context, id int, status notification.Status, errorMsg *string) error {
-	update := r.client.Notification.UpdateOneID(id).
+	// The allowed previous statuses are part of the update, so concurrent updates can't regress the status
+	update := r.client.Notification.Update().
+		Where(
+			notification.ID(id),
+			notification.StatusIn(previousStatuses(status)...),
+		).
 		SetStatus(status)
 
 	if errorMsg != nil {
 		update.SetErrorMessage(*errorMsg)
 	}
 
-	return update.Exec(ctx)
+	now := time.Now()
+	switch status {
+	case notification.StatusSENT:
+		update.SetSentAt(now)
+	case notification.StatusDELIVERED:
+		update.SetDeliveredAt(now)
+	case notification.StatusBOUNCED:
+		update.SetBouncedAt(now)
+	case notification.StatusUNDELIVERED:
+		update.SetUndeliveredAt(now)
+	case notification.StatusOPENED:
+		update.SetOpenedAt(now)
+	case notification.StatusCLICKED:
+		update.SetClickedAt(now)
+	case notification.StatusFAILED:
+		update.SetFailedAt(now)
+	case notification.StatusCANCEL:
+		update.SetCancelledAt(now)
+	case notification.StatusSUPPRESSED:
+		update.SetSuppressedAt(now)
+	case notification.StatusCAPPED:
+		update.SetCappedAt(now)
+	case notification.StatusDUPLICATE:
+		update.SetDuplicatedAt(now)
+	case notification.StatusHELD:
+		update.SetHeldAt(now)
+	case notification.StatusDIGESTED:
+		update.SetDigestedAt(now)
+	}
+
+	updated, err := update.Save(ctx)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return r.transitionError(ctx, id, status)
+	}
+
+	return nil
+}
+
+// previousStatuses returns the statuses a notification may reach the status from
+func previousStatuses(status notification.Status) []notification.Status {
+	var previous []notification.Status
+	for _, from := range models.NotificationStatus(status).PreviousStatuses() {
+		previous = append(previous, notification.Status(from))
+	}
+	return previous
+}
+
+// transitionError reports that a notification couldn't move to the status from its current one
+func (r *NotificationRepository) transitionError(ctx context.Context, id int, status notification.Status) error {
+	current, err := r.client.Notification.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return &StatusTransitionError{From: current.Status, To: status}
+}
+
+// DigestGroup identifies the held notifications that go out in one digest
+type DigestGroup struct {
+	TenantID  int64  `json:"tenant_id"`
+	DigestKey string `json:"digest_key"`
+}
+
+// HoldForDigest marks a notification HELD under a digest key until the digest is due
+func (r *NotificationRepository) HoldForDigest(ctx context.Context, id int, digestKey string, dueAt time.Time) error {
+	updated, err := r.client.Notification.Update().
+		Where(
+			notification.ID(id),
+			notification.StatusIn(previousStatuses(notification.StatusHELD)...),
+		).
+		SetStatus(notification.StatusHELD).
+		SetHeldAt(time.Now()).
+		SetDigestKey(digestKey).
+		SetDigestDueAt(dueAt).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return r.transitionError(ctx, id, notification.StatusHELD)
+	}
+	return nil
+}
+
+// GetHeldForDigest returns the notifications held under a digest key, the earliest due first
+func (r *NotificationRepository) GetHeldForDigest(ctx context.Context, tenantID int64, digestKey string) ([]*ent.Notification, error) {
+	return r.client.Notification.Query().
+		Where(
+			notification.TenantID(tenantID),
+			notification.DigestKey(digestKey),
+			notification.StatusEQ(notification.StatusHELD),
+		).
+		Order(ent.Asc(notification.FieldDigestDueAt), ent.Asc(notification.FieldID)).
+		Select(notification.FieldID, notification.FieldDigestDueAt).
+		All(ctx)
+}
+
+// GetDueDigests returns the digests with held notifications due by the given time
+func (r *NotificationRepository) GetDueDigests(ctx context.Context, now time.Time) ([]*DigestGroup, error) {
+	var groups []*DigestGroup
+	err := r.client.Notification.Query().
+		Where(
+			notification.StatusEQ(notification.StatusHELD),
+			notification.DigestDueAtLTE(now),
+		).
+		GroupBy(notification.FieldTenantID, notification.FieldDigestKey).
+		Scan(ctx, &groups)
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// ClaimDigest marks the notifications held under a digest key DIGESTED into the digest with the
+// request ID and returns them in the order they were created. Notifications claimed by a
+// concurrent digest are left out.
+func (r *NotificationRepository) ClaimDigest(ctx context.Context, tenantID int64, digestKey, digestRequestID string) ([]*ent.Notification, error) {
+	_, err := r.client.Notification.Update().
+		Where(
+			notification.TenantID(tenantID),
+			notification.DigestKey(digestKey),
+			notification.StatusEQ(notification.StatusHELD),
+		).
+		SetStatus(notification.StatusDIGESTED).
+		SetDigestedAt(time.Now()).
+		SetDigestRequestID(digestRequestID).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.client.Notification.Query().
+		Where(notification.DigestRequestID(digestRequestID)).
+		Order(ent.Asc(notification.FieldID)).
+		All(ctx)
+}
+
+// CreateDigest stores the digest of held notifications, addressed like the first of them
+func (r *NotificationRepository) CreateDigest(ctx context.Context, items []*ent.Notification, requestID, headline, body string) (*ent.Notification, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no notifications to digest")
+	}
+	first := items[0]
+
+	create := r.client.Notification.Create().
+		SetRequestID(requestID).
+		SetTenantID(first.TenantID).
+		SetType(first.Type).
+		SetBody(body).
+		SetAddress(first.Address).
+		SetStatus(notification.StatusPENDING).
+		SetDigestKey(first.DigestKey).
+		SetMessageType(first.MessageType)
+
+	if headline != "" {
+		create.SetHeadline(headline)
+	}
+	if first.Name != "" {
+		create.SetName(first.Name)
+	}
+	if first.From != "" {
+		create.SetFrom(first.From)
+	}
+	if first.ReplyTo != "" {
+		create.SetReplyTo(first.ReplyTo)
+	}
+	if first.Tag != "" {
+		create.SetTag(first.Tag)
+	}
+	if first.Meta != nil && first.Meta.Service != "" {
+		create.SetMeta(&schema.NotificationMeta{Service: first.Meta.Service})
+	}
+
+	return create.Save(ctx)
+}
+
+// GetCascadesByRequestID returns the notifications of the cascades of a request, ordered by cascade and step
+func (r *NotificationRepository) GetCascadesByRequestID(ctx context.Context, requestID string) ([]*ent.Notification, error) {
+	return r.client.Notification.Query().
+		Where(notification.CascadeIDHasPrefix(requestID+":")).
+		Order(
+			ent.Asc(notification.FieldCascadeID),
+			ent.Asc(notification.FieldCascadeStep),
+			ent.Asc(notification.FieldID),
+		).
+		All(ctx)
+}
+
+// CountUnsettledCascadeStep counts the notifications of a cascade step that may still be delivered
+func (r *NotificationRepository) CountUnsettledCascadeStep(ctx context.Context, cascadeID string, step int) (int, error) {
+	return r.client.Notification.Query().
+		Where(
+			notification.CascadeID(cascadeID),
+			notification.CascadeStep(step),
+			notification.StatusNotIn(
+				notification.StatusFAILED,
+				notification.StatusSUPPRESSED,
+				notification.StatusCAPPED,
+				notification.StatusDUPLICATE,
+				notification.StatusUNDELIVERED,
+				notification.StatusBOUNCED,
+				notification.StatusCANCEL,
+			),
+		).
+		Count(ctx)
+}
+
+// CancelCascadeAfter cancels the pending notifications of the steps after the given step of a cascade
+func (r *NotificationRepository) CancelCascadeAfter(ctx context.Context, cascadeID string, step int, reason string) (int, error) {
+	return r.client.Notification.Update().
+		Where(
+			notification.CascadeID(cascadeID),
+			notification.CascadeStepGT(step),
+			notification.StatusEQ(notification.StatusPENDING),
+		).
+		SetStatus(notification.StatusCANCEL).
+		SetErrorMessage(reason).
+		SetCancelledAt(time.Now()).
+		Save(ctx)
+}
+
+// AdvanceCascadeAfter moves the pending steps after the given step of a cascade forward so the
+// next one is due at the given time, keeping the timeouts between the later steps. Nothing
+// moves once a later step was picked up.
+func (r *NotificationRepository) AdvanceCascadeAfter(ctx context.Context, cascadeID string, step int, now int64) (int, error) {
+	started, err := r.client.Notification.Query().
+		Where(
+			notification.CascadeID(cascadeID),
+			notification.CascadeStepGT(step),
+			notification.StatusNotIn(notification.StatusPENDING, notification.StatusCANCEL),
+		).
+		Exist(ctx)
+	if err != nil || started {
+		return 0, err
+	}
+
+	later := []predicate.Notification{
+		notification.CascadeID(cascadeID),
+		notification.CascadeStepGT(step),
+		notification.StatusEQ(notification.StatusPENDING),
+		notification.ScheduleTsNotNil(),
+	}
+
+	next, err := r.client.Notification.Query().
+		Where(later...).
+		Order(ent.Asc(notification.FieldScheduleTs)).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	shift := *next.ScheduleTs - now
+	if shift <= 0 {
+		return 0, nil
+	}
+
+	return r.client.Notification.Update().
+		Where(later...).
+		AddScheduleTs(-shift).
+		Save(ctx)
 }
 
 func (r *NotificationRepository) GetByTenantAndStatus(ctx context.Context, tenantID int64, status notification.Status, limit int) ([]*ent.Notification, error) {
@@ -259,3 +559,205 @@ func (r *NotificationRepository) GetByBatchID(ctx context.Context, batchID strin
 		Where(notification.BatchID(batchID)).
 		All(ctx)
 }
+
+// MessageTypeStatusCount is the number of notifications of a message type in a status
+type MessageTypeStatusCount struct {
+	MessageType string              `json:"message_type"`
+	Status      notification.Status `json:"status"`
+	Count       int                 `json:"count"`
+}
+
+// CountByMessageTypeAndStatus counts a tenant's notifications created within the given window
+// by message type and status, optionally only of one notification type
+func (r *NotificationRepository) CountByMessageTypeAndStatus(ctx context.Context, tenantID int64, notifType *notification.Type, from, to time.Time) ([]*MessageTypeStatusCount, error) {
+	query := r.client.Notification.Query().
+		Where(
+			notification.TenantID(tenantID),
+			notification.CreateTimeGTE(from),
+			notification.CreateTimeLT(to),
+		)
+
+	if notifType != nil {
+		query.Where(notification.TypeEQ(*notifType))
+	}
+
+	var counts []*MessageTypeStatusCount
+	err := query.
+		GroupBy(notification.FieldMessageType, notification.FieldStatus).
+		Aggregate(ent.Count()).
+		Scan(ctx, &counts)
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// CountSentByAddress counts the notifications of a message type the tenant sent to each of the
+// addresses on a channel since the given time, keyed by normalized address
+func (r *NotificationRepository) CountSentByAddress(ctx context.Context, tenantID int64, notifType notification.Type, messageType models.MessageType, addresses []string, since time.Time) (map[string]int, error) {
+	counts := make(map[string]int, len(addresses))
+	for start := 0; start < len(addresses); start += lookupChunkSize {
+		end := min(start+lookupChunkSize, len(addresses))
+
+		chunk := make([]types.Address, 0, end-start)
+		for _, address := range addresses[start:end] {
+			chunk = append(chunk, types.Address(address))
+		}
+
+		var rows []struct {
+			Address types.Address `json:"address"`
+			Count   int           `json:"count"`
+		}
+		err := r.client.Notification.Query().
+			Where(
+				notification.TenantID(tenantID),
+				notification.TypeEQ(notifType),
+				notification.MessageType(string(messageType)),
+				notification.SentAtGTE(since),
+				notification.AddressIn(chunk...),
+			).
+			GroupBy(notification.FieldAddress).
+			Aggregate(ent.Count()).
+			Scan(ctx, &rows)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			counts[NormalizeAddress(string(row.Address))] += row.Count
+		}
+	}
+
+	return counts, nil
+}
+
+// GetRecentByContentHash returns the tenant's notifications with one of the content hashes
+// created since the given time that are being sent or were sent. Pending, held and skipped
+// notifications may never reach the recipient and aren't returned.
+func (r *NotificationRepository) GetRecentByContentHash(ctx context.Context, tenantID int64, hashes []string, since time.Time) ([]*ent.Notification, error) {
+	var recent []*ent.Notification
+	for start := 0; start < len(hashes); start += lookupChunkSize {
+		end := min(start+lookupChunkSize, len(hashes))
+
+		entries, err := r.client.Notification.Query().
+			Where(
+				notification.TenantID(tenantID),
+				notification.ContentHashIn(hashes[start:end]...),
+				notification.CreateTimeGTE(since),
+				notification.StatusIn(
+					notification.StatusACTIVE,
+					notification.StatusSENT,
+					notification.StatusDELIVERED,
+					notification.StatusBOUNCED,
+					notification.StatusUNDELIVERED,
+					notification.StatusOPENED,
+					notification.StatusCLICKED,
+					notification.StatusDIGESTED,
+				),
+			).
+			Select(
+				notification.FieldID,
+				notification.FieldRequestID,
+				notification.FieldContentHash,
+				notification.FieldCreateTime,
+			).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		recent = append(recent, entries...)
+	}
+
+	return recent, nil
+}
+
+// contentHash identifies what a notification of the request to an address says, so the same
+// content sent twice can be recognized regardless of request IDs
+func contentHash(req *models.NotificationRequest, notifType models.NotificationType, address string) string {
+	content := struct {
+		Type       models.NotificationType `json:"type"`
+		Address    string                  `json:"address"`
+		Headline   string                  `json:"headline"`
+		Body       string                  `json:"body"`
+		TemplateID string                  `json:"template_id,omitempty"`
+		Params     map[string]interface{}  `json:"params,omitempty"`
+		Data       map[string]interface{}  `json:"data,omitempty"`
+		MetaData   json.RawMessage         `json:"meta_data,omitempty"`
+	}{
+		Type:     notifType,
+		Address:  NormalizeAddress(address),
+		Headline: req.Headline,
+		Body:     req.Body,
+		Data:     req.Data,
+	}
+
+	if req.Meta != nil {
+		content.TemplateID = req.Meta.TemplateID
+		content.MetaData = req.Meta.Data
+
+		// The original request ID differs between repeated requests
+		content.Params = make(map[string]interface{}, len(req.Meta.Params))
+		for key, value := range req.Meta.Params {
+			if key != "original_request_id" {
+				content.Params[key] = value
+			}
+		}
+	}
+
+	// Map keys are marshaled in order, so equal content hashes equally
+	encoded, _ := json.Marshal(content)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// buildMeta converts the request meta into its stored form and adds the
+// tracking params shared by every notification created from the request
+func buildMeta(req *models.NotificationRequest) *schema.NotificationMeta {
+	meta := &schema.NotificationMeta{}
+	if req.Meta != nil {
+		meta.Service = req.Meta.Service
+		meta.TemplateID = req.Meta.TemplateID
+		meta.Params = req.Meta.Params
+		meta.Data = req.Meta.Data
+		meta.TextBody = req.Meta.TextBody
+		meta.Headers = req.Meta.Headers
+		if req.Meta.Attachment != nil {
+			meta.Attachment = ToSchemaAttachment(req.Meta.Attachment)
+		}
+		for _, attachment := range req.Meta.Attachments {
+			// Requests published to Kafka directly skip the handler validation
+			if attachment != nil {
+				meta.Attachments = append(meta.Attachments, ToSchemaAttachment(attachment))
+			}
+		}
+	}
+
+	for _, attachment := range req.Attachments {
+		if attachment != nil {
+			meta.Attachments = append(meta.Attachments, ToSchemaAttachment(attachment))
+		}
+	}
+
+	if meta.Params == nil {
+		meta.Params = make(map[string]interface{})
+	}
+	meta.Params["original_request_id"] = req.RequestID
+	meta.Params["message_type"] = string(req.MessageType)
+
+	return meta
+}
+
+// ToSchemaAttachment converts a request attachment into the attachment stored with the notification
+func ToSchemaAttachment(attachment *models.Attachment) *schema.Attachment {
+	return &schema.Attachment{
+		Filename:    attachment.Filename,
+		Content:     attachment.Content,
+		URL:         attachment.URL,
+		Bucket:      attachment.Bucket,
+		ObjectKey:   attachment.ObjectKey,
+		Disposition: attachment.Disposition,
+		Type:        attachment.Type,
+		ContentID:   attachment.ContentID,
+	}
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/enttest"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// newTestClient opens an ent client on an in-memory SQLite database private to the test
+func newTestClient(t *testing.T) *ent.Client {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	client := enttest.Open(t, "sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared&_fk=1", name))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestUpdateStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    []notification.Status
+		next    notification.Status
+		wantErr bool
+	}{
+		{name: "sent", next: notification.StatusSENT},
+		{name: "delivered after sent", path: []notification.Status{notification.StatusSENT}, next: notification.StatusDELIVERED},
+		{name: "clicked after opened", path: []notification.Status{notification.StatusSENT, notification.StatusOPENED}, next: notification.StatusCLICKED},
+		{name: "delivered after opened", path: []notification.Status{notification.StatusSENT, notification.StatusOPENED}, next: notification.StatusDELIVERED, wantErr: true},
+		{name: "sent after delivered", path: []notification.Status{notification.StatusSENT, notification.StatusDELIVERED}, next: notification.StatusSENT, wantErr: true},
+		{name: "delivered before sent", next: notification.StatusDELIVERED, wantErr: true},
+		{name: "sent after failed", path: []notification.Status{notification.StatusFAILED}, next: notification.StatusSENT, wantErr: true},
+		{name: "sent after suppressed", path: []notification.Status{notification.StatusSUPPRESSED}, next: notification.StatusSENT, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := NewNotificationRepository(newTestClient(t), logrus.New())
+
+			notif, err := repo.Create(ctx, &models.NotificationRequest{TenantID: 1, Type: models.TypeSMS, Body: "Hello"}, "+37499123456")
+			if err != nil {
+				t.Fatalf("failed to create notification: %v", err)
+			}
+			for _, status := range tt.path {
+				if err := repo.UpdateStatus(ctx, notif.ID, status, nil); err != nil {
+					t.Fatalf("failed to move notification to %s: %v", status, err)
+				}
+			}
+			current := notification.StatusPENDING
+			if len(tt.path) > 0 {
+				current = tt.path[len(tt.path)-1]
+			}
+
+			errorMsg := "provider report"
+			err = repo.UpdateStatus(ctx, notif.ID, tt.next, &errorMsg)
+
+			updated, getErr := repo.GetByID(ctx, notif.ID)
+			if getErr != nil {
+				t.Fatalf("failed to load notification: %v", getErr)
+			}
+
+			if tt.wantErr {
+				var transitionErr *StatusTransitionError
+				if !errors.Is(err, ErrInvalidStatusTransition) || !errors.As(err, &transitionErr) {
+					t.Fatalf("UpdateStatus() error = %v, want a status transition error", err)
+				}
+				if transitionErr.From != current || transitionErr.To != tt.next {
+					t.Errorf("transition error = %s -> %s, want %s -> %s", transitionErr.From, transitionErr.To, current, tt.next)
+				}
+				if updated.Status != current {
+					t.Errorf("status = %s, want it left at %s", updated.Status, current)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("UpdateStatus() error = %v", err)
+			}
+			if updated.Status != tt.next {
+				t.Errorf("status = %s, want %s", updated.Status, tt.next)
+			}
+			if updated.ErrorMessage == nil || *updated.ErrorMessage != errorMsg {
+				t.Errorf("error message = %v, want %q", updated.ErrorMessage, errorMsg)
+			}
+		})
+	}
+}
+
+func TestUpdateStatusTimestamps(t *testing.T) {
+	ctx := context.Background()
+	repo := NewNotificationRepository(newTestClient(t), logrus.New())
+
+	notif, err := repo.Create(ctx, &models.NotificationRequest{TenantID: 1, Type: models.TypeEmail, Body: "Hello"}, "player@example.org")
+	if err != nil {
+		t.Fatalf("failed to create notification: %v", err)
+	}
+	for _, status := range []notification.Status{notification.StatusSENT, notification.StatusDELIVERED, notification.StatusOPENED} {
+		if err := repo.UpdateStatus(ctx, notif.ID, status, nil); err != nil {
+			t.Fatalf("failed to move notification to %s: %v", status, err)
+		}
+	}
+
+	updated, err := repo.GetByID(ctx, notif.ID)
+	if err != nil {
+		t.Fatalf("failed to load notification: %v", err)
+	}
+	if updated.SentAt == nil || updated.DeliveredAt == nil || updated.OpenedAt == nil {
+		t.Errorf("sent_at, delivered_at and opened_at must be set, got %v, %v, %v", updated.SentAt, updated.DeliveredAt, updated.OpenedAt)
+	}
+	if updated.ClickedAt != nil || updated.FailedAt != nil {
+		t.Errorf("clicked_at and failed_at must stay empty")
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	base := func() *models.NotificationRequest {
+		return &models.NotificationRequest{
+			RequestID: "req-1",
+			Headline:  "Deposit",
+			Body:      "Deposit received",
+			Meta: &models.NotificationMeta{
+				TemplateID: "deposit",
+				Params:     map[string]interface{}{"amount": 100, "original_request_id": "req-1"},
+			},
+		}
+	}
+	want := contentHash(base(), models.TypeSMS, "+37499000001")
+
+	tests := []struct {
+		name    string
+		modify  func(req *models.NotificationRequest)
+		channel models.NotificationType
+		address string
+		same    bool
+	}{
+		{name: "other request ID", modify: func(req *models.NotificationRequest) {
+			req.RequestID = "req-2"
+			req.Meta.Params["original_request_id"] = "req-2"
+		}, same: true},
+		{name: "address formatting", address: " +37499000001 ", same: true},
+		{name: "other body", modify: func(req *models.NotificationRequest) { req.Body = "Withdrawal sent" }},
+		{name: "other params", modify: func(req *models.NotificationRequest) { req.Meta.Params["amount"] = 200 }},
+		{name: "other template", modify: func(req *models.NotificationRequest) { req.Meta.TemplateID = "withdrawal" }},
+		{name: "other address", address: "+37499000002"},
+		{name: "other channel", channel: models.TypeEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := base()
+			if tt.modify != nil {
+				tt.modify(req)
+			}
+			channel, address := tt.channel, tt.address
+			if channel == "" {
+				channel = models.TypeSMS
+			}
+			if address == "" {
+				address = "+37499000001"
+			}
+			if got := contentHash(req, channel, address); (got == want) != tt.same {
+				t.Errorf("contentHash() equal = %v, want %v", got == want, tt.same)
+			}
+		})
+	}
+}
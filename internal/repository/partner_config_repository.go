@@ -39,6 +39,22 @@ func (r *PartnerConfigRepository) GetByTenantID(ctx context.Context, tenantID in
 	return r.entToModel(config), nil
 }
 
+// GetAllEnabled returns the stored configurations of all enabled tenants
+func (r *PartnerConfigRepository) GetAllEnabled(ctx context.Context) ([]*models.PartnerConfig, error) {
+	configs, err := r.client.PartnerConfig.Query().
+		Where(partnerconfig.Enabled(true)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*models.PartnerConfig, 0, len(configs))
+	for _, config := range configs {
+		result = append(result, r.entToModel(config))
+	}
+	return result, nil
+}
+
 func (r *PartnerConfigRepository) Save(ctx context.Context, config *models.PartnerConfig) error {
 	// Check if exists
 	exists, err := r.client.PartnerConfig.Query().
@@ -56,8 +72,15 @@ func (r *PartnerConfigRepository) Save(ctx context.Context, config *models.Partn
 			SetEmailProviders(config.EmailProviders).
 			SetSmsProviders(config.SMSProviders).
 			SetPushProviders(config.PushProviders).
+			SetTelegramProviders(config.TelegramProviders).
+			SetViberProviders(config.ViberProviders).
+			SetWhatsappProviders(config.WhatsAppProviders).
+			SetWhatsappProviders(config.WhatsAppProviders).
 			SetBatchConfig(config.BatchConfig).
 			SetRateLimits(config.RateLimits).
+			SetFrequencyCaps(config.FrequencyCaps).
+			SetDedupWindows(config.DedupWindows).
+			SetDigestPolicies(config.DigestPolicies).
 			SetEnabled(config.Enabled).
 			Exec(ctx)
 	}
@@ -69,8 +92,14 @@ func (r *PartnerConfigRepository) Save(ctx context.Context, config *models.Partn
 		SetEmailProviders(config.EmailProviders).
 		SetSmsProviders(config.SMSProviders).
 		SetPushProviders(config.PushProviders).
+		SetTelegramProviders(config.TelegramProviders).
+		SetViberProviders(config.ViberProviders).
+		SetWhatsappProviders(config.WhatsAppProviders).
 		SetBatchConfig(config.BatchConfig).
 		SetRateLimits(config.RateLimits).
+		SetFrequencyCaps(config.FrequencyCaps).
+		SetDedupWindows(config.DedupWindows).
+		SetDigestPolicies(config.DigestPolicies).
 		SetEnabled(config.Enabled).
 		Save(ctx)
 
@@ -79,16 +108,22 @@ func (r *PartnerConfigRepository) Save(ctx context.Context, config *models.Partn
 
 func (r *PartnerConfigRepository) entToModel(config *ent.PartnerConfig) *models.PartnerConfig {
 	return &models.PartnerConfig{
-		ID:             config.ID,
-		TenantID:       config.TenantID,
-		EmailProviders: config.EmailProviders,
-		SMSProviders:   config.SmsProviders,
-		PushProviders:  config.PushProviders,
-		BatchConfig:    config.BatchConfig,
-		RateLimits:     config.RateLimits,
-		Enabled:        config.Enabled,
-		CreatedAt:      config.CreateTime,
-		UpdatedAt:      config.UpdateTime,
+		ID:                config.ID,
+		TenantID:          config.TenantID,
+		EmailProviders:    config.EmailProviders,
+		SMSProviders:      config.SmsProviders,
+		PushProviders:     config.PushProviders,
+		TelegramProviders: config.TelegramProviders,
+		ViberProviders:    config.ViberProviders,
+		WhatsAppProviders: config.WhatsappProviders,
+		BatchConfig:       config.BatchConfig,
+		RateLimits:        config.RateLimits,
+		FrequencyCaps:     config.FrequencyCaps,
+		DedupWindows:      config.DedupWindows,
+		DigestPolicies:    config.DigestPolicies,
+		Enabled:           config.Enabled,
+		CreatedAt:         config.CreateTime,
+		UpdatedAt:         config.UpdateTime,
 	}
 }
 
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/predicate"
+	"gitlab.smartbet.am/golang/notification/ent/preference"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+type PreferenceRepository struct {
+	client *ent.Client
+	logger *logrus.Logger
+}
+
+func NewPreferenceRepository(client *ent.Client, logger *logrus.Logger) *PreferenceRepository {
+	return &PreferenceRepository{
+		client: client,
+		logger: logger,
+	}
+}
+
+// GetByRecipient returns the stored preferences of a recipient, known by user ID, address or both
+func (r *PreferenceRepository) GetByRecipient(ctx context.Context, tenantID int64, userID, address string) ([]*ent.Preference, error) {
+	return r.client.Preference.Query().
+		Where(
+			preference.TenantID(tenantID),
+			preference.UserID(userID),
+			preference.Address(NormalizeAddress(address)),
+		).
+		All(ctx)
+}
+
+// Set stores whether a recipient receives a message type on a channel
+func (r *PreferenceRepository) Set(ctx context.Context, tenantID int64, userID, address string, pref *models.ChannelPreference, source string) error {
+	address = NormalizeAddress(address)
+	channel := preference.Channel(pref.Channel)
+
+	existing, err := r.client.Preference.Query().
+		Where(
+			preference.TenantID(tenantID),
+			preference.UserID(userID),
+			preference.Address(address),
+			preference.ChannelEQ(channel),
+			preference.MessageType(string(pref.MessageType)),
+		).
+		First(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		return err
+	}
+
+	if existing != nil {
+		return existing.Update().
+			SetOptedIn(pref.OptedIn).
+			SetSource(source).
+			Exec(ctx)
+	}
+
+	return r.client.Preference.Create().
+		SetTenantID(tenantID).
+		SetUserID(userID).
+		SetAddress(address).
+		SetChannel(channel).
+		SetMessageType(string(pref.MessageType)).
+		SetOptedIn(pref.OptedIn).
+		SetSource(source).
+		Exec(ctx)
+}
+
+// GetOptedOut returns the normalized addresses among the given ones that opted out of the
+// message type on the channel. users maps normalized addresses to the users having them, whose
+// preferences stored without an address cover all of their addresses. When an address has
+// several preferences, e.g. one stored together with a user ID and one without, the latest
+// change wins.
+func (r *PreferenceRepository) GetOptedOut(ctx context.Context, tenantID int64, channel models.NotificationType, messageType models.MessageType, addresses []string, users map[string][]string) (map[string]bool, error) {
+	normalized := make([]string, 0, len(addresses))
+	var userIDs []string
+	for _, address := range addresses {
+		address = NormalizeAddress(address)
+		normalized = append(normalized, address)
+		userIDs = append(userIDs, users[address]...)
+	}
+
+	query := func(predicate predicate.Preference) ([]*ent.Preference, error) {
+		return r.client.Preference.Query().
+			Where(
+				preference.TenantID(tenantID),
+				predicate,
+				preference.ChannelEQ(preference.Channel(channel)),
+				preference.MessageType(string(messageType)),
+			).
+			All(ctx)
+	}
+
+	byAddress := make(map[string][]*ent.Preference)
+	for start := 0; start < len(normalized); start += lookupChunkSize {
+		end := min(start+lookupChunkSize, len(normalized))
+
+		entries, err := query(preference.AddressIn(normalized[start:end]...))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			byAddress[entry.Address] = append(byAddress[entry.Address], entry)
+		}
+	}
+
+	userIDs = UniqueUserIDs(userIDs)
+	byUser := make(map[string][]*ent.Preference)
+	for start := 0; start < len(userIDs); start += lookupChunkSize {
+		end := min(start+lookupChunkSize, len(userIDs))
+
+		entries, err := query(preference.And(
+			preference.UserIDIn(userIDs[start:end]...),
+			preference.Address(""),
+		))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			byUser[entry.UserID] = append(byUser[entry.UserID], entry)
+		}
+	}
+
+	optedOut := make(map[string]bool)
+	for _, address := range normalized {
+		entries := byAddress[address]
+		for _, userID := range users[address] {
+			entries = append(entries, byUser[userID]...)
+		}
+
+		var latest *ent.Preference
+		for _, entry := range entries {
+			if latest == nil || entry.UpdateTime.After(latest.UpdateTime) {
+				latest = entry
+			}
+		}
+		if latest != nil && !latest.OptedIn {
+			optedOut[address] = true
+		}
+	}
+	return optedOut, nil
+}
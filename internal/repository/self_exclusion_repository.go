@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/selfexclusion"
+	"gitlab.smartbet.am/golang/notification/ent/selfexclusionblock"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+type SelfExclusionRepository struct {
+	client *ent.Client
+	logger *logrus.Logger
+}
+
+func NewSelfExclusionRepository(client *ent.Client, logger *logrus.Logger) *SelfExclusionRepository {
+	return &SelfExclusionRepository{
+		client: client,
+		logger: logger,
+	}
+}
+
+// Sync stores the self-exclusion of every address in the request, replacing the period of
+// existing exclusions. Existing exclusions keep their start unless the request has one.
+func (r *SelfExclusionRepository) Sync(ctx context.Context, tenantID int64, req *models.SelfExclusionRequest, source string) ([]*ent.SelfExclusion, error) {
+	seen := make(map[string]bool, len(req.Addresses))
+	entries := make([]*ent.SelfExclusion, 0, len(req.Addresses))
+	for _, address := range req.Addresses {
+		address = exclusionAddress(address)
+		if address == "" || seen[address] {
+			continue
+		}
+		seen[address] = true
+
+		existing, err := r.client.SelfExclusion.Query().
+			Where(
+				selfexclusion.TenantID(tenantID),
+				selfexclusion.Address(address),
+			).
+			First(ctx)
+		if err != nil && !ent.IsNotFound(err) {
+			return nil, err
+		}
+
+		var entry *ent.SelfExclusion
+		if existing != nil {
+			update := existing.Update().
+				SetUserID(req.UserID).
+				SetSource(source).
+				SetDetail(req.Detail)
+			if req.StartsAt != nil {
+				update.SetStartsAt(*req.StartsAt)
+			}
+			if req.EndsAt != nil {
+				update.SetEndsAt(*req.EndsAt)
+			} else {
+				update.ClearEndsAt()
+			}
+			entry, err = update.Save(ctx)
+		} else {
+			startsAt := time.Now()
+			if req.StartsAt != nil {
+				startsAt = *req.StartsAt
+			}
+			entry, err = r.client.SelfExclusion.Create().
+				SetTenantID(tenantID).
+				SetUserID(req.UserID).
+				SetAddress(address).
+				SetStartsAt(startsAt).
+				SetNillableEndsAt(req.EndsAt).
+				SetSource(source).
+				SetDetail(req.Detail).
+				Save(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// GetActive returns the self-exclusions in effect for the given addresses, keyed by normalized address.
+// Phone numbers match in any format.
+func (r *SelfExclusionRepository) GetActive(ctx context.Context, tenantID int64, addresses []string) (map[string]*ent.SelfExclusion, error) {
+	// Exclusions stored before phone numbers were kept in E.164 are found by their plain form
+	keys := make(map[string][]string, len(addresses))
+	stored := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		normalized := NormalizeAddress(address)
+		forms := []string{normalized}
+		if phone := exclusionAddress(address); phone != normalized {
+			forms = append(forms, phone)
+		}
+		for _, form := range forms {
+			if len(keys[form]) == 0 {
+				stored = append(stored, form)
+			}
+			keys[form] = append(keys[form], normalized)
+		}
+	}
+
+	active := make(map[string]*ent.SelfExclusion)
+	for start := 0; start < len(stored); start += lookupChunkSize {
+		end := min(start+lookupChunkSize, len(stored))
+
+		entries, err := r.activeQuery(tenantID).
+			Where(selfexclusion.AddressIn(stored[start:end]...)).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			for _, key := range keys[entry.Address] {
+				active[key] = entry
+			}
+		}
+	}
+
+	return active, nil
+}
+
+// GetActiveByUser returns the self-exclusions in effect for the given users, keyed by user ID
+func (r *SelfExclusionRepository) GetActiveByUser(ctx context.Context, tenantID int64, userIDs []string) (map[string]*ent.SelfExclusion, error) {
+	userIDs = UniqueUserIDs(userIDs)
+
+	active := make(map[string]*ent.SelfExclusion)
+	for start := 0; start < len(userIDs); start += lookupChunkSize {
+		end := min(start+lookupChunkSize, len(userIDs))
+
+		entries, err := r.activeQuery(tenantID).
+			Where(selfexclusion.UserIDIn(userIDs[start:end]...)).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			active[entry.UserID] = entry
+		}
+	}
+
+	return active, nil
+}
+
+// activeQuery selects the tenant's self-exclusions that are in effect
+func (r *SelfExclusionRepository) activeQuery(tenantID int64) *ent.SelfExclusionQuery {
+	now := time.Now()
+	return r.client.SelfExclusion.Query().
+		Where(
+			selfexclusion.TenantID(tenantID),
+			selfexclusion.StartsAtLTE(now),
+			selfexclusion.Or(
+				selfexclusion.EndsAtIsNil(),
+				selfexclusion.EndsAtGT(now),
+			),
+		)
+}
+
+// exclusionAddress returns the form excluded addresses are stored in, phone numbers are kept in E.164
+func exclusionAddress(address string) string {
+	return NormalizePhone(NormalizeAddress(address))
+}
+
+// SelfExclusionFilter narrows down a listing of a tenant's self-exclusion registry
+type SelfExclusionFilter struct {
+	UserID string
+	// Address matches entries containing it
+	Address string
+	// ActiveOnly leaves out exclusions that ended or haven't started yet
+	ActiveOnly bool
+	Limit      int
+	Offset     int
+}
+
+// List returns a page of the tenant's self-exclusions, newest first, and the total number of matching entries
+func (r *SelfExclusionRepository) List(ctx context.Context, tenantID int64, filter SelfExclusionFilter) ([]*ent.SelfExclusion, int, error) {
+	query := r.client.SelfExclusion.Query().
+		Where(selfexclusion.TenantID(tenantID))
+
+	if filter.UserID != "" {
+		query.Where(selfexclusion.UserID(filter.UserID))
+	}
+	if filter.Address != "" {
+		query.Where(selfexclusion.AddressContains(exclusionAddress(filter.Address)))
+	}
+	if filter.ActiveOnly {
+		now := time.Now()
+		query.Where(
+			selfexclusion.StartsAtLTE(now),
+			selfexclusion.Or(
+				selfexclusion.EndsAtIsNil(),
+				selfexclusion.EndsAtGT(now),
+			),
+		)
+	}
+
+	total, err := query.Clone().Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries, err := query.
+		Order(ent.Desc(selfexclusion.FieldID)).
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		All(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// RecordBlock adds a notification withheld because of a self-exclusion to the audit log. The
+// notification may go to another address of the excluded user than the excluded one.
+func (r *SelfExclusionRepository) RecordBlock(ctx context.Context, notif *ent.Notification, exclusion *ent.SelfExclusion, messageType models.MessageType) error {
+	return r.client.SelfExclusionBlock.Create().
+		SetTenantID(notif.TenantID).
+		SetNotificationID(notif.ID).
+		SetRequestID(notif.RequestID).
+		SetChannel(selfexclusionblock.Channel(notif.Type)).
+		SetAddress(NormalizeAddress(string(notif.Address))).
+		SetMessageType(string(messageType)).
+		SetSelfExclusionID(exclusion.ID).
+		SetUserID(exclusion.UserID).
+		Exec(ctx)
+}
+
+// SelfExclusionBlockFilter narrows down a listing of a tenant's self-exclusion audit log
+type SelfExclusionBlockFilter struct {
+	Address string
+	From    *time.Time
+	To      *time.Time
+	Limit   int
+	Offset  int
+}
+
+// ListBlocks returns a page of the tenant's self-exclusion audit log, newest first, and the
+// total number of matching entries
+func (r *SelfExclusionRepository) ListBlocks(ctx context.Context, tenantID int64, filter SelfExclusionBlockFilter) ([]*ent.SelfExclusionBlock, int, error) {
+	query := r.client.SelfExclusionBlock.Query().
+		Where(selfexclusionblock.TenantID(tenantID))
+
+	if filter.Address != "" {
+		query.Where(selfexclusionblock.Address(NormalizeAddress(filter.Address)))
+	}
+	if filter.From != nil {
+		query.Where(selfexclusionblock.BlockedAtGTE(*filter.From))
+	}
+	if filter.To != nil {
+		query.Where(selfexclusionblock.BlockedAtLT(*filter.To))
+	}
+
+	total, err := query.Clone().Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries, err := query.
+		Order(ent.Desc(selfexclusionblock.FieldBlockedAt), ent.Desc(selfexclusionblock.FieldID)).
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		All(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
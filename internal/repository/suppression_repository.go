@@ -0,0 +1,286 @@
+// File: internal/repository/suppression_repository.go
+
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/suppression"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+type SuppressionRepository struct {
+	client *ent.Client
+	logger *logrus.Logger
+}
+
+func NewSuppressionRepository(client *ent.Client, logger *logrus.Logger) *SuppressionRepository {
+	return &SuppressionRepository{
+		client: client,
+		logger: logger,
+	}
+}
+
+// Suppress adds an address to the tenant's suppression list, replacing the reason and
+// expiry of an existing entry for the same channel and address
+func (r *SuppressionRepository) Suppress(ctx context.Context, req *models.SuppressionRequest) (*ent.Suppression, error) {
+	address := NormalizeAddress(req.Address)
+	channel := suppression.Channel(req.Channel)
+
+	existing, err := r.client.Suppression.Query().
+		Where(
+			suppression.TenantID(req.TenantID),
+			suppression.ChannelEQ(channel),
+			suppression.Address(address),
+		).
+		First(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		return nil, err
+	}
+
+	if existing != nil {
+		update := existing.Update().
+			SetReason(suppression.Reason(req.Reason)).
+			SetSource(req.Source).
+			SetDetail(req.Detail).
+			SetNillableNotificationID(req.NotificationID)
+
+		if req.ExpiresAt != nil {
+			update.SetExpiresAt(*req.ExpiresAt)
+		} else {
+			update.ClearExpiresAt()
+		}
+
+		return update.Save(ctx)
+	}
+
+	return r.client.Suppression.Create().
+		SetTenantID(req.TenantID).
+		SetChannel(channel).
+		SetAddress(address).
+		SetReason(suppression.Reason(req.Reason)).
+		SetSource(req.Source).
+		SetDetail(req.Detail).
+		SetNillableNotificationID(req.NotificationID).
+		SetNillableExpiresAt(req.ExpiresAt).
+		Save(ctx)
+}
+
+// lookupChunkSize bounds the number of addresses per query and bulk insert
+const lookupChunkSize = 500
+
+// Import adds addresses to the tenant's suppression list with the same reason and expiry,
+// updating entries that already exist. It returns the number of imported addresses.
+func (r *SuppressionRepository) Import(ctx context.Context, tenantID int64, req *models.SuppressionImportRequest) (int, error) {
+	channel := suppression.Channel(req.Channel)
+
+	seen := make(map[string]bool, len(req.Addresses))
+	addresses := make([]string, 0, len(req.Addresses))
+	for _, address := range req.Addresses {
+		address = NormalizeAddress(address)
+		if address != "" && !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+
+	for start := 0; start < len(addresses); start += lookupChunkSize {
+		end := min(start+lookupChunkSize, len(addresses))
+		chunk := addresses[start:end]
+
+		existing, err := r.client.Suppression.Query().
+			Where(
+				suppression.TenantID(tenantID),
+				suppression.ChannelEQ(channel),
+				suppression.AddressIn(chunk...),
+			).
+			All(ctx)
+		if err != nil {
+			return start, err
+		}
+
+		existingIDs := make([]int, 0, len(existing))
+		existingAddresses := make(map[string]bool, len(existing))
+		for _, entry := range existing {
+			existingIDs = append(existingIDs, entry.ID)
+			existingAddresses[entry.Address] = true
+		}
+
+		if len(existingIDs) > 0 {
+			update := r.client.Suppression.Update().
+				Where(suppression.IDIn(existingIDs...)).
+				SetReason(suppression.Reason(req.Reason)).
+				SetSource("import").
+				SetDetail(req.Detail).
+				ClearNotificationID()
+			if req.ExpiresAt != nil {
+				update.SetExpiresAt(*req.ExpiresAt)
+			} else {
+				update.ClearExpiresAt()
+			}
+			if err := update.Exec(ctx); err != nil {
+				return start, err
+			}
+		}
+
+		builders := make([]*ent.SuppressionCreate, 0, len(chunk)-len(existing))
+		for _, address := range chunk {
+			if existingAddresses[address] {
+				continue
+			}
+			builders = append(builders, r.client.Suppression.Create().
+				SetTenantID(tenantID).
+				SetChannel(channel).
+				SetAddress(address).
+				SetReason(suppression.Reason(req.Reason)).
+				SetSource("import").
+				SetDetail(req.Detail).
+				SetNillableExpiresAt(req.ExpiresAt))
+		}
+		if len(builders) > 0 {
+			if err := r.client.Suppression.CreateBulk(builders...).Exec(ctx); err != nil {
+				return start, err
+			}
+		}
+	}
+
+	return len(addresses), nil
+}
+
+// GetActive returns the unexpired suppressions of the given addresses, keyed by normalized address
+func (r *SuppressionRepository) GetActive(ctx context.Context, tenantID int64, channel models.NotificationType, addresses []string) (map[string]*ent.Suppression, error) {
+	normalized := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		normalized = append(normalized, NormalizeAddress(address))
+	}
+
+	active := make(map[string]*ent.Suppression)
+	for start := 0; start < len(normalized); start += lookupChunkSize {
+		end := min(start+lookupChunkSize, len(normalized))
+
+		entries, err := r.client.Suppression.Query().
+			Where(
+				suppression.TenantID(tenantID),
+				suppression.ChannelEQ(suppression.Channel(channel)),
+				suppression.AddressIn(normalized[start:end]...),
+				suppression.Or(
+					suppression.ExpiresAtIsNil(),
+					suppression.ExpiresAtGT(time.Now()),
+				),
+			).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			active[entry.Address] = entry
+		}
+	}
+
+	return active, nil
+}
+
+// SuppressionFilter narrows down a listing of a tenant's suppression list
+type SuppressionFilter struct {
+	Channel models.NotificationType
+	Reason  models.SuppressionReason
+	// Address matches entries containing it
+	Address string
+	Limit   int
+	Offset  int
+}
+
+// List returns a page of the tenant's suppression list, newest first, and the total number of matching entries
+func (r *SuppressionRepository) List(ctx context.Context, tenantID int64, filter SuppressionFilter) ([]*ent.Suppression, int, error) {
+	query := r.client.Suppression.Query().
+		Where(suppression.TenantID(tenantID))
+
+	if filter.Channel != "" {
+		query.Where(suppression.ChannelEQ(suppression.Channel(filter.Channel)))
+	}
+	if filter.Reason != "" {
+		query.Where(suppression.ReasonEQ(suppression.Reason(filter.Reason)))
+	}
+	if filter.Address != "" {
+		query.Where(suppression.AddressContains(NormalizeAddress(filter.Address)))
+	}
+
+	total, err := query.Clone().Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries, err := query.
+		Order(ent.Desc(suppression.FieldID)).
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		All(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// Get returns a suppression of the tenant by ID
+func (r *SuppressionRepository) Get(ctx context.Context, tenantID int64, id int) (*ent.Suppression, error) {
+	return r.client.Suppression.Query().
+		Where(
+			suppression.ID(id),
+			suppression.TenantID(tenantID),
+		).
+		Only(ctx)
+}
+
+// Delete removes a suppression of the tenant, reporting whether it existed
+func (r *SuppressionRepository) Delete(ctx context.Context, tenantID int64, id int) (bool, error) {
+	deleted, err := r.client.Suppression.Delete().
+		Where(
+			suppression.ID(id),
+			suppression.TenantID(tenantID),
+		).
+		Exec(ctx)
+	return deleted > 0, err
+}
+
+// NormalizeAddress returns the form addresses are stored and looked up in
+func NormalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// NormalizePhone returns phone numbers in E.164 so that differently formatted numbers match,
+// other addresses are returned trimmed. Numbers in national format only lose their formatting
+// as their country isn't known.
+func NormalizePhone(address string) string {
+	address = strings.TrimSpace(address)
+
+	var digits strings.Builder
+	for _, r := range address {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case strings.ContainsRune(" +-().", r):
+		default:
+			return address
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case len(number) < 7 || len(number) > 17:
+		return address
+	case strings.HasPrefix(address, "+"):
+		return "+" + number
+	case strings.HasPrefix(number, "00"):
+		return "+" + number[2:]
+	case strings.HasPrefix(number, "0"):
+		return number
+	default:
+		return "+" + number
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/whatsappsession"
+)
+
+type WhatsAppSessionRepository struct {
+	client *ent.Client
+	logger *logrus.Logger
+}
+
+func NewWhatsAppSessionRepository(client *ent.Client, logger *logrus.Logger) *WhatsAppSessionRepository {
+	return &WhatsAppSessionRepository{
+		client: client,
+		logger: logger,
+	}
+}
+
+// Touch records a message the phone number sent to the tenant at the given time. Older
+// messages delivered late by the webhook don't move the session back.
+func (r *WhatsAppSessionRepository) Touch(ctx context.Context, tenantID int64, phone string, at time.Time) error {
+	phone = NormalizeWhatsAppPhone(phone)
+
+	existing, err := r.client.WhatsAppSession.Query().
+		Where(
+			whatsappsession.TenantID(tenantID),
+			whatsappsession.Phone(phone),
+		).
+		First(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		return err
+	}
+
+	if existing != nil {
+		if !at.After(existing.LastInboundAt) {
+			return nil
+		}
+		return existing.Update().SetLastInboundAt(at).Exec(ctx)
+	}
+
+	return r.client.WhatsAppSession.Create().
+		SetTenantID(tenantID).
+		SetPhone(phone).
+		SetLastInboundAt(at).
+		Exec(ctx)
+}
+
+// LastInbound returns when each of the phone numbers last sent a message to the tenant, keyed by
+// the phone numbers as given. Numbers that never did are left out.
+func (r *WhatsAppSessionRepository) LastInbound(ctx context.Context, tenantID int64, phones []string) (map[string]time.Time, error) {
+	byNormalized := make(map[string][]string, len(phones))
+	for _, phone := range phones {
+		normalized := NormalizeWhatsAppPhone(phone)
+		byNormalized[normalized] = append(byNormalized[normalized], phone)
+	}
+
+	normalized := make([]string, 0, len(byNormalized))
+	for phone := range byNormalized {
+		normalized = append(normalized, phone)
+	}
+
+	sessions, err := r.client.WhatsAppSession.Query().
+		Where(
+			whatsappsession.TenantID(tenantID),
+			whatsappsession.PhoneIn(normalized...),
+		).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lastInbound := make(map[string]time.Time, len(phones))
+	for _, session := range sessions {
+		for _, phone := range byNormalized[session.Phone] {
+			lastInbound[phone] = session.LastInboundAt
+		}
+	}
+	return lastInbound, nil
+}
+
+// NormalizeWhatsAppPhone brings a phone number to the form WhatsApp reports senders in
+func NormalizeWhatsAppPhone(phone string) string {
+	return strings.TrimPrefix(strings.TrimSpace(phone), "+")
+}
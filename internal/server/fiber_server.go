@@ -17,12 +17,21 @@ import (
 )
 
 type FiberServer struct {
-	app           *fiber.App
-	config        *config.Config
-	notifHandler  *handlers.NotificationHandler
-	configHandler *handlers.ConfigHandler
-	healthHandler *handlers.HealthHandler
-	logger        *logrus.Logger
+	app                  *fiber.App
+	config               *config.Config
+	notifHandler         *handlers.NotificationHandler
+	configHandler        *handlers.ConfigHandler
+	healthHandler        *handlers.HealthHandler
+	webhookHandler       *handlers.WebhookHandler
+	suppressionHandler   *handlers.SuppressionHandler
+	preferenceHandler    *handlers.PreferenceHandler
+	selfExclusionHandler *handlers.SelfExclusionHandler
+	contactHandler       *handlers.ContactHandler
+	deviceHandler        *handlers.DeviceHandler
+	inboxHandler         *handlers.InboxHandler
+	streamHandler        *handlers.StreamHandler
+	unsubscribeHandler   *handlers.UnsubscribeHandler
+	logger               *logrus.Logger
 }
 
 func NewFiberServer(
@@ -30,6 +39,15 @@ func NewFiberServer(
 	notifHandler *handlers.NotificationHandler,
 	configHandler *handlers.ConfigHandler,
 	healthHandler *handlers.HealthHandler,
+	webhookHandler *handlers.WebhookHandler,
+	suppressionHandler *handlers.SuppressionHandler,
+	preferenceHandler *handlers.PreferenceHandler,
+	selfExclusionHandler *handlers.SelfExclusionHandler,
+	contactHandler *handlers.ContactHandler,
+	deviceHandler *handlers.DeviceHandler,
+	inboxHandler *handlers.InboxHandler,
+	streamHandler *handlers.StreamHandler,
+	unsubscribeHandler *handlers.UnsubscribeHandler,
 	logger *logrus.Logger,
 ) *FiberServer {
 	app := fiber.New(fiber.Config{
@@ -52,12 +70,21 @@ func NewFiberServer(
 	}))
 
 	server := &FiberServer{
-		app:           app,
-		config:        config,
-		notifHandler:  notifHandler,
-		configHandler: configHandler,
-		healthHandler: healthHandler,
-		logger:        logger,
+		app:                  app,
+		config:               config,
+		notifHandler:         notifHandler,
+		configHandler:        configHandler,
+		healthHandler:        healthHandler,
+		webhookHandler:       webhookHandler,
+		suppressionHandler:   suppressionHandler,
+		preferenceHandler:    preferenceHandler,
+		selfExclusionHandler: selfExclusionHandler,
+		contactHandler:       contactHandler,
+		deviceHandler:        deviceHandler,
+		inboxHandler:         inboxHandler,
+		streamHandler:        streamHandler,
+		unsubscribeHandler:   unsubscribeHandler,
+		logger:               logger,
 	}
 
 	server.setupRoutes()
@@ -75,6 +102,27 @@ func (s *FiberServer) setupRoutes() {
 		s.app.Get("/swagger/*", swagger.HandlerDefault)
 	}
 
+	// Provider webhooks - public, each request is verified with the provider's signature
+	webhooks := s.app.Group("/webhooks")
+	webhooks.Post("/twilio/:tenant_id/status", s.webhookHandler.TwilioStatusCallback)
+	webhooks.Post("/nikita/:tenant_id/dlr", s.webhookHandler.NikitaDeliveryReport)
+	webhooks.Get("/nikita/:tenant_id/dlr", s.webhookHandler.NikitaDeliveryReport)
+	webhooks.Post("/viber/:tenant_id/dlr", s.webhookHandler.ViberDeliveryReport)
+	webhooks.Get("/viber/:tenant_id/dlr", s.webhookHandler.ViberDeliveryReport)
+	webhooks.Get("/whatsapp/:tenant_id", s.webhookHandler.WhatsAppVerify)
+	webhooks.Post("/whatsapp/:tenant_id", s.webhookHandler.WhatsAppEvents)
+	webhooks.Post("/ses/:tenant_id", s.webhookHandler.SESNotification)
+	webhooks.Post("/sendgrid/:tenant_id", s.webhookHandler.SendGridEvents)
+
+	// Hosted unsubscribe links - public, the token carries its own signature
+	s.app.Get("/u/:token", s.unsubscribeHandler.ShowUnsubscribe)
+	s.app.Post("/u/:token", s.unsubscribeHandler.Unsubscribe)
+
+	// Real-time inbox streams - public, opened with a stream token issued through the API
+	stream := s.app.Group("/stream")
+	stream.Get("/ws", s.streamHandler.StreamWebSocket)
+	stream.Get("/sse", s.streamHandler.StreamSSE)
+
 	// API v1 routes - Apply global authentication with config
 	v1 := s.app.Group("/api/v1")
 	v1.Use(middleware.AuthMiddleware(s.config)) // Pass config to middleware
@@ -90,6 +138,8 @@ func (s *FiberServer) setupRoutes() {
 	notifications.Post("/batch", s.notifHandler.SendBatchNotification)
 	notifications.Get("/status/:request_id", s.notifHandler.GetNotificationStatus)
 	notifications.Get("/batch/:batch_id/status", s.notifHandler.GetBatchStatus)
+	notifications.Get("/cascade/:request_id/status", s.notifHandler.GetCascadeStatus)
+	notifications.Get("/stats/delivery", s.notifHandler.GetDeliveryStats)
 
 	// Partner configuration routes - tenant_id in URL
 	configs := v1.Group("/config")
@@ -98,8 +148,53 @@ func (s *FiberServer) setupRoutes() {
 	configs.Post("/:tenant_id/providers/email", s.configHandler.AddEmailProvider)
 	configs.Post("/:tenant_id/providers/sms", s.configHandler.AddSMSProvider)
 	configs.Post("/:tenant_id/providers/push", s.configHandler.AddPushProvider)
+	configs.Post("/:tenant_id/providers/telegram", s.configHandler.AddTelegramProvider)
+	configs.Post("/:tenant_id/providers/viber", s.configHandler.AddViberProvider)
+	configs.Post("/:tenant_id/providers/whatsapp", s.configHandler.AddWhatsAppProvider)
 	configs.Delete("/:tenant_id/providers/:type/:name", s.configHandler.RemoveProvider)
 
+	// Suppression list routes - tenant_id in URL
+	suppressions := v1.Group("/suppressions")
+	suppressions.Get("/:tenant_id", s.suppressionHandler.ListSuppressions)
+	suppressions.Post("/:tenant_id", s.suppressionHandler.CreateSuppression)
+	suppressions.Post("/:tenant_id/import", s.suppressionHandler.ImportSuppressions)
+	suppressions.Get("/:tenant_id/:id", s.suppressionHandler.GetSuppression)
+	suppressions.Delete("/:tenant_id/:id", s.suppressionHandler.DeleteSuppression)
+
+	// Recipient preference routes - tenant_id in URL
+	preferences := v1.Group("/preferences")
+	preferences.Get("/:tenant_id", s.preferenceHandler.GetPreferences)
+	preferences.Put("/:tenant_id", s.preferenceHandler.UpdatePreferences)
+	preferences.Post("/:tenant_id/unsubscribe-link", s.preferenceHandler.CreateUnsubscribeLink)
+
+	// Contact routes - tenant_id in URL
+	contacts := v1.Group("/contacts")
+	contacts.Post("/:tenant_id/bulk", s.contactHandler.UpsertContacts)
+	contacts.Get("/:tenant_id/:user_id", s.contactHandler.GetContact)
+	contacts.Put("/:tenant_id/:user_id", s.contactHandler.UpsertContact)
+	contacts.Delete("/:tenant_id/:user_id", s.contactHandler.DeleteContact)
+
+	// Push device routes - tenant_id in URL
+	devices := v1.Group("/devices")
+	devices.Post("/:tenant_id", s.deviceHandler.RegisterDevice)
+	devices.Post("/:tenant_id/unregister", s.deviceHandler.UnregisterDevice)
+	devices.Get("/:tenant_id/users/:user_id", s.deviceHandler.ListDevices)
+
+	// In-app inbox routes - tenant_id in URL
+	inbox := v1.Group("/inbox")
+	inbox.Get("/:tenant_id/users/:user_id", s.inboxHandler.ListInbox)
+	inbox.Get("/:tenant_id/users/:user_id/unread-count", s.inboxHandler.GetUnreadCount)
+	inbox.Post("/:tenant_id/users/:user_id/read", s.inboxHandler.MarkRead)
+	inbox.Post("/:tenant_id/users/:user_id/unread", s.inboxHandler.MarkUnread)
+	inbox.Post("/:tenant_id/users/:user_id/archive", s.inboxHandler.ArchiveMessages)
+	inbox.Post("/:tenant_id/users/:user_id/stream-token", s.streamHandler.IssueStreamToken)
+
+	// Self-exclusion registry routes - tenant_id in URL
+	selfExclusions := v1.Group("/self-exclusions")
+	selfExclusions.Get("/:tenant_id", s.selfExclusionHandler.ListSelfExclusions)
+	selfExclusions.Put("/:tenant_id", s.selfExclusionHandler.SyncSelfExclusion)
+	selfExclusions.Get("/:tenant_id/blocks", s.selfExclusionHandler.ListSelfExclusionBlocks)
+
 	// Kafka API endpoints (for direct Kafka publishing)
 	kafkaAPI := v1.Group("/kafka")
 	kafkaAPI.Use(middleware.KafkaAuthMiddleware(s.config)) // Pass config to middleware
@@ -112,13 +112,16 @@ func (bns *BufferedNotificationService) createDefaultConfig(tenantID int64) *mod
 			MaxBatchSize:         50,
 			FlushIntervalSeconds: 30,
 		},
-		EmailProviders: []schema.ProviderConfig{},
-		SMSProviders:   []schema.ProviderConfig{},
-		PushProviders:  []schema.ProviderConfig{},
-		RateLimits:     map[string]schema.RateLimit{},
-		Enabled:        true,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+		EmailProviders:    []schema.ProviderConfig{},
+		SMSProviders:      []schema.ProviderConfig{},
+		PushProviders:     []schema.ProviderConfig{},
+		TelegramProviders: []schema.ProviderConfig{},
+		ViberProviders:    []schema.ProviderConfig{},
+		WhatsAppProviders: []schema.ProviderConfig{},
+		RateLimits:        map[string]schema.RateLimit{},
+		Enabled:           true,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
 	}
 }
 
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+)
+
+// CascadeService runs channel cascades. Every step of a user's cascade is stored up front as a
+// linked notification scheduled after the timeouts of the steps before it. A delivered step
+// cancels the later ones, as does a sent step on a channel without delivery reports. A step that
+// fails moves the next one forward.
+type CascadeService struct {
+	notifRepo   *repository.NotificationRepository
+	contactRepo *repository.ContactRepository
+	logger      *logrus.Logger
+}
+
+func NewCascadeService(
+	notifRepo *repository.NotificationRepository,
+	contactRepo *repository.ContactRepository,
+	logger *logrus.Logger,
+) *CascadeService {
+	return &CascadeService{
+		notifRepo:   notifRepo,
+		contactRepo: contactRepo,
+		logger:      logger,
+	}
+}
+
+// CascadeID returns the ID of the cascade of a user within a request
+func CascadeID(requestID, userID string) string {
+	return requestID + ":" + userID
+}
+
+// Create stores the cascades of a request, one per user. Steps the user has no address for are
+// skipped. It returns the stored notifications and the users without an address on any step.
+func (s *CascadeService) Create(ctx context.Context, req *models.NotificationRequest) ([]*ent.Notification, []string, error) {
+	userIDs := repository.UniqueUserIDs(req.UserIDs)
+
+	addresses := make([]map[string][]string, len(req.Cascade))
+	for i, step := range req.Cascade {
+		byUser, err := s.contactRepo.AddressesByUser(ctx, req.TenantID, step.Type, userIDs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve user IDs: %w", err)
+		}
+		addresses[i] = byUser
+	}
+
+	// Steps due right away are left unscheduled and sent by the caller
+	start := time.Now().Unix()
+	scheduled := req.ScheduleTS != nil && *req.ScheduleTS > start
+	if scheduled {
+		start = *req.ScheduleTS
+	}
+
+	var entries []*repository.CascadeNotification
+	var unresolved []string
+	for _, userID := range userIDs {
+		offset := int64(0)
+		reachable := false
+		for i, step := range req.Cascade {
+			if len(addresses[i][userID]) == 0 {
+				continue
+			}
+			reachable = true
+
+			var scheduleTS *int64
+			if scheduled || offset > 0 {
+				ts := start + offset
+				scheduleTS = &ts
+			}
+			for _, address := range addresses[i][userID] {
+				entries = append(entries, &repository.CascadeNotification{
+					CascadeID:  CascadeID(req.RequestID, userID),
+					Step:       i,
+					Type:       step.Type,
+					Address:    address,
+					ScheduleTS: scheduleTS,
+				})
+			}
+			offset += int64(step.TimeoutSeconds)
+		}
+		if !reachable {
+			unresolved = append(unresolved, userID)
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, unresolved, nil
+	}
+
+	notifications, err := s.notifRepo.CreateCascade(ctx, req, entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	return notifications, unresolved, nil
+}
+
+// Settle moves the cascade of a notification on after the notification reached a status. A
+// delivered notification cancels the later steps, as does a duplicate since the recipient
+// already got the content. SENT is only settled for channels without delivery reports, where
+// it's the final success. Once every notification of a step failed the next step is sent
+// without waiting for the timeout.
+func (s *CascadeService) Settle(ctx context.Context, notif *ent.Notification, status models.NotificationStatus) {
+	if notif.CascadeID == "" {
+		return
+	}
+
+	log := s.logger.WithFields(logrus.Fields{
+		"notification_id": notif.ID,
+		"cascade_id":      notif.CascadeID,
+		"cascade_step":    notif.CascadeStep,
+	})
+
+	switch status {
+	case models.StatusSent, models.StatusDelivered, models.StatusOpened, models.StatusClicked:
+		reason := fmt.Sprintf("cascade step %d was delivered", notif.CascadeStep)
+		if status == models.StatusSent {
+			reason = fmt.Sprintf("cascade step %d was sent on a channel without delivery reports", notif.CascadeStep)
+		}
+		cancelled, err := s.notifRepo.CancelCascadeAfter(ctx, notif.CascadeID, notif.CascadeStep, reason)
+		if err != nil {
+			log.WithError(err).Error("Failed to cancel later cascade steps")
+			return
+		}
+		if cancelled > 0 {
+			logger.WithRequest(notif.RequestID).Info("Cascade delivered, later steps cancelled", map[string]interface{}{
+				"cascade_id": notif.CascadeID,
+				"step":       notif.CascadeStep,
+				"cancelled":  cancelled,
+			})
+		}
+
+	case models.StatusDuplicate:
+		reason := fmt.Sprintf("cascade step %d was a duplicate", notif.CascadeStep)
+		if _, err := s.notifRepo.CancelCascadeAfter(ctx, notif.CascadeID, notif.CascadeStep, reason); err != nil {
+			log.WithError(err).Error("Failed to cancel later cascade steps")
+		}
+
+	case models.StatusFailed, models.StatusSuppressed, models.StatusCapped, models.StatusUndelivered, models.StatusBounced:
+		// Push steps fan out to every device, the step only fails once all of them did
+		unsettled, err := s.notifRepo.CountUnsettledCascadeStep(ctx, notif.CascadeID, notif.CascadeStep)
+		if err != nil {
+			log.WithError(err).Error("Failed to check cascade step")
+			return
+		}
+		if unsettled > 0 {
+			return
+		}
+
+		advanced, err := s.notifRepo.AdvanceCascadeAfter(ctx, notif.CascadeID, notif.CascadeStep, time.Now().Unix())
+		if err != nil {
+			log.WithError(err).Error("Failed to advance cascade")
+			return
+		}
+		if advanced > 0 {
+			logger.WithRequest(notif.RequestID).Info("Cascade step failed, next step moved forward", map[string]interface{}{
+				"cascade_id": notif.CascadeID,
+				"step":       notif.CascadeStep,
+				"status":     status,
+			})
+		}
+	}
+}
+
+// SettleByID is Settle for a notification known by ID
+func (s *CascadeService) SettleByID(ctx context.Context, notificationID int, status models.NotificationStatus) {
+	notif, err := s.notifRepo.GetByID(ctx, notificationID)
+	if err != nil {
+		s.logger.WithField("notification_id", notificationID).
+			WithError(err).
+			Error("Failed to load notification for its cascade")
+		return
+	}
+	s.Settle(ctx, notif, status)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+)
+
+func TestProcessNotificationCascade(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	s.withPushProvider(t, &fakePushProvider{invalid: map[string]bool{"token-stale": true}})
+
+	devices := repository.NewDeviceRepository(s.client, logrus.New())
+	for _, req := range []*models.ContactRequest{
+		{UserID: "player-1", Phone: "+37499000001"},
+		{UserID: "player-2", Phone: "+37499000002"},
+	} {
+		if _, err := s.contactRepo.Upsert(ctx, testTenantID, req); err != nil {
+			t.Fatalf("failed to store contact: %v", err)
+		}
+	}
+	for _, req := range []*models.DeviceRegisterRequest{
+		{UserID: "player-1", Platform: models.PlatformAndroid, Token: "token-stale"},
+		{UserID: "player-2", Platform: models.PlatformAndroid, Token: "token-ok"},
+	} {
+		if _, err := devices.Register(ctx, testTenantID, req); err != nil {
+			t.Fatalf("failed to register device: %v", err)
+		}
+	}
+
+	err := s.ProcessNotification(ctx, &models.NotificationRequest{
+		RequestID:   "req-cascade",
+		TenantID:    testTenantID,
+		UserIDs:     []string{"player-1", "player-2", "player-3"},
+		Body:        "Your withdrawal is ready",
+		MessageType: models.MessageTypePayment,
+		Cascade: []*models.CascadeStep{
+			{Type: models.TypePush, TimeoutSeconds: 600},
+			{Type: models.TypeSMS},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+
+	cascades, err := s.notifRepo.GetCascadesByRequestID(ctx, "req-cascade")
+	if err != nil {
+		t.Fatalf("failed to load cascades: %v", err)
+	}
+	steps := make(map[string]*ent.Notification, len(cascades))
+	for _, notif := range cascades {
+		steps[string(notif.Address)] = notif
+	}
+	// player-3 has no address on any channel
+	if len(steps) != 4 {
+		t.Fatalf("got %d cascade notifications, want 4", len(steps))
+	}
+
+	// A failed step moves the next one forward
+	if status := steps["token-stale"].Status; status != notification.StatusFAILED {
+		t.Errorf("push to the invalid token = %s, want FAILED", status)
+	}
+	if next := steps["+37499000001"]; next.Status != notification.StatusPENDING || next.ScheduleTs == nil || *next.ScheduleTs > time.Now().Unix() {
+		t.Errorf("SMS after the failed push = %s at %v, want PENDING and due now", next.Status, next.ScheduleTs)
+	}
+
+	// Push has no delivery reports, a sent push is final and cancels the later steps
+	if status := steps["token-ok"].Status; status != notification.StatusSENT {
+		t.Errorf("push to the valid token = %s, want SENT", status)
+	}
+	next := steps["+37499000002"]
+	if status, reason := s.status(t, next.ID); status != notification.StatusCANCEL || reason != "cascade step 0 was sent on a channel without delivery reports" {
+		t.Errorf("SMS after the sent push = %s (%q), want CANCEL", status, reason)
+	}
+}
+
+func TestCascadeSettleDelivered(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	if _, err := s.contactRepo.Upsert(ctx, testTenantID, &models.ContactRequest{UserID: "player-1", Email: "player-1@example.org", Phone: "+37499000001"}); err != nil {
+		t.Fatalf("failed to store contact: %v", err)
+	}
+
+	scheduleTS := time.Now().Add(time.Hour).Unix()
+	cascade, _, err := s.cascades.Create(ctx, &models.NotificationRequest{
+		RequestID:   "req-delivered",
+		TenantID:    testTenantID,
+		UserIDs:     []string{"player-1"},
+		Body:        "Your withdrawal is ready",
+		MessageType: models.MessageTypePayment,
+		ScheduleTS:  &scheduleTS,
+		Cascade: []*models.CascadeStep{
+			{Type: models.TypeEmail, TimeoutSeconds: 600},
+			{Type: models.TypeSMS},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if len(cascade) != 2 {
+		t.Fatalf("got %d cascade notifications, want 2", len(cascade))
+	}
+
+	// A delivered step cancels the later ones
+	s.cascades.Settle(ctx, cascade[0], models.StatusDelivered)
+	if status, reason := s.status(t, cascade[1].ID); status != notification.StatusCANCEL || reason != "cascade step 0 was delivered" {
+		t.Errorf("SMS after the delivered email = %s (%q), want CANCEL", status, reason)
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/providers/sms"
+	"gitlab.smartbet.am/golang/notification/internal/providers/viber"
+	"gitlab.smartbet.am/golang/notification/internal/providers/whatsapp"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+)
+
+// ErrUnknownProviderMessage is returned for receipts of messages we have no delivery attempt for
+var ErrUnknownProviderMessage = errors.New("unknown provider message")
+
+// DeliveryReceipt is a status report a provider sent after accepting a notification
+type DeliveryReceipt struct {
+	TenantID          int64
+	ProviderType      string
+	ProviderMessageID string
+	ProviderStatus    string
+	// Status is the notification status the receipt leads to, empty for intermediate reports
+	Status       models.NotificationStatus
+	ErrorCode    string
+	ErrorMessage string
+}
+
+// NikitaReceipt converts a Nikita delivery report to a delivery receipt of the tenant
+func NikitaReceipt(tenantID int64, report *sms.NikitaDeliveryReport) *DeliveryReceipt {
+	receipt := &DeliveryReceipt{
+		TenantID:          tenantID,
+		ProviderType:      "custom",
+		ProviderMessageID: report.MessageID,
+		ProviderStatus:    report.Status,
+		ErrorCode:         report.OperatorErrorCode(),
+	}
+	if status, final := sms.NikitaDeliveryStatus(report.Status); final {
+		receipt.Status = status
+	}
+	if receipt.Status == models.StatusUndelivered {
+		receipt.ErrorMessage = fmt.Sprintf("Nikita delivery failed: %s", report.Status)
+		if receipt.ErrorCode != "" {
+			receipt.ErrorMessage = fmt.Sprintf("Nikita delivery failed: %s (error code: %s)", report.Status, receipt.ErrorCode)
+		}
+	}
+	return receipt
+}
+
+// ViberReceipt converts a Nikita Viber delivery report to a delivery receipt of the tenant.
+// With the SMS fallback an undelivered Viber message isn't final, the report of the SMS sent
+// in its place decides.
+func ViberReceipt(tenantID int64, report *viber.DeliveryReport) *DeliveryReceipt {
+	receipt := &DeliveryReceipt{
+		TenantID:          tenantID,
+		ProviderType:      "nikita",
+		ProviderMessageID: report.MessageID,
+		ProviderStatus:    report.ProviderStatus(),
+		ErrorCode:         report.OperatorErrorCode(),
+	}
+	if status, final := viber.DeliveryStatus(report); final {
+		receipt.Status = status
+	}
+	if receipt.Status == models.StatusUndelivered && report.HasSMSFallback() && !report.ViaSMS() {
+		receipt.Status = ""
+	}
+	if receipt.Status == models.StatusUndelivered {
+		receipt.ErrorMessage = fmt.Sprintf("Viber delivery failed: %s", receipt.ProviderStatus)
+		if receipt.ErrorCode != "" {
+			receipt.ErrorMessage = fmt.Sprintf("Viber delivery failed: %s (error code: %s)", receipt.ProviderStatus, receipt.ErrorCode)
+		}
+	}
+	return receipt
+}
+
+// WhatsAppReceipt converts a WhatsApp message status to a delivery receipt of the tenant
+func WhatsAppReceipt(tenantID int64, status *whatsapp.MessageStatus) *DeliveryReceipt {
+	receipt := &DeliveryReceipt{
+		TenantID:          tenantID,
+		ProviderType:      "cloud",
+		ProviderMessageID: status.ID,
+		ProviderStatus:    status.Status,
+		ErrorCode:         status.ErrorCode(),
+	}
+	if notificationStatus, final := whatsapp.DeliveryStatus(status.Status); final {
+		receipt.Status = notificationStatus
+	}
+	if receipt.Status == models.StatusUndelivered {
+		receipt.ErrorMessage = "WhatsApp delivery failed"
+		if title := status.ErrorTitle(); title != "" {
+			receipt.ErrorMessage = fmt.Sprintf("WhatsApp delivery failed: %s (error code: %s)", title, receipt.ErrorCode)
+		}
+	}
+	return receipt
+}
+
+// DeliveryReceiptService applies provider delivery receipts to notifications
+type DeliveryReceiptService struct {
+	notifRepo       *repository.NotificationRepository
+	attemptRepo     *repository.DeliveryAttemptRepository
+	suppressionRepo *repository.SuppressionRepository
+	cascades        *CascadeService
+	logger          *logrus.Logger
+}
+
+func NewDeliveryReceiptService(
+	notifRepo *repository.NotificationRepository,
+	attemptRepo *repository.DeliveryAttemptRepository,
+	suppressionRepo *repository.SuppressionRepository,
+	cascades *CascadeService,
+	logger *logrus.Logger,
+) *DeliveryReceiptService {
+	return &DeliveryReceiptService{
+		notifRepo:       notifRepo,
+		attemptRepo:     attemptRepo,
+		suppressionRepo: suppressionRepo,
+		cascades:        cascades,
+		logger:          logger,
+	}
+}
+
+// ApplyEmailEvent applies a bounce, complaint or engagement event to the email it is about.
+// Hard bounces and complaints also suppress the recipient address, even when the email
+// itself can't be found.
+func (s *DeliveryReceiptService) ApplyEmailEvent(ctx context.Context, event *models.EmailEvent) error {
+	var notificationID *int
+	if event.MessageID != "" {
+		if attempt, err := s.attemptRepo.GetByProviderMessageID(ctx, event.TenantID, "smtp", event.MessageID); err == nil {
+			notificationID = &attempt.NotificationID
+		}
+	}
+
+	if event.Recipient != "" && (event.Type == models.EmailEventHardBounce || event.Type == models.EmailEventComplaint) {
+		reason := models.SuppressionReasonHardBounce
+		if event.Type == models.EmailEventComplaint {
+			reason = models.SuppressionReasonComplaint
+		}
+
+		_, err := s.suppressionRepo.Suppress(ctx, &models.SuppressionRequest{
+			TenantID:       event.TenantID,
+			Channel:        models.TypeEmail,
+			Address:        event.Recipient,
+			Reason:         reason,
+			Detail:         strings.TrimSpace(event.StatusCode + " " + event.Diagnostic),
+			Source:         event.Source,
+			NotificationID: notificationID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to suppress address: %w", err)
+		}
+
+		logger.WithTenant(event.TenantID).Info("Address suppressed", map[string]interface{}{
+			"address": event.Recipient,
+			"reason":  reason,
+			"source":  event.Source,
+		})
+	}
+
+	if event.MessageID == "" {
+		return ErrUnknownProviderMessage
+	}
+
+	receipt := &DeliveryReceipt{
+		TenantID:          event.TenantID,
+		ProviderType:      "smtp",
+		ProviderMessageID: event.MessageID,
+		ProviderStatus:    string(event.Type),
+		ErrorCode:         event.StatusCode,
+	}
+
+	switch event.Type {
+	case models.EmailEventDelivered:
+		receipt.Status = models.StatusDelivered
+	case models.EmailEventHardBounce:
+		receipt.Status = models.StatusBounced
+		receipt.ErrorMessage = strings.TrimSpace(fmt.Sprintf("Email bounced: %s %s", event.StatusCode, event.Diagnostic))
+	case models.EmailEventOpened:
+		receipt.Status = models.StatusOpened
+	case models.EmailEventClicked:
+		receipt.Status = models.StatusClicked
+	}
+
+	return s.Apply(ctx, receipt)
+}
+
+// Apply correlates a receipt with its delivery attempt by provider message ID and advances the notification
+func (s *DeliveryReceiptService) Apply(ctx context.Context, receipt *DeliveryReceipt) error {
+	attempt, err := s.attemptRepo.GetByProviderMessageID(ctx, receipt.TenantID, receipt.ProviderType, receipt.ProviderMessageID)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return ErrUnknownProviderMessage
+		}
+		return fmt.Errorf("failed to find delivery attempt: %w", err)
+	}
+
+	notif, err := s.notifRepo.GetByID(ctx, attempt.NotificationID)
+	if err != nil {
+		return fmt.Errorf("failed to get notification: %w", err)
+	}
+
+	log := logger.WithRequest(notif.RequestID)
+
+	if err := s.attemptRepo.UpdateProviderStatus(ctx, attempt.ID, receipt.ProviderStatus, receipt.ErrorCode, time.Now()); err != nil {
+		return fmt.Errorf("failed to update delivery attempt: %w", err)
+	}
+
+	if receipt.Status == "" {
+		return nil
+	}
+
+	var errorMsg *string
+	if receipt.ErrorMessage != "" {
+		errorMsg = &receipt.ErrorMessage
+	}
+
+	if err := s.notifRepo.UpdateStatus(ctx, notif.ID, notification.Status(receipt.Status), errorMsg); err != nil {
+		// Late or out-of-order receipts must not regress the notification
+		if errors.Is(err, repository.ErrInvalidStatusTransition) {
+			log.Info("Ignoring delivery receipt that doesn't advance the notification", map[string]interface{}{
+				"notification_id": notif.ID,
+				"status":          receipt.Status,
+				"provider_status": receipt.ProviderStatus,
+				"reason":          err.Error(),
+			})
+			return nil
+		}
+		return fmt.Errorf("failed to update notification status: %w", err)
+	}
+
+	log.Info("Delivery receipt applied", map[string]interface{}{
+		"notification_id":     notif.ID,
+		"status":              receipt.Status,
+		"provider_status":     receipt.ProviderStatus,
+		"provider_message_id": receipt.ProviderMessageID,
+		"error_code":          receipt.ErrorCode,
+	})
+
+	s.cascades.Settle(ctx, notif, receipt.Status)
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+	"gitlab.smartbet.am/golang/notification/internal/logger"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+	"gitlab.smartbet.am/golang/notification/internal/unsubscribe"
+)
+
+// DigestService holds the notifications of the tenants' digest policies and collects them into
+// digest notifications. Held notifications to a recipient on a channel share a digest key and
+// go out together once the window of the first one passed or the policy's max items are held.
+type DigestService struct {
+	notifRepo *repository.NotificationRepository
+	logger    *logrus.Logger
+}
+
+func NewDigestService(notifRepo *repository.NotificationRepository, logger *logrus.Logger) *DigestService {
+	return &DigestService{
+		notifRepo: notifRepo,
+		logger:    logger,
+	}
+}
+
+// Hold holds the notifications falling under a digest policy of the tenant and returns the ones
+// to send right away, along with the digests that collected their max items and are due now.
+// Notifications that can't be held are sent right away.
+func (s *DigestService) Hold(ctx context.Context, notifications []*ent.Notification, config *models.PartnerConfig, messageType models.MessageType) ([]*ent.Notification, []*repository.DigestGroup) {
+	if len(config.DigestPolicies) == 0 {
+		return notifications, nil
+	}
+
+	pending := make([]*ent.Notification, 0, len(notifications))
+	var full []*repository.DigestGroup
+	seen := make(map[string]bool)
+	for _, notif := range notifications {
+		// Cascade steps are time-bound and digests are never held again
+		policy := matchDigestPolicy(config.DigestPolicies, notif.Type, notif.Tag, messageType)
+		if policy == nil || notif.CascadeID != "" || notif.DigestKey != "" {
+			pending = append(pending, notif)
+			continue
+		}
+
+		key := digestKey(notif, messageType, policy)
+		held, err := s.hold(ctx, notif, key, policy)
+		if err != nil {
+			s.logger.WithField("notification_id", notif.ID).
+				WithError(err).
+				Error("Failed to hold notification for digest, sending it right away")
+			pending = append(pending, notif)
+			continue
+		}
+
+		logger.WithRequest(notif.RequestID).Info("Notification held for digest", map[string]interface{}{
+			"notification_id": notif.ID,
+			"held":            held,
+		})
+
+		if held >= policy.MaxItems && !seen[key] {
+			seen[key] = true
+			full = append(full, &repository.DigestGroup{TenantID: notif.TenantID, DigestKey: key})
+		}
+	}
+
+	return pending, full
+}
+
+// hold holds a notification until the digest of its key is due and returns how many
+// notifications the digest holds with it
+func (s *DigestService) hold(ctx context.Context, notif *ent.Notification, key string, policy *schema.DigestPolicy) (int, error) {
+	window, err := time.ParseDuration(policy.Window)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window of digest policy: %w", err)
+	}
+
+	held, err := s.notifRepo.GetHeldForDigest(ctx, notif.TenantID, key)
+	if err != nil {
+		return 0, err
+	}
+
+	// The digest is due a window after its first notification was held
+	dueAt := time.Now().Add(window)
+	if len(held) > 0 && held[0].DigestDueAt != nil {
+		dueAt = *held[0].DigestDueAt
+	}
+
+	if err := s.notifRepo.HoldForDigest(ctx, notif.ID, key, dueAt); err != nil {
+		return 0, err
+	}
+	return len(held) + 1, nil
+}
+
+// Collect claims the notifications held for a digest and stores the digest rendered from them
+// with the templates of their policy. It returns nil when there was nothing left to claim.
+func (s *DigestService) Collect(ctx context.Context, group *repository.DigestGroup, config *models.PartnerConfig) (*ent.Notification, error) {
+	requestID := uuid.New().String()
+
+	items, err := s.notifRepo.ClaimDigest(ctx, group.TenantID, group.DigestKey, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim held notifications: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	// Policies may have changed since the notifications were held, the defaults apply without one
+	first := items[0]
+	policy := matchDigestPolicy(config.DigestPolicies, first.Type, first.Tag, models.MessageType(first.MessageType))
+	if policy == nil {
+		policy = &schema.DigestPolicy{}
+	}
+
+	headline, body, err := renderDigest(policy, items)
+	if err != nil {
+		s.logger.WithFields(logrus.Fields{
+			"tenant_id":  group.TenantID,
+			"request_id": requestID,
+		}).WithError(err).Warn("Failed to render digest with the policy templates, using the defaults")
+
+		headline, body, err = renderDigest(&schema.DigestPolicy{}, items)
+		if err != nil {
+			s.failItems(ctx, items, "failed to render digest")
+			return nil, fmt.Errorf("failed to render digest: %w", err)
+		}
+	}
+
+	digest, err := s.notifRepo.CreateDigest(ctx, items, requestID, headline, body)
+	if err != nil {
+		s.failItems(ctx, items, "failed to store digest")
+		return nil, fmt.Errorf("failed to store digest: %w", err)
+	}
+
+	logger.WithTenant(group.TenantID).Info("Digest collected", map[string]interface{}{
+		"request_id": requestID,
+		"items":      len(items),
+	})
+
+	return digest, nil
+}
+
+// failItems marks claimed notifications FAILED when their digest can't be sent
+func (s *DigestService) failItems(ctx context.Context, items []*ent.Notification, errorMsg string) {
+	for _, item := range items {
+		if err := s.notifRepo.UpdateStatus(ctx, item.ID, notification.StatusFAILED, &errorMsg); err != nil {
+			s.logger.WithField("notification_id", item.ID).
+				WithError(err).
+				Error("Failed to update notification status")
+		}
+	}
+}
+
+// matchDigestPolicy returns the first digest policy covering notifications of the channel,
+// tag and message type
+func matchDigestPolicy(policies []schema.DigestPolicy, notifType notification.Type, tag string, messageType models.MessageType) *schema.DigestPolicy {
+	for i, policy := range policies {
+		if policy.MessageType != string(messageType) {
+			continue
+		}
+		if policy.Channel != "" && policy.Channel != string(notifType) {
+			continue
+		}
+		if policy.Tag != "" && policy.Tag != tag {
+			continue
+		}
+		return &policies[i]
+	}
+	return nil
+}
+
+// digestKey identifies the digest a notification is held for, one per recipient, channel and policy
+func digestKey(notif *ent.Notification, messageType models.MessageType, policy *schema.DigestPolicy) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%s\n%s",
+		notif.Type,
+		repository.NormalizeAddress(string(notif.Address)),
+		messageType,
+		policy.Tag,
+	)))
+	return hex.EncodeToString(sum[:])
+}
+
+// renderDigest renders the headline and body of a digest of the items
+func renderDigest(policy *schema.DigestPolicy, items []*ent.Notification) (string, string, error) {
+	data := models.DigestTemplateData{
+		Count:          len(items),
+		Items:          make([]models.DigestItem, 0, len(items)),
+		UnsubscribeURL: unsubscribe.Placeholder,
+	}
+	for _, item := range items {
+		digestItem := models.DigestItem{
+			RequestID: item.RequestID,
+			Headline:  item.Headline,
+			Body:      item.Body,
+			Tag:       item.Tag,
+			CreatedAt: item.CreateTime,
+		}
+		if item.Meta != nil {
+			digestItem.Params = item.Meta.Params
+		}
+		data.Items = append(data.Items, digestItem)
+	}
+
+	headline, err := executeDigestTemplate("headline", policy.Headline, models.DefaultDigestHeadline, data)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := executeDigestTemplate("body", policy.Body, models.DefaultDigestBody, data)
+	if err != nil {
+		return "", "", err
+	}
+	return headline, body, nil
+}
+
+// executeDigestTemplate renders one template of a digest
+func executeDigestTemplate(name, text, fallback string, data models.DigestTemplateData) (string, error) {
+	tmpl, err := models.ParseDigestTemplate(name, text, fallback)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
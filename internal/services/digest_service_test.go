@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func TestProcessNotificationDigest(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	provider := &fakePushProvider{}
+	s.withPushProvider(t, provider)
+
+	config, err := s.configRepo.GetByTenantID(ctx, testTenantID)
+	if err != nil {
+		t.Fatalf("failed to load partner config: %v", err)
+	}
+	config.DigestPolicies = []schema.DigestPolicy{{
+		MessageType: "bonus",
+		Channel:     "PUSH",
+		Window:      "1h",
+		MaxItems:    3,
+		Headline:    "{{.Count}} bonuses",
+	}}
+	if err := s.configRepo.Save(ctx, config); err != nil {
+		t.Fatalf("failed to store partner config: %v", err)
+	}
+
+	send := func(body string, messageType models.MessageType) {
+		t.Helper()
+		err := s.ProcessNotification(ctx, &models.NotificationRequest{
+			TenantID:    testTenantID,
+			Type:        models.TypePush,
+			Recipients:  []string{"token-1"},
+			Body:        body,
+			MessageType: messageType,
+		})
+		if err != nil {
+			t.Fatalf("ProcessNotification() error = %v", err)
+		}
+	}
+
+	// Message types without a policy are sent right away
+	send("Deposit received", models.MessageTypePayment)
+	send("Free spins", models.MessageTypeBonus)
+	send("Cashback", models.MessageTypeBonus)
+	if fmt.Sprint(provider.bodies) != fmt.Sprint([]string{"Deposit received"}) {
+		t.Fatalf("sent %q, want only the payment message before the digest", provider.bodies)
+	}
+
+	held, err := s.client.Notification.Query().Where(notification.StatusEQ(notification.StatusHELD)).All(ctx)
+	if err != nil {
+		t.Fatalf("failed to load held notifications: %v", err)
+	}
+	if len(held) != 2 || held[0].DigestKey == "" || held[0].DigestKey != held[1].DigestKey || held[0].DigestDueAt == nil {
+		t.Fatalf("got %d held notifications, want 2 sharing a digest", len(held))
+	}
+	if due := *held[0].DigestDueAt; due.Before(time.Now().Add(59*time.Minute)) || !due.Equal(*held[1].DigestDueAt) {
+		t.Errorf("digest due at %v and %v, want a window after the first was held", due, *held[1].DigestDueAt)
+	}
+
+	// The max items send the digest without waiting for the window
+	send("Reload bonus", models.MessageTypeBonus)
+	if len(provider.bodies) != 2 || provider.bodies[1] != "Free spins\n\nCashback\n\nReload bonus" {
+		t.Fatalf("sent %q, want the digest of the three bonuses", provider.bodies)
+	}
+
+	digest, err := s.client.Notification.Query().Where(notification.StatusEQ(notification.StatusSENT), notification.Body(provider.bodies[1])).Only(ctx)
+	if err != nil {
+		t.Fatalf("failed to load digest: %v", err)
+	}
+	if digest.Headline != "3 bonuses" || string(digest.Address) != "token-1" {
+		t.Errorf("digest headline %q to %s, want \"3 bonuses\" to token-1", digest.Headline, digest.Address)
+	}
+	digested, err := s.client.Notification.Query().Where(notification.DigestRequestID(digest.RequestID)).Count(ctx)
+	if err != nil || digested != 3 {
+		t.Errorf("digest collected %d notifications (%v), want 3", digested, err)
+	}
+}
+
+func TestSendDigestDue(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	provider := &fakePushProvider{}
+	s.withPushProvider(t, provider)
+
+	config := &models.PartnerConfig{DigestPolicies: []schema.DigestPolicy{{MessageType: "promo", Window: "1h", MaxItems: 10}}}
+	for _, body := range []string{"Offer", "Tournament"} {
+		notif := s.create(t, models.TypePush, models.MessageTypePromo, "token-1", body)
+		if pending, full := s.digests.Hold(ctx, []*ent.Notification{notif}, config, models.MessageTypePromo); len(pending) != 0 || len(full) != 0 {
+			t.Fatalf("Hold() = %d pending, %d full, want the notification held", len(pending), len(full))
+		}
+	}
+
+	if groups, err := s.notifRepo.GetDueDigests(ctx, time.Now()); err != nil || len(groups) != 0 {
+		t.Fatalf("GetDueDigests() = %d, %v, want none before the window passed", len(groups), err)
+	}
+	groups, err := s.notifRepo.GetDueDigests(ctx, time.Now().Add(time.Hour))
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("GetDueDigests() = %d, %v, want the digest once the window passed", len(groups), err)
+	}
+
+	if err := s.SendDigest(ctx, groups[0]); err != nil {
+		t.Fatalf("SendDigest() error = %v", err)
+	}
+	if fmt.Sprint(provider.bodies) != fmt.Sprint([]string{"Offer\n\nTournament"}) {
+		t.Errorf("sent %q, want the digest", provider.bodies)
+	}
+
+	// A digest is sent once
+	if err := s.SendDigest(ctx, groups[0]); err != nil || len(provider.bodies) != 1 {
+		t.Errorf("SendDigest() again = %v with %d sent, want nothing sent", err, len(provider.bodies))
+	}
+}
+
+func TestRenderDigest(t *testing.T) {
+	items := []*ent.Notification{
+		{RequestID: "req-1", Headline: "Bonus", Body: "Free spins", Meta: &schema.NotificationMeta{Params: map[string]interface{}{"amount": 10}}},
+		{RequestID: "req-2", Headline: "Bonus", Body: "Cashback", Meta: &schema.NotificationMeta{Params: map[string]interface{}{"amount": 5}}},
+	}
+
+	tests := []struct {
+		name         string
+		policy       schema.DigestPolicy
+		wantHeadline string
+		wantBody     string
+	}{
+		{name: "defaults", wantHeadline: "2 new notifications", wantBody: "Free spins\n\nCashback"},
+		{
+			name:         "policy templates",
+			policy:       schema.DigestPolicy{Headline: "Your {{.Count}} bonuses", Body: "{{range .Items}}- {{.Body}} {{.Params.amount}}\n{{end}}"},
+			wantHeadline: "Your 2 bonuses",
+			wantBody:     "- Free spins 10\n- Cashback 5\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headline, body, err := renderDigest(&tt.policy, items)
+			if err != nil {
+				t.Fatalf("renderDigest() error = %v", err)
+			}
+			if headline != tt.wantHeadline || body != tt.wantBody {
+				t.Errorf("renderDigest() = %q, %q, want %q, %q", headline, body, tt.wantHeadline, tt.wantBody)
+			}
+		})
+	}
+}
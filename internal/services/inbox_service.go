@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+)
+
+// InboxService serves the inboxes of users. Reading an in-app notification counts as opening
+// it, so it moves to OPENED and, as a cascade step, cancels the later steps.
+type InboxService struct {
+	inboxRepo *repository.InboxRepository
+	notifRepo *repository.NotificationRepository
+	cascades  *CascadeService
+	logger    *logrus.Logger
+}
+
+func NewInboxService(
+	inboxRepo *repository.InboxRepository,
+	notifRepo *repository.NotificationRepository,
+	cascades *CascadeService,
+	logger *logrus.Logger,
+) *InboxService {
+	return &InboxService{
+		inboxRepo: inboxRepo,
+		notifRepo: notifRepo,
+		cascades:  cascades,
+		logger:    logger,
+	}
+}
+
+// List returns a page of a user's inbox and the number of messages matching the filter
+func (s *InboxService) List(ctx context.Context, tenantID int64, userID string, filter repository.InboxFilter) ([]*ent.Notification, int, error) {
+	return s.inboxRepo.List(ctx, tenantID, userID, filter)
+}
+
+// CountUnread returns how many messages of a user's inbox are unread
+func (s *InboxService) CountUnread(ctx context.Context, tenantID int64, userID string) (int, error) {
+	return s.inboxRepo.CountUnread(ctx, tenantID, userID)
+}
+
+// MarkRead marks messages of a user's inbox read and returns how many were unread
+func (s *InboxService) MarkRead(ctx context.Context, tenantID int64, userID string, requestIDs []string) (int, error) {
+	read, err := s.inboxRepo.MarkRead(ctx, tenantID, userID, requestIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, message := range read {
+		if message.Status != notification.StatusSENT && message.Status != notification.StatusDELIVERED {
+			continue
+		}
+
+		err := s.notifRepo.UpdateStatus(ctx, message.ID, notification.StatusOPENED, nil)
+		var transitionErr *repository.StatusTransitionError
+		if errors.As(err, &transitionErr) {
+			continue
+		}
+		if err != nil {
+			s.logger.WithField("notification_id", message.ID).
+				WithError(err).
+				Error("Failed to update notification status")
+			continue
+		}
+		s.cascades.Settle(ctx, message, models.StatusOpened)
+	}
+
+	return len(read), nil
+}
+
+// MarkUnread marks messages of a user's inbox unread and returns how many were read
+func (s *InboxService) MarkUnread(ctx context.Context, tenantID int64, userID string, requestIDs []string) (int, error) {
+	return s.inboxRepo.MarkUnread(ctx, tenantID, userID, requestIDs)
+}
+
+// Archive archives messages of a user's inbox and returns how many weren't archived yet
+func (s *InboxService) Archive(ctx context.Context, tenantID int64, userID string, requestIDs []string) (int, error) {
+	return s.inboxRepo.Archive(ctx, tenantID, userID, requestIDs)
+}
+
+// NewInboxMessage converts an in-app notification to its inbox message
+func NewInboxMessage(message *ent.Notification) *models.InboxMessage {
+	return &models.InboxMessage{
+		RequestID:   message.RequestID,
+		Headline:    message.Headline,
+		Body:        message.Body,
+		Data:        message.Data,
+		Tag:         message.Tag,
+		MessageType: message.MessageType,
+		Read:        message.ReadAt != nil,
+		Archived:    message.ArchivedAt != nil,
+		SentAt:      message.SentAt,
+		ReadAt:      message.ReadAt,
+		ArchivedAt:  message.ArchivedAt,
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func TestProcessNotificationInAppStream(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	stream := s.hub.Open(testTenantID, "player-1")
+	other := s.hub.Open(testTenantID, "player-2")
+
+	err := s.ProcessNotification(ctx, &models.NotificationRequest{
+		RequestID:   "req-inapp",
+		TenantID:    testTenantID,
+		Type:        models.TypeInApp,
+		Recipients:  []string{"player-1"},
+		Headline:    "Bonus credited",
+		Body:        "Your 50 free spins are waiting",
+		MessageType: models.MessageTypeBonus,
+	})
+	if err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+
+	if len(stream.Events) != 1 {
+		t.Fatalf("stream received %d events, want 1", len(stream.Events))
+	}
+	var event models.StreamEvent
+	if err := json.Unmarshal(<-stream.Events, &event); err != nil {
+		t.Fatalf("invalid event: %v", err)
+	}
+	if event.Message.Body != "Your 50 free spins are waiting" || event.Message.SentAt == nil || event.Message.Read {
+		t.Errorf("event message = %+v, want the unread notification", event.Message)
+	}
+	if len(other.Events) != 0 {
+		t.Error("the stream of another user received the event")
+	}
+}
@@ -3,39 +3,85 @@ package services
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"gitlab.smartbet.am/golang/notification/ent"
 	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/ent/schema"
 	"gitlab.smartbet.am/golang/notification/internal/logger"
 	"gitlab.smartbet.am/golang/notification/internal/models"
 	"gitlab.smartbet.am/golang/notification/internal/providers"
+	"gitlab.smartbet.am/golang/notification/internal/realtime"
 	"gitlab.smartbet.am/golang/notification/internal/repository"
+	"gitlab.smartbet.am/golang/notification/internal/unsubscribe"
 )
 
 type NotificationService struct {
-	notifRepo    *repository.NotificationRepository
-	configRepo   *repository.PartnerConfigRepository
-	emailManager *providers.EmailProviderManager
-	smsManager   *providers.SMSProviderManager
-	logger       *logrus.Logger
+	notifRepo         *repository.NotificationRepository
+	attemptRepo       *repository.DeliveryAttemptRepository
+	configRepo        *repository.PartnerConfigRepository
+	suppressionRepo   *repository.SuppressionRepository
+	preferenceRepo    *repository.PreferenceRepository
+	selfExclusionRepo *repository.SelfExclusionRepository
+	contactRepo       *repository.ContactRepository
+	deviceRepo        *repository.DeviceRepository
+	cascades          *CascadeService
+	digests           *DigestService
+	realtime          *realtime.Broadcaster
+	unsubscribe       *unsubscribe.Links
+	emailManager      *providers.EmailProviderManager
+	smsManager        *providers.SMSProviderManager
+	pushManager       *providers.PushProviderManager
+	telegramManager   *providers.TelegramProviderManager
+	viberManager      *providers.ViberProviderManager
+	whatsappManager   *providers.WhatsAppProviderManager
+	logger            *logrus.Logger
 }
 
 func NewNotificationService(
 	notifRepo *repository.NotificationRepository,
+	attemptRepo *repository.DeliveryAttemptRepository,
 	configRepo *repository.PartnerConfigRepository,
+	suppressionRepo *repository.SuppressionRepository,
+	preferenceRepo *repository.PreferenceRepository,
+	selfExclusionRepo *repository.SelfExclusionRepository,
+	contactRepo *repository.ContactRepository,
+	deviceRepo *repository.DeviceRepository,
+	cascades *CascadeService,
+	digests *DigestService,
+	broadcaster *realtime.Broadcaster,
+	unsubscribeLinks *unsubscribe.Links,
 	emailManager *providers.EmailProviderManager,
 	smsManager *providers.SMSProviderManager,
+	pushManager *providers.PushProviderManager,
+	telegramManager *providers.TelegramProviderManager,
+	viberManager *providers.ViberProviderManager,
+	whatsappManager *providers.WhatsAppProviderManager,
 	logger *logrus.Logger,
 ) *NotificationService {
 	return &NotificationService{
-		notifRepo:    notifRepo,
-		configRepo:   configRepo,
-		emailManager: emailManager,
-		smsManager:   smsManager,
-		logger:       logger,
+		notifRepo:         notifRepo,
+		attemptRepo:       attemptRepo,
+		configRepo:        configRepo,
+		suppressionRepo:   suppressionRepo,
+		preferenceRepo:    preferenceRepo,
+		selfExclusionRepo: selfExclusionRepo,
+		contactRepo:       contactRepo,
+		deviceRepo:        deviceRepo,
+		cascades:          cascades,
+		digests:           digests,
+		realtime:          broadcaster,
+		unsubscribe:       unsubscribeLinks,
+		emailManager:      emailManager,
+		smsManager:        smsManager,
+		pushManager:       pushManager,
+		telegramManager:   telegramManager,
+		viberManager:      viberManager,
+		whatsappManager:   whatsappManager,
+		logger:            logger,
 	}
 }
 
@@ -65,6 +111,33 @@ func (s *NotificationService) ProcessNotification(ctx context.Context, req *mode
 		return fmt.Errorf("failed to get partner config: %w", err)
 	}
 
+	if len(req.Cascade) > 0 {
+		return s.processCascade(ctx, req, config)
+	}
+
+	// Requests published to Kafka directly may still target users by ID
+	if len(req.UserIDs) > 0 {
+		recipients, unresolved, err := s.contactRepo.ResolveRecipients(ctx, req.TenantID, req.Type, req.Recipients, req.UserIDs)
+		if err != nil {
+			log.Error("Failed to resolve user IDs", err, map[string]interface{}{
+				"tenant_id": req.TenantID,
+				"user_ids":  len(req.UserIDs),
+			})
+			return fmt.Errorf("failed to resolve user IDs: %w", err)
+		}
+		if len(unresolved) > 0 {
+			log.Info("Skipping user IDs without an address for the channel", map[string]interface{}{
+				"tenant_id":           req.TenantID,
+				"unresolved_user_ids": unresolved,
+			})
+		}
+		if len(recipients) == 0 {
+			return nil
+		}
+		req.Recipients = recipients
+		req.UserIDs = nil
+	}
+
 	notifications, err := s.notifRepo.CreateBatch(ctx, req)
 	if err != nil {
 		log.Error("Failed to store notifications in database", err, map[string]interface{}{
@@ -87,6 +160,26 @@ func (s *NotificationService) ProcessNotification(ctx context.Context, req *mode
 		return nil // Scheduler worker will handle it
 	}
 
+	// Suppressed, opted-out and self-excluded addresses are never handed to a provider
+	notifications, err = s.skipSuppressed(ctx, notifications, config, req.MessageType)
+	if err != nil {
+		log.Error("Failed to check suppression list", err, map[string]interface{}{
+			"tenant_id": req.TenantID,
+		})
+		return err
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	// Notifications of a digest policy wait for their digest
+	notifications, full := s.digests.Hold(ctx, notifications, config, req.MessageType)
+	s.sendDigests(ctx, full, config)
+	if len(notifications) == 0 {
+		return nil
+	}
+	s.renderUnsubscribeLinks(notifications, req.MessageType)
+
 	// Process notifications immediately
 	if config.BatchConfig.Enabled && len(notifications) > 1 {
 		return s.processBatch(ctx, notifications, config, req.MessageType)
@@ -94,14 +187,14 @@ func (s *NotificationService) ProcessNotification(ctx context.Context, req *mode
 
 	// Process individual notifications
 	for _, notif := range notifications {
-		if err := s.sendNotification(ctx, notif, config, req.MessageType); err != nil {
+		if _, err := s.sendNotification(ctx, notif, config, req.MessageType); err != nil {
 			s.updateNotificationStatus(ctx, notif.ID, notification.StatusFAILED, err.Error())
 			log.Error("Failed to send notification", err, map[string]interface{}{
 				"notification_id": notif.ID,
 				"recipient":       string(notif.Address),
 			})
 		} else {
-			s.updateNotificationStatus(ctx, notif.ID, notification.StatusCOMPLETED, "")
+			s.markSent(ctx, notif)
 			log.Info("Notification sent successfully", map[string]interface{}{
 				"notification_id": notif.ID,
 				"recipient":       string(notif.Address),
@@ -112,28 +205,147 @@ func (s *NotificationService) ProcessNotification(ctx context.Context, req *mode
 	return nil
 }
 
+// processCascade stores the cascades of a request and sends the first step of each right away,
+// the scheduler sends the later steps when they are due
+func (s *NotificationService) processCascade(ctx context.Context, req *models.NotificationRequest, config *models.PartnerConfig) error {
+	log := logger.WithRequest(req.RequestID)
+
+	if err := req.ValidateCascade(); err != nil {
+		return fmt.Errorf("invalid cascade: %w", err)
+	}
+	req.Type = req.Cascade[0].Type
+
+	notifications, unresolved, err := s.cascades.Create(ctx, req)
+	if err != nil {
+		log.Error("Failed to store cascade notifications", err, map[string]interface{}{
+			"tenant_id": req.TenantID,
+			"user_ids":  len(req.UserIDs),
+		})
+		return fmt.Errorf("failed to store cascade notifications: %w", err)
+	}
+	if len(unresolved) > 0 {
+		log.Info("Skipping user IDs without an address on any cascade channel", map[string]interface{}{
+			"tenant_id":           req.TenantID,
+			"unresolved_user_ids": unresolved,
+		})
+	}
+
+	due := make([]*ent.Notification, 0, len(notifications))
+	for _, notif := range notifications {
+		if notif.ScheduleTs == nil {
+			due = append(due, notif)
+		}
+	}
+
+	log.Info("Cascade notifications stored", map[string]interface{}{
+		"tenant_id": req.TenantID,
+		"stored":    len(notifications),
+		"due":       len(due),
+	})
+
+	due, err = s.skipSuppressed(ctx, due, config, req.MessageType)
+	if err != nil {
+		return err
+	}
+	s.renderUnsubscribeLinks(due, req.MessageType)
+
+	for _, notif := range due {
+		if _, err := s.sendNotification(ctx, notif, config, req.MessageType); err != nil {
+			s.updateNotificationStatus(ctx, notif.ID, notification.StatusFAILED, err.Error())
+			log.Error("Failed to send cascade notification", err, map[string]interface{}{
+				"notification_id": notif.ID,
+				"cascade_id":      notif.CascadeID,
+			})
+			continue
+		}
+		s.markSent(ctx, notif)
+	}
+
+	return nil
+}
+
 // ProcessStoredNotification processes a notification that's already stored in database
 func (s *NotificationService) ProcessStoredNotification(ctx context.Context, notif *ent.Notification) error {
-	log := logger.WithRequest(notif.RequestID)
-
 	// Get partner configuration
 	config, err := s.configRepo.GetByTenantID(ctx, notif.TenantID)
 	if err != nil {
 		return fmt.Errorf("failed to get partner config: %w", err)
 	}
 
-	// Determine message type from notification meta or default to system
-	messageType := models.MessageTypeSystem
+	messageType := storedMessageType(notif)
+
+	pending, full := s.digests.Hold(ctx, []*ent.Notification{notif}, config, messageType)
+	s.sendDigests(ctx, full, config)
+	if len(pending) == 0 {
+		return nil
+	}
+
+	return s.sendStored(ctx, notif, config, messageType)
+}
+
+// SendDigest collects the notifications held for a digest and sends the digest
+func (s *NotificationService) SendDigest(ctx context.Context, group *repository.DigestGroup) error {
+	config, err := s.configRepo.GetByTenantID(ctx, group.TenantID)
+	if err != nil {
+		return fmt.Errorf("failed to get partner config: %w", err)
+	}
+	return s.sendDigest(ctx, group, config)
+}
+
+// sendDigests sends the digests that are due, each on its own
+func (s *NotificationService) sendDigests(ctx context.Context, groups []*repository.DigestGroup, config *models.PartnerConfig) {
+	for _, group := range groups {
+		if err := s.sendDigest(ctx, group, config); err != nil {
+			s.logger.WithFields(logrus.Fields{
+				"tenant_id":  group.TenantID,
+				"digest_key": group.DigestKey,
+			}).WithError(err).Error("Failed to send digest")
+		}
+	}
+}
+
+func (s *NotificationService) sendDigest(ctx context.Context, group *repository.DigestGroup, config *models.PartnerConfig) error {
+	digest, err := s.digests.Collect(ctx, group, config)
+	if err != nil {
+		return err
+	}
+	if digest == nil {
+		return nil
+	}
+	return s.sendStored(ctx, digest, config, storedMessageType(digest))
+}
+
+// storedMessageType determines the message type of a stored notification from the notification,
+// its meta or defaults to system
+func storedMessageType(notif *ent.Notification) models.MessageType {
+	if notif.MessageType != "" {
+		return models.MessageType(notif.MessageType)
+	}
 	if notif.Meta != nil && notif.Meta.Params != nil {
 		if mt, exists := notif.Meta.Params["message_type"]; exists {
 			if mtStr, ok := mt.(string); ok {
-				messageType = models.MessageType(mtStr)
+				return models.MessageType(mtStr)
 			}
 		}
 	}
+	return models.MessageTypeSystem
+}
+
+// sendStored checks a stored notification against the tenant's lists and caps and sends it
+func (s *NotificationService) sendStored(ctx context.Context, notif *ent.Notification, config *models.PartnerConfig, messageType models.MessageType) error {
+	log := logger.WithRequest(notif.RequestID)
+
+	pending, err := s.skipSuppressed(ctx, []*ent.Notification{notif}, config, messageType)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	s.renderUnsubscribeLinks(pending, messageType)
 
 	// Send the notification
-	if err := s.sendNotification(ctx, notif, config, messageType); err != nil {
+	if _, err := s.sendNotification(ctx, notif, config, messageType); err != nil {
 		s.updateNotificationStatus(ctx, notif.ID, notification.StatusFAILED, err.Error())
 		log.Error("Failed to send stored notification", err, map[string]interface{}{
 			"notification_id": notif.ID,
@@ -141,7 +353,7 @@ func (s *NotificationService) ProcessStoredNotification(ctx context.Context, not
 		return err
 	}
 
-	s.updateNotificationStatus(ctx, notif.ID, notification.StatusCOMPLETED, "")
+	s.markSent(ctx, notif)
 	log.Info("Stored notification sent successfully", map[string]interface{}{
 		"notification_id": notif.ID,
 	})
@@ -149,29 +361,472 @@ func (s *NotificationService) ProcessStoredNotification(ctx context.Context, not
 	return nil
 }
 
+// skipSuppressed marks notifications to addresses on the tenant's suppression list, whose
+// recipients opted out of the message type or, for promotional messages, are self-excluded,
+// SUPPRESSED and returns the ones to send. Self-exclusion blocks are recorded for audits.
+// Notifications repeating content sent to the recipient within the tenant's dedup window are
+// marked DUPLICATE and ones over a frequency cap of the tenant CAPPED. When the lists or caps
+// can't be checked, all of them are marked FAILED.
+func (s *NotificationService) skipSuppressed(ctx context.Context, notifications []*ent.Notification, config *models.PartnerConfig, messageType models.MessageType) ([]*ent.Notification, error) {
+	if len(notifications) == 0 {
+		return nil, nil
+	}
+
+	tenantID := notifications[0].TenantID
+
+	addresses := make(map[notification.Type][]string)
+	allAddresses := make([]string, 0, len(notifications))
+	for _, notif := range notifications {
+		addresses[notif.Type] = append(addresses[notif.Type], string(notif.Address))
+		allAddresses = append(allAddresses, string(notif.Address))
+	}
+
+	// Self-exclusions and opt-outs of a user cover all of its addresses
+	var users map[string][]string
+	if messageType.AllowsOptOut() {
+		var err error
+		users, err = s.recipientUsers(ctx, tenantID, notifications)
+		if err != nil {
+			s.failAll(ctx, notifications, "failed to look up recipient contacts")
+			return nil, fmt.Errorf("failed to look up recipient contacts: %w", err)
+		}
+	}
+
+	// Self-exclusions cover every channel, promotional messages are never sent when they can't be checked
+	var excluded map[string]*ent.SelfExclusion
+	if messageType.IsPromotional() {
+		var err error
+		excluded, err = s.activeSelfExclusions(ctx, tenantID, allAddresses, users)
+		if err != nil {
+			s.failAll(ctx, notifications, "failed to check self-exclusion registry")
+			return nil, fmt.Errorf("failed to check self-exclusion registry: %w", err)
+		}
+	}
+
+	suppressed := make(map[notification.Type]map[string]*ent.Suppression, len(addresses))
+	optedOut := make(map[notification.Type]map[string]bool, len(addresses))
+	for notifType, typeAddresses := range addresses {
+		active, err := s.suppressionRepo.GetActive(ctx, tenantID, models.NotificationType(notifType), typeAddresses)
+		if err != nil {
+			s.failAll(ctx, notifications, "failed to check suppression list")
+			return nil, fmt.Errorf("failed to check suppression list: %w", err)
+		}
+		suppressed[notifType] = active
+
+		if messageType.AllowsOptOut() {
+			out, err := s.preferenceRepo.GetOptedOut(ctx, tenantID, models.NotificationType(notifType), messageType, typeAddresses, users)
+			if err != nil {
+				s.failAll(ctx, notifications, "failed to check recipient preferences")
+				return nil, fmt.Errorf("failed to check recipient preferences: %w", err)
+			}
+			optedOut[notifType] = out
+		}
+	}
+
+	window, dedup, err := dedupWindow(config, messageType)
+	if err != nil {
+		s.failAll(ctx, notifications, "failed to check duplicates")
+		return nil, err
+	}
+	duplicates, err := s.duplicatesOf(ctx, tenantID, notifications, config, messageType)
+	if err != nil {
+		s.failAll(ctx, notifications, "failed to check duplicates")
+		return nil, fmt.Errorf("failed to check duplicates: %w", err)
+	}
+	// Notifications let through earlier in the list are repeated like ones already sent
+	letThrough := make(map[string]*ent.Notification)
+
+	usages, err := s.frequencyCapUsages(ctx, tenantID, config, messageType, addresses)
+	if err != nil {
+		s.failAll(ctx, notifications, "failed to check frequency caps")
+		return nil, fmt.Errorf("failed to check frequency caps: %w", err)
+	}
+
+	pending := make([]*ent.Notification, 0, len(notifications))
+	for _, notif := range notifications {
+		address := repository.NormalizeAddress(string(notif.Address))
+
+		status, message := notification.StatusSUPPRESSED, "Notification suppressed"
+		var reason string
+		original, ok := duplicates[notif.ID]
+		if !ok && dedup {
+			original = letThrough[notif.ContentHash]
+			ok = original != nil && !original.CreateTime.Before(notif.CreateTime.Add(-window))
+		}
+		if ok {
+			status, message = notification.StatusDUPLICATE, "Notification duplicate"
+			reason = fmt.Sprintf("duplicate of %s", original.RequestID)
+		} else if exclusion, ok := excluded[address]; ok {
+			reason = "recipient is self-excluded"
+			s.recordSelfExclusionBlock(ctx, notif, exclusion, messageType)
+		} else if entry, ok := suppressed[notif.Type][address]; ok {
+			reason = fmt.Sprintf("address suppressed: %s", entry.Reason)
+		} else if optedOut[notif.Type][address] {
+			reason = fmt.Sprintf("recipient opted out of %s messages", messageType)
+		} else if usage := reachedFrequencyCap(usages, notif.Type, address); usage != nil {
+			status, message = notification.StatusCAPPED, "Notification capped"
+			reason = fmt.Sprintf("frequency cap reached: %d %s %s per %s", usage.cap.Limit, usage.cap.MessageType, usage.cap.Channel, usage.cap.Window)
+		} else {
+			// Notifications let through count towards the caps of the ones after them
+			for _, usage := range usages {
+				if usage.cap.Channel == string(notif.Type) {
+					usage.sent[address]++
+				}
+			}
+			if _, ok := letThrough[notif.ContentHash]; !ok && notif.ContentHash != "" {
+				letThrough[notif.ContentHash] = notif
+			}
+			pending = append(pending, notif)
+			continue
+		}
+
+		s.updateNotificationStatus(ctx, notif.ID, status, reason)
+		logger.WithRequest(notif.RequestID).Info(message, map[string]interface{}{
+			"notification_id": notif.ID,
+			"recipient":       string(notif.Address),
+			"reason":          reason,
+		})
+	}
+
+	return pending, nil
+}
+
+// recipientUsers returns the users the recipients of the notifications belong to according to
+// the contact registry, keyed by normalized address
+func (s *NotificationService) recipientUsers(ctx context.Context, tenantID int64, notifications []*ent.Notification) (map[string][]string, error) {
+	addresses := make([]string, 0, len(notifications))
+	for _, notif := range notifications {
+		addresses = append(addresses, string(notif.Address))
+	}
+
+	users, err := s.contactRepo.UsersByAddress(ctx, tenantID, addresses)
+	if err != nil {
+		return nil, err
+	}
+	// In-app notifications are addressed to the user ID itself
+	for _, notif := range notifications {
+		if notif.Type == notification.TypeINAPP {
+			address := repository.NormalizeAddress(string(notif.Address))
+			users[address] = append(users[address], strings.TrimSpace(string(notif.Address)))
+		}
+	}
+	return users, nil
+}
+
+// activeSelfExclusions returns the self-exclusions in effect for the addresses, keyed by
+// normalized address. An exclusion of a user covers every address and device the user has in
+// the contact registry, not only the excluded addresses.
+func (s *NotificationService) activeSelfExclusions(ctx context.Context, tenantID int64, addresses []string, users map[string][]string) (map[string]*ent.SelfExclusion, error) {
+	excluded, err := s.selfExclusionRepo.GetActive(ctx, tenantID, addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []string
+	for _, addressUsers := range users {
+		userIDs = append(userIDs, addressUsers...)
+	}
+	if len(userIDs) == 0 {
+		return excluded, nil
+	}
+
+	byUser, err := s.selfExclusionRepo.GetActiveByUser(ctx, tenantID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for address, addressUsers := range users {
+		if _, ok := excluded[address]; ok {
+			continue
+		}
+		for _, userID := range addressUsers {
+			if exclusion, ok := byUser[userID]; ok {
+				excluded[address] = exclusion
+				break
+			}
+		}
+	}
+	return excluded, nil
+}
+
+// dedupWindow returns the dedup window of the message type and whether it has one
+func dedupWindow(config *models.PartnerConfig, messageType models.MessageType) (time.Duration, bool, error) {
+	setting, ok := config.DedupWindows[string(messageType)]
+	if !ok {
+		return 0, false, nil
+	}
+	window, err := time.ParseDuration(setting)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid dedup window: %w", err)
+	}
+	return window, true, nil
+}
+
+// duplicatesOf returns the notifications repeating the content of an earlier notification sent
+// to the same recipient within the dedup window of the message type, keyed by ID, together with
+// the earliest one they repeat
+func (s *NotificationService) duplicatesOf(ctx context.Context, tenantID int64, notifications []*ent.Notification, config *models.PartnerConfig, messageType models.MessageType) (map[int]*ent.Notification, error) {
+	window, ok, err := dedupWindow(config, messageType)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var hashes []string
+	var since time.Time
+	for _, notif := range notifications {
+		if notif.ContentHash == "" {
+			continue
+		}
+		hashes = append(hashes, notif.ContentHash)
+		if start := notif.CreateTime.Add(-window); since.IsZero() || start.Before(since) {
+			since = start
+		}
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+
+	recent, err := s.notifRepo.GetRecentByContentHash(ctx, tenantID, hashes, since)
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := make(map[string][]*ent.Notification, len(recent))
+	for _, entry := range recent {
+		byHash[entry.ContentHash] = append(byHash[entry.ContentHash], entry)
+	}
+
+	duplicates := make(map[int]*ent.Notification)
+	for _, notif := range notifications {
+		for _, entry := range byHash[notif.ContentHash] {
+			if entry.ID >= notif.ID || entry.CreateTime.Before(notif.CreateTime.Add(-window)) {
+				continue
+			}
+			if original, ok := duplicates[notif.ID]; !ok || entry.ID < original.ID {
+				duplicates[notif.ID] = entry
+			}
+		}
+	}
+	return duplicates, nil
+}
+
+// frequencyCapUsage is how many notifications each address got within the window of a cap
+type frequencyCapUsage struct {
+	cap  schema.FrequencyCap
+	sent map[string]int
+}
+
+// frequencyCapUsages loads the recent history of the addresses for every frequency cap of the
+// tenant that covers the message type on one of their channels
+func (s *NotificationService) frequencyCapUsages(ctx context.Context, tenantID int64, config *models.PartnerConfig, messageType models.MessageType, addresses map[notification.Type][]string) ([]*frequencyCapUsage, error) {
+	var usages []*frequencyCapUsage
+	for _, frequencyCap := range config.FrequencyCaps {
+		typeAddresses, ok := addresses[notification.Type(frequencyCap.Channel)]
+		if !ok || frequencyCap.MessageType != string(messageType) {
+			continue
+		}
+
+		window, err := time.ParseDuration(frequencyCap.Window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window of frequency cap: %w", err)
+		}
+
+		sent, err := s.notifRepo.CountSentByAddress(ctx, tenantID, notification.Type(frequencyCap.Channel), messageType, typeAddresses, time.Now().Add(-window))
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, &frequencyCapUsage{cap: frequencyCap, sent: sent})
+	}
+	return usages, nil
+}
+
+// reachedFrequencyCap returns the first cap on the channel the address has reached
+func reachedFrequencyCap(usages []*frequencyCapUsage, notifType notification.Type, address string) *frequencyCapUsage {
+	for _, usage := range usages {
+		if usage.cap.Channel == string(notifType) && usage.sent[address] >= usage.cap.Limit {
+			return usage
+		}
+	}
+	return nil
+}
+
+// smsFallbacks returns the IDs of the Viber notifications whose SMS fallback may be sent. The
+// fallback goes to the same number as an SMS, so it's held to the SMS suppression list, opt-outs
+// and frequency caps; when they can't be checked, no fallback is sent.
+func (s *NotificationService) smsFallbacks(ctx context.Context, notifications []*ent.Notification, config *models.PartnerConfig, messageType models.MessageType) map[int]bool {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	tenantID := notifications[0].TenantID
+	addresses := make([]string, 0, len(notifications))
+	for _, notif := range notifications {
+		addresses = append(addresses, string(notif.Address))
+	}
+
+	suppressed, err := s.suppressionRepo.GetActive(ctx, tenantID, models.TypeSMS, addresses)
+	if err != nil {
+		s.logger.WithField("tenant_id", tenantID).WithError(err).Error("Failed to check SMS suppression list, sending Viber messages without SMS fallback")
+		return nil
+	}
+
+	var optedOut map[string]bool
+	if messageType.AllowsOptOut() {
+		var users map[string][]string
+		users, err = s.recipientUsers(ctx, tenantID, notifications)
+		if err == nil {
+			optedOut, err = s.preferenceRepo.GetOptedOut(ctx, tenantID, models.TypeSMS, messageType, addresses, users)
+		}
+		if err != nil {
+			s.logger.WithField("tenant_id", tenantID).WithError(err).Error("Failed to check SMS preferences, sending Viber messages without SMS fallback")
+			return nil
+		}
+	}
+
+	usages, err := s.frequencyCapUsages(ctx, tenantID, config, messageType, map[notification.Type][]string{notification.TypeSMS: addresses})
+	if err != nil {
+		s.logger.WithField("tenant_id", tenantID).WithError(err).Error("Failed to check SMS frequency caps, sending Viber messages without SMS fallback")
+		return nil
+	}
+
+	allowed := make(map[int]bool, len(notifications))
+	for _, notif := range notifications {
+		address := repository.NormalizeAddress(string(notif.Address))
+		if _, ok := suppressed[address]; ok || optedOut[address] || reachedFrequencyCap(usages, notification.TypeSMS, address) != nil {
+			continue
+		}
+		for _, usage := range usages {
+			usage.sent[address]++
+		}
+		allowed[notif.ID] = true
+	}
+	return allowed
+}
+
+// recordSelfExclusionBlock adds a withheld notification to the self-exclusion audit log
+func (s *NotificationService) recordSelfExclusionBlock(ctx context.Context, notif *ent.Notification, exclusion *ent.SelfExclusion, messageType models.MessageType) {
+	if err := s.selfExclusionRepo.RecordBlock(ctx, notif, exclusion, messageType); err != nil {
+		s.logger.WithFields(logrus.Fields{
+			"notification_id":   notif.ID,
+			"self_exclusion_id": exclusion.ID,
+		}).WithError(err).Error("Failed to record self-exclusion block")
+	}
+
+	logger.WithTenant(notif.TenantID).Info("Promotional notification blocked for self-excluded recipient", map[string]interface{}{
+		"notification_id":   notif.ID,
+		"request_id":        notif.RequestID,
+		"self_exclusion_id": exclusion.ID,
+		"user_id":           exclusion.UserID,
+		"message_type":      messageType,
+	})
+}
+
+// renderUnsubscribeLinks replaces the unsubscribe placeholder in the headline and body of each
+// notification with a signed link for its recipient. The changes aren't stored.
+func (s *NotificationService) renderUnsubscribeLinks(notifications []*ent.Notification, messageType models.MessageType) {
+	for _, notif := range notifications {
+		if !strings.Contains(notif.Body, unsubscribe.Placeholder) && !strings.Contains(notif.Headline, unsubscribe.Placeholder) {
+			continue
+		}
+
+		// Mandatory message types can't be unsubscribed from, their placeholder is dropped
+		var link string
+		if messageType.AllowsOptOut() {
+			var err error
+			link, err = s.unsubscribe.URL(&unsubscribe.Token{
+				TenantID:    notif.TenantID,
+				Channel:     models.NotificationType(notif.Type),
+				Address:     string(notif.Address),
+				MessageType: messageType,
+			})
+			if err != nil {
+				// The placeholder is dropped rather than sent to the recipient as is
+				s.logger.WithField("notification_id", notif.ID).
+					WithError(err).
+					Warn("Failed to create unsubscribe link")
+			}
+		}
+
+		notif.Body = strings.ReplaceAll(notif.Body, unsubscribe.Placeholder, link)
+		notif.Headline = strings.ReplaceAll(notif.Headline, unsubscribe.Placeholder, link)
+	}
+}
+
+// failAll marks all notifications FAILED with the same error
+func (s *NotificationService) failAll(ctx context.Context, notifications []*ent.Notification, errorMsg string) {
+	for _, notif := range notifications {
+		s.updateNotificationStatus(ctx, notif.ID, notification.StatusFAILED, errorMsg)
+	}
+}
+
 // sendNotification sends a single notification using the appropriate provider
-func (s *NotificationService) sendNotification(ctx context.Context, notif *ent.Notification, config *models.PartnerConfig, messageType models.MessageType) error {
+func (s *NotificationService) sendNotification(ctx context.Context, notif *ent.Notification, config *models.PartnerConfig, messageType models.MessageType) (*models.SendResult, error) {
 	switch notif.Type {
 	case notification.TypeEMAIL:
 		provider, err := s.emailManager.GetProvider(notif.TenantID)
 		if err != nil {
-			return fmt.Errorf("failed to get email provider: %w", err)
+			return nil, fmt.Errorf("failed to get email provider: %w", err)
 		}
-		return provider.Send(ctx, notif, messageType)
+		result, err := provider.Send(ctx, notif, messageType)
+		s.recordAttempt(ctx, result, s.emailManager.GetProviderName(notif.TenantID), provider.GetType())
+		return result, err
 
 	case notification.TypeSMS:
 		provider, err := s.smsManager.GetProvider(notif.TenantID)
 		if err != nil {
-			return fmt.Errorf("failed to get SMS provider: %w", err)
+			return nil, fmt.Errorf("failed to get SMS provider: %w", err)
 		}
-		return provider.Send(ctx, notif, messageType)
+		result, err := provider.Send(ctx, notif, messageType)
+		s.recordAttempt(ctx, result, s.smsManager.GetProviderName(notif.TenantID), provider.GetType())
+		return result, err
 
 	case notification.TypePUSH:
-		// TODO: Implement push provider when available
-		return fmt.Errorf("push notifications not yet implemented")
+		provider, err := s.pushManager.GetProvider(notif.TenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get push provider: %w", err)
+		}
+		result, err := provider.Send(ctx, notif, messageType)
+		s.recordAttempt(ctx, result, s.pushManager.GetProviderName(notif.TenantID), provider.GetType())
+		s.pruneDevice(ctx, notif, result)
+		return result, err
+
+	case notification.TypeTELEGRAM:
+		provider, err := s.telegramManager.GetProvider(notif.TenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Telegram provider: %w", err)
+		}
+		result, err := provider.Send(ctx, notif, messageType)
+		s.recordAttempt(ctx, result, s.telegramManager.GetProviderName(notif.TenantID), provider.GetType())
+		return result, err
+
+	case notification.TypeVIBER:
+		provider, err := s.viberManager.GetProvider(notif.TenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Viber provider: %w", err)
+		}
+		smsFallbacks := s.smsFallbacks(ctx, []*ent.Notification{notif}, config, messageType)
+		result, err := provider.Send(ctx, notif, messageType, smsFallbacks[notif.ID])
+		s.recordAttempt(ctx, result, s.viberManager.GetProviderName(notif.TenantID), provider.GetType())
+		return result, err
+
+	case notification.TypeWHATSAPP:
+		provider, err := s.whatsappManager.GetProvider(notif.TenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get WhatsApp provider: %w", err)
+		}
+		openSessions, err := s.whatsappManager.OpenSessions(ctx, notif.TenantID, []*ent.Notification{notif})
+		if err != nil {
+			return nil, err
+		}
+		result, err := provider.Send(ctx, notif, messageType, openSessions[notif.ID])
+		s.recordAttempt(ctx, result, s.whatsappManager.GetProviderName(notif.TenantID), provider.GetType())
+		return result, err
+
+	case notification.TypeINAPP:
+		// There is no provider, the notification shows in the user's inbox once it's sent
+		return models.NewSentResult(notif.ID, ""), nil
 
 	default:
-		return fmt.Errorf("unsupported notification type: %s", notif.Type)
+		return nil, fmt.Errorf("unsupported notification type: %s", notif.Type)
 	}
 }
 
@@ -205,7 +860,8 @@ func (s *NotificationService) processBatch(ctx context.Context, notifications []
 			}
 
 			batch := group[i:end]
-			if err := s.sendBatch(ctx, batch, notifType, messageType); err != nil {
+			results, err := s.sendBatch(ctx, batch, notifType, config, messageType)
+			if err != nil {
 				// Update status for failed batch
 				for _, notif := range batch {
 					s.updateNotificationStatus(ctx, notif.ID, notification.StatusFAILED, err.Error())
@@ -214,26 +870,52 @@ func (s *NotificationService) processBatch(ctx context.Context, notifications []
 					"batch_size": len(batch),
 					"type":       notifType,
 				})
-			} else {
-				// Update status for successful batch
-				for _, notif := range batch {
-					s.updateNotificationStatus(ctx, notif.ID, notification.StatusCOMPLETED, "")
+				continue
+			}
+
+			// Update each notification with its own outcome, notifications the provider returned no
+			// result for are failed rather than left ACTIVE
+			resultsByID := make(map[int]*models.SendResult, len(results))
+			for _, result := range results {
+				if result != nil {
+					resultsByID[result.NotificationID] = result
+				}
+			}
+
+			failed := 0
+			for _, notif := range batch {
+				result, ok := resultsByID[notif.ID]
+				switch {
+				case !ok:
+					failed++
+					s.updateNotificationStatus(ctx, notif.ID, notification.StatusFAILED, "provider returned no result")
+				case result.Failed():
+					failed++
+					errorMsg := "provider reported a failure"
+					if result.Error != nil {
+						errorMsg = result.Error.Error()
+					}
+					s.updateNotificationStatus(ctx, notif.ID, notification.StatusFAILED, errorMsg)
+				default:
+					s.markSent(ctx, notif)
 				}
-				log.Info("Batch sent successfully", map[string]interface{}{
-					"batch_size": len(batch),
-					"type":       notifType,
-				})
 			}
+
+			log.Info("Batch processed", map[string]interface{}{
+				"batch_size": len(batch),
+				"failed":     failed,
+				"type":       notifType,
+			})
 		}
 	}
 
 	return nil
 }
 
-// sendBatch sends a batch of notifications
-func (s *NotificationService) sendBatch(ctx context.Context, notifications []*ent.Notification, notifType notification.Type, messageType models.MessageType) error {
+// sendBatch sends a batch of notifications, returning one result per notification
+func (s *NotificationService) sendBatch(ctx context.Context, notifications []*ent.Notification, notifType notification.Type, config *models.PartnerConfig, messageType models.MessageType) ([]*models.SendResult, error) {
 	if len(notifications) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	tenantID := notifications[0].TenantID
@@ -242,24 +924,182 @@ func (s *NotificationService) sendBatch(ctx context.Context, notifications []*en
 	case notification.TypeEMAIL:
 		provider, err := s.emailManager.GetProvider(tenantID)
 		if err != nil {
-			return fmt.Errorf("failed to get email provider: %w", err)
+			return nil, fmt.Errorf("failed to get email provider: %w", err)
+		}
+		results, err := provider.SendBatch(ctx, notifications, messageType)
+		for _, result := range results {
+			s.recordAttempt(ctx, result, s.emailManager.GetProviderName(tenantID), provider.GetType())
 		}
-		return provider.SendBatch(ctx, notifications, messageType)
+		return results, err
 
 	case notification.TypeSMS:
 		provider, err := s.smsManager.GetProvider(tenantID)
 		if err != nil {
-			return fmt.Errorf("failed to get SMS provider: %w", err)
+			return nil, fmt.Errorf("failed to get SMS provider: %w", err)
+		}
+		results, err := provider.SendBatch(ctx, notifications, messageType)
+		for _, result := range results {
+			s.recordAttempt(ctx, result, s.smsManager.GetProviderName(tenantID), provider.GetType())
 		}
-		return provider.SendBatch(ctx, notifications, messageType)
+		return results, err
 
 	case notification.TypePUSH:
-		// TODO: Implement push provider batch sending
-		return fmt.Errorf("push notification batching not yet implemented")
+		provider, err := s.pushManager.GetProvider(tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get push provider: %w", err)
+		}
+		results, err := provider.SendBatch(ctx, notifications, messageType)
+		byID := make(map[int]*ent.Notification, len(notifications))
+		for _, notif := range notifications {
+			byID[notif.ID] = notif
+		}
+		for _, result := range results {
+			s.recordAttempt(ctx, result, s.pushManager.GetProviderName(tenantID), provider.GetType())
+			if result == nil {
+				continue
+			}
+			if notif, ok := byID[result.NotificationID]; ok {
+				s.pruneDevice(ctx, notif, result)
+			}
+		}
+		return results, err
+
+	case notification.TypeTELEGRAM:
+		provider, err := s.telegramManager.GetProvider(tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Telegram provider: %w", err)
+		}
+		results, err := provider.SendBatch(ctx, notifications, messageType)
+		for _, result := range results {
+			s.recordAttempt(ctx, result, s.telegramManager.GetProviderName(tenantID), provider.GetType())
+		}
+		return results, err
+
+	case notification.TypeVIBER:
+		provider, err := s.viberManager.GetProvider(tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Viber provider: %w", err)
+		}
+		smsFallbacks := s.smsFallbacks(ctx, notifications, config, messageType)
+		results, err := provider.SendBatch(ctx, notifications, messageType, smsFallbacks)
+		for _, result := range results {
+			s.recordAttempt(ctx, result, s.viberManager.GetProviderName(tenantID), provider.GetType())
+		}
+		return results, err
+
+	case notification.TypeWHATSAPP:
+		provider, err := s.whatsappManager.GetProvider(tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get WhatsApp provider: %w", err)
+		}
+		openSessions, err := s.whatsappManager.OpenSessions(ctx, tenantID, notifications)
+		if err != nil {
+			return nil, err
+		}
+		results, err := provider.SendBatch(ctx, notifications, messageType, openSessions)
+		for _, result := range results {
+			s.recordAttempt(ctx, result, s.whatsappManager.GetProviderName(tenantID), provider.GetType())
+		}
+		return results, err
+
+	case notification.TypeINAPP:
+		results := make([]*models.SendResult, 0, len(notifications))
+		for _, notif := range notifications {
+			results = append(results, models.NewSentResult(notif.ID, ""))
+		}
+		return results, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported notification type for batch: %s", notifType)
+	}
+}
+
+// recordAttempt stores a send result in the delivery history of its notification
+func (s *NotificationService) recordAttempt(ctx context.Context, result *models.SendResult, providerName, providerType string) {
+	if result == nil {
+		return
+	}
+
+	result.ProviderName = providerName
+	result.ProviderType = providerType
+
+	if _, err := s.attemptRepo.Create(ctx, result); err != nil {
+		s.logger.WithField("notification_id", result.NotificationID).
+			WithError(err).
+			Error("Failed to record delivery attempt")
+	}
+}
+
+// pruneDevice deactivates the device of a push notification whose token the provider
+// reported as invalid or unregistered, so later notifications no longer go to it
+func (s *NotificationService) pruneDevice(ctx context.Context, notif *ent.Notification, result *models.SendResult) {
+	if result == nil || result.ErrorClass != models.ErrorClassInvalidRecipient {
+		return
+	}
+
+	pruned, err := s.deviceRepo.Deactivate(ctx, notif.TenantID, string(notif.Address), repository.DeactivationInvalidToken)
+	if err != nil {
+		s.logger.WithField("notification_id", notif.ID).
+			WithError(err).
+			Error("Failed to deactivate invalid push token")
+		return
+	}
+	if pruned {
+		s.logger.WithFields(logrus.Fields{
+			"notification_id": notif.ID,
+			"tenant_id":       notif.TenantID,
+		}).Info("Deactivated device with invalid push token")
+	}
+}
 
+// markSent marks a notification SENT and pushes in-app notifications to the streams of their user.
+// A cascade step sent on a channel without delivery reports is as far as it will get, so it
+// settles the cascade right away.
+func (s *NotificationService) markSent(ctx context.Context, notif *ent.Notification) {
+	s.updateNotificationStatus(ctx, notif.ID, notification.StatusSENT, "")
+	if notif.CascadeID != "" && !s.reportsDelivery(notif) {
+		s.cascades.Settle(ctx, notif, models.StatusSent)
+	}
+	if notif.Type != notification.TypeINAPP {
+		return
+	}
+
+	message := NewInboxMessage(notif)
+	sentAt := time.Now()
+	message.SentAt = &sentAt
+
+	// The message is in the inbox either way, streams only save clients from polling for it
+	if err := s.realtime.Publish(ctx, notif.TenantID, string(notif.Address), message); err != nil {
+		s.logger.WithField("notification_id", notif.ID).
+			WithError(err).
+			Warn("Failed to publish in-app notification to streams")
+	}
+}
+
+// reportsDelivery tells whether the tenant's provider for the channel of a notification reports
+// delivery. Push and Telegram never do, in-app notifications report being read.
+func (s *NotificationService) reportsDelivery(notif *ent.Notification) bool {
+	var provider interface{}
+	var err error
+	switch notif.Type {
+	case notification.TypeINAPP:
+		return true
+	case notification.TypeEMAIL:
+		provider, err = s.emailManager.GetProvider(notif.TenantID)
+	case notification.TypeSMS:
+		provider, err = s.smsManager.GetProvider(notif.TenantID)
+	case notification.TypeVIBER:
+		provider, err = s.viberManager.GetProvider(notif.TenantID)
+	case notification.TypeWHATSAPP:
+		provider, err = s.whatsappManager.GetProvider(notif.TenantID)
 	default:
-		return fmt.Errorf("unsupported notification type for batch: %s", notifType)
+		return false
+	}
+	if err != nil {
+		return false
 	}
+	reporter, ok := provider.(providers.DeliveryReporter)
+	return ok && reporter.ReportsDelivery()
 }
 
 // updateNotificationStatus updates the status of a notification
@@ -273,6 +1113,13 @@ func (s *NotificationService) updateNotificationStatus(ctx context.Context, noti
 		s.logger.WithField("notification_id", notificationID).
 			WithError(err).
 			Error("Failed to update notification status")
+		return
+	}
+
+	// A step of a cascade that wasn't sent settles the cascade right away
+	if status == notification.StatusFAILED || status == notification.StatusSUPPRESSED ||
+		status == notification.StatusCAPPED || status == notification.StatusDUPLICATE {
+		s.cascades.SettleByID(ctx, notificationID, models.NotificationStatus(status))
 	}
 }
 
@@ -0,0 +1,581 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/ent"
+	"gitlab.smartbet.am/golang/notification/ent/device"
+	"gitlab.smartbet.am/golang/notification/ent/enttest"
+	"gitlab.smartbet.am/golang/notification/ent/notification"
+	"gitlab.smartbet.am/golang/notification/ent/schema"
+	"gitlab.smartbet.am/golang/notification/internal/config"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/providers"
+	"gitlab.smartbet.am/golang/notification/internal/realtime"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+)
+
+const testTenantID int64 = 1001
+
+// testService is a notification service backed by an in-memory SQLite database, without providers
+type testService struct {
+	*NotificationService
+	client *ent.Client
+	hub    *realtime.Hub
+}
+
+// newTestClient opens an ent client on an in-memory SQLite database private to the test
+func newTestClient(t *testing.T) *ent.Client {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	client := enttest.Open(t, "sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared&_fk=1", name))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+	client := newTestClient(t)
+	log := logrus.New()
+
+	notifRepo := repository.NewNotificationRepository(client, log)
+	contactRepo := repository.NewContactRepository(client, log)
+	hub := realtime.NewHub(log)
+	service := NewNotificationService(
+		notifRepo,
+		repository.NewDeliveryAttemptRepository(client, log),
+		repository.NewPartnerConfigRepository(client, log),
+		repository.NewSuppressionRepository(client, log),
+		repository.NewPreferenceRepository(client, log),
+		repository.NewSelfExclusionRepository(client, log),
+		contactRepo,
+		repository.NewDeviceRepository(client, log),
+		NewCascadeService(notifRepo, contactRepo, log),
+		NewDigestService(notifRepo, log),
+		realtime.NewBroadcaster(&config.Config{}, nil, hub, log),
+		nil, nil, nil, nil, nil, nil, nil,
+		log,
+	)
+	return &testService{NotificationService: service, client: client, hub: hub}
+}
+
+// create stores a pending notification of the test tenant
+func (s *testService) create(t *testing.T, notifType models.NotificationType, messageType models.MessageType, address, body string) *ent.Notification {
+	t.Helper()
+	req := &models.NotificationRequest{TenantID: testTenantID, Type: notifType, Body: body, MessageType: messageType}
+	notif, err := s.notifRepo.Create(context.Background(), req, address)
+	if err != nil {
+		t.Fatalf("failed to create notification: %v", err)
+	}
+	return notif
+}
+
+// status returns the current status and error message of a notification
+func (s *testService) status(t *testing.T, id int) (notification.Status, string) {
+	t.Helper()
+	notif, err := s.client.Notification.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to load notification: %v", err)
+	}
+	if notif.ErrorMessage == nil {
+		return notif.Status, ""
+	}
+	return notif.Status, *notif.ErrorMessage
+}
+
+// fakePushProvider accepts every push token except the invalid ones. Batches leave out the
+// results of dropped tokens.
+type fakePushProvider struct {
+	invalid map[string]bool
+	dropped map[string]bool
+	sent    []string
+	bodies  []string
+}
+
+func (p *fakePushProvider) Send(ctx context.Context, notif *ent.Notification, messageType models.MessageType) (*models.SendResult, error) {
+	p.sent = append(p.sent, string(notif.Address))
+	p.bodies = append(p.bodies, notif.Body)
+	if p.invalid[string(notif.Address)] {
+		err := fmt.Errorf("token not registered")
+		return &models.SendResult{
+			NotificationID: notif.ID,
+			Status:         models.StatusFailed,
+			ErrorClass:     models.ErrorClassInvalidRecipient,
+			Error:          err,
+		}, err
+	}
+	return &models.SendResult{NotificationID: notif.ID, Status: models.StatusSent, ProviderMessageID: "msg-" + string(notif.Address)}, nil
+}
+
+func (p *fakePushProvider) SendBatch(ctx context.Context, notifications []*ent.Notification, messageType models.MessageType) ([]*models.SendResult, error) {
+	results := make([]*models.SendResult, 0, len(notifications))
+	for _, notif := range notifications {
+		result, _ := p.Send(ctx, notif, messageType)
+		if !p.dropped[string(notif.Address)] {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+func (p *fakePushProvider) ValidateConfig() error { return nil }
+
+func (p *fakePushProvider) GetType() string { return "fake" }
+
+// withPushProvider makes the test tenant send push notifications through provider
+func (s *testService) withPushProvider(t *testing.T, provider providers.PushProvider) {
+	t.Helper()
+	registry := providers.NewProviderRegistry()
+	registry.RegisterPushProvider("fake", func(map[string]interface{}) (providers.PushProvider, error) {
+		return provider, nil
+	})
+	if err := s.configRepo.Save(context.Background(), &models.PartnerConfig{
+		ID:            "test",
+		TenantID:      testTenantID,
+		PushProviders: []schema.ProviderConfig{{Name: "primary", Type: "fake", Enabled: true}},
+		BatchConfig:   &schema.BatchConfig{Enabled: true, MaxBatchSize: 10},
+		Enabled:       true,
+	}); err != nil {
+		t.Fatalf("failed to store partner config: %v", err)
+	}
+	s.pushManager = providers.NewPushProviderManager(registry, s.configRepo, logrus.New())
+}
+
+// pendingIDs returns the IDs of the notifications left to send
+func pendingIDs(pending []*ent.Notification) []int {
+	ids := make([]int, 0, len(pending))
+	for _, notif := range pending {
+		ids = append(ids, notif.ID)
+	}
+	return ids
+}
+
+func TestSkipSuppressed(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	expired := time.Now().Add(-time.Hour)
+	for _, req := range []*models.SuppressionRequest{
+		{TenantID: testTenantID, Channel: models.TypeEmail, Address: "Bounced@Example.org", Reason: models.SuppressionReasonHardBounce},
+		{TenantID: testTenantID, Channel: models.TypeSMS, Address: "+37499000001", Reason: models.SuppressionReasonManual},
+		{TenantID: testTenantID, Channel: models.TypeEmail, Address: "expired@example.org", Reason: models.SuppressionReasonComplaint, ExpiresAt: &expired},
+		{TenantID: testTenantID + 1, Channel: models.TypeEmail, Address: "other-tenant@example.org", Reason: models.SuppressionReasonManual},
+	} {
+		if _, err := s.suppressionRepo.Suppress(ctx, req); err != nil {
+			t.Fatalf("failed to suppress address: %v", err)
+		}
+	}
+
+	bounced := s.create(t, models.TypeEmail, models.MessageTypePayment, "bounced@example.org", "Deposit received")
+	suppressedSMS := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000001", "Deposit received")
+	// Suppressed on another channel only
+	otherChannel := s.create(t, models.TypeEmail, models.MessageTypePayment, "+37499000001", "Deposit received")
+	expiredEntry := s.create(t, models.TypeEmail, models.MessageTypePayment, "expired@example.org", "Deposit received")
+	otherTenant := s.create(t, models.TypeEmail, models.MessageTypePayment, "other-tenant@example.org", "Deposit received")
+
+	pending, err := s.skipSuppressed(ctx, []*ent.Notification{bounced, suppressedSMS, otherChannel, expiredEntry, otherTenant}, &models.PartnerConfig{}, models.MessageTypePayment)
+	if err != nil {
+		t.Fatalf("skipSuppressed() error = %v", err)
+	}
+
+	want := []int{otherChannel.ID, expiredEntry.ID, otherTenant.ID}
+	if got := pendingIDs(pending); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("pending = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name       string
+		notif      *ent.Notification
+		wantStatus notification.Status
+		wantReason string
+	}{
+		{name: "hard bounce", notif: bounced, wantStatus: notification.StatusSUPPRESSED, wantReason: "address suppressed: hard_bounce"},
+		{name: "manual", notif: suppressedSMS, wantStatus: notification.StatusSUPPRESSED, wantReason: "address suppressed: manual"},
+		{name: "other channel", notif: otherChannel, wantStatus: notification.StatusPENDING},
+		{name: "expired", notif: expiredEntry, wantStatus: notification.StatusPENDING},
+		{name: "other tenant", notif: otherTenant, wantStatus: notification.StatusPENDING},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reason := s.status(t, tt.notif.ID)
+			if status != tt.wantStatus || reason != tt.wantReason {
+				t.Errorf("status = %s (%q), want %s (%q)", status, reason, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestSkipSuppressedOptOut(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	preferences := []struct {
+		address string
+		pref    *models.ChannelPreference
+	}{
+		{address: "+37499000001", pref: &models.ChannelPreference{Channel: models.TypeSMS, MessageType: models.MessageTypePromo}},
+		{address: "+37499000002", pref: &models.ChannelPreference{Channel: models.TypeSMS, MessageType: models.MessageTypeBonus}},
+		{address: "+37499000003", pref: &models.ChannelPreference{Channel: models.TypeSMS, MessageType: models.MessageTypePromo, OptedIn: true}},
+		{address: "+37499000004", pref: &models.ChannelPreference{Channel: models.TypeEmail, MessageType: models.MessageTypePromo}},
+	}
+	for _, p := range preferences {
+		if err := s.preferenceRepo.Set(ctx, testTenantID, "", p.address, p.pref, "api"); err != nil {
+			t.Fatalf("failed to store preference: %v", err)
+		}
+	}
+
+	// Opted out by user ID only, which covers the phone of the contact
+	if _, err := s.contactRepo.Upsert(ctx, testTenantID, &models.ContactRequest{UserID: "player-5", Phone: "+37499000005"}); err != nil {
+		t.Fatalf("failed to store contact: %v", err)
+	}
+	if err := s.preferenceRepo.Set(ctx, testTenantID, "player-5", "", &models.ChannelPreference{Channel: models.TypeSMS, MessageType: models.MessageTypePromo}, "api"); err != nil {
+		t.Fatalf("failed to store preference: %v", err)
+	}
+
+	optedOut := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000001", "Offer")
+	otherType := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000002", "Offer")
+	optedIn := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000003", "Offer")
+	otherChannel := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000004", "Offer")
+	optedOutUser := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000005", "Offer")
+
+	pending, err := s.skipSuppressed(ctx, []*ent.Notification{optedOut, otherType, optedIn, otherChannel, optedOutUser}, &models.PartnerConfig{}, models.MessageTypePromo)
+	if err != nil {
+		t.Fatalf("skipSuppressed() error = %v", err)
+	}
+	want := []int{otherType.ID, optedIn.ID, otherChannel.ID}
+	if got := pendingIDs(pending); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("pending = %v, want %v", got, want)
+	}
+	for _, notif := range []*ent.Notification{optedOut, optedOutUser} {
+		if status, reason := s.status(t, notif.ID); status != notification.StatusSUPPRESSED || reason != "recipient opted out of promo messages" {
+			t.Errorf("notification %d status = %s (%q), want SUPPRESSED for the opt-out", notif.ID, status, reason)
+		}
+	}
+
+	// Payment messages are always delivered
+	payment := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000001", "Deposit received")
+	if err := s.preferenceRepo.Set(ctx, testTenantID, "", "+37499000001", &models.ChannelPreference{Channel: models.TypeSMS, MessageType: models.MessageTypePayment}, "api"); err != nil {
+		t.Fatalf("failed to store preference: %v", err)
+	}
+	pending, err = s.skipSuppressed(ctx, []*ent.Notification{payment}, &models.PartnerConfig{}, models.MessageTypePayment)
+	if err != nil || len(pending) != 1 {
+		t.Errorf("skipSuppressed() of a payment message = %v, %v, want it pending", pendingIDs(pending), err)
+	}
+}
+
+func TestSkipSuppressedSelfExclusion(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	if _, err := s.selfExclusionRepo.Sync(ctx, testTenantID, &models.SelfExclusionRequest{
+		UserID:    "player-1",
+		Addresses: []string{"Player@Example.org", "+37499000001"},
+	}, "api"); err != nil {
+		t.Fatalf("failed to store self-exclusion: %v", err)
+	}
+	started, ended := time.Now().Add(-48*time.Hour), time.Now().Add(-time.Hour)
+	if _, err := s.selfExclusionRepo.Sync(ctx, testTenantID, &models.SelfExclusionRequest{
+		Addresses: []string{"+37499000002"},
+		StartsAt:  &started,
+		EndsAt:    &ended,
+	}, "api"); err != nil {
+		t.Fatalf("failed to store self-exclusion: %v", err)
+	}
+
+	// Self-excluded by email, which covers the phone of the same user
+	if _, err := s.contactRepo.Upsert(ctx, testTenantID, &models.ContactRequest{UserID: "player-3", Email: "player3@example.org", Phone: "+37499000003"}); err != nil {
+		t.Fatalf("failed to store contact: %v", err)
+	}
+	if _, err := s.selfExclusionRepo.Sync(ctx, testTenantID, &models.SelfExclusionRequest{UserID: "player-3", Addresses: []string{"player3@example.org"}}, "api"); err != nil {
+		t.Fatalf("failed to store self-exclusion: %v", err)
+	}
+
+	// Self-excluded in E.164, notified in the international format with a 00 prefix
+	if _, err := s.selfExclusionRepo.Sync(ctx, testTenantID, &models.SelfExclusionRequest{Addresses: []string{"+37499000004"}}, "api"); err != nil {
+		t.Fatalf("failed to store self-exclusion: %v", err)
+	}
+
+	excludedEmail := s.create(t, models.TypeEmail, models.MessageTypePromo, "player@example.org", "Offer")
+	excludedPhone := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000001", "Offer")
+	excludedUser := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000003", "Offer")
+	excludedE164 := s.create(t, models.TypeSMS, models.MessageTypePromo, "0037499000004", "Offer")
+	lifted := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000002", "Offer")
+
+	pending, err := s.skipSuppressed(ctx, []*ent.Notification{excludedEmail, excludedPhone, excludedUser, excludedE164, lifted}, &models.PartnerConfig{}, models.MessageTypePromo)
+	if err != nil {
+		t.Fatalf("skipSuppressed() error = %v", err)
+	}
+	if got := pendingIDs(pending); fmt.Sprint(got) != fmt.Sprint([]int{lifted.ID}) {
+		t.Errorf("pending = %v, want %v", got, []int{lifted.ID})
+	}
+	for _, notif := range []*ent.Notification{excludedEmail, excludedPhone, excludedUser, excludedE164} {
+		if status, reason := s.status(t, notif.ID); status != notification.StatusSUPPRESSED || reason != "recipient is self-excluded" {
+			t.Errorf("notification %d status = %s (%q), want SUPPRESSED for the self-exclusion", notif.ID, status, reason)
+		}
+	}
+
+	blocks, total, err := s.selfExclusionRepo.ListBlocks(ctx, testTenantID, repository.SelfExclusionBlockFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("failed to list self-exclusion blocks: %v", err)
+	}
+	if total != 4 || len(blocks) != 4 {
+		t.Fatalf("got %d self-exclusion blocks, want 4", total)
+	}
+	users := map[string]int{}
+	for _, block := range blocks {
+		users[block.UserID]++
+	}
+	if users["player-1"] != 2 || users["player-3"] != 1 {
+		t.Errorf("self-exclusion blocks by user = %v, want 2 of player-1 and 1 of player-3", users)
+	}
+
+	// Transactional messages ignore self-exclusions
+	payment := s.create(t, models.TypeEmail, models.MessageTypePayment, "player@example.org", "Deposit received")
+	pending, err = s.skipSuppressed(ctx, []*ent.Notification{payment}, &models.PartnerConfig{}, models.MessageTypePayment)
+	if err != nil || len(pending) != 1 {
+		t.Errorf("skipSuppressed() of a payment message = %v, %v, want it pending", pendingIDs(pending), err)
+	}
+}
+
+func TestProcessNotificationPushFanOut(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	provider := &fakePushProvider{invalid: map[string]bool{"token-stale": true}}
+	s.withPushProvider(t, provider)
+
+	devices := repository.NewDeviceRepository(s.client, logrus.New())
+	for _, req := range []*models.DeviceRegisterRequest{
+		{UserID: "player-1", Platform: models.PlatformAndroid, Token: "token-phone"},
+		{UserID: "player-1", Platform: models.PlatformIOS, Token: "token-tablet"},
+		{UserID: "player-1", Platform: models.PlatformWeb, Token: "token-stale"},
+		{UserID: "player-2", Platform: models.PlatformAndroid, Token: "token-other"},
+	} {
+		if _, err := devices.Register(ctx, testTenantID, req); err != nil {
+			t.Fatalf("failed to register device: %v", err)
+		}
+	}
+
+	err := s.ProcessNotification(ctx, &models.NotificationRequest{
+		TenantID:    testTenantID,
+		Type:        models.TypePush,
+		UserIDs:     []string{"player-1"},
+		Headline:    "Bonus",
+		Body:        "Your bonus is ready",
+		MessageType: models.MessageTypeBonus,
+	})
+	if err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+
+	// Every device of the user gets the notification
+	if fmt.Sprint(provider.sent) != fmt.Sprint([]string{"token-phone", "token-tablet", "token-stale"}) {
+		t.Errorf("sent to %v, want every device of player-1", provider.sent)
+	}
+
+	// The token the provider rejected is no longer used
+	active, err := devices.ListByUser(ctx, testTenantID, "player-1", true)
+	if err != nil {
+		t.Fatalf("failed to list devices: %v", err)
+	}
+	var tokens []string
+	for _, entry := range active {
+		tokens = append(tokens, entry.Token)
+		if entry.Token == "token-stale" {
+			t.Error("the invalid token must be deactivated")
+		}
+	}
+	if len(tokens) != 2 {
+		t.Errorf("active devices = %v, want the phone and the tablet", tokens)
+	}
+
+	stale, err := s.client.Device.Query().Where(device.Token("token-stale")).Only(ctx)
+	if err != nil {
+		t.Fatalf("failed to load device: %v", err)
+	}
+	if stale.DeactivationReason != repository.DeactivationInvalidToken {
+		t.Errorf("deactivation reason = %q, want %q", stale.DeactivationReason, repository.DeactivationInvalidToken)
+	}
+}
+
+func TestProcessNotificationBatchMissingResult(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	s.withPushProvider(t, &fakePushProvider{dropped: map[string]bool{"token-lost": true}})
+
+	err := s.ProcessNotification(ctx, &models.NotificationRequest{
+		RequestID:   "req-batch",
+		TenantID:    testTenantID,
+		Type:        models.TypePush,
+		Recipients:  []string{"token-ok", "token-lost"},
+		Body:        "Your bonus is ready",
+		MessageType: models.MessageTypeBonus,
+	})
+	if err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+
+	notifications, err := s.client.Notification.Query().All(ctx)
+	if err != nil {
+		t.Fatalf("failed to load notifications: %v", err)
+	}
+	for _, notif := range notifications {
+		want := notification.StatusSENT
+		if notif.Address == "token-lost" {
+			want = notification.StatusFAILED
+		}
+		if notif.Status != want {
+			t.Errorf("status of %s = %s, want %s", notif.Address, notif.Status, want)
+		}
+	}
+}
+
+func TestSkipSuppressedFrequencyCap(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	config := &models.PartnerConfig{
+		FrequencyCaps: []schema.FrequencyCap{{Channel: "SMS", MessageType: "promo", Limit: 2, Window: "24h"}},
+	}
+
+	// One promo SMS already sent today, the cap allows two
+	sent := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000001", "Yesterday's offer")
+	if err := s.notifRepo.UpdateStatus(ctx, sent.ID, notification.StatusSENT, nil); err != nil {
+		t.Fatalf("failed to send notification: %v", err)
+	}
+
+	belowCap := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000001", "Offer")
+	capped := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000001", "Another offer")
+	otherAddress := s.create(t, models.TypeSMS, models.MessageTypePromo, "+37499000002", "Offer")
+	otherChannel := s.create(t, models.TypeEmail, models.MessageTypePromo, "+37499000001", "Offer")
+
+	pending, err := s.skipSuppressed(ctx, []*ent.Notification{belowCap, capped, otherAddress, otherChannel}, config, models.MessageTypePromo)
+	if err != nil {
+		t.Fatalf("skipSuppressed() error = %v", err)
+	}
+	want := []int{belowCap.ID, otherAddress.ID, otherChannel.ID}
+	if got := pendingIDs(pending); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("pending = %v, want %v", got, want)
+	}
+	if status, reason := s.status(t, capped.ID); status != notification.StatusCAPPED || reason != "frequency cap reached: 2 promo SMS per 24h" {
+		t.Errorf("status = %s (%q), want CAPPED", status, reason)
+	}
+
+	// Caps only count the message type they are set for
+	bonus := s.create(t, models.TypeSMS, models.MessageTypeBonus, "+37499000001", "Bonus")
+	pending, err = s.skipSuppressed(ctx, []*ent.Notification{bonus}, config, models.MessageTypeBonus)
+	if err != nil || len(pending) != 1 {
+		t.Errorf("skipSuppressed() of a bonus message = %v, %v, want it pending", pendingIDs(pending), err)
+	}
+}
+
+func TestDuplicatesOf(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	config := &models.PartnerConfig{DedupWindows: map[string]string{"payment": "1h"}}
+
+	first := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000001", "Deposit received")
+	if err := s.notifRepo.UpdateStatus(ctx, first.ID, notification.StatusSENT, nil); err != nil {
+		t.Fatalf("failed to send notification: %v", err)
+	}
+
+	// The same content sent outside the window isn't repeated by the later ones
+	_, err := s.client.Notification.Create().
+		SetRequestID("old").
+		SetTenantID(testTenantID).
+		SetType(notification.TypeSMS).
+		SetBody("Deposit received").
+		SetAddress("+37499000001").
+		SetStatus(notification.StatusSENT).
+		SetMessageType("payment").
+		SetContentHash(first.ContentHash).
+		SetCreateTime(time.Now().Add(-2 * time.Hour)).
+		Save(ctx)
+	if err != nil {
+		t.Fatalf("failed to create notification: %v", err)
+	}
+
+	failed := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000002", "Deposit received")
+	if err := s.notifRepo.UpdateStatus(ctx, failed.ID, notification.StatusFAILED, nil); err != nil {
+		t.Fatalf("failed to fail notification: %v", err)
+	}
+	second := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000001", "Deposit received")
+	third := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000001", "Deposit received")
+	retried := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000002", "Deposit received")
+	otherBody := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000001", "Withdrawal sent")
+	otherChannel := s.create(t, models.TypeEmail, models.MessageTypePayment, "+37499000001", "Deposit received")
+	// Notifications that weren't sent yet may never be, they aren't repeated by the later ones
+	unsent := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000003", "Deposit received")
+	afterUnsent := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000003", "Deposit received")
+
+	batch := []*ent.Notification{first, second, third, retried, otherBody, otherChannel, unsent, afterUnsent}
+
+	duplicates, err := s.duplicatesOf(ctx, testTenantID, batch, config, models.MessageTypePayment)
+	if err != nil {
+		t.Fatalf("duplicatesOf() error = %v", err)
+	}
+
+	want := map[int]int{second.ID: first.ID, third.ID: first.ID}
+	if len(duplicates) != len(want) {
+		t.Errorf("got %d duplicates, want %d", len(duplicates), len(want))
+	}
+	for id, originalID := range want {
+		if original, ok := duplicates[id]; !ok || original.ID != originalID {
+			t.Errorf("notification %d: got original %v, want %d", id, original, originalID)
+		}
+	}
+	for _, notif := range []*ent.Notification{first, retried, otherBody, otherChannel, unsent, afterUnsent} {
+		if original, ok := duplicates[notif.ID]; ok {
+			t.Errorf("notification %d marked duplicate of %d", notif.ID, original.ID)
+		}
+	}
+
+	// Message types without a window are never deduplicated
+	duplicates, err = s.duplicatesOf(ctx, testTenantID, batch, config, models.MessageTypePromo)
+	if err != nil || len(duplicates) != 0 {
+		t.Errorf("duplicatesOf() without window = %v, %v, want none", duplicates, err)
+	}
+
+	invalid := &models.PartnerConfig{DedupWindows: map[string]string{"payment": "soon"}}
+	if _, err := s.duplicatesOf(ctx, testTenantID, batch, invalid, models.MessageTypePayment); err == nil {
+		t.Error("duplicatesOf() with an invalid window must fail")
+	}
+}
+
+func TestSkipSuppressedDuplicatesInBatch(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	config := &models.PartnerConfig{DedupWindows: map[string]string{"payment": "1h"}}
+
+	if _, err := s.suppressionRepo.Suppress(ctx, &models.SuppressionRequest{
+		TenantID: testTenantID, Channel: models.TypeSMS, Address: "+37499000002", Reason: models.SuppressionReasonManual,
+	}); err != nil {
+		t.Fatalf("failed to suppress address: %v", err)
+	}
+
+	first := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000001", "Deposit received")
+	repeated := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000001", "Deposit received")
+	suppressed := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000002", "Deposit received")
+	afterSuppressed := s.create(t, models.TypeSMS, models.MessageTypePayment, "+37499000002", "Deposit received")
+
+	pending, err := s.skipSuppressed(ctx, []*ent.Notification{first, repeated, suppressed, afterSuppressed}, config, models.MessageTypePayment)
+	if err != nil {
+		t.Fatalf("skipSuppressed() error = %v", err)
+	}
+	if got := pendingIDs(pending); len(got) != 1 || got[0] != first.ID {
+		t.Errorf("pending = %v, want [%d]", got, first.ID)
+	}
+
+	// Only the notification let through is repeated, the suppressed one never goes out
+	if status, _ := s.status(t, repeated.ID); status != notification.StatusDUPLICATE {
+		t.Errorf("repeated notification = %s, want DUPLICATE", status)
+	}
+	if status, _ := s.status(t, afterSuppressed.ID); status != notification.StatusSUPPRESSED {
+		t.Errorf("notification after the suppressed one = %s, want SUPPRESSED", status)
+	}
+}
@@ -0,0 +1,104 @@
+package unsubscribe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"gitlab.smartbet.am/golang/notification/internal/config"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+// Placeholder is replaced with the recipient's unsubscribe link in notification bodies and headlines
+const Placeholder = "{{unsubscribe_url}}"
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed or not signed with our secret
+	ErrInvalidToken = errors.New("invalid unsubscribe token")
+	// ErrNotConfigured is returned when no secret is configured to sign tokens with
+	ErrNotConfigured = errors.New("unsubscribe links are not configured")
+)
+
+// Token identifies what a recipient unsubscribes from
+type Token struct {
+	TenantID    int64                   `json:"t"`
+	Channel     models.NotificationType `json:"c"`
+	Address     string                  `json:"a"`
+	MessageType models.MessageType      `json:"m"`
+}
+
+// Links signs and verifies unsubscribe tokens and builds the public links carrying them.
+// Tokens don't expire, links in old messages keep working as long as the secret is unchanged.
+type Links struct {
+	secret []byte
+	config *config.Config
+}
+
+func NewLinks(cfg *config.Config) *Links {
+	return &Links{
+		secret: []byte(cfg.Unsubscribe.Secret),
+		config: cfg,
+	}
+}
+
+// Sign encodes the token as base64url JSON followed by its HMAC-SHA256 signature
+func (l *Links) Sign(token *Token) (string, error) {
+	if len(l.secret) == 0 {
+		return "", ErrNotConfigured
+	}
+
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + l.signature(encoded), nil
+}
+
+// Verify checks the signature of a signed token and decodes it
+func (l *Links) Verify(value string) (*Token, error) {
+	if len(l.secret) == 0 {
+		return nil, ErrNotConfigured
+	}
+
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(l.signature(encoded))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var token Token
+	if err := json.Unmarshal(payload, &token); err != nil || token.TenantID == 0 || token.Address == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return &token, nil
+}
+
+// URL returns the public unsubscribe link of the token
+func (l *Links) URL(token *Token) (string, error) {
+	signed, err := l.Sign(token)
+	if err != nil {
+		return "", err
+	}
+
+	link := l.config.GetUnsubscribeURL(signed)
+	if link == "" {
+		return "", ErrNotConfigured
+	}
+	return link, nil
+}
+
+func (l *Links) signature(encoded string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,82 @@
+package unsubscribe
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gitlab.smartbet.am/golang/notification/internal/config"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+)
+
+func newTestLinks(secret, baseURL string) *Links {
+	cfg := &config.Config{}
+	cfg.Unsubscribe.Secret = secret
+	cfg.Unsubscribe.BaseURL = baseURL
+	return NewLinks(cfg)
+}
+
+func TestSignVerify(t *testing.T) {
+	links := newTestLinks("secret", "")
+	token := &Token{TenantID: 1001, Channel: models.TypeEmail, Address: "player@example.org", MessageType: models.MessageTypePromo}
+
+	signed, err := links.Sign(token)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	verified, err := links.Verify(signed)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if *verified != *token {
+		t.Errorf("Verify() = %+v, want %+v", verified, token)
+	}
+
+	encoded, signature, _ := strings.Cut(signed, ".")
+	other, err := newTestLinks("other-secret", "").Sign(token)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	empty, err := links.Sign(&Token{TenantID: 1001, Channel: models.TypeEmail})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "other secret", value: other},
+		{name: "modified payload", value: encoded + "x." + signature},
+		{name: "modified signature", value: encoded + "." + signature[1:]},
+		{name: "no signature", value: encoded},
+		{name: "without address", value: empty},
+		{name: "empty", value: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := links.Verify(tt.value); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify() error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestURL(t *testing.T) {
+	token := &Token{TenantID: 1001, Channel: models.TypeSMS, Address: "+37499123456", MessageType: models.MessageTypeBonus}
+
+	link, err := newTestLinks("secret", "https://notify.example.com/").URL(token)
+	if err != nil {
+		t.Fatalf("URL() error = %v", err)
+	}
+	if !strings.HasPrefix(link, "https://notify.example.com/u/") {
+		t.Errorf("URL() = %q, want a link below https://notify.example.com/u/", link)
+	}
+
+	if _, err := newTestLinks("secret", "").URL(token); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("URL() without a base URL error = %v, want ErrNotConfigured", err)
+	}
+	if _, err := newTestLinks("", "https://notify.example.com").URL(token); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("URL() without a secret error = %v, want ErrNotConfigured", err)
+	}
+}
@@ -0,0 +1,127 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/providers/email"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+	"gitlab.smartbet.am/golang/notification/internal/services"
+)
+
+const (
+	bouncePollInterval = 2 * time.Minute
+	bouncePollLimit    = 200
+)
+
+// BouncePollWorker drains the bounce mailboxes of tenants whose SMTP provider has one,
+// turning the DSNs and abuse reports in them into email events
+type BouncePollWorker struct {
+	configRepo *repository.PartnerConfigRepository
+	receipts   *services.DeliveryReceiptService
+	logger     *logrus.Logger
+	ticker     *time.Ticker
+	stopChan   chan struct{}
+}
+
+func NewBouncePollWorker(
+	configRepo *repository.PartnerConfigRepository,
+	receipts *services.DeliveryReceiptService,
+	logger *logrus.Logger,
+) *BouncePollWorker {
+	return &BouncePollWorker{
+		configRepo: configRepo,
+		receipts:   receipts,
+		logger:     logger,
+		stopChan:   make(chan struct{}),
+	}
+}
+
+func (w *BouncePollWorker) Start(ctx context.Context) error {
+	w.ticker = time.NewTicker(bouncePollInterval)
+
+	go w.run(ctx)
+
+	w.logger.Info("Bounce poll worker started")
+	return nil
+}
+
+func (w *BouncePollWorker) Stop() {
+	if w.ticker != nil {
+		w.ticker.Stop()
+	}
+	close(w.stopChan)
+}
+
+func (w *BouncePollWorker) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Bounce poll worker stopping due to context cancellation")
+			return
+		case <-w.stopChan:
+			w.logger.Info("Bounce poll worker stopping")
+			return
+		case <-w.ticker.C:
+			w.pollBounceMailboxes(ctx)
+		}
+	}
+}
+
+func (w *BouncePollWorker) pollBounceMailboxes(ctx context.Context) {
+	configs, err := w.configRepo.GetAllEnabled(ctx)
+	if err != nil {
+		w.logger.WithError(err).Error("Failed to get partner configs")
+		return
+	}
+
+	for _, partnerConfig := range configs {
+		for _, provider := range partnerConfig.EmailProviders {
+			if !provider.Enabled || provider.Type != "smtp" {
+				continue
+			}
+
+			mailbox, err := email.NewBounceMailbox(provider.Config)
+			if err != nil {
+				w.logger.WithField("tenant_id", partnerConfig.TenantID).WithError(err).Error("Invalid bounce mailbox config")
+				continue
+			}
+			if mailbox == nil {
+				continue
+			}
+
+			w.pollBounceMailbox(ctx, partnerConfig.TenantID, mailbox)
+		}
+	}
+}
+
+func (w *BouncePollWorker) pollBounceMailbox(ctx context.Context, tenantID int64, mailbox *email.BounceMailbox) {
+	log := w.logger.WithField("tenant_id", tenantID)
+
+	handled, err := mailbox.Poll(ctx, bouncePollLimit, func(raw []byte) error {
+		report, err := email.ParseBounceReport(raw)
+		if err != nil {
+			// Anything else in the bounce mailbox (auto replies, spam) is dropped
+			log.WithError(err).Debug("Skipping bounce mailbox message")
+			return nil
+		}
+
+		for _, event := range report.Events(tenantID) {
+			if err := w.receipts.ApplyEmailEvent(ctx, event); err != nil && !errors.Is(err, services.ErrUnknownProviderMessage) {
+				// Keep the message in the mailbox so it is retried on the next poll
+				log.WithField("message_id", event.MessageID).WithError(err).Error("Failed to apply bounce report")
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to poll bounce mailbox")
+	}
+
+	if handled > 0 {
+		log.WithField("count", handled).Info("Processed bounce mailbox messages")
+	}
+}
@@ -0,0 +1,86 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+	"gitlab.smartbet.am/golang/notification/internal/services"
+)
+
+// DigestWorker sends the digests whose window has passed
+type DigestWorker struct {
+	notifRepo       *repository.NotificationRepository
+	notificationSvc *services.NotificationService
+	logger          *logrus.Logger
+	ticker          *time.Ticker
+	stopChan        chan struct{}
+}
+
+func NewDigestWorker(
+	notifRepo *repository.NotificationRepository,
+	notificationSvc *services.NotificationService,
+	logger *logrus.Logger,
+) *DigestWorker {
+	return &DigestWorker{
+		notifRepo:       notifRepo,
+		notificationSvc: notificationSvc,
+		logger:          logger,
+		stopChan:        make(chan struct{}),
+	}
+}
+
+func (w *DigestWorker) Start(ctx context.Context) error {
+	w.ticker = time.NewTicker(30 * time.Second)
+
+	go w.run(ctx)
+
+	w.logger.Info("Digest worker started")
+	return nil
+}
+
+func (w *DigestWorker) Stop() {
+	if w.ticker != nil {
+		w.ticker.Stop()
+	}
+	close(w.stopChan)
+}
+
+func (w *DigestWorker) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Digest worker stopping due to context cancellation")
+			return
+		case <-w.stopChan:
+			w.logger.Info("Digest worker stopping")
+			return
+		case <-w.ticker.C:
+			w.sendDueDigests(ctx)
+		}
+	}
+}
+
+func (w *DigestWorker) sendDueDigests(ctx context.Context) {
+	groups, err := w.notifRepo.GetDueDigests(ctx, time.Now())
+	if err != nil {
+		w.logger.WithError(err).Error("Failed to get due digests")
+		return
+	}
+
+	if len(groups) == 0 {
+		return
+	}
+
+	w.logger.WithField("count", len(groups)).Info("Sending due digests")
+
+	for _, group := range groups {
+		if err := w.notificationSvc.SendDigest(ctx, group); err != nil {
+			w.logger.WithFields(logrus.Fields{
+				"tenant_id":  group.TenantID,
+				"digest_key": group.DigestKey,
+			}).WithError(err).Error("Failed to send digest")
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/providers/sms"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+	"gitlab.smartbet.am/golang/notification/internal/services"
+)
+
+const (
+	dlrPollInterval = 5 * time.Minute
+	// Reports are only polled for messages sent within the window, older ones are considered lost
+	dlrPollWindow = 48 * time.Hour
+	// Give pushed reports a chance to arrive before polling for them
+	dlrPollMinAge = time.Minute
+	dlrPollLimit  = 500
+	// Messages without a report are asked for again after the interval, up to the maximum
+	dlrRepollInterval = time.Hour
+	dlrMaxPolls       = 5
+)
+
+// DLRPollWorker fetches Nikita delivery reports for tenants whose custom SMS provider
+// has a dlr_poll_url, complementing the reports pushed to the DLR webhook
+type DLRPollWorker struct {
+	attemptRepo *repository.DeliveryAttemptRepository
+	configRepo  *repository.PartnerConfigRepository
+	receipts    *services.DeliveryReceiptService
+	logger      *logrus.Logger
+	ticker      *time.Ticker
+	stopChan    chan struct{}
+}
+
+func NewDLRPollWorker(
+	attemptRepo *repository.DeliveryAttemptRepository,
+	configRepo *repository.PartnerConfigRepository,
+	receipts *services.DeliveryReceiptService,
+	logger *logrus.Logger,
+) *DLRPollWorker {
+	return &DLRPollWorker{
+		attemptRepo: attemptRepo,
+		configRepo:  configRepo,
+		receipts:    receipts,
+		logger:      logger,
+		stopChan:    make(chan struct{}),
+	}
+}
+
+func (w *DLRPollWorker) Start(ctx context.Context) error {
+	w.ticker = time.NewTicker(dlrPollInterval)
+
+	go w.run(ctx)
+
+	w.logger.Info("DLR poll worker started")
+	return nil
+}
+
+func (w *DLRPollWorker) Stop() {
+	if w.ticker != nil {
+		w.ticker.Stop()
+	}
+	close(w.stopChan)
+}
+
+func (w *DLRPollWorker) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("DLR poll worker stopping due to context cancellation")
+			return
+		case <-w.stopChan:
+			w.logger.Info("DLR poll worker stopping")
+			return
+		case <-w.ticker.C:
+			w.pollDeliveryReports(ctx)
+		}
+	}
+}
+
+func (w *DLRPollWorker) pollDeliveryReports(ctx context.Context) {
+	now := time.Now()
+
+	providers, err := w.pollingProviders(ctx)
+	if err != nil {
+		w.logger.WithError(err).Error("Failed to get tenants polling delivery reports")
+		return
+	}
+	if len(providers) == 0 {
+		return
+	}
+
+	tenantIDs := make([]int64, 0, len(providers))
+	for tenantID := range providers {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+
+	attempts, err := w.attemptRepo.GetAwaitingReceipt(ctx, "custom", tenantIDs, now.Add(-dlrPollWindow), now.Add(-dlrPollMinAge),
+		now.Add(-dlrRepollInterval), dlrMaxPolls, dlrPollLimit)
+	if err != nil {
+		w.logger.WithError(err).Error("Failed to get delivery attempts awaiting reports")
+		return
+	}
+
+	// Promotional and transactional messages are sent from different Nikita accounts
+	type pollKey struct {
+		tenantID    int64
+		promotional bool
+	}
+	messageIDs := make(map[pollKey][]string)
+	attemptIDs := make(map[pollKey][]int)
+	for _, attempt := range attempts {
+		notif := attempt.Edges.Notification
+		if notif == nil {
+			continue
+		}
+		key := pollKey{
+			tenantID:    notif.TenantID,
+			promotional: models.MessageType(notif.MessageType).IsPromotional(),
+		}
+		messageIDs[key] = append(messageIDs[key], attempt.ProviderMessageID)
+		attemptIDs[key] = append(attemptIDs[key], attempt.ID)
+	}
+
+	for key, ids := range messageIDs {
+		provider := providers[key.tenantID]
+
+		messageType := models.MessageTypeSystem
+		if key.promotional {
+			messageType = models.MessageTypePromo
+		}
+
+		reports, err := provider.FetchDeliveryReports(ctx, messageType, ids)
+		if err != nil {
+			w.logger.WithField("tenant_id", key.tenantID).
+				WithError(err).
+				Error("Failed to fetch Nikita delivery reports")
+			continue
+		}
+
+		// Messages the provider has no report for yet are asked for again after a while
+		if err := w.attemptRepo.MarkReceiptPolled(ctx, attemptIDs[key], now); err != nil {
+			w.logger.WithField("tenant_id", key.tenantID).
+				WithError(err).
+				Error("Failed to record polled delivery attempts")
+		}
+
+		applied := 0
+		for i := range reports {
+			if err := w.receipts.Apply(ctx, services.NikitaReceipt(key.tenantID, &reports[i])); err != nil {
+				if !errors.Is(err, services.ErrUnknownProviderMessage) {
+					w.logger.WithField("tenant_id", key.tenantID).
+						WithField("message_id", reports[i].MessageID).
+						WithError(err).
+						Error("Failed to apply Nikita delivery report")
+				}
+				continue
+			}
+			applied++
+		}
+
+		w.logger.WithFields(logrus.Fields{
+			"tenant_id": key.tenantID,
+			"requested": len(ids),
+			"applied":   applied,
+		}).Info("Polled Nikita delivery reports")
+	}
+}
+
+// pollingProviders returns the enabled Nikita providers of the tenants that poll for delivery
+// reports, keyed by tenant ID
+func (w *DLRPollWorker) pollingProviders(ctx context.Context) (map[int64]*sms.CustomProvider, error) {
+	configs, err := w.configRepo.GetAllEnabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	providers := make(map[int64]*sms.CustomProvider)
+	for _, partnerConfig := range configs {
+		for _, providerConfig := range partnerConfig.SMSProviders {
+			if !providerConfig.Enabled || providerConfig.Type != "custom" {
+				continue
+			}
+			provider, err := sms.NewCustomProvider(providerConfig.Config)
+			if err != nil {
+				w.logger.WithField("tenant_id", partnerConfig.TenantID).WithError(err).Error("Failed to create Nikita provider")
+				break
+			}
+			if provider.PollsDeliveryReports() {
+				providers[partnerConfig.TenantID] = provider
+			}
+			break
+		}
+	}
+
+	return providers, nil
+}
@@ -117,12 +117,18 @@ func (w *NotificationWorker) processMessage(ctx context.Context, msg *message.Me
 		return nil
 	}
 
-	if req.TenantID == 0 || req.Type == "" || len(req.Recipients) == 0 || req.Body == "" {
+	// Cascades are sent on the type of their first step
+	if req.Type == "" && len(req.Cascade) > 0 && req.Cascade[0] != nil {
+		req.Type = req.Cascade[0].Type
+	}
+
+	if req.TenantID == 0 || req.Type == "" || (len(req.Recipients) == 0 && len(req.UserIDs) == 0) || req.Body == "" {
 		w.logger.WithFields(logrus.Fields{
 			"message_id": msg.UUID,
 			"tenant_id":  req.TenantID,
 			"type":       req.Type,
 			"recipients": len(req.Recipients),
+			"user_ids":   len(req.UserIDs),
 		}).Error("Invalid notification request - skipping")
 		return nil
 	}
@@ -0,0 +1,106 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/config"
+	"gitlab.smartbet.am/golang/notification/internal/kafka"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/realtime"
+)
+
+// RealtimeWorker delivers the in-app notifications announced on the realtime topic to the
+// streams connected to this instance. Every instance consumes every event, outside of the
+// consumer group, since any of them may hold a stream of the user.
+type RealtimeWorker struct {
+	cfg        *config.Config
+	hub        *realtime.Hub
+	subscriber *kafka.Subscriber
+	topic      string
+	logger     *logrus.Logger
+	stopChan   chan struct{}
+}
+
+func NewRealtimeWorker(cfg *config.Config, hub *realtime.Hub, logger *logrus.Logger) *RealtimeWorker {
+	return &RealtimeWorker{
+		cfg:      cfg,
+		hub:      hub,
+		topic:    cfg.Kafka.Topics.Realtime,
+		logger:   logger,
+		stopChan: make(chan struct{}),
+	}
+}
+
+func (w *RealtimeWorker) Start(ctx context.Context) error {
+	if w.topic == "" {
+		w.logger.Info("Realtime topic not configured, in-app notifications are only streamed from this instance")
+		return nil
+	}
+
+	subscriber, err := kafka.NewBroadcastSubscriber(w.cfg)
+	if err != nil {
+		return err
+	}
+	w.subscriber = subscriber
+
+	messages, err := w.subscriber.Subscribe(ctx, w.topic)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to realtime topic %s: %w", w.topic, err)
+	}
+
+	go w.processMessages(ctx, messages)
+
+	w.logger.WithField("topic", w.topic).Info("Realtime worker started")
+	return nil
+}
+
+func (w *RealtimeWorker) Stop() {
+	close(w.stopChan)
+	if w.subscriber != nil {
+		if err := w.subscriber.Close(); err != nil {
+			w.logger.WithError(err).Error("Failed to close realtime subscriber")
+		}
+	}
+}
+
+func (w *RealtimeWorker) processMessages(ctx context.Context, messages <-chan *message.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Realtime worker stopping due to context cancellation")
+			return
+		case <-w.stopChan:
+			w.logger.Info("Realtime worker stopping")
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			// Events are only useful while fresh, they are never redelivered
+			w.processMessage(msg)
+			msg.Ack()
+		}
+	}
+}
+
+func (w *RealtimeWorker) processMessage(msg *message.Message) {
+	var event models.InboxEvent
+	if err := json.Unmarshal(msg.Payload, &event); err != nil {
+		w.logger.WithFields(logrus.Fields{
+			"message_id": msg.UUID,
+			"error":      err.Error(),
+		}).Error("Failed to unmarshal inbox event - skipping")
+		return
+	}
+	if event.TenantID == 0 || event.UserID == "" || event.Message == nil {
+		w.logger.WithField("message_id", msg.UUID).Error("Incomplete inbox event - skipping")
+		return
+	}
+
+	w.hub.Deliver(&event)
+}
@@ -0,0 +1,124 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/sirupsen/logrus"
+	"gitlab.smartbet.am/golang/notification/internal/config"
+	"gitlab.smartbet.am/golang/notification/internal/kafka"
+	"gitlab.smartbet.am/golang/notification/internal/models"
+	"gitlab.smartbet.am/golang/notification/internal/repository"
+)
+
+// SelfExclusionWorker syncs player self-exclusions published by the operator to the registry
+type SelfExclusionWorker struct {
+	subscriber        *kafka.Subscriber
+	selfExclusionRepo *repository.SelfExclusionRepository
+	topic             string
+	logger            *logrus.Logger
+	stopChan          chan struct{}
+}
+
+func NewSelfExclusionWorker(
+	cfg *config.Config,
+	subscriber *kafka.Subscriber,
+	selfExclusionRepo *repository.SelfExclusionRepository,
+	logger *logrus.Logger,
+) *SelfExclusionWorker {
+	return &SelfExclusionWorker{
+		subscriber:        subscriber,
+		selfExclusionRepo: selfExclusionRepo,
+		topic:             cfg.Kafka.Topics.SelfExclusions,
+		logger:            logger,
+		stopChan:          make(chan struct{}),
+	}
+}
+
+func (w *SelfExclusionWorker) Start(ctx context.Context) error {
+	if w.topic == "" {
+		w.logger.Info("Self-exclusion topic not configured, Kafka sync disabled")
+		return nil
+	}
+
+	messages, err := w.subscriber.Subscribe(ctx, w.topic)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to self-exclusion topic %s: %w", w.topic, err)
+	}
+
+	go w.processMessages(ctx, messages)
+
+	w.logger.WithField("topic", w.topic).Info("Self-exclusion worker started")
+	return nil
+}
+
+func (w *SelfExclusionWorker) Stop() {
+	close(w.stopChan)
+}
+
+func (w *SelfExclusionWorker) processMessages(ctx context.Context, messages <-chan *message.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Self-exclusion worker stopping due to context cancellation")
+			return
+		case <-w.stopChan:
+			w.logger.Info("Self-exclusion worker stopping")
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			if err := w.processMessage(ctx, msg); err != nil {
+				// The registry must not miss exclusions, failed writes are redelivered
+				w.logger.WithField("message_id", msg.UUID).
+					WithError(err).
+					Error("Failed to sync self-exclusion - will retry")
+				msg.Nack()
+				continue
+			}
+			msg.Ack()
+		}
+	}
+}
+
+func (w *SelfExclusionWorker) processMessage(ctx context.Context, msg *message.Message) error {
+	var event models.SelfExclusionEvent
+	if err := json.Unmarshal(msg.Payload, &event); err != nil {
+		w.logger.WithFields(logrus.Fields{
+			"message_id": msg.UUID,
+			"error":      err.Error(),
+			"payload":    string(msg.Payload),
+		}).Error("Failed to unmarshal self-exclusion - skipping")
+		return nil
+	}
+
+	if event.TenantID == 0 {
+		w.logger.WithField("message_id", msg.UUID).Error("Self-exclusion without tenant - skipping")
+		return nil
+	}
+	if err := event.Validate(); err != nil {
+		w.logger.WithFields(logrus.Fields{
+			"message_id": msg.UUID,
+			"tenant_id":  event.TenantID,
+			"user_id":    event.UserID,
+		}).WithError(err).Error("Invalid self-exclusion - skipping")
+		return nil
+	}
+
+	entries, err := w.selfExclusionRepo.Sync(ctx, event.TenantID, &event.SelfExclusionRequest, "kafka")
+	if err != nil {
+		return err
+	}
+
+	w.logger.WithFields(logrus.Fields{
+		"tenant_id": event.TenantID,
+		"user_id":   event.UserID,
+		"addresses": len(entries),
+		"source":    "kafka",
+	}).Info("Self-exclusion synced")
+	return nil
+}